@@ -0,0 +1,113 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Affected returns the paths of the subtrees of the document that applying the patch would change,
+// without applying it, such as for locking or invalidating the caches of only these subtrees before the patch
+// is applied. The paths are sorted, and no path is in the subtree of another one.
+// The paths are computed for the default options, conservatively: an array is affected as a whole by
+// the insertions and the removals of its elements, and by the indices that are resolved when the patch is
+// applied ("-" and the negative indices). The "test" operations and the JSON Predicate operations don't
+// change the document, and the from paths of the "copy" operations are only read.
+func (p Patch) Affected(doc []byte) ([]string, error) {
+	pd, err := NewNode(doc).intoContainer()
+	if pd == nil {
+		if err == nil {
+			err = ErrInvalid
+		}
+		return nil, fmt.Errorf("unexpected document, %w", err)
+	}
+
+	options := NewOptions()
+	paths := make([]string, 0, len(p))
+	for i, op := range p {
+		if op.Op == "test" || predicateOperations[op.Op] {
+			continue
+		}
+		targets := []string{op.Path}
+		if op.Op == "move" {
+			targets = append(targets, op.From)
+		}
+		for _, path := range targets {
+			if path != "" && path[0] != '/' {
+				return nil, &PathError{Op: op.Op, Path: op.Path, Index: i,
+					Err: fmt.Errorf("invalid JSON pointer %q, %w", path, ErrInvalid)}
+			}
+			paths = append(paths, affectedPath(pd, op.Op, path, options))
+		}
+	}
+	return outermostPaths(paths), nil
+}
+
+// affectedPath returns the path of the subtree changed by writing to the path with the operation.
+func affectedPath(pd container, op, path string, options *Options) string {
+	if path == "" {
+		return ""
+	}
+
+	tokens := strings.Split(path[1:], "/")
+	last := len(tokens) - 1
+	for i, token := range tokens[:last] {
+		if token == "-" || isNegativeIndex(token) {
+			return joinTokens(tokens[:i])
+		}
+	}
+	if !isArrayIndex(tokens[last]) && !isNegativeIndex(tokens[last]) {
+		return path
+	}
+
+	con, _ := findObject(&pd, path, options)
+	if _, ok := con.(*partialArray); con != nil && !ok {
+		// the numeric key of an object.
+		return path
+	}
+	if con != nil && op == "replace" && tokens[last][0] != '-' {
+		return path
+	}
+	return joinTokens(tokens[:last])
+}
+
+func isNegativeIndex(token string) bool {
+	return len(token) > 1 && token[0] == '-' && isDigits(token[1:])
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func joinTokens(tokens []string) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+	return "/" + strings.Join(tokens, "/")
+}
+
+// outermostPaths returns the sorted unique paths that are not in the subtrees of the other paths.
+func outermostPaths(paths []string) []string {
+	sort.Strings(paths)
+	kept := make(map[string]bool, len(paths))
+	res := make([]string, 0, len(paths))
+	for _, path := range paths {
+		covered := kept[""] || kept[path]
+		for i := 1; i < len(path) && !covered; i++ {
+			covered = path[i] == '/' && kept[path[:i]]
+		}
+		if !covered {
+			kept[path] = true
+			res = append(res, path)
+		}
+	}
+	return res
+}
@@ -0,0 +1,54 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAffected(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"a":{"b":1,"c":[1,2],"1":true},"l":[{"x":1},{"x":2}],"m":{"n":1},"a~1b":{},"s":"x"}`)
+	for i, c := range []struct {
+		patch string
+		paths []string
+	}{
+		{`[]`, []string{}},
+		{`[{"op": "test", "path": "/a", "value": 1}, {"op": "copy", "from": "/a", "path": "/z"}]`, []string{"/z"}},
+		{`[{"op": "replace", "path": "/a/b", "value": 2}, {"op": "add", "path": "/a/d", "value": 2}]`,
+			[]string{"/a/b", "/a/d"}},
+		{`[{"op": "replace", "path": "/a/b", "value": 2}, {"op": "remove", "path": "/a"}]`, []string{"/a"}},
+		{`[{"op": "replace", "path": "/l/1/x", "value": 3}]`, []string{"/l/1/x"}},
+		{`[{"op": "replace", "path": "/l/1", "value": 3}]`, []string{"/l/1"}},
+		{`[{"op": "replace", "path": "/l/-1", "value": 3}]`, []string{"/l"}},
+		{`[{"op": "replace", "path": "/l/-/x", "value": 3}]`, []string{"/l"}},
+		{`[{"op": "add", "path": "/l/0", "value": 3}, {"op": "add", "path": "/a/c/-", "value": 3}]`,
+			[]string{"/a/c", "/l"}},
+		{`[{"op": "remove", "path": "/a/1"}]`, []string{"/a/1"}},
+		{`[{"op": "move", "from": "/l/0/x", "path": "/m/x"}]`, []string{"/l/0/x", "/m/x"}},
+		{`[{"op": "move", "from": "/l/0", "path": "/m/x"}]`, []string{"/l", "/m/x"}},
+		{`[{"op": "add", "path": "/new", "value": []}, {"op": "add", "path": "/new/0", "value": 1},
+			{"op": "replace", "path": "/new/0", "value": 2}]`, []string{"/new"}},
+		{`[{"op": "add", "path": "/m/0", "value": 1}]`, []string{"/m/0"}},
+		{`[{"op": "add", "path": "/a~1b/c", "value": 1}, {"op": "add", "path": "/a!", "value": 1},
+			{"op": "add", "path": "/a/b/c", "value": 1}]`, []string{"/a!", "/a/b/c", "/a~1b/c"}},
+		{`[{"op": "add", "path": "/a/b", "value": 1}, {"op": "replace", "path": "", "value": {}}]`, []string{""}},
+		{`[{"op": "contains", "path": "/s", "value": "x"}, {"op": "inc", "path": "/a/b", "value": 1}]`,
+			[]string{"/a/b"}},
+	} {
+		p, err := NewPatch([]byte(c.patch))
+		assert.Nil(err, "case %d", i)
+		paths, err := p.Affected(doc)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.paths, paths, "case %d", i)
+	}
+
+	_, err := Patch{{Op: "add", Path: "a", Value: []byte(`1`)}}.Affected(doc)
+	assert.ErrorIs(err, ErrInvalid)
+	_, err = Patch{}.Affected([]byte(`1`))
+	assert.ErrorIs(err, ErrInvalid)
+}
@@ -0,0 +1,72 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AnnotatedPatch is a patch with the metadata of its provenance, such as to store the patches in a log.
+// It is encoded as a JSON object with the patch in the "patch" member:
+//
+//	{"author": "alice", "time": "2022-01-02T15:04:05Z", "seq": 3, "comment": "fix typo", "patch": [...]}
+type AnnotatedPatch struct {
+	Author   string    `json:"author,omitempty"`
+	Time     time.Time `json:"time"`
+	Sequence uint64    `json:"seq"`
+	Comment  string    `json:"comment,omitempty"`
+	Patch    Patch     `json:"patch"`
+}
+
+// Annotate returns the AnnotatedPatch of the patch, stamped with the time of the Clock in options.
+func (p Patch) Annotate(author string, seq uint64, comment string, options *Options) *AnnotatedPatch {
+	return &AnnotatedPatch{Author: author, Time: options.Now(), Sequence: seq, Comment: comment, Patch: p}
+}
+
+// NewAnnotatedPatch decodes the passed JSON document as an AnnotatedPatch.
+// A plain RFC 6902 patch is decoded as an AnnotatedPatch without metadata.
+func NewAnnotatedPatch(doc []byte) (*AnnotatedPatch, error) {
+	doc = bytes.TrimSpace(doc)
+	if len(doc) > 0 && doc[0] == '[' {
+		p, err := NewPatch(doc)
+		if err != nil {
+			return nil, err
+		}
+		return &AnnotatedPatch{Patch: p}, nil
+	}
+
+	a := &AnnotatedPatch{}
+	if err := json.Unmarshal(doc, a); err != nil {
+		return nil, err
+	}
+	if a.Patch == nil {
+		return nil, fmt.Errorf("unable to decode annotated patch without patch member, %w", ErrMissing)
+	}
+	return a, nil
+}
+
+// Strip returns the plain RFC 6902 patch of the AnnotatedPatch.
+func (a *AnnotatedPatch) Strip() Patch {
+	return a.Patch
+}
+
+// StripAnnotations returns the JSON encoding of the plain RFC 6902 patch of an annotated patch document.
+// A plain RFC 6902 patch document is returned as is.
+func StripAnnotations(doc []byte) ([]byte, error) {
+	if d := bytes.TrimSpace(doc); len(d) > 0 && d[0] == '[' {
+		if _, err := NewPatch(d); err != nil {
+			return nil, err
+		}
+		return doc, nil
+	}
+
+	a, err := NewAnnotatedPatch(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(a.Strip())
+}
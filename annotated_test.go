@@ -0,0 +1,61 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotatedPatch(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := NewPatch([]byte(`[{"op": "add", "path": "/a", "value": 1}]`))
+	assert.Nil(err)
+
+	options := NewOptions()
+	options.Clock = &testClock{t: time.Date(2022, 1, 2, 15, 4, 4, 0, time.UTC)}
+	a := p.Annotate("alice", 3, "fix typo", options)
+	data, err := json.Marshal(a)
+	assert.Nil(err)
+	assert.Equal(`{"author":"alice","time":"2022-01-02T15:04:05Z","seq":3,"comment":"fix typo","patch":[{"op":"add","path":"/a","value":1}]}`,
+		string(data))
+
+	a2, err := NewAnnotatedPatch(data)
+	assert.Nil(err)
+	assert.Equal("alice", a2.Author)
+	assert.True(a.Time.Equal(a2.Time))
+	assert.Equal(uint64(3), a2.Sequence)
+	assert.Equal("fix typo", a2.Comment)
+	res, err := a2.Strip().Apply([]byte(`{}`))
+	assert.Nil(err)
+	assert.Equal(`{"a":1}`, string(res))
+
+	a2, err = NewAnnotatedPatch([]byte(` [{"op": "remove", "path": "/a"}]`))
+	assert.Nil(err)
+	assert.Equal("", a2.Author)
+	assert.Equal(uint64(0), a2.Sequence)
+	assert.Equal(1, len(a2.Patch))
+
+	_, err = NewAnnotatedPatch([]byte(`{"author": "alice"}`))
+	assert.ErrorIs(err, ErrMissing)
+	_, err = NewAnnotatedPatch([]byte(`{"patch": {}}`))
+	assert.NotNil(err)
+
+	data, err = StripAnnotations(data)
+	assert.Nil(err)
+	assert.Equal(`[{"op":"add","path":"/a","value":1}]`, string(data))
+
+	data, err = StripAnnotations([]byte(`[{"op": "remove", "path": "/a"}]`))
+	assert.Nil(err)
+	assert.Equal(`[{"op": "remove", "path": "/a"}]`, string(data))
+
+	_, err = StripAnnotations([]byte(`[1]`))
+	assert.NotNil(err)
+	_, err = StripAnnotations([]byte(`{"seq": 1}`))
+	assert.ErrorIs(err, ErrMissing)
+}
@@ -0,0 +1,70 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"runtime"
+	"sync"
+)
+
+// ApplyAll applies the patch to each of the documents, such as rolling one change out to many stored
+// documents. It returns the patched documents and the errors in the order of the documents,
+// the patched document is nil if the error of the document is not nil.
+// The patch is compiled with the options once, see Patch.Compile, then the documents are patched
+// concurrently by up to Options.ApplyParallelism workers, so Options.OnChange and Options.OnWarning
+// may be called concurrently.
+func ApplyAll(docs [][]byte, patch Patch, options *Options) ([][]byte, []error) {
+	results := make([][]byte, len(docs))
+	errs := make([]error, len(docs))
+	if len(docs) == 0 {
+		return results, errs
+	}
+
+	c, err := patch.Compile(options)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return results, errs
+	}
+
+	workers := c.options.ApplyParallelism
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	parallel(len(docs), workers, func(i int) {
+		results[i], errs[i] = c.Apply(docs[i])
+	})
+	return results, errs
+}
+
+// parallel calls fn with 0 to n-1 by the number of workers, sequentially if workers is less than 2.
+func parallel(n, workers int, fn func(i int)) {
+	if workers < 2 || n < 2 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+
+	if workers > n {
+		workers = n
+	}
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
@@ -0,0 +1,63 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyAll(t *testing.T) {
+	assert := assert.New(t)
+
+	patch := Patch{
+		{Op: "test", Path: "/kind", Value: []byte(`"config"`)},
+		{Op: "replace", Path: "/version", Value: []byte(`2`)},
+	}
+
+	var docs [][]byte
+	for i := 0; i < 100; i++ {
+		kind := "config"
+		if i%10 == 0 {
+			kind = "other"
+		}
+		docs = append(docs, []byte(fmt.Sprintf(`{"id":%d,"kind":%q,"version":1}`, i, kind)))
+	}
+
+	for _, workers := range []int{0, 1, 4} {
+		options := NewOptions()
+		options.ApplyParallelism = workers
+		results, errs := ApplyAll(docs, patch, options)
+		assert.Len(results, len(docs))
+		assert.Len(errs, len(docs))
+		for i := range docs {
+			if i%10 == 0 {
+				assert.ErrorIs(errs[i], ErrTestFailed, "case %d", i)
+				assert.Nil(results[i], "case %d", i)
+				continue
+			}
+			assert.NoError(errs[i], "case %d", i)
+			assert.Equal(fmt.Sprintf(`{"id":%d,"kind":"config","version":2}`, i), string(results[i]), "case %d", i)
+		}
+	}
+
+	results, errs := ApplyAll(nil, patch, nil)
+	assert.Len(results, 0)
+	assert.Len(errs, 0)
+
+	// the limits of the patch are checked once for all the documents.
+	options := NewOptions()
+	options.MaxPatchOps = 1
+	results, errs = ApplyAll(docs[:3], patch, options)
+	for i := range results {
+		assert.Nil(results[i], "case %d", i)
+		assert.ErrorIs(errs[i], ErrLimitExceeded, "case %d", i)
+	}
+
+	_, errs = ApplyAll([][]byte{[]byte(`{"a":1}`), []byte(`[1`)}, Patch{{Op: "remove", Path: "/a"}}, nil)
+	assert.NoError(errs[0])
+	assert.Error(errs[1])
+}
@@ -0,0 +1,196 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path is a JSON Pointer represented by its unescaped reference tokens.
+// A nil or empty Path references the whole document.
+type Path []string
+
+// ParsePath parses a JSON Pointer into a Path.
+func ParsePath(pointer string) (Path, error) {
+	if pointer == "" {
+		return Path{}, nil
+	}
+
+	parts := strings.Split(pointer, "/")
+	if parts[0] != "" {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	p := make(Path, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		p = append(p, decodePatchKey(part))
+	}
+	return p, nil
+}
+
+// String returns the JSON Pointer of the path, with the reference tokens escaped.
+func (p Path) String() string {
+	var b strings.Builder
+	for _, token := range p {
+		b.WriteString("/")
+		b.WriteString(encodePatchKey(token))
+	}
+	return b.String()
+}
+
+// PatchBuilder builds a Patch with chainable methods.
+// The values of the *Value methods are native Go values encoded to JSON once when they are appended,
+// the first encoding error is returned by Err and MarshalJSON, and the failed operation is not appended.
+type PatchBuilder struct {
+	patch Patch
+	err   error
+}
+
+// NewPatchBuilder returns a new PatchBuilder.
+func NewPatchBuilder() *PatchBuilder {
+	return &PatchBuilder{patch: make(Patch, 0)}
+}
+
+// Add appends an "add" operation.
+func (b *PatchBuilder) Add(path Path, value json.RawMessage) *PatchBuilder {
+	b.patch = append(b.patch, NewAddOperation(path.String(), value))
+	return b
+}
+
+// Remove appends a "remove" operation.
+func (b *PatchBuilder) Remove(path Path) *PatchBuilder {
+	b.patch = append(b.patch, NewRemoveOperation(path.String()))
+	return b
+}
+
+// Replace appends a "replace" operation.
+func (b *PatchBuilder) Replace(path Path, value json.RawMessage) *PatchBuilder {
+	b.patch = append(b.patch, NewReplaceOperation(path.String(), value))
+	return b
+}
+
+// Move appends a "move" operation.
+func (b *PatchBuilder) Move(from, path Path) *PatchBuilder {
+	b.patch = append(b.patch, NewMoveOperation(from.String(), path.String()))
+	return b
+}
+
+// Copy appends a "copy" operation.
+func (b *PatchBuilder) Copy(from, path Path) *PatchBuilder {
+	b.patch = append(b.patch, NewCopyOperation(from.String(), path.String()))
+	return b
+}
+
+// Test appends a "test" operation.
+func (b *PatchBuilder) Test(path Path, value json.RawMessage) *PatchBuilder {
+	b.patch = append(b.patch, NewTestOperation(path.String(), value))
+	return b
+}
+
+// AddValue appends an "add" operation with the value encoded from a native Go value.
+func (b *PatchBuilder) AddValue(path Path, value interface{}) *PatchBuilder {
+	if data, ok := b.encode("add", path, value); ok {
+		b.Add(path, data)
+	}
+	return b
+}
+
+// ReplaceValue appends a "replace" operation with the value encoded from a native Go value.
+func (b *PatchBuilder) ReplaceValue(path Path, value interface{}) *PatchBuilder {
+	if data, ok := b.encode("replace", path, value); ok {
+		b.Replace(path, data)
+	}
+	return b
+}
+
+// TestValue appends a "test" operation with the value encoded from a native Go value.
+func (b *PatchBuilder) TestValue(path Path, value interface{}) *PatchBuilder {
+	if data, ok := b.encode("test", path, value); ok {
+		b.Test(path, data)
+	}
+	return b
+}
+
+// AddString appends an "add" operation with a string value.
+func (b *PatchBuilder) AddString(path Path, value string) *PatchBuilder {
+	return b.Add(path, encodeString(value))
+}
+
+// AddInt appends an "add" operation with an integer value.
+func (b *PatchBuilder) AddInt(path Path, value int64) *PatchBuilder {
+	return b.Add(path, strconv.AppendInt(nil, value, 10))
+}
+
+// AddBool appends an "add" operation with a boolean value.
+func (b *PatchBuilder) AddBool(path Path, value bool) *PatchBuilder {
+	return b.Add(path, strconv.AppendBool(nil, value))
+}
+
+// ReplaceString appends a "replace" operation with a string value.
+func (b *PatchBuilder) ReplaceString(path Path, value string) *PatchBuilder {
+	return b.Replace(path, encodeString(value))
+}
+
+// ReplaceInt appends a "replace" operation with an integer value.
+func (b *PatchBuilder) ReplaceInt(path Path, value int64) *PatchBuilder {
+	return b.Replace(path, strconv.AppendInt(nil, value, 10))
+}
+
+// ReplaceBool appends a "replace" operation with a boolean value.
+func (b *PatchBuilder) ReplaceBool(path Path, value bool) *PatchBuilder {
+	return b.Replace(path, strconv.AppendBool(nil, value))
+}
+
+// TestString appends a "test" operation with a string value.
+func (b *PatchBuilder) TestString(path Path, value string) *PatchBuilder {
+	return b.Test(path, encodeString(value))
+}
+
+// TestInt appends a "test" operation with an integer value.
+func (b *PatchBuilder) TestInt(path Path, value int64) *PatchBuilder {
+	return b.Test(path, strconv.AppendInt(nil, value, 10))
+}
+
+// TestBool appends a "test" operation with a boolean value.
+func (b *PatchBuilder) TestBool(path Path, value bool) *PatchBuilder {
+	return b.Test(path, strconv.AppendBool(nil, value))
+}
+
+// Err returns the first error of encoding the values of the *Value methods.
+func (b *PatchBuilder) Err() error {
+	return b.err
+}
+
+func (b *PatchBuilder) encode(op string, path Path, value interface{}) (json.RawMessage, bool) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		if b.err == nil {
+			b.err = fmt.Errorf("unable to encode the value of %s operation at %q, %w", op, path.String(), err)
+		}
+		return nil, false
+	}
+	return data, true
+}
+
+func encodeString(s string) json.RawMessage {
+	data, _ := json.Marshal(s)
+	return data
+}
+
+// Build returns the built patch. The builder can be used to build more operations after it.
+func (b *PatchBuilder) Build() Patch {
+	p := make(Patch, len(b.patch))
+	copy(p, b.patch)
+	return p
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (b *PatchBuilder) MarshalJSON() ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return json.Marshal(b.patch)
+}
@@ -0,0 +1,125 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPath(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("", Path(nil).String())
+	assert.Equal("", Path{}.String())
+	assert.Equal("/a/b~1c/d~0e/0/-", Path{"a", "b/c", "d~e", "0", "-"}.String())
+	assert.Equal("/", Path{""}.String())
+
+	p, err := ParsePath("/a/b~1c/d~0e/0/-")
+	assert.Nil(err)
+	assert.Equal(Path{"a", "b/c", "d~e", "0", "-"}, p)
+
+	p, err = ParsePath("")
+	assert.Nil(err)
+	assert.Equal(Path{}, p)
+
+	p, err = ParsePath("/")
+	assert.Nil(err)
+	assert.Equal(Path{""}, p)
+
+	_, err = ParsePath("a/b")
+	assert.NotNil(err)
+}
+
+func TestPatchBuilder(t *testing.T) {
+	assert := assert.New(t)
+
+	b := NewPatchBuilder().
+		Test(Path{"name"}, []byte(`"John"`)).
+		Replace(Path{"name"}, []byte(`"Jane"`)).
+		Add(Path{"labels", "app/name"}, []byte(`"web"`)).
+		Copy(Path{"labels", "app/name"}, Path{"app"}).
+		Move(Path{"age"}, Path{"meta", "age"}).
+		Remove(Path{"height"})
+
+	data, err := b.MarshalJSON()
+	assert.Nil(err)
+	assert.Equal(`[{"op":"test","path":"/name","value":"John"},`+
+		`{"op":"replace","path":"/name","value":"Jane"},`+
+		`{"op":"add","path":"/labels/app~1name","value":"web"},`+
+		`{"op":"copy","path":"/app","from":"/labels/app~1name"},`+
+		`{"op":"move","path":"/meta/age","from":"/age"},`+
+		`{"op":"remove","path":"/height"}]`, string(data))
+
+	p := b.Build()
+	assert.Equal(6, len(p))
+	res, err := p.Apply([]byte(`{"name":"John","age":24,"height":3.21,"labels":{},"meta":{}}`))
+	assert.Nil(err)
+	assert.Equal(`{"name":"Jane","labels":{"app/name":"web"},"meta":{"age":24},"app":"web"}`, string(res))
+
+	b.Remove(Path{"app"})
+	assert.Equal(6, len(p))
+	assert.Equal(7, len(b.Build()))
+
+	assert.Equal(`[]`, mustJSONString(NewPatchBuilder()))
+}
+
+func TestPatchBuilderValues(t *testing.T) {
+	assert := assert.New(t)
+
+	type meta struct {
+		Age  int      `json:"age"`
+		Tags []string `json:"tags"`
+	}
+	b := NewPatchBuilder().
+		TestString(Path{"name"}, "John").
+		TestInt(Path{"age"}, 24).
+		TestBool(Path{"active"}, false).
+		TestValue(Path{"labels"}, map[string]string{}).
+		ReplaceString(Path{"name"}, `Jane "J"`).
+		ReplaceInt(Path{"age"}, -25).
+		ReplaceBool(Path{"active"}, true).
+		ReplaceValue(Path{"labels"}, map[string]interface{}{"app": "web", "ports": []int{80, 443}}).
+		AddString(Path{"nick"}, "jj").
+		AddInt(Path{"rank"}, 1).
+		AddBool(Path{"admin"}, false).
+		AddValue(Path{"meta"}, meta{26, []string{"a"}}).
+		AddValue(Path{"extra"}, json.RawMessage(`{"x": null}`)).
+		AddValue(Path{"none"}, nil)
+	assert.Nil(b.Err())
+
+	data, err := b.MarshalJSON()
+	assert.Nil(err)
+	assert.Equal(`[{"op":"test","path":"/name","value":"John"},`+
+		`{"op":"test","path":"/age","value":24},`+
+		`{"op":"test","path":"/active","value":false},`+
+		`{"op":"test","path":"/labels","value":{}},`+
+		`{"op":"replace","path":"/name","value":"Jane \"J\""},`+
+		`{"op":"replace","path":"/age","value":-25},`+
+		`{"op":"replace","path":"/active","value":true},`+
+		`{"op":"replace","path":"/labels","value":{"app":"web","ports":[80,443]}},`+
+		`{"op":"add","path":"/nick","value":"jj"},`+
+		`{"op":"add","path":"/rank","value":1},`+
+		`{"op":"add","path":"/admin","value":false},`+
+		`{"op":"add","path":"/meta","value":{"age":26,"tags":["a"]}},`+
+		`{"op":"add","path":"/extra","value":{"x":null}},`+
+		`{"op":"add","path":"/none","value":null}]`, string(data))
+
+	res, err := b.Build().Apply([]byte(`{"name":"John","age":24,"active":false,"labels":{}}`))
+	assert.Nil(err)
+	assert.Equal(`{"name":"Jane \"J\"","age":-25,"active":true,"labels":{"app":"web","ports":[80,443]},`+
+		`"nick":"jj","rank":1,"admin":false,"meta":{"age":26,"tags":["a"]},"extra":{"x":null},"none":null}`, string(res))
+
+	b = NewPatchBuilder().
+		AddInt(Path{"a"}, 1).
+		AddValue(Path{"b"}, make(chan int)).
+		ReplaceValue(Path{"c"}, func() {}).
+		AddBool(Path{"d"}, true)
+	assert.ErrorContains(b.Err(), `unable to encode the value of add operation at "/b"`)
+	_, err = b.MarshalJSON()
+	assert.Equal(b.Err(), err)
+	assert.Equal(2, len(b.Build()))
+}
@@ -0,0 +1,167 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// MarshalCanonical returns the canonical JSON of the node defined by RFC 8785 (JSON Canonicalization Scheme):
+// no insignificant whitespace, object keys sorted by their UTF-16 code units, numbers serialized as
+// ECMAScript numbers and strings with the minimal escapes. The output does not depend on the layout
+// of the input, so it can be hashed and signed.
+func (n *Node) MarshalCanonical() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := n.writeCanonical(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (n *Node) writeCanonical(buf *bytes.Buffer) error {
+	if n == nil || n.which != eDoc && n.which != eAry && n.raw == nil {
+		buf.WriteString("null")
+		return nil
+	}
+
+	if _, err := n.intoContainer(); err != nil && err != ErrInvalid {
+		return err
+	}
+
+	switch n.which {
+	case eDoc:
+		keys := make([]string, 0, len(n.doc.obj))
+		for k := range n.doc.obj {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUTF16(keys[i], keys[j])
+		})
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, k)
+			buf.WriteByte(':')
+			if err := n.doc.obj[k].writeCanonical(buf); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+
+	case eAry:
+		buf.WriteByte('[')
+		for i, v := range n.ary {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := v.writeCanonical(buf); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+
+	raw := bytes.TrimSpace(*n.raw)
+	if !json.Valid(raw) {
+		return fmt.Errorf("invalid JSON value %q", raw)
+	}
+	switch raw[0] {
+	case '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return err
+		}
+		writeCanonicalString(buf, s)
+	case 't', 'f', 'n':
+		buf.Write(raw)
+	default:
+		f, err := strconv.ParseFloat(string(raw), 64)
+		if err != nil {
+			return fmt.Errorf("unable to canonicalize number %s, %w", raw, err)
+		}
+		buf.WriteString(canonicalNumber(f))
+	}
+	return nil
+}
+
+// canonicalNumber serializes the number as ECMAScript Number.prototype.toString.
+func canonicalNumber(f float64) string {
+	if f == 0 {
+		return "0"
+	}
+	if abs := math.Abs(f); abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	// ECMAScript has no leading zeros in the exponent.
+	i := strings.IndexByte(s, 'e')
+	exp := strings.TrimLeft(s[i+2:], "0")
+	return s[:i+2] + exp
+}
+
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+
+	buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			buf.WriteRune(r)
+			i += size
+			continue
+		}
+
+		switch c {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[c>>4])
+				buf.WriteByte(hex[c&0xf])
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+		i++
+	}
+	buf.WriteByte('"')
+}
+
+// lessUTF16 compares the strings by their UTF-16 code units.
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
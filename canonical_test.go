@@ -0,0 +1,52 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalCanonical(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		doc, canonical string
+	}{
+		{`null`, `null`},
+		{` true `, `true`},
+		{`{ "b" : 2, "a" : [ 1 , { "d":null, "c":false } ] }`, `{"a":[1,{"c":false,"d":null}],"b":2}`},
+		// RFC 8785, 3.2.2
+		{`{"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+		   "string": "€$\u000F\u000aA'B\"\\\\\"\/",
+		   "literals": [null, true, false]}`,
+			`{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],` +
+				`"string":"€$\u000f\nA'B\"\\\\\"/"}`},
+		{`[-0, 0.0, 1.0, 100, 1e21, 1e-7, 5e-324, -1.5e-10]`,
+			`[0,0,1,100,1e+21,1e-7,5e-324,-1.5e-10]`},
+		// RFC 8785, 3.2.3
+		{`{"€": "Euro Sign", "\r": "Carriage Return", "דּ": "Hebrew Letter Dalet With Dagesh",
+		   "1": "One", "😀": "Emoji: Grinning Face", "\u0080": "Control", "ö": "Latin Small Letter O With Diaeresis"}`,
+			`{"\r":"Carriage Return","1":"One","` + "\u0080" + `":"Control","ö":"Latin Small Letter O With Diaeresis",` +
+				`"€":"Euro Sign","😀":"Emoji: Grinning Face","` + "\ufb33" + `":"Hebrew Letter Dalet With Dagesh"}`},
+		{`"<a & b>"`, `"<a & b>"`},
+	}
+	for i, c := range cases {
+		data, err := NewNode([]byte(c.doc)).MarshalCanonical()
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.canonical, string(data), "case %d", i)
+	}
+
+	node := NewNode([]byte(`{"b":1,"a":{}}`))
+	assert.Nil(node.Patch(Patch{NewAddOperation("/a/z", []byte(`1.50`)), NewAddOperation("/a/y", []byte(`"x"`))}, nil))
+	data, err := node.MarshalCanonical()
+	assert.Nil(err)
+	assert.Equal(`{"a":{"y":"x","z":1.5},"b":1}`, string(data))
+
+	_, err = NewNode([]byte(`{"a":tru}`)).MarshalCanonical()
+	assert.NotNil(err)
+	_, err = NewNode([]byte(`[1,`)).MarshalCanonical()
+	assert.NotNil(err)
+}
@@ -0,0 +1,92 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Capability describes the patch features supported by this build with the given options,
+// it can be serialized to JSON and advertised to clients.
+type Capability struct {
+	// Dialects lists the supported patch formats, such as "json-patch" for RFC 6902.
+	Dialects []string `json:"dialects"`
+	// MediaTypes lists the media types of the supported patch formats.
+	MediaTypes []string `json:"mediaTypes"`
+	// Operations lists the supported RFC 6902 operations.
+	Operations []string `json:"operations"`
+	// Extensions lists the extension operations enabled in the options,
+	// including the JSON Predicate operations and the custom operations.
+	Extensions []string `json:"extensions"`
+	// Options lists the options and their values, keyed by the lower camel case name of the field in Options.
+	Options map[string]interface{} `json:"options"`
+}
+
+// Capabilities returns the Capability with the given options.
+// nil options means the default options returned by NewOptions.
+func Capabilities(options *Options) Capability {
+	if options == nil {
+		options = NewOptions()
+	}
+
+	c := Capability{
+		Dialects:   []string{"json-patch"},
+		MediaTypes: []string{"application/json-patch+json"},
+		Operations: []string{
+			string(OpAdd), string(OpRemove), string(OpReplace),
+			string(OpMove), string(OpCopy), string(OpTest),
+		},
+		Extensions: make([]string, 0, len(options.operations)),
+		Options:    make(map[string]interface{}),
+	}
+	for name := range options.operations {
+		if !options.EnablePredicates || !predicateOperations[name] {
+			c.Extensions = append(c.Extensions, name)
+		}
+	}
+	if options.EnablePredicates {
+		for name := range predicateOperations {
+			c.Extensions = append(c.Extensions, name)
+		}
+	}
+	if _, ok := options.operations["inc"]; options.EnableIncrement && !ok {
+		c.Extensions = append(c.Extensions, "inc")
+	}
+	if _, ok := options.operations["splice"]; options.EnableSplice && !ok {
+		c.Extensions = append(c.Extensions, "splice")
+	}
+	if options.EnableStringOps {
+		for name := range stringOperations {
+			if _, ok := options.operations[name]; !ok {
+				c.Extensions = append(c.Extensions, name)
+			}
+		}
+	}
+	sort.Strings(c.Extensions)
+
+	v := reflect.ValueOf(options).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		switch f.Type.Kind() {
+		case reflect.Bool, reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			c.Options[lowerCamel(f.Name)] = v.Field(i).Interface()
+		}
+	}
+	return c
+}
+
+func lowerCamel(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,31 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilities(t *testing.T) {
+	assert := assert.New(t)
+
+	c := Capabilities(nil)
+	assert.Equal([]string{"json-patch"}, c.Dialects)
+	assert.Equal([]string{"add", "remove", "replace", "move", "copy", "test"}, c.Operations)
+	assert.Equal(true, c.Options["supportNegativeIndices"])
+	assert.Equal(false, c.Options["allowMissingPathOnRemove"])
+	assert.Equal(int64(0), c.Options["accumulatedCopySizeLimit"])
+
+	options := NewOptions()
+	options.EnsurePathExistsOnAdd = true
+	c = Capabilities(options)
+	assert.Equal(true, c.Options["ensurePathExistsOnAdd"])
+
+	data := mustJSONString(c)
+	assert.Contains(data, `"dialects":["json-patch"]`)
+	assert.Contains(data, `"mediaTypes":["application/json-patch+json"]`)
+	assert.Contains(data, `"ensurePathExistsOnAdd":true`)
+}
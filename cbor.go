@@ -0,0 +1,605 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidCBOR is returned for malformed CBOR documents and for the CBOR items without a JSON mapping.
+var ErrInvalidCBOR = errors.New("invalid CBOR data")
+
+// cborMaxDepth limits the nesting depth of the CBOR documents to decode.
+const cborMaxDepth = 1000
+
+// The members of the objects that represent the CBOR items without a JSON counterpart.
+// A byte string is {"$bytes": "<base64url without padding>"}, a tagged item is {"$tag": <tag>, "$value": <item>},
+// and a non-finite float is {"$float": "NaN" | "Infinity" | "-Infinity"}.
+const (
+	cborBytesKey = "$bytes"
+	cborTagKey   = "$tag"
+	cborValueKey = "$value"
+	cborFloatKey = "$float"
+)
+
+// NewNodeCBOR decodes a CBOR document (RFC 8949) into a node, so it can be patched and queried as JSON.
+//
+// Integers of any size, including the bignums (tags 2 and 3), are kept exactly as JSON numbers, floats are
+// JSON numbers with a fraction or an exponent, and map keys must be text strings. Byte strings, tags and
+// non-finite floats are represented by the objects of the members "$bytes", "$tag" and "$value", and "$float".
+// The undefined value is decoded as null.
+func NewNodeCBOR(doc []byte) (*Node, error) {
+	d := &cborDecoder{data: doc}
+	var buf bytes.Buffer
+	if err := d.decode(&buf, 0); err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, d.errorf("unexpected data after the document")
+	}
+	return NewNode(buf.Bytes()), nil
+}
+
+// MarshalCBOR encodes the node as a CBOR document, it reverses the mapping of NewNodeCBOR.
+// Integers are encoded as CBOR integers (bignums beyond 64 bits), other numbers as floats in the shortest
+// width that keeps their values, and map keys in the order of the node.
+func (n *Node) MarshalCBOR() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := n.encodeCBOR(&buf, 0); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ApplyCBOR mutates a CBOR document according to the patch and the passed in Options,
+// and returns the new CBOR document. See NewNodeCBOR for the JSON mapping the patch applies to.
+func (p Patch) ApplyCBOR(doc []byte, options *Options) ([]byte, error) {
+	node, err := NewNodeCBOR(doc)
+	if err != nil {
+		return nil, err
+	}
+	if err = node.Patch(p, options); err != nil {
+		return nil, err
+	}
+	return node.MarshalCBOR()
+}
+
+// DecodePatchCBOR decodes a CBOR encoded RFC 6902 patch, an array of maps with the members of the operations.
+// The values of the operations are mapped to JSON as NewNodeCBOR, so the patch has the same semantics
+// as its JSON encoding.
+func DecodePatchCBOR(doc []byte) (Patch, error) {
+	node, err := NewNodeCBOR(doc)
+	if err != nil {
+		return nil, err
+	}
+	data, err := node.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return NewPatch(data)
+}
+
+// MarshalCBOR encodes the patch as a CBOR document, it reverses DecodePatchCBOR.
+func (p Patch) MarshalCBOR() ([]byte, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return NewNode(data).MarshalCBOR()
+}
+
+// GetValueByPathCBOR returns the CBOR encoded value of a given path in a CBOR document.
+func GetValueByPathCBOR(doc []byte, path string) ([]byte, error) {
+	node, err := NewNodeCBOR(doc)
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		if node, err = node.GetChild(path, nil); err != nil {
+			return nil, err
+		}
+	}
+	return node.MarshalCBOR()
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *cborDecoder) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("%s at offset %d, %w", fmt.Sprintf(format, a...), d.pos, ErrInvalidCBOR)
+}
+
+// head reads the initial byte and the argument of an item, indefinite is true for the additional information 31.
+func (d *cborDecoder) head() (major byte, arg uint64, indefinite bool, err error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, false, d.errorf("unexpected end of data")
+	}
+	ib := d.data[d.pos]
+	d.pos++
+	major, ai := ib>>5, ib&0x1f
+
+	switch {
+	case ai < 24:
+		return major, uint64(ai), false, nil
+	case ai == 31:
+		return major, 0, true, nil
+	case ai > 27:
+		return 0, 0, false, d.errorf("reserved additional information %d", ai)
+	}
+
+	sz := 1 << (ai - 24)
+	if d.pos+sz > len(d.data) {
+		return 0, 0, false, d.errorf("unexpected end of data")
+	}
+	b := d.data[d.pos : d.pos+sz]
+	d.pos += sz
+	switch sz {
+	case 1:
+		arg = uint64(b[0])
+	case 2:
+		arg = uint64(binary.BigEndian.Uint16(b))
+	case 4:
+		arg = uint64(binary.BigEndian.Uint32(b))
+	default:
+		arg = binary.BigEndian.Uint64(b)
+	}
+	return major, arg, false, nil
+}
+
+func (d *cborDecoder) isBreak() bool {
+	return d.pos < len(d.data) && d.data[d.pos] == 0xff
+}
+
+// str reads the content of a byte string or a text string of the major type, including the indefinite ones.
+func (d *cborDecoder) str(major byte, arg uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, d.errorf("unexpected end of data")
+		}
+		b := d.data[d.pos : d.pos+int(arg)]
+		d.pos += int(arg)
+		return b, nil
+	}
+
+	var b []byte
+	for !d.isBreak() {
+		m, arg, indef, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if m != major || indef {
+			return nil, d.errorf("invalid chunk of indefinite length string")
+		}
+		chunk, err := d.str(m, arg, false)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, chunk...)
+	}
+	if d.pos >= len(d.data) {
+		return nil, d.errorf("unexpected end of data")
+	}
+	d.pos++
+	return b, nil
+}
+
+func (d *cborDecoder) decode(buf *bytes.Buffer, depth int) error {
+	if depth > cborMaxDepth {
+		return d.errorf("nesting depth exceeds %d", cborMaxDepth)
+	}
+
+	start := d.pos
+	major, arg, indefinite, err := d.head()
+	if err != nil {
+		return err
+	}
+	if indefinite && (major < 2 || major == 6) {
+		return d.errorf("invalid indefinite length of major type %d", major)
+	}
+
+	switch major {
+	case 0:
+		buf.WriteString(strconv.FormatUint(arg, 10))
+
+	case 1:
+		n := new(big.Int).SetUint64(arg)
+		buf.WriteString(n.Add(n, big.NewInt(1)).Neg(n).String())
+
+	case 2:
+		b, err := d.str(major, arg, indefinite)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(`{"` + cborBytesKey + `":"`)
+		buf.WriteString(base64.RawURLEncoding.EncodeToString(b))
+		buf.WriteString(`"}`)
+
+	case 3:
+		b, err := d.str(major, arg, indefinite)
+		if err != nil {
+			return err
+		}
+		if !utf8.Valid(b) {
+			return d.errorf("invalid UTF-8 text string")
+		}
+		writeCanonicalString(buf, string(b))
+
+	case 4:
+		buf.WriteByte('[')
+		for i := uint64(0); indefinite && !d.isBreak() || !indefinite && i < arg; i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := d.decode(buf, depth+1); err != nil {
+				return err
+			}
+		}
+		if indefinite {
+			d.pos++
+		}
+		buf.WriteByte(']')
+
+	case 5:
+		buf.WriteByte('{')
+		for i := uint64(0); indefinite && !d.isBreak() || !indefinite && i < arg; i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			m, arg, indef, err := d.head()
+			if err != nil {
+				return err
+			}
+			if m != 3 {
+				return d.errorf("unsupported map key of major type %d", m)
+			}
+			key, err := d.str(m, arg, indef)
+			if err != nil {
+				return err
+			}
+			if !utf8.Valid(key) {
+				return d.errorf("invalid UTF-8 text string")
+			}
+			writeCanonicalString(buf, string(key))
+			buf.WriteByte(':')
+			if err := d.decode(buf, depth+1); err != nil {
+				return err
+			}
+		}
+		if indefinite {
+			d.pos++
+		}
+		buf.WriteByte('}')
+
+	case 6:
+		if arg == 2 || arg == 3 {
+			content := d.pos
+			if m, sz, indef, err := d.head(); err == nil && m == 2 {
+				b, err := d.str(m, sz, indef)
+				if err != nil {
+					return err
+				}
+				n := new(big.Int).SetBytes(b)
+				if arg == 3 {
+					n.Add(n, big.NewInt(1)).Neg(n)
+				}
+				buf.WriteString(n.String())
+				return nil
+			}
+			d.pos = content
+		}
+		buf.WriteString(`{"` + cborTagKey + `":`)
+		buf.WriteString(strconv.FormatUint(arg, 10))
+		buf.WriteString(`,"` + cborValueKey + `":`)
+		if err := d.decode(buf, depth+1); err != nil {
+			return err
+		}
+		buf.WriteByte('}')
+
+	default:
+		return d.simple(buf, d.data[start]&0x1f, arg, indefinite)
+	}
+	return nil
+}
+
+func (d *cborDecoder) simple(buf *bytes.Buffer, ai byte, arg uint64, indefinite bool) error {
+	var f float64
+	switch {
+	case indefinite:
+		return d.errorf("unexpected break")
+	case ai == 20:
+		buf.WriteString("false")
+		return nil
+	case ai == 21:
+		buf.WriteString("true")
+		return nil
+	case ai == 22, ai == 23:
+		buf.WriteString("null")
+		return nil
+	case ai == 25:
+		f = float16ToFloat64(uint16(arg))
+	case ai == 26:
+		f = float64(math.Float32frombits(uint32(arg)))
+	case ai == 27:
+		f = math.Float64frombits(arg)
+	default:
+		return d.errorf("unsupported simple value %d", arg)
+	}
+
+	switch {
+	case math.IsNaN(f):
+		buf.WriteString(`{"` + cborFloatKey + `":"NaN"}`)
+	case math.IsInf(f, 1):
+		buf.WriteString(`{"` + cborFloatKey + `":"Infinity"}`)
+	case math.IsInf(f, -1):
+		buf.WriteString(`{"` + cborFloatKey + `":"-Infinity"}`)
+	default:
+		s := strconv.FormatFloat(f, 'g', -1, 64)
+		// keeps the number a float.
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		buf.WriteString(s)
+	}
+	return nil
+}
+
+func cborHead(buf *bytes.Buffer, major byte, arg uint64) {
+	major <<= 5
+	var b [8]byte
+	switch {
+	case arg < 24:
+		buf.WriteByte(major | byte(arg))
+	case arg <= math.MaxUint8:
+		buf.WriteByte(major | 24)
+		buf.WriteByte(byte(arg))
+	case arg <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		binary.BigEndian.PutUint16(b[:2], uint16(arg))
+		buf.Write(b[:2])
+	case arg <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		binary.BigEndian.PutUint32(b[:4], uint32(arg))
+		buf.Write(b[:4])
+	default:
+		buf.WriteByte(major | 27)
+		binary.BigEndian.PutUint64(b[:], arg)
+		buf.Write(b[:])
+	}
+}
+
+func (n *Node) encodeCBOR(buf *bytes.Buffer, depth int) error {
+	if depth > cborMaxDepth {
+		return fmt.Errorf("nesting depth exceeds %d, %w", cborMaxDepth, ErrInvalidCBOR)
+	}
+	if n == nil || n.which != eDoc && n.which != eAry && n.raw == nil {
+		buf.WriteByte(0xf6)
+		return nil
+	}
+	if _, err := n.intoContainer(); err != nil && err != ErrInvalid {
+		return err
+	}
+
+	switch n.which {
+	case eDoc:
+		if ok, err := n.encodeCBORItem(buf, depth); ok || err != nil {
+			return err
+		}
+		cborHead(buf, 5, uint64(len(n.doc.keys)))
+		for _, k := range n.doc.keys {
+			cborHead(buf, 3, uint64(len(k)))
+			buf.WriteString(k)
+			if err := n.doc.obj[k].encodeCBOR(buf, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case eAry:
+		cborHead(buf, 4, uint64(len(n.ary)))
+		for _, v := range n.ary {
+			if err := v.encodeCBOR(buf, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	raw := bytes.TrimSpace(*n.raw)
+	if !json.Valid(raw) {
+		return fmt.Errorf("invalid JSON value %q", raw)
+	}
+	switch raw[0] {
+	case '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return err
+		}
+		cborHead(buf, 3, uint64(len(s)))
+		buf.WriteString(s)
+	case 't':
+		buf.WriteByte(0xf5)
+	case 'f':
+		buf.WriteByte(0xf4)
+	case 'n':
+		buf.WriteByte(0xf6)
+	default:
+		return encodeCBORNumber(buf, string(raw))
+	}
+	return nil
+}
+
+// encodeCBORItem encodes the objects that represent the CBOR items without a JSON counterpart.
+func (n *Node) encodeCBORItem(buf *bytes.Buffer, depth int) (bool, error) {
+	d := n.doc
+	switch len(d.keys) {
+	case 1:
+		var s string
+		switch d.keys[0] {
+		case cborBytesKey:
+			if err := json.Unmarshal(d.obj[cborBytesKey].rawOrNull(), &s); err != nil {
+				return false, nil
+			}
+			b, err := base64.RawURLEncoding.DecodeString(s)
+			if err != nil {
+				return false, nil
+			}
+			cborHead(buf, 2, uint64(len(b)))
+			buf.Write(b)
+			return true, nil
+
+		case cborFloatKey:
+			if err := json.Unmarshal(d.obj[cborFloatKey].rawOrNull(), &s); err != nil {
+				return false, nil
+			}
+			switch s {
+			case "NaN":
+				buf.Write([]byte{0xf9, 0x7e, 0x00})
+			case "Infinity":
+				buf.Write([]byte{0xf9, 0x7c, 0x00})
+			case "-Infinity":
+				buf.Write([]byte{0xf9, 0xfc, 0x00})
+			default:
+				return false, nil
+			}
+			return true, nil
+		}
+
+	case 2:
+		tag, ok := d.obj[cborTagKey]
+		val, vok := d.obj[cborValueKey]
+		if !ok || !vok {
+			return false, nil
+		}
+		t, err := strconv.ParseUint(string(bytes.TrimSpace(tag.rawOrNull())), 10, 64)
+		if err != nil {
+			return false, nil
+		}
+		cborHead(buf, 6, t)
+		return true, val.encodeCBOR(buf, depth+1)
+	}
+	return false, nil
+}
+
+// rawOrNull returns the raw encoded JSON of a scalar node, or null.
+func (n *Node) rawOrNull() json.RawMessage {
+	if n == nil || n.raw == nil || n.which == eDoc || n.which == eAry {
+		return json.RawMessage("null")
+	}
+	return *n.raw
+}
+
+func encodeCBORNumber(buf *bytes.Buffer, s string) error {
+	if !strings.ContainsAny(s, ".eE") {
+		n, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return fmt.Errorf("invalid JSON number %q", s)
+		}
+		major, tag := byte(0), uint64(2)
+		if n.Sign() < 0 {
+			// -1 - n
+			major, tag = 1, 3
+			n.Neg(n).Sub(n, big.NewInt(1))
+		}
+		if n.IsUint64() {
+			cborHead(buf, major, n.Uint64())
+		} else {
+			b := n.Bytes()
+			cborHead(buf, 6, tag)
+			cborHead(buf, 2, uint64(len(b)))
+			buf.Write(b)
+		}
+		return nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("unable to encode number %s, %w", s, err)
+	}
+	if h, ok := float64ToFloat16(f); ok {
+		buf.WriteByte(0xf9)
+		buf.WriteByte(byte(h >> 8))
+		buf.WriteByte(byte(h))
+		return nil
+	}
+
+	var b [8]byte
+	if f32 := float32(f); float64(f32) == f {
+		buf.WriteByte(0xfa)
+		binary.BigEndian.PutUint32(b[:4], math.Float32bits(f32))
+		buf.Write(b[:4])
+		return nil
+	}
+	buf.WriteByte(0xfb)
+	binary.BigEndian.PutUint64(b[:], math.Float64bits(f))
+	buf.Write(b[:])
+	return nil
+}
+
+func float16ToFloat64(h uint16) float64 {
+	exp, mant := int(h>>10)&0x1f, float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
+
+// float64ToFloat16 converts the finite float to a half-precision float if it keeps the value.
+func float64ToFloat16(f float64) (uint16, bool) {
+	f32 := float32(f)
+	if float64(f32) != f {
+		return 0, false
+	}
+
+	b := math.Float32bits(f32)
+	sign := uint16(b>>16) & 0x8000
+	exp, mant := int(b>>23)&0xff, b&0x7fffff
+	switch {
+	case exp == 0 && mant == 0:
+		return sign, true
+	case exp == 0:
+		return 0, false
+	}
+
+	e := exp - 127
+	switch {
+	case e >= -14 && e <= 15:
+		if mant&0x1fff != 0 {
+			return 0, false
+		}
+		return sign | uint16(e+15)<<10 | uint16(mant>>13), true
+	case e >= -24 && e < -14:
+		m := mant | 1<<23
+		shift := uint(-(e + 1))
+		if m&(1<<shift-1) != 0 {
+			return 0, false
+		}
+		return sign | uint16(m>>shift), true
+	}
+	return 0, false
+}
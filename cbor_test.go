@@ -0,0 +1,167 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCBOR(t *testing.T) {
+	assert := assert.New(t)
+
+	// RFC 8949, Appendix A
+	for i, c := range []struct {
+		cbor, json string
+	}{
+		{"00", `0`},
+		{"17", `23`},
+		{"1818", `24`},
+		{"1903e8", `1000`},
+		{"1b000000e8d4a51000", `1000000000000`},
+		{"1bffffffffffffffff", `18446744073709551615`},
+		{"c249010000000000000000", `18446744073709551616`},
+		{"3bffffffffffffffff", `-18446744073709551616`},
+		{"c349010000000000000000", `-18446744073709551617`},
+		{"20", `-1`},
+		{"3903e7", `-1000`},
+		{"f90000", `0.0`},
+		{"f98000", `-0.0`},
+		{"f93c00", `1.0`},
+		{"fb3ff199999999999a", `1.1`},
+		{"f93e00", `1.5`},
+		{"f97bff", `65504.0`},
+		{"fa47c35000", `100000.0`},
+		{"fa7f7fffff", `3.4028234663852886e+38`},
+		{"fb7e37e43c8800759c", `1e+300`},
+		{"f90001", `5.960464477539063e-08`},
+		{"f90400", `6.103515625e-05`},
+		{"f9c400", `-4.0`},
+		{"fbc010666666666666", `-4.1`},
+		{"f97c00", `{"$float":"Infinity"}`},
+		{"f97e00", `{"$float":"NaN"}`},
+		{"f9fc00", `{"$float":"-Infinity"}`},
+		{"f4", `false`},
+		{"f5", `true`},
+		{"f6", `null`},
+		{"c074323031332d30332d32315432303a30343a30305a", `{"$tag":0,"$value":"2013-03-21T20:04:00Z"}`},
+		{"d74401020304", `{"$tag":23,"$value":{"$bytes":"AQIDBA"}}`},
+		{"40", `{"$bytes":""}`},
+		{"60", `""`},
+		{"6449455446", `"IETF"`},
+		{"62225c", `"\"\\"`},
+		{"62c3bc", `"ü"`},
+		{"80", `[]`},
+		{"83010203", `[1,2,3]`},
+		{"8301820203820405", `[1,[2,3],[4,5]]`},
+		{"a0", `{}`},
+		{"a26161016162820203", `{"a":1,"b":[2,3]}`},
+		{"826161a161626163", `["a",{"b":"c"}]`},
+	} {
+		data, err := hex.DecodeString(c.cbor)
+		assert.Nil(err)
+		node, err := NewNodeCBOR(data)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.json, mustJSONString(node), "case %d", i)
+
+		res, err := NewNode([]byte(c.json)).MarshalCBOR()
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.cbor, hex.EncodeToString(res), "case %d", i)
+	}
+
+	// indefinite length items
+	for i, c := range []struct {
+		cbor, json string
+	}{
+		{"5f42010243030405ff", `{"$bytes":"AQIDBAU"}`},
+		{"7f657374726561646d696e67ff", `"streaming"`},
+		{"9fff", `[]`},
+		{"9f018202039f0405ffff", `[1,[2,3],[4,5]]`},
+		{"bf61610161629f0203ffff", `{"a":1,"b":[2,3]}`},
+		{"f7", `null`},
+		{"fa3fc00000", `1.5`},
+	} {
+		data, _ := hex.DecodeString(c.cbor)
+		node, err := NewNodeCBOR(data)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.json, mustJSONString(node), "case %d", i)
+	}
+
+	for i, s := range []string{"", "18", "1c", "5f01ff", "a10102", "830102", "62c3", "ff", "f818", "0000", "9f01"} {
+		data, _ := hex.DecodeString(s)
+		_, err := NewNodeCBOR(data)
+		assert.ErrorIs(err, ErrInvalidCBOR, "case %d", i)
+	}
+}
+
+func TestApplyCBOR(t *testing.T) {
+	assert := assert.New(t)
+
+	// {"id": 18446744073709551616, "sig": h'01020304', "at": 1(1363896240), "n": 1.5}
+	doc, _ := hex.DecodeString("a4626964c24901000000000000000063736967440102030462617" +
+		"4c11a514b67b0616ef93e00")
+	node, err := NewNodeCBOR(doc)
+	assert.Nil(err)
+	assert.Equal(`{"id":18446744073709551616,"sig":{"$bytes":"AQIDBA"},"at":{"$tag":1,"$value":1363896240},"n":1.5}`,
+		mustJSONString(node))
+
+	p, err := NewPatch([]byte(`[
+		{"op": "test", "path": "/id", "value": 18446744073709551616},
+		{"op": "replace", "path": "/at/$value", "value": 1363896241},
+		{"op": "add", "path": "/key", "value": {"$bytes": "_w"}},
+		{"op": "remove", "path": "/n"}
+	]`))
+	assert.Nil(err)
+	res, err := p.ApplyCBOR(doc, nil)
+	assert.Nil(err)
+	assert.Equal("a4626964c249010000000000000000637369674401020304626174c11a514b67b1636b657941ff",
+		hex.EncodeToString(res))
+
+	v, err := GetValueByPathCBOR(res, "/key")
+	assert.Nil(err)
+	assert.Equal("41ff", hex.EncodeToString(v))
+	v, err = GetValueByPathCBOR(res, "")
+	assert.Nil(err)
+	assert.Equal(res, v)
+	_, err = GetValueByPathCBOR(res, "/x")
+	assert.ErrorIs(err, ErrMissing)
+}
+
+func TestPatchCBOR(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := NewPatch([]byte(`[
+		{"op": "test", "path": "/id", "value": 18446744073709551616},
+		{"op": "add", "path": "/tags/-", "value": {"name": "x", "n": [1, 2.5, null]}},
+		{"op": "replace", "path": "/n", "value": null},
+		{"op": "move", "from": "/a", "path": "/b"},
+		{"op": "remove", "path": "/c"}
+	]`))
+	assert.Nil(err)
+
+	data, err := p.MarshalCBOR()
+	assert.Nil(err)
+	js, _ := json.Marshal(p)
+	assert.Less(len(data), len(js))
+
+	p2, err := DecodePatchCBOR(data)
+	assert.Nil(err)
+	js2, _ := json.Marshal(p2)
+	assert.Equal(string(js), string(js2))
+
+	doc := []byte(`{"id": 18446744073709551616, "tags": [], "n": 1, "a": true, "c": "c"}`)
+	res, err := p2.Apply(doc)
+	assert.Nil(err)
+	assert.Equal(`{"id":18446744073709551616,"tags":[{"name":"x","n":[1,2.5,null]}],"n":null,"b":true}`, string(res))
+
+	_, err = DecodePatchCBOR([]byte{0x81, 0xa1})
+	assert.ErrorIs(err, ErrInvalidCBOR)
+
+	// {"op": "add"}
+	_, err = DecodePatchCBOR([]byte{0xa1, 0x62, 'o', 'p', 0x63, 'a', 'd', 'd'})
+	assert.NotNil(err)
+}
@@ -0,0 +1,334 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Command json-patch applies, diffs and queries JSON documents with the jsonpatch package,
+// so that shell scripts and CI pipelines get the same semantics as the library.
+//
+// Usage:
+//
+//	json-patch apply -p patch.json [doc.json]
+//	json-patch merge -p merge-patch.json [doc.json]
+//	json-patch diff a.json b.json
+//	json-patch get <pointer> [doc.json]
+//	json-patch find <tests> [doc.json]
+//	json-patch validate [-doc doc.json] [patch.json]
+//
+// The documents are read from the files, or from stdin if they are omitted or "-",
+// and the results are written to stdout. The tests of find are a JSON array of
+// {"path": ..., "value": ...} test operations, as Node.FindChildren.
+// The exit code is 1 for failed operations and 2 for invalid usages.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	jsonpatch "github.com/ldclabs/json-patch"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+const usage = `Usage:
+  json-patch apply -p patch.json [doc.json]
+  json-patch merge -p merge-patch.json [doc.json]
+  json-patch diff a.json b.json
+  json-patch get <pointer> [doc.json]
+  json-patch find <tests> [doc.json]
+  json-patch validate [-doc doc.json] [patch.json]
+
+The documents are read from stdin if they are omitted or "-".
+Run "json-patch <command> -h" for the flags of a command.
+`
+
+// command is a subcommand, flags defines its flags and returns the function that runs it.
+type command struct {
+	name    string
+	args    string
+	nargs   int
+	minArgs int
+	flags   func(fs *flag.FlagSet) func(c *env) error
+}
+
+// env is the environment of a running command.
+type env struct {
+	args   []string
+	stdin  io.Reader
+	stdout io.Writer
+	pretty bool
+}
+
+var commands = []*command{
+	{name: "apply", args: "[doc.json]", nargs: 1, flags: func(fs *flag.FlagSet) func(c *env) error {
+		patchFile := fs.String("p", "", "the JSON patch file (required)")
+		atomic := fs.Bool("atomic", true, "apply all the operations or none")
+		negative := fs.Bool("negative-indices", false, "support negative array indices")
+		missing := fs.Bool("allow-missing", false, "allow removing missing paths")
+		return func(c *env) error {
+			if *patchFile == "" {
+				return fmt.Errorf("flag -p is required")
+			}
+			if *patchFile == "-" && c.arg(0) == "-" {
+				return fmt.Errorf("only one of the patch and the document can be read from stdin")
+			}
+			p, err := readPatch(c, *patchFile)
+			if err != nil {
+				return err
+			}
+			doc, err := c.read(c.arg(0))
+			if err != nil {
+				return err
+			}
+			options := jsonpatch.NewOptions()
+			options.SupportNegativeIndices = *negative
+			options.AllowMissingPathOnRemove = *missing
+			var res []byte
+			if *atomic {
+				res, err = p.ApplyAtomic(doc, options)
+			} else {
+				res, err = p.ApplyWithOptions(doc, options)
+			}
+			if err != nil {
+				return err
+			}
+			return c.write(res)
+		}
+	}},
+	{name: "merge", args: "[doc.json]", nargs: 1, flags: func(fs *flag.FlagSet) func(c *env) error {
+		patchFile := fs.String("p", "", "the JSON merge patch file (required)")
+		return func(c *env) error {
+			if *patchFile == "" {
+				return fmt.Errorf("flag -p is required")
+			}
+			if *patchFile == "-" && c.arg(0) == "-" {
+				return fmt.Errorf("only one of the patch and the document can be read from stdin")
+			}
+			patch, err := c.read(*patchFile)
+			if err != nil {
+				return err
+			}
+			doc, err := c.read(c.arg(0))
+			if err != nil {
+				return err
+			}
+			res, err := jsonpatch.MergePatch(doc, patch)
+			if err != nil {
+				return err
+			}
+			return c.write(res)
+		}
+	}},
+	{name: "diff", args: "a.json b.json", nargs: 2, minArgs: 2, flags: func(fs *flag.FlagSet) func(c *env) error {
+		idKey := fs.String("id-key", "", "the key that identifies the objects of arrays")
+		moves := fs.Bool("moves", false, "emit move operations")
+		copies := fs.Bool("copies", false, "emit copy operations")
+		return func(c *env) error {
+			if c.arg(0) == "-" && c.arg(1) == "-" {
+				return fmt.Errorf("only one of the documents can be read from stdin")
+			}
+			a, err := c.read(c.arg(0))
+			if err != nil {
+				return err
+			}
+			b, err := c.read(c.arg(1))
+			if err != nil {
+				return err
+			}
+			p, err := jsonpatch.Diff(a, b, &jsonpatch.DiffOptions{IDKey: *idKey, DetectMoves: *moves, DetectCopies: *copies})
+			if err != nil {
+				return err
+			}
+			if p == nil {
+				p = jsonpatch.Patch{}
+			}
+			res, err := json.Marshal(p)
+			if err != nil {
+				return err
+			}
+			return c.write(res)
+		}
+	}},
+	{name: "get", args: "<pointer> [doc.json]", nargs: 2, minArgs: 1, flags: func(fs *flag.FlagSet) func(c *env) error {
+		negative := fs.Bool("negative-indices", false, "support negative array indices")
+		return func(c *env) error {
+			doc, err := c.read(c.arg(1))
+			if err != nil {
+				return err
+			}
+			options := jsonpatch.NewOptions()
+			options.SupportNegativeIndices = *negative
+			res, err := jsonpatch.NewNode(doc).GetValue(c.arg(0), options)
+			if err != nil {
+				return err
+			}
+			return c.write(res)
+		}
+	}},
+	{name: "find", args: "<tests> [doc.json]", nargs: 2, minArgs: 1, flags: func(fs *flag.FlagSet) func(c *env) error {
+		return func(c *env) error {
+			var tests jsonpatch.PVs
+			if err := json.Unmarshal([]byte(c.arg(0)), &tests); err != nil {
+				return fmt.Errorf("invalid tests, %w", err)
+			}
+			doc, err := c.read(c.arg(1))
+			if err != nil {
+				return err
+			}
+			pvs, err := jsonpatch.NewNode(doc).FindChildren(tests, nil)
+			if err != nil {
+				return err
+			}
+			res, err := json.Marshal(pvs)
+			if err != nil {
+				return err
+			}
+			return c.write(res)
+		}
+	}},
+	{name: "validate", args: "[patch.json]", nargs: 1, flags: func(fs *flag.FlagSet) func(c *env) error {
+		docFile := fs.String("doc", "", "the JSON document to validate the patch against")
+		return func(c *env) error {
+			if *docFile == "-" && c.arg(0) == "-" {
+				return fmt.Errorf("only one of the patch and the document can be read from stdin")
+			}
+			p, err := readPatch(c, c.arg(0))
+			if err != nil {
+				return err
+			}
+			if err = checkPatch(p); err != nil {
+				return err
+			}
+			if *docFile != "" {
+				doc, err := c.read(*docFile)
+				if err != nil {
+					return err
+				}
+				if err = p.Validate(doc, nil); err != nil {
+					return err
+				}
+			}
+			_, err = fmt.Fprintf(c.stdout, "valid patch of %d operations\n", len(p))
+			return err
+		}
+	}},
+}
+
+// run runs the command of the arguments, and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		fmt.Fprint(stderr, usage)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+
+	var cmd *command
+	for _, c := range commands {
+		if c.name == args[0] {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "json-patch: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("json-patch "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: json-patch %s [flags] %s\n", cmd.name, cmd.args)
+		fs.PrintDefaults()
+	}
+	pretty := fs.Bool("pretty", false, "indent the output")
+	exec := cmd.flags(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() < cmd.minArgs || fs.NArg() > cmd.nargs {
+		fs.Usage()
+		return 2
+	}
+
+	c := &env{args: fs.Args(), stdin: stdin, stdout: stdout, pretty: *pretty}
+	if err := exec(c); err != nil {
+		fmt.Fprintf(stderr, "json-patch %s: %v\n", cmd.name, err)
+		return 1
+	}
+	return 0
+}
+
+// arg returns the argument of index i, or "-" if it is omitted.
+func (c *env) arg(i int) string {
+	if i < len(c.args) {
+		return c.args[i]
+	}
+	return "-"
+}
+
+// read reads the file, or stdin if the name is "-".
+func (c *env) read(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(c.stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// write writes the JSON result with a trailing newline.
+func (c *env) write(data []byte) error {
+	if c.pretty {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", "  "); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+	_, err := c.stdout.Write(append(data, '\n'))
+	return err
+}
+
+func readPatch(c *env, name string) (jsonpatch.Patch, error) {
+	data, err := c.read(name)
+	if err != nil {
+		return nil, err
+	}
+	p, err := jsonpatch.NewPatch(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid patch, %w", err)
+	}
+	return p, nil
+}
+
+// checkPatch checks the operations of the patch without a document.
+func checkPatch(p jsonpatch.Patch) error {
+	for i, op := range p {
+		var err error
+		switch op.Kind() {
+		case jsonpatch.OpAdd, jsonpatch.OpReplace, jsonpatch.OpTest:
+			if op.Value == nil {
+				err = fmt.Errorf("%s operation has no value", op.Op)
+			}
+		case jsonpatch.OpMove, jsonpatch.OpCopy:
+			_, err = jsonpatch.ParsePath(op.From)
+		case jsonpatch.OpRemove:
+		default:
+			err = fmt.Errorf("unexpected operation %q", op.Op)
+		}
+		if err == nil {
+			_, err = jsonpatch.ParsePath(op.Path)
+		}
+		if err != nil {
+			return &jsonpatch.PathError{Op: op.Op, Path: op.Path, Index: i, Err: err}
+		}
+	}
+	return nil
+}
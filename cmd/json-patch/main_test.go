@@ -0,0 +1,72 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		file := filepath.Join(dir, name)
+		assert.Nil(os.WriteFile(file, []byte(data), 0o644))
+		return file
+	}
+	doc := write("doc.json", `{"name":"a","tags":["x","y"]}`)
+	patch := write("patch.json", `[{"op":"add","path":"/tags/-","value":"z"}]`)
+	bad := write("bad.json", `[{"op":"test","path":"/name","value":"b"}]`)
+	target := write("target.json", `{"name":"b","tags":["x","y"]}`)
+
+	for i, c := range []struct {
+		args   []string
+		stdin  string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{[]string{"apply", "-p", patch, doc}, "", 0, `{"name":"a","tags":["x","y","z"]}` + "\n", ""},
+		{[]string{"apply", "-p", patch}, `{"tags":[]}`, 0, `{"tags":["z"]}` + "\n", ""},
+		{[]string{"apply", "-p", patch, "-pretty", "-"}, `{"tags":[]}`, 0, "{\n  \"tags\": [\n    \"z\"\n  ]\n}\n", ""},
+		{[]string{"apply", "-p", bad, doc}, "", 1, "", "json-patch apply: operation 0, "},
+		{[]string{"apply", doc}, "", 1, "", "flag -p is required"},
+		{[]string{"apply", "-p", "-"}, "", 1, "", "only one of the patch and the document can be read from stdin"},
+		{[]string{"merge", "-p", "-", doc}, `{"name":null}`, 0, `{"tags":["x","y"]}` + "\n", ""},
+		{[]string{"diff", doc, target}, "", 0, `[{"op":"replace","path":"/name","value":"b"}]` + "\n", ""},
+		{[]string{"diff", doc, "-"}, `{"name":"a","tags":["x","y"]}`, 0, "[]\n", ""},
+		{[]string{"diff", doc}, "", 2, "", "Usage: json-patch diff [flags] a.json b.json"},
+		{[]string{"get", "/tags/1", doc}, "", 0, `"y"` + "\n", ""},
+		{[]string{"get", "-negative-indices", "/tags/-1"}, `{"tags":[1,2]}`, 0, "2\n", ""},
+		{[]string{"get", "/x", doc}, "", 1, "", "missing value"},
+		{[]string{"find", `[{"path":"/1","value":"y"}]`, doc}, "", 0, `[{"path":"/tags","value":["x","y"]}]` + "\n", ""},
+		{[]string{"find", `[{"path":"/1","value":"q"}]`, doc}, "", 0, "[]\n", ""},
+		{[]string{"find", `{`, doc}, "", 1, "", "invalid tests"},
+		{[]string{"validate", patch}, "", 0, "valid patch of 1 operations\n", ""},
+		{[]string{"validate", "-doc", doc, bad}, "", 1, "", `test operation for path "/name" failed`},
+		{[]string{"validate"}, `[{"op":"add","path":"/a"}]`, 1, "", "add operation has no value"},
+		{[]string{"validate"}, `[{"op":"inc","path":"/a"}]`, 1, "", `unexpected operation "inc"`},
+		{[]string{"validate"}, `[{"op":"remove","path":"a"}]`, 1, "", `invalid JSON pointer "a"`},
+		{[]string{"validate"}, `{}`, 1, "", "invalid patch"},
+		{[]string{}, "", 2, "", "Usage:"},
+		{[]string{"help"}, "", 0, "", "Usage:"},
+		{[]string{"zip"}, "", 2, "", `unknown command "zip"`},
+		{[]string{"get", "-h"}, "", 0, "", "Usage: json-patch get [flags] <pointer> [doc.json]"},
+	} {
+		var stdout, stderr bytes.Buffer
+		code := run(c.args, strings.NewReader(c.stdin), &stdout, &stderr)
+		assert.Equal(c.code, code, "case %d: %s", i, stderr.String())
+		assert.Equal(c.stdout, stdout.String(), "case %d", i)
+		if c.stderr != "" {
+			assert.Contains(stderr.String(), c.stderr, "case %d", i)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CompiledPatch is a patch prepared by Patch.Compile to be applied to many documents.
+// It is safe for concurrent use.
+type CompiledPatch struct {
+	patch   Patch
+	options *Options
+}
+
+// compiledOp is the prepared operation of a CompiledPatch.
+type compiledOp struct {
+	// path and from are the unescaped keys of the paths.
+	path, from []string
+	// value is the validated raw value, shared by the nodes added to the documents.
+	value *json.RawMessage
+	// test is the frozen value of the "test" operation.
+	test    *Node
+	handler OperationHandler
+}
+
+// Compile prepares the patch to be applied with the options to many documents: the paths are split
+// into their keys, the values are validated and wrapped once, and the handlers of the extension
+// operations are resolved. It returns an error for the invalid values, the unknown operations,
+// and the patch that exceeds the limits of the options. The options and the registered operations are copied.
+func (p Patch) Compile(options *Options) (*CompiledPatch, error) {
+	if options == nil {
+		options = NewOptions()
+	}
+	o := *options
+	if o.operations != nil {
+		o.operations = make(map[string]OperationHandler, len(options.operations))
+		for name, fn := range options.operations {
+			o.operations[name] = fn
+		}
+	}
+	if err := o.checkPatch(p); err != nil {
+		return nil, err
+	}
+
+	c := &CompiledPatch{patch: make(Patch, len(p)), options: &o}
+	for i, op := range p {
+		var err error
+		if op.compiled, err = compileOp(op, &o); err != nil {
+			return nil, &PathError{Op: op.Op, Path: op.Path, Index: i, Err: err}
+		}
+		c.patch[i] = op
+	}
+	return c, nil
+}
+
+func compileOp(op Operation, options *Options) (*compiledOp, error) {
+	c := &compiledOp{path: splitKeys(op.Path), from: splitKeys(op.From)}
+	if op.Value != nil {
+		if err := checkValidJSON(op.Value); err != nil {
+			return nil, fmt.Errorf("%s operation has invalid value, %w", op.Op, err)
+		}
+		raw := make(json.RawMessage, len(op.Value))
+		copy(raw, op.Value)
+		c.value = &raw
+	}
+
+	switch op.Op {
+	case "add", "remove", "replace", "move", "copy":
+	case "test":
+		c.test = c.node()
+		if err := c.test.Freeze(); err != nil {
+			return nil, err
+		}
+	default:
+		fn, ok := options.extension(op.Op)
+		if !ok {
+			return nil, fmt.Errorf("unexpected operation %q", op.Op)
+		}
+		c.handler = fn
+	}
+	return c, nil
+}
+
+// Apply applies the compiled patch to the JSON document.
+func (c *CompiledPatch) Apply(doc []byte) ([]byte, error) {
+	node := NewNode(doc)
+	if err := c.PatchNode(node); err != nil {
+		return nil, err
+	}
+	return marshalPatched(node, doc, c.options)
+}
+
+// PatchNode applies the compiled patch to the node, as Node.Patch.
+func (c *CompiledPatch) PatchNode(n *Node) error {
+	if n == nil {
+		return errors.New("nil node")
+	}
+	return n.Patch(c.patch, c.options)
+}
+
+// Patch returns the operations of the compiled patch.
+func (c *CompiledPatch) Patch() Patch {
+	p := make(Patch, len(c.patch))
+	for i, op := range c.patch {
+		op.compiled = nil
+		p[i] = op
+	}
+	return p
+}
+
+// splitKeys splits the path into its unescaped keys as findObject, or returns nil for the root.
+func splitKeys(path string) []string {
+	split := strings.Split(path, "/")
+	if len(split) < 2 {
+		return nil
+	}
+	keys := split[1:]
+	for i, key := range keys {
+		keys[i] = decodePatchKey(key)
+	}
+	return keys
+}
+
+// node returns a new node of the value, sharing the validated raw value.
+func (c *compiledOp) node() *Node {
+	if c.value == nil {
+		return NewNode(nil)
+	}
+	raw := *c.value
+	return &Node{raw: &raw, valid: true}
+}
+
+// findPath returns the container and the key of the path of the operation as findObject.
+func (op Operation) findPath(doc *container, options *Options) (container, string) {
+	if op.compiled != nil {
+		return findObjectByKeys(doc, op.compiled.path, options)
+	}
+	return findObject(doc, op.Path, options)
+}
+
+// findFrom returns the container and the key of the from path of the operation as findObject.
+func (op Operation) findFrom(doc *container, options *Options) (container, string) {
+	if op.compiled != nil {
+		return findObjectByKeys(doc, op.compiled.from, options)
+	}
+	return findObject(doc, op.From, options)
+}
+
+// valueNode returns a new node of the value of the operation to be added to the document.
+func (op Operation) valueNode() *Node {
+	var n *Node
+	if op.compiled != nil {
+		n = op.compiled.node()
+	} else {
+		n = NewNode(op.Value)
+	}
+	n.reformat = true
+	return n
+}
+
+// testValue returns the node of the value of the operation to be compared with the document.
+func (op Operation) testValue() *Node {
+	if op.compiled != nil && op.compiled.test != nil {
+		return op.compiled.test
+	}
+	return NewNode(op.Value)
+}
@@ -0,0 +1,148 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompiledPatch(t *testing.T) {
+	assert := assert.New(t)
+
+	for i, c := range Cases {
+		p, err := NewPatch([]byte(c.patch))
+		assert.NoError(err, "case %d", i)
+		options := NewOptions()
+		options.AllowMissingPathOnRemove = c.allowMissingPathOnRemove
+		options.EnsurePathExistsOnAdd = c.ensurePathExistsOnAdd
+
+		expected, expectedErr := p.ApplyWithOptions([]byte(c.doc), options)
+		cp, err := p.Compile(options)
+		assert.NoError(err, "case %d", i)
+		// the compiled patch can be applied many times.
+		for j := 0; j < 2; j++ {
+			res, err := cp.Apply([]byte(c.doc))
+			assert.Equal(expectedErr, err, "case %d", i)
+			assert.Equal(string(expected), string(res), "case %d", i)
+		}
+	}
+
+	for i, c := range TestCases {
+		p, err := NewPatch([]byte(c.patch))
+		assert.NoError(err, "case %d", i)
+		cp, err := p.Compile(nil)
+		assert.NoError(err, "case %d", i)
+		_, err = cp.Apply([]byte(c.doc))
+		if c.result {
+			assert.NoError(err, "case %d", i)
+		} else {
+			assert.ErrorIs(err, ErrTestFailed, "case %d", i)
+		}
+	}
+
+	p := Patch{
+		{Op: "add", Path: "/a", Value: []byte(`{"b":[1]}`)},
+		{Op: "add", Path: "/a/b/-", Value: []byte(`2`)},
+		{Op: "test", Path: "/a", Value: []byte(`{"b":[1,2]}`)},
+	}
+	cp, err := p.Compile(nil)
+	assert.NoError(err)
+	assert.Equal(p, cp.Patch())
+	for i := 0; i < 3; i++ {
+		res, err := cp.Apply([]byte(`{}`))
+		assert.NoError(err, "case %d", i)
+		assert.Equal(`{"a":{"b":[1,2]}}`, string(res), "case %d", i)
+	}
+	assert.Equal(`{"b":[1]}`, string(p[0].Value))
+
+	_, err = Patch{{Op: "add", Path: "/a", Value: []byte(`{"b":`)}}.Compile(nil)
+	assert.Error(err)
+	_, err = Patch{{Op: "inc", Path: "/a", Value: []byte(`1`)}}.Compile(nil)
+	assert.ErrorContains(err, `unexpected operation "inc"`)
+	options := NewOptions()
+	options.MaxPatchOps = 1
+	_, err = p.Compile(options)
+	assert.ErrorIs(err, ErrLimitExceeded)
+
+	assert.ErrorContains((&CompiledPatch{}).PatchNode(nil), "nil node")
+}
+
+func TestCompiledPatchOperations(t *testing.T) {
+	assert := assert.New(t)
+
+	options := NewOptions()
+	options.RegisterOperation("inc", func(doc *Node, op Operation, options *Options) error {
+		val, err := doc.GetValue(op.Path, options)
+		if err != nil {
+			return err
+		}
+		var a, b int
+		if err = json.Unmarshal(val, &a); err != nil {
+			return err
+		}
+		if err = json.Unmarshal(op.Value, &b); err != nil {
+			return err
+		}
+		return doc.Patch(Patch{NewReplaceOperation(op.Path, []byte(strconv.Itoa(a+b)))}, options)
+	})
+	cp, err := Patch{{Op: "inc", Path: "/count", Value: []byte(`2`)}}.Compile(options)
+	assert.NoError(err)
+
+	// the options are copied by Compile.
+	options.RegisterOperation("inc", nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			node := NewNode([]byte(fmt.Sprintf(`{"count":%d}`, i)))
+			assert.NoError(cp.PatchNode(node))
+			data, err := node.MarshalJSON()
+			assert.NoError(err)
+			assert.Equal(fmt.Sprintf(`{"count":%d}`, i+2), string(data))
+		}(i)
+	}
+	wg.Wait()
+}
+
+func BenchmarkPatchFanOut(b *testing.B) {
+	doc := []byte(`{"id":1,"kind":"config","spec":{"replicas":1,"labels":{"app":"web","tier":"1"}},"version":1}`)
+	p, err := NewPatch([]byte(`[
+		{"op": "test", "path": "/kind", "value": "config"},
+		{"op": "replace", "path": "/spec/labels/tier", "value": "frontend"},
+		{"op": "add", "path": "/spec/env", "value": {"name": "LOG_LEVEL", "value": "debug"}},
+		{"op": "replace", "path": "/version", "value": 2}
+	]`))
+	if err != nil {
+		b.Fatal(err)
+	}
+	cp, err := p.Compile(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("Patch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := p.Apply(doc); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("CompiledPatch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := cp.Apply(doc); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,132 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import "fmt"
+
+// Conflict is a pair of operations in two patches that touch overlapping paths.
+type Conflict struct {
+	// A and B are the indices of the operations in the two patches.
+	A int `json:"a"`
+	B int `json:"b"`
+	// PathA and PathB are the overlapping paths of the operations,
+	// one of them is equal to or an ancestor of the other.
+	PathA string `json:"path_a"`
+	PathB string `json:"path_b"`
+}
+
+// Conflicts returns the conflicts between two independently produced patches. Two operations conflict
+// if one of them writes a path that is equal to, an ancestor or a descendant of a path that the other one
+// reads or writes. "test" reads its path, "copy" reads its from path, "move" writes both of its paths,
+// the other operations write their paths.
+func Conflicts(a, b Patch) ([]Conflict, error) {
+	as, err := touchesOf(a)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := touchesOf(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Conflict
+	for i, ta := range as {
+	Next:
+		for j, tb := range bs {
+			for _, x := range ta {
+				for _, y := range tb {
+					if (x.write || y.write) && pathsOverlap(x.path, y.path) {
+						res = append(res, Conflict{A: i, B: j, PathA: x.path, PathB: y.path})
+						continue Next
+					}
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+type touch struct {
+	path  string
+	write bool
+}
+
+func touchesOf(p Patch) ([][]touch, error) {
+	res := make([][]touch, len(p))
+	for i, op := range p {
+		if _, err := ParsePath(op.Path); err != nil {
+			return nil, fmt.Errorf("operation %d, %w", i, err)
+		}
+
+		switch op.Op {
+		case "test":
+			res[i] = []touch{{op.Path, false}}
+		case "move", "copy":
+			if _, err := ParsePath(op.From); err != nil {
+				return nil, fmt.Errorf("operation %d, %w", i, err)
+			}
+			res[i] = []touch{{op.Path, true}, {op.From, op.Op == "move"}}
+		default:
+			res[i] = []touch{{op.Path, !predicateOperations[op.Op]}}
+		}
+	}
+	return res, nil
+}
+
+// pathsOverlap reports whether one of the paths is equal to or an ancestor of the other.
+func pathsOverlap(a, b string) bool {
+	return hasPathPrefix(a, b) || hasPathPrefix(b, a)
+}
+
+// MergeThreeWay merges the changes of ours and theirs, which are both derived from base.
+// The changes are computed by diffing base with ours and theirs, the changes of theirs that don't conflict
+// with the changes of ours are transformed and applied after the changes of ours. It returns the merged
+// document and the conflicts, where A and B are the indices of the operations of the diffs of ours and theirs,
+// the conflicting changes of theirs are not applied. The changes made by both sides are not conflicts.
+func MergeThreeWay(base, ours, theirs []byte, options *Options) ([]byte, []Conflict, error) {
+	po, err := Diff(base, ours, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	pt, err := Diff(base, theirs, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cs, err := Conflicts(po, pt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	skip := make(map[int]bool)
+	conflicts := make([]Conflict, 0, len(cs))
+	for _, c := range cs {
+		skip[c.B] = true
+		if !sameOperation(po[c.A], pt[c.B]) {
+			conflicts = append(conflicts, c)
+		}
+	}
+
+	rest := make(Patch, 0, len(pt))
+	for i, op := range pt {
+		if !skip[i] {
+			rest = append(rest, op)
+		}
+	}
+	rest, err = Transform(rest, po)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := ConcatPatches(po, rest).ApplyWithOptions(base, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, conflicts, nil
+}
+
+func sameOperation(a, b Operation) bool {
+	return a.Op == b.Op && a.Path == b.Path && a.From == b.From &&
+		(a.Value == nil) == (b.Value == nil) && (a.Value == nil || Equal(a.Value, b.Value))
+}
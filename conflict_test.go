@@ -0,0 +1,70 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConflicts(t *testing.T) {
+	assert := assert.New(t)
+
+	a := Patch{
+		NewReplaceOperation("/name", []byte(`"Jane"`)),
+		NewTestOperation("/age", []byte(`24`)),
+		NewCopyOperation("/tags", "/labels"),
+		NewAddOperation("/meta/a", []byte(`1`)),
+	}
+	b := Patch{
+		NewTestOperation("/name", []byte(`"John"`)),
+		NewTestOperation("/age", []byte(`24`)),
+		NewAddOperation("/tags/-", []byte(`"x"`)),
+		NewRemoveOperation("/meta"),
+		NewAddOperation("/names", []byte(`[]`)),
+		NewMoveOperation("/labels/0", "/first"),
+	}
+
+	cs, err := Conflicts(a, b)
+	assert.Nil(err)
+	assert.Equal([]Conflict{
+		{A: 0, B: 0, PathA: "/name", PathB: "/name"},
+		{A: 2, B: 2, PathA: "/tags", PathB: "/tags/-"},
+		{A: 2, B: 5, PathA: "/labels", PathB: "/labels/0"},
+		{A: 3, B: 3, PathA: "/meta/a", PathB: "/meta"},
+	}, cs)
+
+	cs, err = Conflicts(a, Patch{NewReplaceOperation("", []byte(`{}`))})
+	assert.Nil(err)
+	assert.Equal(4, len(cs))
+
+	cs, err = Conflicts(a, nil)
+	assert.Nil(err)
+	assert.Nil(cs)
+
+	_, err = Conflicts(a, Patch{NewRemoveOperation("name")})
+	assert.NotNil(err)
+}
+
+func TestMergeThreeWay(t *testing.T) {
+	assert := assert.New(t)
+
+	base := []byte(`{"name":"n","age":1,"meta":{"a":1,"b":2},"tags":["x"]}`)
+	ours := []byte(`{"name":"ours","age":2,"meta":{"a":1,"b":2,"c":3},"tags":["x"]}`)
+	theirs := []byte(`{"name":"theirs","age":2,"meta":{"a":0,"b":2},"tags":["x","y"],"new":true}`)
+
+	res, cs, err := MergeThreeWay(base, ours, theirs, nil)
+	assert.Nil(err)
+	assert.Equal(`{"name":"ours","age":2,"meta":{"a":0,"b":2,"c":3},"tags":["x","y"],"new":true}`, string(res))
+	assert.Equal([]Conflict{{A: 0, B: 0, PathA: "/name", PathB: "/name"}}, cs)
+
+	res, cs, err = MergeThreeWay(base, base, theirs, nil)
+	assert.Nil(err)
+	assert.True(Equal(theirs, res))
+	assert.Equal(0, len(cs))
+
+	_, _, err = MergeThreeWay(base, []byte(`{`), theirs, nil)
+	assert.NotNil(err)
+}
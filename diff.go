@@ -1,6 +1,9 @@
 package jsonpatch
 
 import (
+	"bytes"
+	"math"
+	"math/big"
 	"strconv"
 	"strings"
 )
@@ -12,8 +15,146 @@ func Diff(src, dst []byte, opts *DiffOptions) (Patch, error) {
 
 // DiffOptions is used to customize the behavior of the Diff function.
 type DiffOptions struct {
-	// IDKey is the name of the key to use as the unique identifier for JSON object
+	// IDKey is the name of the key to use as the unique identifier for JSON object,
+	// the elements of arrays are matched by it when diffing arrays.
 	IDKey string
+	// DetectMoves instructs Diff to emit "move" operations for the values that are removed from
+	// a path and added to another path, and for the reordered elements of arrays, instead of
+	// "remove" and "add" operations.
+	DetectMoves bool
+	// DetectCopies instructs Diff to emit "copy" operations for the added objects and arrays
+	// that are equal to the existing values, instead of "add" operations.
+	DetectCopies bool
+	// IgnorePaths are the JSON Pointers of the values to ignore, the differences in them and in
+	// their subtrees generate no operation.
+	IgnorePaths []string
+	// NumericEquality instructs Diff to compare the numbers by their values instead of their representations,
+	// so 1, 1.0 and 1e0 are equal.
+	NumericEquality bool
+	// NumericEpsilon is the tolerance of the numbers, the numbers that differ by no more than it are equal.
+	NumericEpsilon float64
+	// IgnoreArrayOrder instructs Diff to treat the arrays with the same elements in different orders as equal.
+	IgnoreArrayOrder bool
+}
+
+// EqualWithOptions indicates if 2 JSON documents are equal with the semantics of the DiffOptions,
+// which are IgnorePaths, NumericEquality, NumericEpsilon and IgnoreArrayOrder.
+func EqualWithOptions(a, b []byte, opts *DiffOptions) bool {
+	return NewNode(a).EqualWithOptions(NewNode(b), opts)
+}
+
+// EqualWithOptions indicates if two JSON Nodes are equal with the semantics of the DiffOptions,
+// which are IgnorePaths, NumericEquality, NumericEpsilon and IgnoreArrayOrder.
+func (n *Node) EqualWithOptions(o *Node, opts *DiffOptions) bool {
+	return n.equalWith(o, "", opts)
+}
+
+func (opts *DiffOptions) lenient() bool {
+	return opts != nil && (len(opts.IgnorePaths) > 0 || opts.NumericEquality || opts.NumericEpsilon > 0 ||
+		opts.IgnoreArrayOrder)
+}
+
+func (opts *DiffOptions) ignored(path string) bool {
+	if opts == nil {
+		return false
+	}
+	for _, p := range opts.IgnorePaths {
+		if hasPathPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// equalWith indicates if the nodes at path are equal with the semantics of the DiffOptions.
+func (n *Node) equalWith(o *Node, path string, opts *DiffOptions) bool {
+	if !opts.lenient() {
+		return n.Equal(o)
+	}
+	if opts.ignored(path) {
+		return true
+	}
+
+	n.intoContainer()
+	o.intoContainer()
+	if n.isNull() || o.isNull() || n.which != o.which {
+		return n.Equal(o)
+	}
+
+	switch n.which {
+	case eDoc:
+		for _, key := range n.doc.keys {
+			p := path + "/" + encodePatchKey(key)
+			if ov, ok := o.doc.obj[key]; ok {
+				if !n.doc.obj[key].equalWith(ov, p, opts) {
+					return false
+				}
+			} else if !opts.ignored(p) {
+				return false
+			}
+		}
+		for _, key := range o.doc.keys {
+			if _, ok := n.doc.obj[key]; !ok && !opts.ignored(path+"/"+encodePatchKey(key)) {
+				return false
+			}
+		}
+		return true
+
+	case eAry:
+		if len(n.ary) != len(o.ary) {
+			return false
+		}
+		if !opts.IgnoreArrayOrder {
+			for i, v := range n.ary {
+				if !v.equalWith(o.ary[i], path+"/"+strconv.Itoa(i), opts) {
+					return false
+				}
+			}
+			return true
+		}
+
+		used := make([]bool, len(o.ary))
+	Next:
+		for i, v := range n.ary {
+			p := path + "/" + strconv.Itoa(i)
+			for j, ov := range o.ary {
+				if !used[j] && v.equalWith(ov, p, opts) {
+					used[j] = true
+					continue Next
+				}
+			}
+			return false
+		}
+		return true
+	}
+
+	if opts.NumericEpsilon > 0 || opts.NumericEquality {
+		if a, ok := n.number(); ok {
+			if b, ok := o.number(); ok {
+				if a.Cmp(b) == 0 {
+					return true
+				}
+				if opts.NumericEpsilon > 0 {
+					d, _ := new(big.Rat).Sub(a, b).Float64()
+					return math.Abs(d) <= opts.NumericEpsilon
+				}
+				return false
+			}
+		}
+	}
+	return n.Equal(o)
+}
+
+// number returns the exact value of the scalar node if it is a number.
+func (n *Node) number() (*big.Rat, bool) {
+	if n.raw == nil {
+		return nil, false
+	}
+	raw := bytes.TrimSpace(*n.raw)
+	if len(raw) == 0 || raw[0] != '-' && (raw[0] < '0' || raw[0] > '9') {
+		return nil, false
+	}
+	return new(big.Rat).SetString(string(raw))
 }
 
 type collector struct {
@@ -54,6 +195,10 @@ func (c *collector) addOp(token string, node *Node) error {
 	return err
 }
 
+func (c *collector) moveOp(fromToken, token string) {
+	c.patch = append(c.patch, Operation{Op: "move", From: c.withPathToken(fromToken), Path: c.withPathToken(token)})
+}
+
 func (c *collector) removeOp(token string) {
 	c.patch = append(c.patch, Operation{Op: "remove", Path: c.withPathToken(token)})
 }
@@ -64,15 +209,124 @@ func (n *Node) Diff(target *Node, opts *DiffOptions) (Patch, error) {
 	if err := n.diff(target, c, opts); err != nil {
 		return nil, err
 	}
+	if opts != nil && (opts.DetectMoves || opts.DetectCopies) {
+		return relocate(n, target, c.patch, opts)
+	}
 	return c.patch, nil
 }
 
+// relocate replaces the pairs of "remove" and "add" operations of the same values in the patch with
+// "move" operations, and the "add" operations of the existing values with "copy" operations.
+// Every replacement is verified by applying the patch to the source.
+func relocate(src, dst *Node, p Patch, opts *DiffOptions) (Patch, error) {
+	doc, err := src.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	verify := func(p Patch) bool {
+		res, err := p.Apply(doc)
+		return err == nil && dst.equalWith(NewNode(res), "", opts)
+	}
+
+	if opts.DetectMoves {
+		tried := make(map[[2]string]bool)
+	Next:
+		for {
+			_, changes, err := p.ApplyWithReport(doc, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			for r, rm := range p {
+				if rm.Op != "remove" {
+					continue
+				}
+				for k, add := range p {
+					key := [2]string{rm.Path, add.Path}
+					if add.Op != "add" || tried[key] || !Equal(changes[r].Old, add.Value) {
+						continue
+					}
+					tried[key] = true
+
+					// the move is tried at the position of the add and of the remove.
+					mv := NewMoveOperation(rm.Path, add.Path)
+					for _, at := range []int{k, r} {
+						c := make(Patch, 0, len(p)-1)
+						for i, op := range p {
+							switch {
+							case i == at:
+								c = append(c, mv)
+							case i != r && i != k:
+								c = append(c, op)
+							}
+						}
+						if verify(c) {
+							p = c
+							continue Next
+						}
+					}
+				}
+			}
+			break
+		}
+	}
+
+	if opts.DetectCopies {
+		// the values can be copied from the source, or from the target if they are added before.
+		var subtrees []*nodePV
+		collectSubtrees(src, "", &subtrees)
+		collectSubtrees(dst, "", &subtrees)
+		for k, add := range p {
+			if add.Op != "add" {
+				continue
+			}
+			v := NewNode(add.Value)
+			if _, err := v.intoContainer(); err != nil {
+				continue
+			}
+			for _, st := range subtrees {
+				if !st.node.Equal(v) {
+					continue
+				}
+				c := make(Patch, len(p))
+				copy(c, p)
+				c[k] = NewCopyOperation(st.pv.Path, add.Path)
+				if verify(c) {
+					p = c
+					break
+				}
+			}
+		}
+	}
+	return p, nil
+}
+
+// collectSubtrees collects the objects and arrays in the node, except the node itself.
+func collectSubtrees(n *Node, path string, res *[]*nodePV) {
+	if _, err := n.intoContainer(); err != nil {
+		return
+	}
+	if path != "" {
+		*res = append(*res, &nodePV{&PV{Path: path}, n})
+	}
+	switch n.which {
+	case eDoc:
+		for _, key := range n.doc.keys {
+			collectSubtrees(n.doc.obj[key], path+"/"+encodePatchKey(key), res)
+		}
+	case eAry:
+		for i, v := range n.ary {
+			collectSubtrees(v, path+"/"+strconv.Itoa(i), res)
+		}
+	}
+}
+
 func (n *Node) diff(target *Node, c *collector, opts *DiffOptions) error {
 	if n == nil || target == nil {
 		return c.replaceOp("", target)
 	}
 
-	if n.Equal(target) {
+	if opts.ignored(c.path) || n.equalWith(target, c.path, opts) {
 		return nil
 	}
 
@@ -88,7 +342,7 @@ func (n *Node) diff(target *Node, c *collector, opts *DiffOptions) error {
 		}
 
 		for _, key := range n.doc.keys {
-			if _, ok := target.doc.obj[key]; !ok {
+			if _, ok := target.doc.obj[key]; !ok && !opts.ignored(c.withPathToken(encodePatchKey(key))) {
 				c.removeOp(encodePatchKey(key))
 			}
 		}
@@ -96,6 +350,7 @@ func (n *Node) diff(target *Node, c *collector, opts *DiffOptions) error {
 		for _, key := range target.doc.keys {
 			node, ok := n.doc.obj[key]
 			switch {
+			case opts.ignored(c.withPathToken(encodePatchKey(key))):
 			case ok:
 				c.pushPathToken(encodePatchKey(key))
 				if err := node.diff(target.doc.obj[key], c, opts); err != nil {
@@ -113,26 +368,192 @@ func (n *Node) diff(target *Node, c *collector, opts *DiffOptions) error {
 		return nil
 	}
 
-	nl := len(n.ary)
-	for i, node := range target.ary {
-		switch {
-		case i < nl:
-			c.pushPathToken(strconv.Itoa(i))
-			if err := n.ary[i].diff(node, c, opts); err != nil {
-				return err
+	return diffArray(n.ary, target.ary, c, opts)
+}
+
+// maxLCSCells limits the number of the comparisons of the elements of the arrays, in the LCS table and
+// in the matching of the moved elements. The arrays with larger LCS tables are compared by their indices
+// after the common prefix and suffix, and the elements beyond the limit of the matching are not moved.
+// It does not limit the work of every comparison, which is proportional to the size of the elements.
+const maxLCSCells = 1 << 22
+
+// diffArray diffs two arrays with their longest common subsequence, so that the inserted and removed
+// elements are emitted as "add" and "remove" operations instead of chains of "replace" operations, and
+// the reordered elements as "move" operations if DiffOptions.DetectMoves is true. The elements are matched
+// by their values, or by the values of the IDKey if it is set. The unmatched elements between two matched
+// ones are diffed in place.
+func diffArray(src, dst partialArray, c *collector, opts *DiffOptions) error {
+	idKey := ""
+	if opts != nil {
+		idKey = opts.IDKey
+	}
+	same := func(i, j int) bool {
+		a, b := src[i], dst[j]
+		if idKey != "" {
+			av, bv := objectID(a, idKey), objectID(b, idKey)
+			if av != nil || bv != nil {
+				return av != nil && bv != nil && av.Equal(bv)
+			}
+		}
+		return a.equalWith(b, c.withPathToken(strconv.Itoa(i)), opts)
+	}
+
+	// match[i] is the index in dst of src[i], or -1.
+	match := make([]int, len(src))
+	for i := range match {
+		match[i] = -1
+	}
+	matched := make([]bool, len(dst))
+
+	// the common prefix and suffix
+	lo, hi, dhi := 0, len(src), len(dst)
+	for lo < hi && lo < dhi && same(lo, lo) {
+		match[lo], matched[lo] = lo, true
+		lo++
+	}
+	for hi > lo && dhi > lo && same(hi-1, dhi-1) {
+		hi, dhi = hi-1, dhi-1
+		match[hi], matched[dhi] = dhi, true
+	}
+
+	// the longest common subsequence of the rest
+	if n, m := hi-lo, dhi-lo; n > 0 && m > 0 && n*m <= maxLCSCells {
+		table := make([][]int32, n+1)
+		for i := range table {
+			table[i] = make([]int32, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				switch {
+				case same(lo+i, lo+j):
+					table[i][j] = table[i+1][j+1] + 1
+				case table[i+1][j] >= table[i][j+1]:
+					table[i][j] = table[i+1][j]
+				default:
+					table[i][j] = table[i][j+1]
+				}
+			}
+		}
+		for i, j := 0, 0; i < n && j < m; {
+			switch {
+			case same(lo+i, lo+j):
+				match[lo+i], matched[lo+j] = lo+j, true
+				i, j = i+1, j+1
+			case table[i+1][j] >= table[i][j+1]:
+				i++
+			default:
+				j++
 			}
-			c.popPathToken()
+		}
+	}
 
+	// moves: the unmatched elements of src that are the same as unmatched elements of dst
+	moved, movedTo := make([]bool, len(src)), make([]bool, len(dst))
+	if opts != nil && opts.DetectMoves {
+		budget := maxLCSCells
+	Moves:
+		for j := range dst {
+			if matched[j] {
+				continue
+			}
+			for i := range src {
+				if match[i] >= 0 {
+					continue
+				}
+				if budget--; budget < 0 {
+					break Moves
+				}
+				if same(i, j) {
+					match[i], matched[j], moved[i], movedTo[j] = j, true, true, true
+					break
+				}
+			}
+		}
+	}
+
+	// the unmatched elements between two matched ones are paired to be diffed in place
+	for i, j := 0, 0; i < len(src) && j < len(dst); {
+		switch {
+		case match[i] >= 0 && !moved[i]:
+			i, j = i+1, match[i]+1
+		case moved[i]:
+			i++
+		case movedTo[j]:
+			j++
+		case matched[j]:
+			// no unmatched elements of dst before the next matched one
+			i++
 		default:
-			if err := c.addOp(strconv.Itoa(i), node); err != nil {
+			match[i], matched[j] = j, true
+			i, j = i+1, j+1
+		}
+	}
+
+	// cur is the array being patched, by the indices of src, or -1 for the added elements.
+	cur := make([]int, 0, len(src))
+	for i := len(src) - 1; i >= 0; i-- {
+		if match[i] < 0 {
+			c.removeOp(strconv.Itoa(i))
+		}
+	}
+	for i := range src {
+		if match[i] >= 0 {
+			cur = append(cur, i)
+		}
+	}
+
+	from := make([]int, len(dst))
+	for j := range from {
+		from[j] = -1
+	}
+	for i, j := range match {
+		if j >= 0 {
+			from[j] = i
+		}
+	}
+
+	for j, node := range dst {
+		i := from[j]
+		if i < 0 {
+			if err := c.addOp(strconv.Itoa(j), node); err != nil {
 				return err
 			}
+			cur = append(cur, 0)
+			copy(cur[j+1:], cur[j:])
+			cur[j] = -1
+			continue
 		}
-	}
 
-	for i := len(target.ary); i < nl; i++ {
-		c.removeOp(strconv.Itoa(i))
+		if p := indexOf(cur[j:], i) + j; p != j {
+			c.moveOp(strconv.Itoa(p), strconv.Itoa(j))
+			copy(cur[j+1:p+1], cur[j:p])
+			cur[j] = i
+		}
+
+		c.pushPathToken(strconv.Itoa(j))
+		if err := src[i].diff(node, c, opts); err != nil {
+			return err
+		}
+		c.popPathToken()
 	}
+	return nil
+}
 
+func objectID(n *Node, key string) *Node {
+	if _, err := n.intoContainer(); err != nil || n.which != eDoc {
+		return nil
+	}
+	if v := n.doc.obj[key]; !v.isNull() {
+		return v
+	}
 	return nil
 }
+
+func indexOf(s []int, v int) int {
+	for i, x := range s {
+		if x == v {
+			return i
+		}
+	}
+	return -1
+}
@@ -177,3 +177,151 @@ func TestAllCasesDiff(t *testing.T) {
 			i, reformatJSON(c.src), reformatJSON(c.dst), reformatJSON(string(out)), mustJSONString(patch))
 	}
 }
+
+func TestDiffArray(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		idKey, src, dst, patch string
+		moves                  bool
+	}{
+		{``, `[1,2,3]`, `[1]`,
+			`[{"op":"remove","path":"/2"},{"op":"remove","path":"/1"}]`, false},
+		{``, `[1,2,3,4,5]`, `[0,1,2,3,4,5]`,
+			`[{"op":"add","path":"/0","value":0}]`, false},
+		{``, `[1,2,3,4,5]`, `[1,2,9,4,5]`,
+			`[{"op":"replace","path":"/2","value":9}]`, false},
+		{``, `[1,2,3,4,5]`, `[1,2,4,5,6]`,
+			`[{"op":"remove","path":"/2"},{"op":"add","path":"/4","value":6}]`, false},
+		{``, `[1,2,3,4,5]`, `[5,1,2,3,4]`,
+			`[{"op":"move","path":"/0","from":"/4"}]`, true},
+		{``, `[1,2,3,4,5]`, `[2,1,4,3,5]`,
+			`[{"op":"move","path":"/0","from":"/1"},{"op":"move","path":"/2","from":"/3"}]`, true},
+		{`id`, `[{"id":1,"v":1},{"id":2,"v":2},{"id":3,"v":3}]`, `[{"id":3,"v":3},{"id":1,"v":1},{"id":2,"v":0}]`,
+			`[{"op":"move","path":"/0","from":"/2"},{"op":"replace","path":"/2/v","value":0}]`, true},
+		{`id`, `[{"id":1},{"id":2}]`, `[{"id":2},{"id":3}]`,
+			`[{"op":"remove","path":"/0"},{"op":"add","path":"/1","value":{"id":3}}]`, false},
+		{``, `[{"a":1},{"b":2}]`, `[{"a":1,"c":3},{"b":2}]`,
+			`[{"op":"add","path":"/0/c","value":3}]`, false},
+		// no "move" operations without DetectMoves.
+		{``, `[1,2,3,4,5]`, `[5,1,2,3,4]`,
+			`[{"op":"remove","path":"/4"},{"op":"add","path":"/0","value":5}]`, false},
+		{``, `[1,2,3,4,5]`, `[2,1,4,3,5]`,
+			`[{"op":"remove","path":"/0"},{"op":"replace","path":"/1","value":1},{"op":"add","path":"/3","value":3}]`, false},
+		{`id`, `[{"id":1,"v":1},{"id":2,"v":2},{"id":3,"v":3}]`, `[{"id":3,"v":3},{"id":1,"v":1},{"id":2,"v":0}]`,
+			`[{"op":"remove","path":"/2"},{"op":"add","path":"/0","value":{"id":3,"v":3}},{"op":"replace","path":"/2/v","value":0}]`, false},
+	}
+	for i, c := range cases {
+		patch, err := Diff([]byte(c.src), []byte(c.dst), &DiffOptions{IDKey: c.idKey, DetectMoves: c.moves})
+		assert.Nil(err)
+		assert.Equal(c.patch, mustJSONString(patch), "case %d", i)
+
+		out, err := patch.Apply([]byte(c.src))
+		assert.Nil(err)
+		assert.True(Equal(out, []byte(c.dst)), "case %d", i)
+	}
+
+	// round trips
+	docs := []string{`[]`, `[1]`, `[1,1,2]`, `[2,1,1]`, `[3,2,1,0]`, `[0,1,2,3]`, `[1,[2],{"a":3},4]`, `[4,{"a":3},[2]]`}
+	for _, a := range docs {
+		for _, b := range docs {
+			for _, opts := range []*DiffOptions{nil, {DetectMoves: true}} {
+				patch, err := Diff([]byte(a), []byte(b), opts)
+				assert.Nil(err)
+				out, err := patch.Apply([]byte(a))
+				assert.Nil(err)
+				assert.True(Equal(out, []byte(b)), "%s -> %s: %s", a, b, mustJSONString(patch))
+				if opts == nil {
+					for _, op := range patch {
+						assert.NotEqual("move", op.Op, "%s -> %s: %s", a, b, mustJSONString(patch))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDiffDetectMovesAndCopies(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		moves, copies bool
+		src, dst      string
+		patch         string
+	}{
+		{false, false, `{"a":{"x":[1,2]},"b":{}}`, `{"b":{"y":{"x":[1,2]}}}`,
+			`[{"op":"remove","path":"/a"},{"op":"add","path":"/b/y","value":{"x":[1,2]}}]`},
+		{true, false, `{"a":{"x":[1,2]},"b":{}}`, `{"b":{"y":{"x":[1,2]}}}`,
+			`[{"op":"move","path":"/b/y","from":"/a"}]`},
+		{true, false, `{"a":1,"list":[{"k":"v"}]}`, `{"list":[],"b":{"k":"v"},"a":1}`,
+			`[{"op":"move","path":"/b","from":"/list/0"}]`},
+		{false, true, `{"a":{"x":[1,2]}}`, `{"a":{"x":[1,2]},"b":{"x":[1,2]},"c":[1,2]}`,
+			`[{"op":"copy","path":"/b","from":"/a"},{"op":"copy","path":"/c","from":"/a/x"}]`},
+		{false, true, `{"a":1}`, `{"a":1,"b":1}`,
+			`[{"op":"add","path":"/b","value":1}]`},
+		{true, true, `{"a":{"x":1},"b":2}`, `{"c":{"x":1},"d":{"x":1},"b":2}`,
+			`[{"op":"move","path":"/c","from":"/a"},{"op":"copy","path":"/d","from":"/c"}]`},
+	}
+	for i, c := range cases {
+		patch, err := Diff([]byte(c.src), []byte(c.dst), &DiffOptions{DetectMoves: c.moves, DetectCopies: c.copies})
+		assert.Nil(err)
+		assert.Equal(c.patch, mustJSONString(patch), "case %d", i)
+
+		out, err := patch.Apply([]byte(c.src))
+		assert.Nil(err)
+		assert.True(Equal(out, []byte(c.dst)), "case %d", i)
+	}
+}
+
+func TestDiffLenientOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	src := `{"name":"a","updated":"2022-01-01","meta":{"rev":1,"v":1.0},"score":0.30000000000000004,"tags":["a","b"]}`
+	dst := `{"name":"b","updated":"2022-02-02","meta":{"rev":2,"v":1.0},"score":0.3,"tags":["b","a"],"etag":"x"}`
+
+	patch, err := Diff([]byte(src), []byte(dst), &DiffOptions{
+		IgnorePaths:      []string{"/updated", "/meta/rev", "/etag"},
+		NumericEpsilon:   1e-9,
+		IgnoreArrayOrder: true,
+	})
+	assert.Nil(err)
+	assert.Equal(`[{"op":"replace","path":"/name","value":"b"}]`, mustJSONString(patch))
+
+	patch, err = Diff([]byte(src), []byte(dst), &DiffOptions{IgnorePaths: []string{"/updated", "/meta"}})
+	assert.Nil(err)
+	assert.Equal(`[{"op":"replace","path":"/name","value":"b"},`+
+		`{"op":"replace","path":"/score","value":0.3},`+
+		`{"op":"remove","path":"/tags/0"},`+
+		`{"op":"add","path":"/tags/1","value":"a"},`+
+		`{"op":"add","path":"/etag","value":"x"}]`, mustJSONString(patch))
+
+	opts := &DiffOptions{IgnorePaths: []string{"/name", "/updated", "/meta/rev", "/etag"}, NumericEpsilon: 1e-9}
+	assert.False(EqualWithOptions([]byte(src), []byte(dst), opts))
+	opts.IgnoreArrayOrder = true
+	assert.True(EqualWithOptions([]byte(src), []byte(dst), opts))
+	assert.True(EqualWithOptions([]byte(dst), []byte(src), opts))
+	assert.False(EqualWithOptions([]byte(src), []byte(dst), nil))
+
+	assert.True(EqualWithOptions([]byte(`[1,[2,3],1]`), []byte(`[[3,2],1,1]`), &DiffOptions{IgnoreArrayOrder: true}))
+	assert.False(EqualWithOptions([]byte(`[1,1,2]`), []byte(`[1,2,2]`), &DiffOptions{IgnoreArrayOrder: true}))
+	assert.True(EqualWithOptions([]byte(`1`), []byte(`1.05`), &DiffOptions{NumericEpsilon: 0.1}))
+	assert.False(EqualWithOptions([]byte(`1`), []byte(`"1"`), &DiffOptions{NumericEpsilon: 0.1}))
+}
+
+func TestEqualNumericEquality(t *testing.T) {
+	assert := assert.New(t)
+
+	opts := &DiffOptions{NumericEquality: true}
+	assert.True(EqualWithOptions([]byte(`1`), []byte(`1.0`), opts))
+	assert.True(EqualWithOptions([]byte(`1`), []byte(`1e0`), opts))
+	assert.True(EqualWithOptions([]byte(`{"a":[10, -0.5]}`), []byte(`{"a":[1E1, -5e-1]}`), opts))
+	assert.True(EqualWithOptions([]byte(`12345678901234567890`), []byte(`1.2345678901234567890e19`), opts))
+	assert.False(EqualWithOptions([]byte(`12345678901234567890`), []byte(`12345678901234567891`), opts))
+	assert.False(EqualWithOptions([]byte(`1`), []byte(`"1"`), opts))
+	assert.False(EqualWithOptions([]byte(`1`), []byte(`1.0`), nil))
+	assert.False(Equal([]byte(`1`), []byte(`1.0`)))
+
+	patch, err := Diff([]byte(`{"a":1,"b":2}`), []byte(`{"a":1.0,"b":2.5}`), opts)
+	assert.Nil(err)
+	assert.Equal(`[{"op":"replace","path":"/b","value":2.5}]`, mustJSONString(patch))
+}
@@ -0,0 +1,10 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package jsonpatch provides functionality for applying RFC 6902 JSON patches on JSON documents,
+// creating patches by diffing documents, and querying documents with JSON Pointers.
+//
+// Malformed documents, patches and paths never cause a panic, they are reported as errors.
+// A panic raised by a custom operation handler is not recovered unless Options.RecoverPanics is true,
+// in which case it is returned as a *PanicError with the index and the path of the operation.
+package jsonpatch
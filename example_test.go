@@ -151,3 +151,24 @@ func ExampleNode_FindChildren() {
 	// Path: "/1/1/3", Value: ["span", {"data-type": "leaf"}, "Hello 2"]
 	// Path: "/1/1/4", Value: ["span", {"data-type": "leaf"}, "Hello 3"]
 }
+
+func ExampleNode_Query() {
+	doc := []byte(`{"books": [
+		{"title": "Sayings", "price": 8.95},
+		{"title": "Sword", "price": 12.99},
+		{"title": "Moby Dick", "price": 8.99}
+	]}`)
+
+	node := NewNode(doc)
+	result, err := node.Query(`$.books[?@.price < 10].title`)
+	if err != nil {
+		panic(err)
+	}
+	for _, r := range result {
+		fmt.Printf("Path: \"%s\", Value: %s\n", r.Path, string(r.Value))
+	}
+
+	// Output:
+	// Path: "/books/0/title", Value: "Sayings"
+	// Path: "/books/2/title", Value: "Moby Dick"
+}
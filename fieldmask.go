@@ -0,0 +1,161 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FieldMaskToMergePatch converts the paths of a google.protobuf.FieldMask into a JSON merge patch (RFC 7386)
+// that updates the fields of the mask with their values in the JSON object src, as the update methods of gRPC APIs.
+// The fields of the mask missing in src are set to null, so the patch clears them.
+//
+// The paths are dot separated field names, such as "user.display_name", and the field names are used
+// as the member names of the JSON objects without case conversion, so they should match the JSON encoding of src.
+func FieldMaskToMergePatch(mask []string, src []byte) ([]byte, error) {
+	if !json.Valid(src) {
+		return nil, errors.New("invalid JSON document")
+	}
+	node := NewNode(src)
+	if node.intoContainer(); node.which != eDoc {
+		return nil, fmt.Errorf("unexpected source %q, should be an object", node.String())
+	}
+
+	options := NewOptions()
+	res := &Node{doc: &partialDoc{obj: make(map[string]*Node, len(mask))}, which: eDoc}
+	// objects created for the parents of the fields, the other values in res are the fields of the mask.
+	parents := map[*Node]bool{res: true}
+	for _, path := range mask {
+		fields, err := fieldMaskPath(path)
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]string, len(fields))
+		for i, f := range fields {
+			keys[i] = encodePatchKey(f)
+		}
+		v, err := node.GetChild("/"+strings.Join(keys, "/"), options)
+		if err != nil {
+			v = nil
+		}
+
+		cur := res
+		for i, f := range fields {
+			if i == len(fields)-1 {
+				cur.doc.set(f, v, options)
+				break
+			}
+			child, ok := cur.doc.obj[f]
+			if !ok {
+				child = &Node{doc: &partialDoc{obj: make(map[string]*Node)}, which: eDoc}
+				parents[child] = true
+				cur.doc.set(f, child, options)
+			} else if !parents[child] {
+				// the field of a shorter path covers this path
+				break
+			}
+			cur = child
+		}
+	}
+	return res.MarshalJSON()
+}
+
+// PatchToFieldMask returns the paths of a google.protobuf.FieldMask with the fields changed by the JSON patch,
+// so that the patch can be sent to a gRPC update method. The paths are sorted, and the paths covered by their
+// parents are omitted.
+//
+// A field mask can not address array elements, so the paths into arrays are truncated to the arrays:
+// the tokens that are array indices ("0", "1", ..., "-") are taken as array indices, not object members.
+// The "test" operations and the JSON Predicate operations change nothing and are skipped,
+// an error is returned for the other operations, for the root path, and for members with "." in their names.
+func PatchToFieldMask(p Patch) ([]string, error) {
+	var paths []string
+	for i, op := range p {
+		var ptrs []string
+		switch op.Op {
+		case "add", "remove", "replace", "copy":
+			ptrs = []string{op.Path}
+		case "move":
+			ptrs = []string{op.From, op.Path}
+		case "test":
+		default:
+			if !predicateOperations[op.Op] {
+				return nil, &PathError{Op: op.Op, Path: op.Path, Index: i,
+					Err: fmt.Errorf("unexpected operation %q", op.Op)}
+			}
+		}
+		for _, ptr := range ptrs {
+			path, err := pointerToFieldMaskPath(ptr)
+			if err != nil {
+				return nil, &PathError{Op: op.Op, Path: op.Path, Index: i, Err: err}
+			}
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+	res := make([]string, 0, len(paths))
+	for _, path := range paths {
+		// the parents sort before their children, so only the last kept path can cover this path.
+		if n := len(res); n > 0 && (res[n-1] == path || strings.HasPrefix(path, res[n-1]+".")) {
+			continue
+		}
+		res = append(res, path)
+	}
+	return res, nil
+}
+
+// fieldMaskPath splits the field mask path into field names.
+func fieldMaskPath(path string) ([]string, error) {
+	fields := strings.Split(path, ".")
+	for _, f := range fields {
+		if f == "" {
+			return nil, fmt.Errorf("invalid field mask path %q", path)
+		}
+	}
+	return fields, nil
+}
+
+// pointerToFieldMaskPath converts the JSON pointer into a field mask path, truncated at the first array index.
+func pointerToFieldMaskPath(ptr string) (string, error) {
+	if ptr == "" || ptr[0] != '/' {
+		return "", fmt.Errorf("path %q can not be converted to a field mask path", ptr)
+	}
+
+	var fields []string
+	for _, key := range strings.Split(ptr[1:], "/") {
+		if isArrayIndex(key) {
+			break
+		}
+		f := decodePatchKey(key)
+		if f == "" || strings.Contains(f, ".") {
+			return "", fmt.Errorf("path %q can not be converted to a field mask path", ptr)
+		}
+		fields = append(fields, f)
+	}
+	if len(fields) == 0 {
+		return "", fmt.Errorf("path %q can not be converted to a field mask path", ptr)
+	}
+	return strings.Join(fields, "."), nil
+}
+
+// isArrayIndex returns true if the token of a JSON pointer is an array index or "-".
+func isArrayIndex(key string) bool {
+	if key == "-" {
+		return true
+	}
+	if key == "" || (key[0] == '0' && len(key) > 1) {
+		return false
+	}
+	for _, c := range key {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldMaskToMergePatch(t *testing.T) {
+	assert := assert.New(t)
+
+	src := []byte(`{"name":"a","user":{"display_name":"b","email":"c","tags":["x"]},"a.b":1}`)
+	for i, c := range []struct {
+		mask   []string
+		result string
+	}{
+		{nil, `{}`},
+		{[]string{"name"}, `{"name":"a"}`},
+		{[]string{"user.display_name", "user.tags"}, `{"user":{"display_name":"b","tags":["x"]}}`},
+		{[]string{"user.display_name", "user"}, `{"user":{"display_name":"b","email":"c","tags":["x"]}}`},
+		{[]string{"user", "user.email"}, `{"user":{"display_name":"b","email":"c","tags":["x"]}}`},
+		{[]string{"age", "user.phone"}, `{"age":null,"user":{"phone":null}}`},
+		{[]string{"name.first"}, `{"name":{"first":null}}`},
+	} {
+		res, err := FieldMaskToMergePatch(c.mask, src)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.result, string(res), "case %d", i)
+	}
+
+	_, err := FieldMaskToMergePatch([]string{"user..email"}, src)
+	assert.ErrorContains(err, `invalid field mask path "user..email"`)
+	_, err = FieldMaskToMergePatch([]string{"name"}, []byte(`[]`))
+	assert.ErrorContains(err, "should be an object")
+	_, err = FieldMaskToMergePatch([]string{"name"}, []byte(`{`))
+	assert.ErrorContains(err, "invalid JSON document")
+}
+
+func TestPatchToFieldMask(t *testing.T) {
+	assert := assert.New(t)
+
+	for i, c := range []struct {
+		patch string
+		mask  []string
+	}{
+		{`[]`, []string{}},
+		{`[{"op":"replace","path":"/name","value":"a"}]`, []string{"name"}},
+		{`[
+			{"op":"test","path":"/user/email","value":"c"},
+			{"op":"add","path":"/user/tags/-","value":"y"},
+			{"op":"remove","path":"/user/tags/0"},
+			{"op":"replace","path":"/user/display_name","value":"b"},
+			{"op":"move","from":"/old","path":"/new/a~1b"}
+		]`, []string{"new.a/b", "old", "user.display_name", "user.tags"}},
+		{`[
+			{"op":"remove","path":"/user/email"},
+			{"op":"copy","from":"/name","path":"/user"},
+			{"op":"add","path":"/users/01","value":1}
+		]`, []string{"user", "users.01"}},
+	} {
+		p, err := NewPatch([]byte(c.patch))
+		assert.Nil(err, "case %d", i)
+		mask, err := PatchToFieldMask(p)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.mask, mask, "case %d", i)
+	}
+
+	for i, c := range []struct {
+		patch, err string
+	}{
+		{`[{"op":"replace","path":"","value":{}}]`, `path "" can not be converted to a field mask path`},
+		{`[{"op":"remove","path":"/0"}]`, `path "/0" can not be converted to a field mask path`},
+		{`[{"op":"remove","path":"/a.b"}]`, `path "/a.b" can not be converted to a field mask path`},
+		{`[{"op":"inc","path":"/a"}]`, `unexpected operation "inc"`},
+	} {
+		p, err := NewPatch([]byte(c.patch))
+		assert.Nil(err, "case %d", i)
+		_, err = PatchToFieldMask(p)
+		assert.ErrorContains(err, c.err, "case %d", i)
+	}
+}
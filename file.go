@@ -0,0 +1,80 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// FileOptions specifies options for ApplyToFile.
+type FileOptions struct {
+	// PatchOptions is used to apply the patch. Default to nil, which means NewOptions().
+	PatchOptions *Options
+	// PreserveIndent instructs ApplyToFile to keep the format of the file, see Patch.ApplyPreservingFormat.
+	// Default to false, which means the document is written compact.
+	PreserveIndent bool
+}
+
+// ApplyToFile applies the patch to the JSON file at path, such as a config file. The patched document
+// is written to a temporary file in the same directory, which is then renamed to the file, so the file
+// is either patched or left untouched, never partially written. The mode of the file is kept.
+func ApplyToFile(path string, patch Patch, options *FileOptions) error {
+	if options == nil {
+		options = &FileOptions{}
+	}
+	opts := options.PatchOptions
+	if opts == nil {
+		opts = NewOptions()
+	}
+
+	// the target of a symlink is replaced, not the symlink.
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	doc, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var res []byte
+	if options.PreserveIndent {
+		res, err = patch.ApplyPreservingFormat(doc, opts)
+	} else {
+		res, err = patch.ApplyWithOptions(doc, opts)
+	}
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, res, info.Mode().Perm())
+}
+
+// writeFileAtomic writes the data to a temporary file in the directory of the file, and renames it to the file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
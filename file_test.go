@@ -0,0 +1,67 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyToFile(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	p, err := NewPatch([]byte(`[{"op": "replace", "path": "/port", "value": 8080}, {"op": "add", "path": "/tags/-", "value": "b"}]`))
+	assert.Nil(err)
+
+	for i, c := range []struct {
+		doc, res string
+		preserve bool
+	}{
+		{"{\n  \"port\": 80,\n  \"tags\": [\"a\"]\n}\n",
+			"{\n  \"port\": 8080,\n  \"tags\": [\n    \"a\",\n    \"b\"\n  ]\n}\n", true},
+		{"{\n\t\"port\": 80,\n\n\t\"tags\": []\n}",
+			"{\n\t\"port\": 8080,\n\t\"tags\": [\n\t\t\"b\"\n\t]\n}", true},
+		{`{"port":80,"tags":[]}` + "\n", `{"port":8080,"tags":["b"]}` + "\n", true},
+		{"{\n  \"port\": 80,\n  \"tags\": [\"a\"]\n}\n", `{"port":8080,"tags":["a","b"]}`, false},
+	} {
+		name := filepath.Join(dir, "config.json")
+		assert.Nil(os.WriteFile(name, []byte(c.doc), 0640), "case %d", i)
+		assert.Nil(ApplyToFile(name, p, &FileOptions{PreserveIndent: c.preserve}), "case %d", i)
+		data, err := os.ReadFile(name)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.res, string(data), "case %d", i)
+		info, err := os.Stat(name)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(os.FileMode(0640), info.Mode().Perm(), "case %d", i)
+	}
+
+	// the file is left untouched if the patch fails.
+	name := filepath.Join(dir, "config.json")
+	assert.Nil(os.WriteFile(name, []byte(`{"port":80}`), 0600))
+	assert.ErrorIs(ApplyToFile(name, p, nil), ErrMissing)
+	data, err := os.ReadFile(name)
+	assert.Nil(err)
+	assert.Equal(`{"port":80}`, string(data))
+
+	// the target of a symlink is patched.
+	link := filepath.Join(dir, "link.json")
+	assert.Nil(os.Symlink(name, link))
+	assert.Nil(ApplyToFile(link, Patch{{Op: "remove", Path: "/port"}}, nil))
+	data, err = os.ReadFile(name)
+	assert.Nil(err)
+	assert.Equal(`{}`, string(data))
+	info, err := os.Lstat(link)
+	assert.Nil(err)
+	assert.Equal(os.ModeSymlink, info.Mode()&os.ModeSymlink)
+
+	entries, err := os.ReadDir(dir)
+	assert.Nil(err)
+	assert.Equal(2, len(entries))
+
+	assert.NotNil(ApplyToFile(filepath.Join(dir, "missing.json"), p, nil))
+}
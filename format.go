@@ -0,0 +1,162 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"bytes"
+	"strings"
+)
+
+// MarshalIndent returns the indented JSON encoding of the node, with the layout of json.MarshalIndent
+// without prefix. The values of the original document that have not been modified are written as they are,
+// with their own whitespace, only the parsed containers and the values of the operations are laid out with
+// the indent. With the indent of the original document, such as returned by DetectIndent, a patched
+// document differs from the original only at the modified values, for minimal diffs of the config files.
+// An empty indent means the compact encoding, as MarshalJSON.
+func (n *Node) MarshalIndent(indent string) ([]byte, error) {
+	if indent == "" {
+		return n.MarshalJSON()
+	}
+
+	w := getWriter()
+	defer putWriter(w)
+	if err := n.writeIndent(w, indent, 0); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), w.Bytes()...), nil
+}
+
+// ApplyPreservingFormat mutates a JSON document according to the patch and the passed in Options,
+// and returns the new document in the format of the original: the unmodified values are kept as they are,
+// the modified ones are indented with the indent of the document (see DetectIndent and Node.MarshalIndent)
+// and the trailing newline of the document is kept. A compact document is returned compact.
+func (p Patch) ApplyPreservingFormat(doc []byte, options *Options) ([]byte, error) {
+	node := NewNode(doc)
+	if err := node.Patch(p, options); err != nil {
+		return nil, err
+	}
+	res, err := node.MarshalIndent(DetectIndent(doc))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasSuffix(doc, []byte("\n")) {
+		res = append(res, '\n')
+	}
+	options.stats().patched(doc, res)
+	return res, nil
+}
+
+// DetectIndent returns the indentation of the first indented line of the JSON document,
+// or "" if the document is compact.
+func DetectIndent(doc []byte) string {
+	for {
+		i := bytes.IndexByte(doc, '\n')
+		if i < 0 {
+			return ""
+		}
+		doc = doc[i+1:]
+		j := 0
+		for j < len(doc) && (doc[j] == ' ' || doc[j] == '\t') {
+			j++
+		}
+		if j > 0 && j < len(doc) && doc[j] != '\n' && doc[j] != '\r' {
+			return string(doc[:j])
+		}
+	}
+}
+
+// writeIndent writes the indented JSON encoding of the node at the depth.
+func (n *Node) writeIndent(w *jsonWriter, indent string, depth int) error {
+	if n == nil {
+		w.WriteString("null")
+		return nil
+	}
+
+	switch n.which {
+	case eRaw, eOther:
+		if n.raw == nil {
+			return ErrInvalid
+		}
+		if err := n.checkValid(); err != nil {
+			return err
+		}
+		raw := bytes.TrimSpace(*n.raw)
+		if checkWhich(raw) == eOther {
+			w.Write(raw)
+			return nil
+		}
+		if !n.reformat && !needsParse(raw) {
+			w.Write(raw)
+			return nil
+		}
+		// the values of the operations, and the objects with duplicate or escaped keys, are laid out.
+		if _, err := n.intoContainer(); err != nil {
+			return err
+		}
+		return n.writeIndent(w, indent, depth)
+
+	case eDoc:
+		if len(n.doc.keys) == 0 {
+			w.WriteString("{}")
+			return nil
+		}
+		w.WriteByte('{')
+		for i, k := range n.doc.keys {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeNewline(w, indent, depth+1)
+			if err := w.writeString(k); err != nil {
+				return err
+			}
+			w.WriteString(": ")
+			if err := n.doc.obj[k].writeIndent(w, indent, depth+1); err != nil {
+				return err
+			}
+		}
+		writeNewline(w, indent, depth)
+		w.WriteByte('}')
+		return nil
+
+	case eAry:
+		if len(n.ary) == 0 {
+			w.WriteString("[]")
+			return nil
+		}
+		w.WriteByte('[')
+		for i, v := range n.ary {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeNewline(w, indent, depth+1)
+			if err := v.writeIndent(w, indent, depth+1); err != nil {
+				return err
+			}
+		}
+		writeNewline(w, indent, depth)
+		w.WriteByte(']')
+		return nil
+	}
+	return ErrInvalid
+}
+
+// needsParse returns true if an object of the valid JSON data has duplicate or escaped keys,
+// which are written by their last members as MarshalJSON.
+func needsParse(data []byte) bool {
+	w := getWriter()
+	defer putWriter(w)
+	return !w.writeRaw(data)
+}
+
+func writeNewline(w *jsonWriter, indent string, depth int) {
+	w.WriteByte('\n')
+	w.WriteString(strings.Repeat(indent, depth))
+}
+
+// setReformat marks the child of a value of an operation to be laid out by MarshalIndent.
+func (n *Node) setReformat() {
+	if n != nil {
+		n.reformat = true
+	}
+}
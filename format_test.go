@@ -0,0 +1,152 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectIndent(t *testing.T) {
+	assert := assert.New(t)
+
+	for i, c := range []struct {
+		doc, indent string
+	}{
+		{`{"a":1}`, ""},
+		{"{\n\"a\": 1\n}", ""},
+		{"{\n  \"a\": 1\n}", "  "},
+		{"{\n\n    \"a\": 1\n}", "    "},
+		{"{\r\n\t\"a\": 1\r\n}", "\t"},
+		{"[\n  \n   1]", "   "},
+	} {
+		assert.Equal(c.indent, DetectIndent([]byte(c.doc)), "case %d", i)
+	}
+}
+
+func TestApplyPreservingFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := `{
+  "name": "app",
+  "ports": [80,  443],
+  "db": {
+    "host": "localhost",
+    "opts": {"ssl": true}
+  },
+  "tags": [
+    "a"
+  ]
+}
+`
+	for i, c := range []struct {
+		patch, res string
+	}{
+		{`[]`, doc},
+		{`[{"op": "replace", "path": "/name", "value": "web"}]`, `{
+  "name": "web",
+  "ports": [80,  443],
+  "db": {
+    "host": "localhost",
+    "opts": {"ssl": true}
+  },
+  "tags": [
+    "a"
+  ]
+}
+`},
+		{`[{"op": "add", "path": "/db/port", "value": {"tcp":5432, "tls": [1,  2]}}]`, `{
+  "name": "app",
+  "ports": [80,  443],
+  "db": {
+    "host": "localhost",
+    "opts": {"ssl": true},
+    "port": {
+      "tcp": 5432,
+      "tls": [
+        1,
+        2
+      ]
+    }
+  },
+  "tags": [
+    "a"
+  ]
+}
+`},
+		{`[{"op": "add", "path": "/tags/-", "value": "b"}, {"op": "remove", "path": "/ports"}]`, `{
+  "name": "app",
+  "db": {
+    "host": "localhost",
+    "opts": {"ssl": true}
+  },
+  "tags": [
+    "a",
+    "b"
+  ]
+}
+`},
+		{`[{"op": "copy", "from": "/db/opts", "path": "/opts"}, {"op": "add", "path": "/empty", "value": {}}]`, `{
+  "name": "app",
+  "ports": [80,  443],
+  "db": {
+    "host": "localhost",
+    "opts": {"ssl": true}
+  },
+  "tags": [
+    "a"
+  ],
+  "opts": {"ssl":true},
+  "empty": {}
+}
+`},
+	} {
+		p, err := NewPatch([]byte(c.patch))
+		assert.Nil(err, "case %d", i)
+		res, err := p.ApplyPreservingFormat([]byte(doc), nil)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.res, string(res), "case %d", i)
+		assert.True(Equal([]byte(c.res), res), "case %d", i)
+	}
+
+	p, err := NewPatch([]byte(`[{"op": "add", "path": "/b", "value": {"c": [1]}}]`))
+	assert.Nil(err)
+	res, err := p.ApplyPreservingFormat([]byte(`{"a": [1, 2]}`), nil)
+	assert.Nil(err)
+	assert.Equal(`{"a":[1,2],"b":{"c":[1]}}`, string(res))
+
+	// the duplicate keys are written by the last members.
+	res, err = p.ApplyPreservingFormat([]byte("{\n\t\"a\": {\"x\": 1, \"x\": 2}\n}"), nil)
+	assert.Nil(err)
+	assert.Equal("{\n\t\"a\": {\n\t\t\"x\": 2\n\t},\n\t\"b\": {\n\t\t\"c\": [\n\t\t\t1\n\t\t]\n\t}\n}", string(res))
+
+	_, err = p.ApplyPreservingFormat([]byte(`{"a": [1, 2]`), nil)
+	assert.NotNil(err)
+}
+
+func TestNodeMarshalIndent(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(` {"a": [1, {"b":null}], "c": "<"} `))
+	data, err := node.MarshalIndent("  ")
+	assert.Nil(err)
+	assert.Equal(`{"a": [1, {"b":null}], "c": "<"}`, string(data))
+
+	data, err = node.MarshalIndent("")
+	assert.Nil(err)
+	assert.Equal(`{"a":[1,{"b":null}],"c":"\u003c"}`, string(data))
+
+	assert.Nil(node.Patch(Patch{{Op: "remove", Path: "/a/1/b"}}, nil))
+	data, err = node.MarshalIndent("  ")
+	assert.Nil(err)
+	assert.Equal("{\n  \"a\": [\n    1,\n    {}\n  ],\n  \"c\": \"<\"\n}", string(data))
+
+	data, err = NewNode(nil).MarshalIndent("  ")
+	assert.Nil(err)
+	assert.Equal(`null`, string(data))
+
+	_, err = NewNode([]byte(`{"a":`)).MarshalIndent("  ")
+	assert.NotNil(err)
+}
@@ -0,0 +1,110 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Freeze parses the node and all its descendants, and makes them read-only, so that the reads of the node,
+// such as GetChild, GetValue, Has, FindChildren and MarshalJSON, can be called by multiple goroutines
+// concurrently. The patches of the frozen node and its children fail with ErrFrozen, also when a frozen child
+// is patched or redacted through its parent that is not frozen, use Clone to get a mutable copy. It returns the error of the invalid JSON in the node.
+func (n *Node) Freeze() error {
+	if n == nil || n.frozen {
+		return nil
+	}
+	if err := n.freeze(); err != nil {
+		return err
+	}
+	// the lookup cache is created before the concurrent reads.
+	n.lookups = &lookupTable{}
+	return nil
+}
+
+func (n *Node) freeze() error {
+	if n == nil || n.frozen {
+		return nil
+	}
+
+	if n.which == eRaw && n.raw != nil {
+		if _, err := n.intoContainer(); err != nil && err != ErrInvalid {
+			return err
+		}
+	}
+
+	switch n.which {
+	case eDoc:
+		for _, v := range n.doc.obj {
+			if err := v.freeze(); err != nil {
+				return err
+			}
+		}
+	case eAry:
+		for _, v := range n.ary {
+			if err := v.freeze(); err != nil {
+				return err
+			}
+		}
+	default:
+		if n.raw != nil {
+			if err := n.checkValid(); err != nil {
+				return err
+			}
+		}
+	}
+	n.frozen = true
+	return nil
+}
+
+// cloneShared returns a deep copy of the node as Clone, but the frozen descendants are shared,
+// they are read-only, so that the patches of them fail on the copy as on the node.
+func (n *Node) cloneShared() *Node {
+	if n == nil || n.frozen {
+		return n
+	}
+	return n.copyWith((*Node).cloneShared)
+}
+
+// checkFrozen returns ErrFrozen if the operation changes a frozen descendant of the document.
+func checkFrozen(pd container, op Operation, options *Options) error {
+	if op.Op == "test" || predicateOperations[op.Op] {
+		return nil
+	}
+	if frozenParent(pd, op.Path, options) || op.Op == "move" && frozenParent(pd, op.From, options) {
+		return fmt.Errorf("%s operation does not apply for %q, %w", op.Op, op.Path, ErrFrozen)
+	}
+	return nil
+}
+
+// frozenParent indicates if the parent of the path in the document is a frozen node.
+// The frozen nodes have frozen descendants, so the walk stops at the first one.
+func frozenParent(pd container, path string, options *Options) bool {
+	if path == "" || path[0] != '/' {
+		return false
+	}
+	doc := pd
+	for {
+		i := strings.IndexByte(path[1:], '/')
+		if i < 0 {
+			return false
+		}
+		key := path[1 : i+1]
+		if strings.IndexByte(key, '~') >= 0 {
+			key = decodePatchKey(key)
+		}
+		next, err := doc.get(key, options)
+		if err != nil || next == nil {
+			return false
+		}
+		if next.frozen {
+			return true
+		}
+		if doc, _ = next.intoContainer(); doc == nil {
+			return false
+		}
+		path = path[i+1:]
+	}
+}
@@ -0,0 +1,125 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeFreeze(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(`{"a":{"b":[1,{"c":"x"}]},"d":null}`))
+	assert.NoError(node.Freeze())
+	assert.NoError(node.Freeze())
+
+	val, err := node.GetValue("/a/b/1/c", nil)
+	assert.NoError(err)
+	assert.Equal(`"x"`, string(val))
+
+	err = node.Patch(Patch{{Op: "remove", Path: "/d"}}, nil)
+	assert.ErrorIs(err, ErrFrozen)
+	err = node.PatchAtomic(Patch{{Op: "remove", Path: "/d"}}, nil)
+	assert.ErrorIs(err, ErrFrozen)
+	err = node.UnmarshalJSON([]byte(`{}`))
+	assert.ErrorIs(err, ErrFrozen)
+	_, err = node.Remove("/a", nil)
+	assert.ErrorIs(err, ErrFrozen)
+
+	child, err := node.GetChild("/a/b", nil)
+	assert.NoError(err)
+	err = child.Patch(Patch{{Op: "add", Path: "/-", Value: []byte(`2`)}}, nil)
+	assert.ErrorIs(err, ErrFrozen)
+
+	data, err := node.MarshalJSON()
+	assert.NoError(err)
+	assert.Equal(`{"a":{"b":[1,{"c":"x"}]},"d":null}`, string(data))
+
+	// the clone is mutable.
+	c := node.Clone()
+	assert.NoError(c.Patch(Patch{{Op: "remove", Path: "/d"}}, nil))
+	data, err = c.MarshalJSON()
+	assert.NoError(err)
+	assert.Equal(`{"a":{"b":[1,{"c":"x"}]}}`, string(data))
+
+	for i, doc := range []string{`{"a":[1,}`, `{"a":{"b":tru}}`, `[1,2`, `--1`} {
+		assert.Error(NewNode([]byte(doc)).Freeze(), "case %d", i)
+	}
+	for i, doc := range []string{`null`, `1`, `"x"`, `[]`, `{}`} {
+		assert.NoError(NewNode([]byte(doc)).Freeze(), "case %d", i)
+	}
+}
+
+func TestNodeFreezeChild(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(`{"a":{"b":"pii","l":[1]},"c":1}`))
+	child, err := node.GetChild("/a", nil)
+	assert.NoError(err)
+	assert.NoError(child.Freeze())
+
+	for i, p := range []Patch{
+		{{Op: "add", Path: "/a/x", Value: []byte(`1`)}},
+		{{Op: "remove", Path: "/a/b"}},
+		{{Op: "replace", Path: "/a/l/0", Value: []byte(`2`)}},
+		{{Op: "move", From: "/a/b", Path: "/x"}},
+		{{Op: "copy", From: "/c", Path: "/a/x"}},
+	} {
+		assert.ErrorIs(node.Patch(p, nil), ErrFrozen, "case %d", i)
+		assert.ErrorIs(node.PatchAtomic(p, nil), ErrFrozen, "case %d", i)
+	}
+	_, err = node.Redact([]string{"/a/b"}, nil, nil)
+	assert.ErrorIs(err, ErrFrozen)
+	_, err = node.RedactChildren([]*PV{{Path: "/c", Value: []byte(`1`)}}, []string{"/a/b"}, nil, nil)
+	assert.ErrorIs(err, ErrFrozen)
+
+	data, err := child.MarshalJSON()
+	assert.NoError(err)
+	assert.Equal(`{"b":"pii","l":[1]}`, string(data))
+
+	// the frozen child is read, copied and replaced as a whole.
+	assert.NoError(node.Patch(Patch{
+		{Op: "test", Path: "/a/b", Value: []byte(`"pii"`)},
+		{Op: "copy", From: "/a/b", Path: "/d"},
+		{Op: "replace", Path: "/a", Value: []byte(`{}`)},
+	}, nil))
+	data, err = node.MarshalJSON()
+	assert.NoError(err)
+	assert.Equal(`{"a":{},"c":1,"d":"pii"}`, string(data))
+	data, err = child.MarshalJSON()
+	assert.NoError(err)
+	assert.Equal(`{"b":"pii","l":[1]}`, string(data))
+}
+
+func TestNodeFreezeConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(`{"items":[{"n":0,"tag":"a"},{"n":1,"tag":"b"},{"n":2,"tag":"a"}],"meta":{"count":3}}`))
+	assert.NoError(node.Freeze())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			val, err := node.GetValue(fmt.Sprintf("/items/%d/n", i%3), nil)
+			assert.NoError(err)
+			assert.Equal(fmt.Sprint(i%3), string(val))
+			assert.True(node.Has("/meta/count", nil))
+
+			pvs, err := node.FindChildren([]*PV{{Path: "/tag", Value: []byte(`"a"`)}}, nil)
+			assert.NoError(err)
+			assert.Len(pvs, 2)
+
+			data, err := node.MarshalJSON()
+			assert.NoError(err)
+			assert.Contains(string(data), `"meta":{"count":3}`)
+		}(i)
+	}
+	wg.Wait()
+}
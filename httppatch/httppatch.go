@@ -0,0 +1,220 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package httppatch implements the boilerplate of HTTP PATCH endpoints around the jsonpatch package.
+//
+// The Middleware inspects the Content-Type of the requests, reads and validates JSON patches (RFC 6902)
+// and JSON merge patches (RFC 7386) with size limits, and passes them to the handler in the request context.
+// The handler loads the document, applies the patch to it with Apply, and writes the errors with WriteError:
+//
+//	http.Handle("/items/", httppatch.Middleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+//		doc := loadItem(r)
+//		res, err := httppatch.Apply(r, doc)
+//		if err != nil {
+//			httppatch.WriteError(w, err)
+//			return
+//		}
+//		saveItem(r, res)
+//		w.Header().Set("Content-Type", "application/json")
+//		w.Write(res)
+//	})))
+package httppatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	jsonpatch "github.com/ldclabs/json-patch"
+)
+
+// The media types of the patch documents.
+const (
+	ContentTypeJSONPatch  = "application/json-patch+json"
+	ContentTypeMergePatch = "application/merge-patch+json"
+)
+
+// DefaultMaxBodyBytes is the size limit of the request bodies if Options.MaxBodyBytes is 0.
+const DefaultMaxBodyBytes int64 = 1 << 20
+
+// acceptPatch is the value of the Accept-Patch header (RFC 5789) of the responses.
+var acceptPatch = ContentTypeJSONPatch + ", " + ContentTypeMergePatch
+
+// ErrUnsupportedMediaType is returned by Apply when the request has no patch decoded by the Middleware.
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// Options specifies options for the Middleware.
+type Options struct {
+	// MaxBodyBytes limits the size of the request bodies, larger bodies are rejected with 413.
+	// Default to 0, which means DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+	// PatchOptions is used to validate and apply the JSON patches, its limits are checked
+	// when the patches are decoded. Default to nil, which means jsonpatch.NewOptions().
+	PatchOptions *jsonpatch.Options
+	// MergeOptions is used to apply the JSON merge patches. Default to nil.
+	MergeOptions *jsonpatch.MergeOptions
+}
+
+// Patch is a patch decoded from the body of a request.
+type Patch struct {
+	// ContentType is the media type of the patch, ContentTypeJSONPatch or ContentTypeMergePatch.
+	ContentType string
+	// Body is the body of the request.
+	Body []byte
+	// JSONPatch is the decoded JSON patch, it is nil for merge patches.
+	JSONPatch jsonpatch.Patch
+
+	options *Options
+}
+
+type contextKey struct{}
+
+// Middleware returns a middleware that decodes the patches of the PATCH requests.
+// The requests with other media types are rejected with 415 and the Accept-Patch header,
+// the bodies over the size limit with 413 and the invalid patches with 400.
+// The requests with other methods are passed through.
+func Middleware(options *Options) func(http.Handler) http.Handler {
+	if options == nil {
+		options = &Options{}
+	}
+	maxBytes := options.MaxBodyBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBodyBytes
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPatch {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Accept-Patch", acceptPatch)
+			ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || (ct != ContentTypeJSONPatch && ct != ContentTypeMergePatch) {
+				http.Error(w, fmt.Sprintf("unsupported media type %q", r.Header.Get("Content-Type")),
+					http.StatusUnsupportedMediaType)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+			if err != nil {
+				http.Error(w, fmt.Sprintf("failed to read request body, %v", err), http.StatusBadRequest)
+				return
+			}
+			if int64(len(body)) > maxBytes {
+				http.Error(w, fmt.Sprintf("request body exceeds the limit %d", maxBytes),
+					http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			p, err := decode(ct, body, options)
+			if err != nil {
+				WriteError(w, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, p)))
+		})
+	}
+}
+
+// decode validates the body of the media type.
+func decode(ct string, body []byte, options *Options) (*Patch, error) {
+	p := &Patch{ContentType: ct, Body: body, options: options}
+	if ct == ContentTypeMergePatch {
+		if !json.Valid(body) {
+			return nil, &Error{http.StatusBadRequest, errors.New("invalid JSON merge patch")}
+		}
+		return p, nil
+	}
+
+	jp, err := jsonpatch.NewPatchWithOptions(body, options.PatchOptions)
+	if err != nil {
+		if errors.Is(err, jsonpatch.ErrLimitExceeded) {
+			return nil, &Error{http.StatusRequestEntityTooLarge, err}
+		}
+		return nil, &Error{http.StatusBadRequest, fmt.Errorf("invalid JSON patch, %w", err)}
+	}
+	p.JSONPatch = jp
+	return p, nil
+}
+
+// FromContext returns the patch decoded by the Middleware.
+func FromContext(ctx context.Context) (*Patch, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Patch)
+	return p, ok
+}
+
+// Apply applies the patch of the request decoded by the Middleware to the document, and returns the new document.
+func Apply(r *http.Request, doc []byte) ([]byte, error) {
+	p, ok := FromContext(r.Context())
+	if !ok {
+		return nil, &Error{http.StatusUnsupportedMediaType, ErrUnsupportedMediaType}
+	}
+	return p.Apply(doc)
+}
+
+// Apply applies the patch to the document, and returns the new document.
+func (p *Patch) Apply(doc []byte) ([]byte, error) {
+	var res []byte
+	var err error
+	if p.ContentType == ContentTypeMergePatch {
+		res, err = jsonpatch.MergePatchWithOptions(doc, p.Body, p.options.MergeOptions)
+	} else {
+		res, err = p.JSONPatch.ApplyWithOptions(doc, p.options.PatchOptions)
+	}
+	if err != nil {
+		return nil, &Error{StatusCode(err), err}
+	}
+	return res, nil
+}
+
+// Error is an error with the status code of the response.
+type Error struct {
+	// Code is the HTTP status code.
+	Code int
+	// Err is the cause of the error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the cause of the error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns the HTTP status code of the error: the code of an *Error,
+// 409 Conflict for failed test operations and conflicting revisions,
+// 413 Request Entity Too Large for exceeded limits, and 422 Unprocessable Entity for the other errors,
+// such as missing paths and invalid indices.
+func StatusCode(err error) int {
+	var e *Error
+	switch {
+	case errors.As(err, &e):
+		return e.Code
+	case errors.Is(err, jsonpatch.ErrTestFailed), errors.Is(err, jsonpatch.ErrUnknownRevision),
+		errors.Is(err, jsonpatch.ErrChecksumMismatch), errors.Is(err, jsonpatch.ErrTransformConflict):
+		return http.StatusConflict
+	case errors.Is(err, jsonpatch.ErrLimitExceeded):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
+
+// WriteError writes the error to the response with its StatusCode.
+func WriteError(w http.ResponseWriter, err error) {
+	code := StatusCode(err)
+	if code == http.StatusUnsupportedMediaType {
+		w.Header().Set("Accept-Patch", acceptPatch)
+	}
+	http.Error(w, err.Error(), code)
+}
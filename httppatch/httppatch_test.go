@@ -0,0 +1,92 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package httppatch
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jsonpatch "github.com/ldclabs/json-patch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"name":"a","tags":["x"]}`)
+	handler := Middleware(&Options{
+		MaxBodyBytes: 128,
+		PatchOptions: &jsonpatch.Options{MaxPatchOps: 2},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch {
+			w.Write(doc)
+			return
+		}
+		res, err := Apply(r, doc)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		w.Write(res)
+	}))
+
+	for i, c := range []struct {
+		method, contentType, body string
+		code                      int
+		result                    string
+	}{
+		{http.MethodGet, "", "", http.StatusOK, `{"name":"a","tags":["x"]}`},
+		{http.MethodPatch, ContentTypeJSONPatch, `[{"op":"add","path":"/tags/-","value":"y"}]`,
+			http.StatusOK, `{"name":"a","tags":["x","y"]}`},
+		{http.MethodPatch, ContentTypeMergePatch + "; charset=utf-8", `{"name":null}`,
+			http.StatusOK, `{"tags":["x"]}`},
+		{http.MethodPatch, "application/json", `{}`, http.StatusUnsupportedMediaType, ""},
+		{http.MethodPatch, "", `{}`, http.StatusUnsupportedMediaType, ""},
+		{http.MethodPatch, ContentTypeMergePatch, `{"name":` + strings.Repeat(" ", 128) + `"b"}`,
+			http.StatusRequestEntityTooLarge, ""},
+		{http.MethodPatch, ContentTypeMergePatch, `{"name":`, http.StatusBadRequest, ""},
+		{http.MethodPatch, ContentTypeJSONPatch, `{"op":"add"}`, http.StatusBadRequest, ""},
+		{http.MethodPatch, ContentTypeJSONPatch,
+			`[{"op":"remove","path":"/a"},{"op":"remove","path":"/b"},{"op":"remove","path":"/c"}]`,
+			http.StatusRequestEntityTooLarge, ""},
+		{http.MethodPatch, ContentTypeJSONPatch, `[{"op":"test","path":"/name","value":"b"}]`, http.StatusConflict, ""},
+		{http.MethodPatch, ContentTypeJSONPatch, `[{"op":"remove","path":"/x"}]`, http.StatusUnprocessableEntity, ""},
+		{http.MethodPatch, ContentTypeJSONPatch, `[{"op":"replace","path":"/tags/5","value":1}]`,
+			http.StatusUnprocessableEntity, ""},
+	} {
+		r := httptest.NewRequest(c.method, "/item", strings.NewReader(c.body))
+		if c.contentType != "" {
+			r.Header.Set("Content-Type", c.contentType)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(c.code, w.Code, "case %d: %s", i, w.Body.String())
+		if c.result != "" {
+			assert.Equal(c.result, w.Body.String(), "case %d", i)
+		}
+		if c.method == http.MethodPatch {
+			assert.Equal(acceptPatch, w.Header().Get("Accept-Patch"), "case %d", i)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	assert := assert.New(t)
+
+	r := httptest.NewRequest(http.MethodPatch, "/item", strings.NewReader(`{}`))
+	_, err := Apply(r, []byte(`{}`))
+	assert.ErrorIs(err, ErrUnsupportedMediaType)
+	assert.Equal(http.StatusUnsupportedMediaType, StatusCode(err))
+
+	w := httptest.NewRecorder()
+	WriteError(w, err)
+	assert.Equal(http.StatusUnsupportedMediaType, w.Code)
+	assert.Equal(acceptPatch, w.Header().Get("Accept-Patch"))
+
+	assert.Equal(http.StatusConflict, StatusCode(jsonpatch.ErrChecksumMismatch))
+	assert.Equal(http.StatusUnprocessableEntity, StatusCode(errors.New("some error")))
+}
@@ -0,0 +1,102 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// applyIncrement applies the "inc" operation enabled by Options.EnableIncrement,
+// it adds the number of the value to the number at the path.
+func applyIncrement(doc *Node, op Operation, options *Options) error {
+	pd, err := doc.intoContainer()
+	if err != nil {
+		return fmt.Errorf("inc operation does not apply for %q, %w", op.Path, err)
+	}
+	con, key := op.findPath(&pd, options)
+	if con == nil || op.Path == "" {
+		return fmt.Errorf("inc operation does not apply for %q, %w", op.Path, ErrMissing)
+	}
+
+	delta := bytes.TrimSpace(op.Value)
+	if !isNumber(delta) {
+		return fmt.Errorf("inc operation has non-numeric value %s, %w", op.Value, ErrInvalid)
+	}
+
+	val, err := con.get(key, options)
+	missing := errors.Is(err, ErrMissing)
+	cur := []byte("0")
+	switch {
+	case missing:
+		if _, ok := con.(*partialDoc); !ok {
+			return fmt.Errorf("inc operation does not apply for %q, %w", op.Path, err)
+		}
+	case err != nil:
+		return fmt.Errorf("inc operation does not apply for %q, %w", op.Path, err)
+	default:
+		if val.raw != nil && val.which != eDoc && val.which != eAry {
+			cur = bytes.TrimSpace(*val.raw)
+		}
+		if val.raw == nil || val.which == eDoc || val.which == eAry || !isNumber(cur) {
+			return fmt.Errorf("inc operation for path %q has non-numeric target, %w", op.Path, ErrInvalid)
+		}
+	}
+
+	sum, err := addNumbers(cur, delta)
+	if err != nil {
+		return fmt.Errorf("inc operation for path %q failed, %w", op.Path, err)
+	}
+	if err := options.validateValue(op.Op, op.Path, sum); err != nil {
+		return err
+	}
+	node := &Node{raw: &sum, valid: true, reformat: true}
+	if missing {
+		return con.add(key, node, options)
+	}
+	return con.set(key, node, options)
+}
+
+// addNumbers returns the sum of the JSON numbers. The integers are added exactly, at any size,
+// the other numbers are added as float64, and the sums beyond the range of float64 are rejected.
+func addNumbers(a, b []byte) (json.RawMessage, error) {
+	if isInteger(a) && isInteger(b) {
+		x, _ := new(big.Int).SetString(string(a), 10)
+		y, _ := new(big.Int).SetString(string(b), 10)
+		return x.Add(x, y).Append(nil, 10), nil
+	}
+
+	x, err := strconv.ParseFloat(string(a), 64)
+	if err != nil {
+		return nil, fmt.Errorf("number %s overflows, %w", a, ErrLimitExceeded)
+	}
+	y, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return nil, fmt.Errorf("number %s overflows, %w", b, ErrLimitExceeded)
+	}
+	sum := x + y
+	if math.IsInf(sum, 0) {
+		return nil, fmt.Errorf("sum of %s and %s overflows, %w", a, b, ErrLimitExceeded)
+	}
+	return json.Marshal(sum)
+}
+
+// isNumber indicates if the data is a JSON number.
+func isNumber(data []byte) bool {
+	if len(data) == 0 || data[0] != '-' && (data[0] < '0' || data[0] > '9') {
+		return false
+	}
+	var n json.Number
+	return json.Unmarshal(data, &n) == nil
+}
+
+// isInteger indicates if the JSON number is an integer without fraction and exponent.
+func isInteger(data []byte) bool {
+	return bytes.IndexAny(data, ".eE") < 0
+}
@@ -0,0 +1,74 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncrement(t *testing.T) {
+	assert := assert.New(t)
+
+	options := NewOptions()
+	options.EnableIncrement = true
+
+	for i, c := range []struct {
+		doc, path, value, res string
+	}{
+		{`{"count":1}`, "/count", `5`, `{"count":6}`},
+		{`{"count":1}`, "/count", `-3`, `{"count":-2}`},
+		{`{"count":9223372036854775807}`, "/count", `1`, `{"count":9223372036854775808}`},
+		{`{"count":-18446744073709551616}`, "/count", `18446744073709551617`, `{"count":1}`},
+		{`{"count":1.5}`, "/count", `1`, `{"count":2.5}`},
+		{`{"count":1}`, "/count", `2.5e1`, `{"count":26}`},
+		{`{"a":{}}`, "/a/count", `2`, `{"a":{"count":2}}`},
+		{`{"a":[1, 2]}`, "/a/1", `2`, `{"a":[1,4]}`},
+		{`{"a":[1, 2]}`, "/a/-1", `2`, `{"a":[1,4]}`},
+	} {
+		p := Patch{{Op: "inc", Path: c.path, Value: json.RawMessage(c.value)}}
+		res, err := p.ApplyWithOptions([]byte(c.doc), options)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.res, string(res), "case %d", i)
+	}
+
+	for i, c := range []struct {
+		doc, path, value string
+		err              error
+	}{
+		{`{"count":1}`, "/count", `"5"`, ErrInvalid},
+		{`{"count":1}`, "/count", ``, ErrInvalid},
+		{`{"count":"1"}`, "/count", `1`, ErrInvalid},
+		{`{"count":null}`, "/count", `1`, ErrInvalid},
+		{`{"count":{}}`, "/count", `1`, ErrInvalid},
+		{`{"count":1.7e308}`, "/count", `1.7e308`, ErrLimitExceeded},
+		{`{"a":[1]}`, "/a/1", `1`, ErrInvalidIndex},
+		{`{"a":1}`, "/b/c", `1`, ErrMissing},
+		{`{"a":1}`, "", `1`, ErrMissing},
+	} {
+		p := Patch{{Op: "inc", Path: c.path, Value: json.RawMessage(c.value)}}
+		_, err := p.ApplyWithOptions([]byte(c.doc), options)
+		assert.ErrorIs(err, c.err, "case %d", i)
+	}
+
+	p := Patch{{Op: "inc", Path: "/count", Value: json.RawMessage(`1`)}}
+	_, err := p.Apply([]byte(`{"count":1}`))
+	assert.NotNil(err)
+
+	cp, err := p.Compile(options)
+	assert.Nil(err)
+	res, err := cp.Apply([]byte(`{"count":1}`))
+	assert.Nil(err)
+	assert.Equal(`{"count":2}`, string(res))
+
+	_, changes, err := p.ApplyWithReport([]byte(`{"count":1}`), options)
+	assert.Nil(err)
+	assert.Equal([]Change{{Index: 0, Op: "inc", Path: "/count", Old: json.RawMessage(`1`), New: json.RawMessage(`2`)}}, changes)
+
+	_, err = DecodePatchStrict([]byte(`[{"op": "inc", "path": "/count", "value": 1}]`), options)
+	assert.Nil(err)
+	assert.Contains(Capabilities(options).Extensions, "inc")
+}
@@ -0,0 +1,96 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// NewNodeWithOptions returns a new Node with the given raw encoded JSON document as NewNode,
+// the comments and the trailing commas are removed from the document if they are allowed
+// by Options.AllowComments and Options.AllowTrailingCommas, so the node holds standard JSON.
+func NewNodeWithOptions(doc json.RawMessage, options *Options) *Node {
+	n := NewNode(doc)
+	n.tolerate(options)
+	return n
+}
+
+// tolerate removes the comments and the trailing commas allowed by the options from the unparsed document.
+func (n *Node) tolerate(options *Options) {
+	if n == nil || n.which != eRaw || n.valid || n.raw == nil || options == nil ||
+		!options.AllowComments && !options.AllowTrailingCommas {
+		return
+	}
+	if json.Valid(*n.raw) {
+		n.valid = true
+		return
+	}
+	raw := json.RawMessage(stripJSONC(*n.raw, options.AllowComments, options.AllowTrailingCommas))
+	n.raw = &raw
+}
+
+// stripJSONC returns a copy of the data with the comments and the trailing commas replaced by spaces,
+// the newlines of the comments are kept, so the offsets of the syntax errors don't change.
+// The unterminated block comments are kept, they are reported as syntax errors.
+func stripJSONC(data []byte, comments, commas bool) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+
+	// comma is the offset of the last comma after a value if only whitespace and comments follow it,
+	// prev is the last byte that is not whitespace or comment.
+	comma, prev := -1, byte(0)
+	for i := 0; i < len(out); {
+		c := out[i]
+		switch {
+		case c == ' ', c == '\t', c == '\n', c == '\r':
+			i++
+
+		case c == '"':
+			comma, prev = -1, c
+			i = scanString(out, i)
+
+		case c == '/' && comments && i+1 < len(out) && out[i+1] == '/':
+			end := bytes.IndexByte(out[i:], '\n')
+			if end < 0 {
+				end = len(out) - i
+			}
+			blank(out[i : i+end])
+			i += end
+
+		case c == '/' && comments && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				return out
+			}
+			blank(out[i : i+end+4])
+			i += end + 4
+
+		case c == ',' && commas && prev != 0 && prev != '[' && prev != '{' && prev != ',' && prev != ':':
+			comma, prev = i, c
+			i++
+
+		case c == '}', c == ']':
+			if comma >= 0 {
+				out[comma] = ' '
+			}
+			comma, prev = -1, c
+			i++
+
+		default:
+			comma, prev = -1, c
+			i++
+		}
+	}
+	return out
+}
+
+// blank replaces the bytes with spaces, except the newlines.
+func blank(data []byte) {
+	for i, c := range data {
+		if c != '\n' && c != '\r' {
+			data[i] = ' '
+		}
+	}
+}
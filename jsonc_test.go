@@ -0,0 +1,75 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripJSONC(t *testing.T) {
+	assert := assert.New(t)
+
+	for i, c := range []struct {
+		doc, res         string
+		comments, commas bool
+	}{
+		{`{"a": 1}`, `{"a": 1}`, true, true},
+		{"{\"a\": 1 // c\n}", "{\"a\": 1     \n}", true, false},
+		{"{\"a\": 1, /* b,\n c */ }", "{\"a\": 1, /* b,\n c */ }", false, true},
+		{"{\"a\": 1, /* b,\n c */ }", "{\"a\": 1," + "      \n      }", true, false},
+		{"{\"a\": 1, /* b,\n c */ }", "{\"a\": 1" + "       \n      }", true, true},
+		{`{"a": "//x", "b": "/*,]"}`, `{"a": "//x", "b": "/*,]"}`, true, true},
+		{`[1, [2,], {"a": 3,},]`, `[1, [2 ], {"a": 3 } ]`, false, true},
+		{`[,]`, `[,]`, true, true},
+		{`[1,,]`, `[1,,]`, true, true},
+		{`{"a":,}`, `{"a":,}`, true, true},
+		{`[1] /* x`, `[1] /* x`, true, true},
+		{`[1] // x`, `[1]     `, true, true},
+	} {
+		assert.Equal(c.res, string(stripJSONC([]byte(c.doc), c.comments, c.commas)), "case %d", i)
+	}
+}
+
+func TestAllowComments(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{
+  // the compiler options
+  "compilerOptions": {
+    "target": "es2020", /* the output */
+    "strict": true,
+  },
+  "include": ["src",],
+}`)
+	p, err := NewPatch([]byte(`[{"op": "replace", "path": "/compilerOptions/strict", "value": false}]`))
+	assert.Nil(err)
+
+	_, err = p.ApplyWithOptions(doc, nil)
+	assert.NotNil(err)
+
+	options := NewOptions()
+	options.AllowComments = true
+	_, err = p.ApplyWithOptions(doc, options)
+	assert.NotNil(err)
+
+	options.AllowTrailingCommas = true
+	res, err := p.ApplyWithOptions(doc, options)
+	assert.Nil(err)
+	assert.Equal(`{"compilerOptions":{"target":"es2020","strict":false},"include":["src"]}`, string(res))
+
+	node := NewNodeWithOptions(doc, options)
+	v, err := node.GetValue("/include/0", options)
+	assert.Nil(err)
+	assert.Equal(`"src"`, string(v))
+	data, err := node.MarshalJSON()
+	assert.Nil(err)
+	assert.Equal(`{"compilerOptions":{"target":"es2020","strict":true},"include":["src"]}`, string(data))
+
+	_, err = p.ApplyWithOptions([]byte(`{"compilerOptions": {"strict": true,,}}`), options)
+	assert.NotNil(err)
+	_, err = p.ApplyWithOptions([]byte(`{"compilerOptions": {"strict": true} /* x`), options)
+	assert.NotNil(err)
+}
@@ -0,0 +1,79 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package jsonpatchtest implements the assertions of JSON documents for the tests.
+//
+// The failures are reported with the differences between the documents, path by path,
+// instead of the whole documents:
+//
+//	func TestHandler(t *testing.T) {
+//		res := callHandler()
+//		jsonpatchtest.AssertEqualJSON(t, []byte(`{"id": 1, "tags": ["a"]}`), res, nil)
+//	}
+package jsonpatchtest
+
+import (
+	"strings"
+	"testing"
+
+	jsonpatch "github.com/ldclabs/json-patch"
+)
+
+// Options specifies options for the assertions.
+type Options struct {
+	// Ignore lists the paths whose values, including their children, are not compared,
+	// such as the timestamps and the generated IDs. Default to nil.
+	Ignore []string
+	// DiffOptions is used to compute the differences. Default to nil.
+	DiffOptions *jsonpatch.DiffOptions
+}
+
+// AssertEqualJSON asserts that the JSON documents are structurally equal, the object keys
+// are compared regardless of their order. On failure, it reports the operations that turn
+// want into got, with the values before and after them. It returns true if they are equal.
+func AssertEqualJSON(t testing.TB, want, got []byte, options *Options) bool {
+	t.Helper()
+	if options == nil {
+		options = &Options{}
+	}
+
+	p, err := jsonpatch.Diff(want, got, options.DiffOptions)
+	if err != nil {
+		t.Errorf("unable to compare JSON documents, %v", err)
+		return false
+	}
+
+	diff := p[:0]
+	for _, op := range p {
+		if !options.ignored(op.Path) && (op.From == "" || !options.ignored(op.From)) {
+			diff = append(diff, op)
+		}
+	}
+	if len(diff) == 0 {
+		return true
+	}
+	t.Errorf("JSON documents are not equal, want -, got +:\n%s", jsonpatch.FormatPatch(diff, want, jsonpatch.StyleUnified))
+	return false
+}
+
+// AssertPatchApplies asserts that the patch applies to the document, and the patched document equals want.
+// It returns true if the assertion passes.
+func AssertPatchApplies(t testing.TB, doc []byte, patch jsonpatch.Patch, want []byte) bool {
+	t.Helper()
+
+	got, err := patch.Apply(doc)
+	if err != nil {
+		t.Errorf("patch does not apply, %v:\n%s", err, jsonpatch.FormatPatch(patch, doc, jsonpatch.StyleUnified))
+		return false
+	}
+	return AssertEqualJSON(t, want, got, nil)
+}
+
+func (o *Options) ignored(path string) bool {
+	for _, p := range o.Ignore {
+		if p == "" || path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatchtest
+
+import (
+	"fmt"
+	"testing"
+
+	jsonpatch "github.com/ldclabs/json-patch"
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertEqualJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	r := &recorder{TB: t}
+	assert.True(AssertEqualJSON(r, []byte(`{"a": 1, "b": [true, null]}`), []byte(`{"b":[true,null],"a":1}`), nil))
+	assert.Equal(0, len(r.errors))
+
+	assert.False(AssertEqualJSON(r, []byte(`{"a": 1, "b": [true, null]}`), []byte(`{"a":2,"b":[true]}`), nil))
+	assert.Equal([]string{`JSON documents are not equal, want -, got +:
+@@ replace /a @@
+- 1
++ 2
+@@ remove /b/1 @@
+- null
+`}, r.errors)
+
+	r = &recorder{TB: t}
+	options := &Options{Ignore: []string{"/at", "/meta"}}
+	assert.True(AssertEqualJSON(r, []byte(`{"a": 1, "at": 1, "meta": {"x": 1}}`),
+		[]byte(`{"a":1,"at":2,"meta":{"x":2,"y":3}}`), options))
+	assert.False(AssertEqualJSON(r, []byte(`{"a": 1, "at": 1}`), []byte(`{"a":2,"at":2}`), options))
+	assert.Equal([]string{`JSON documents are not equal, want -, got +:
+@@ replace /a @@
+- 1
++ 2
+`}, r.errors)
+
+	r = &recorder{TB: t}
+	assert.False(AssertEqualJSON(r, []byte(`{"a":`), []byte(`{}`), nil))
+	assert.Equal(1, len(r.errors))
+}
+
+func TestAssertPatchApplies(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := jsonpatch.NewPatch([]byte(`[{"op": "add", "path": "/b", "value": 2}]`))
+	assert.Nil(err)
+
+	r := &recorder{TB: t}
+	assert.True(AssertPatchApplies(r, []byte(`{"a":1}`), p, []byte(`{"b": 2, "a": 1}`)))
+	assert.Equal(0, len(r.errors))
+
+	assert.False(AssertPatchApplies(r, []byte(`{"a":1}`), p, []byte(`{"a": 1, "b": 3}`)))
+	assert.Equal([]string{`JSON documents are not equal, want -, got +:
+@@ replace /b @@
+- 3
++ 2
+`}, r.errors)
+
+	r = &recorder{TB: t}
+	assert.False(AssertPatchApplies(r, []byte(`[]`), p, []byte(`[]`)))
+	assert.Equal(1, len(r.errors))
+	assert.Contains(r.errors[0], "patch does not apply")
+}
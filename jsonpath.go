@@ -0,0 +1,715 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Query returns the nodes selected by the JSONPath expression in the node, with their JSON Pointer paths,
+// in the order of the document. It implements the common syntax of RFC 9535:
+//
+//	$                  the node
+//	.name, ['name']    the member of an object
+//	.*, [*]            the members of an object or the elements of an array
+//	[0], [-1]          the element of an array, negative indices count from the end
+//	[start:end:step]   the slice of an array
+//	[a,b]              the union of the selectors
+//	..name, ..[0]      the recursive descent, the selectors applied to the node and all its descendants
+//	[?@.price < 10]    the filter of the members or elements, with the comparisons ==, !=, <, <=, > and >=
+//	                   of the singular queries "@..." and "$..." and the literals, the existence tests
+//	                   of the queries and the logical operators &&, || and !. "[?(...)]" is accepted too.
+//
+// The paths of the result can be used in the operations of a patch, such as BuildRemovePatch.
+func (n *Node) Query(jsonpath string) (PVs, error) {
+	segs, err := parseJSONPath(jsonpath)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []jpMatch{{n, ""}}
+	for _, seg := range segs {
+		matches = seg.apply(n, matches)
+		if len(matches) == 0 {
+			break
+		}
+	}
+
+	result := make(PVs, 0, len(matches))
+	for _, m := range matches {
+		value := json.RawMessage("null")
+		if m.node != nil {
+			if value, err = m.node.rawJSON(); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, &PV{Path: m.path, Value: value})
+	}
+	return result, nil
+}
+
+// jpMatch is a node selected by a JSONPath expression, a nil node is a null member or element.
+type jpMatch struct {
+	node *Node
+	path string
+}
+
+// jpSegment is a segment of a JSONPath expression, the selectors of the children of the nodes,
+// or of the nodes and all their descendants for the descendant segments.
+type jpSegment struct {
+	descendant bool
+	selectors  []*jpSelector
+}
+
+func (s *jpSegment) apply(root *Node, matches []jpMatch) []jpMatch {
+	var res []jpMatch
+	var visit func(m jpMatch)
+	visit = func(m jpMatch) {
+		for _, sel := range s.selectors {
+			res = sel.apply(root, m, res)
+		}
+		if s.descendant {
+			eachChild(m, func(c jpMatch) { visit(c) })
+		}
+	}
+	for _, m := range matches {
+		visit(m)
+	}
+	return res
+}
+
+// singular reports whether the segment selects at most one node.
+func (s *jpSegment) singular() bool {
+	return !s.descendant && len(s.selectors) == 1 &&
+		(s.selectors[0].kind == jpName || s.selectors[0].kind == jpIndex)
+}
+
+type jpKind int
+
+const (
+	jpName jpKind = iota
+	jpWildcard
+	jpIndex
+	jpSlice
+	jpFilter
+)
+
+// jpSelector is a selector of the children of a node.
+type jpSelector struct {
+	kind   jpKind
+	name   string
+	index  int
+	slice  [3]*int
+	filter jpExpr
+}
+
+func (sel *jpSelector) apply(root *Node, m jpMatch, res []jpMatch) []jpMatch {
+	pd := jpContainer(m.node)
+	switch sel.kind {
+	case jpName:
+		if doc, ok := pd.(*partialDoc); ok {
+			if v, ok := doc.obj[sel.name]; ok {
+				res = append(res, jpMatch{v, m.path + "/" + encodePatchKey(sel.name)})
+			}
+		}
+
+	case jpIndex:
+		if ary, ok := pd.(*partialArray); ok {
+			i := sel.index
+			if i < 0 {
+				i += len(*ary)
+			}
+			if i >= 0 && i < len(*ary) {
+				res = append(res, jpMatch{(*ary)[i], m.path + "/" + strconv.Itoa(i)})
+			}
+		}
+
+	case jpSlice:
+		if ary, ok := pd.(*partialArray); ok {
+			for _, i := range sliceIndices(len(*ary), sel.slice) {
+				res = append(res, jpMatch{(*ary)[i], m.path + "/" + strconv.Itoa(i)})
+			}
+		}
+
+	case jpWildcard:
+		eachChild(m, func(c jpMatch) { res = append(res, c) })
+
+	case jpFilter:
+		eachChild(m, func(c jpMatch) {
+			if sel.filter.test(root, c.node) {
+				res = append(res, c)
+			}
+		})
+	}
+	return res
+}
+
+// sliceIndices returns the indices of the slice [start:end:step] of an array of length n.
+func sliceIndices(n int, slice [3]*int) []int {
+	step := 1
+	if slice[2] != nil {
+		step = *slice[2]
+	}
+	if step == 0 {
+		return nil
+	}
+
+	bound := func(p *int, def int) int {
+		if p == nil {
+			return def
+		}
+		if *p < 0 {
+			return *p + n
+		}
+		return *p
+	}
+	clamp := func(i, lo, hi int) int {
+		if i < lo {
+			return lo
+		}
+		if i > hi {
+			return hi
+		}
+		return i
+	}
+
+	var res []int
+	if step > 0 {
+		lower, upper := clamp(bound(slice[0], 0), 0, n), clamp(bound(slice[1], n), 0, n)
+		for i := lower; i < upper; i += step {
+			res = append(res, i)
+		}
+		return res
+	}
+	upper, lower := clamp(bound(slice[0], n-1), -1, n-1), clamp(bound(slice[1], -n-1), -1, n-1)
+	for i := upper; lower < i; i += step {
+		res = append(res, i)
+	}
+	return res
+}
+
+// jpContainer returns the container of the node, or nil if the node is not an object or an array.
+func jpContainer(n *Node) container {
+	if n == nil {
+		return nil
+	}
+	pd, err := n.intoContainer()
+	if err != nil {
+		return nil
+	}
+	return pd
+}
+
+// eachChild calls fn with the members of an object or the elements of an array, in the order of the document.
+func eachChild(m jpMatch, fn func(jpMatch)) {
+	switch pd := jpContainer(m.node).(type) {
+	case *partialDoc:
+		for _, k := range pd.keys {
+			fn(jpMatch{pd.obj[k], m.path + "/" + encodePatchKey(k)})
+		}
+	case *partialArray:
+		for i, v := range *pd {
+			fn(jpMatch{v, m.path + "/" + strconv.Itoa(i)})
+		}
+	}
+}
+
+// jpExpr is a logical expression of a filter selector.
+type jpExpr interface {
+	test(root, current *Node) bool
+}
+
+type jpOr []jpExpr
+
+func (e jpOr) test(root, current *Node) bool {
+	for _, x := range e {
+		if x.test(root, current) {
+			return true
+		}
+	}
+	return false
+}
+
+type jpAnd []jpExpr
+
+func (e jpAnd) test(root, current *Node) bool {
+	for _, x := range e {
+		if !x.test(root, current) {
+			return false
+		}
+	}
+	return true
+}
+
+type jpNot struct{ expr jpExpr }
+
+func (e jpNot) test(root, current *Node) bool {
+	return !e.expr.test(root, current)
+}
+
+// jpQuery is a query of a filter, relative to the current node ("@") or to the root node ("$").
+type jpQuery struct {
+	relative bool
+	segs     []*jpSegment
+}
+
+func (q *jpQuery) eval(root, current *Node) []jpMatch {
+	start := root
+	if q.relative {
+		start = current
+	}
+	matches := []jpMatch{{start, ""}}
+	for _, seg := range q.segs {
+		matches = seg.apply(root, matches)
+		if len(matches) == 0 {
+			break
+		}
+	}
+	return matches
+}
+
+// test is the existence test of the query.
+func (q *jpQuery) test(root, current *Node) bool {
+	return len(q.eval(root, current)) > 0
+}
+
+// jpValue is a value compared in a filter, ok is false for the nothing of the singular queries
+// that select no node.
+type jpValue struct {
+	v  interface{}
+	ok bool
+}
+
+// jpOperand is a singular query or a literal compared in a filter.
+type jpOperand struct {
+	query   *jpQuery
+	literal jpValue
+}
+
+func (o *jpOperand) value(root, current *Node) jpValue {
+	if o.query == nil {
+		return o.literal
+	}
+	matches := o.query.eval(root, current)
+	if len(matches) != 1 {
+		return jpValue{}
+	}
+	if matches[0].node == nil {
+		return jpValue{nil, true}
+	}
+	data, err := matches[0].node.rawJSON()
+	if err != nil {
+		return jpValue{}
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return jpValue{}
+	}
+	return jpValue{v, true}
+}
+
+type jpComparison struct {
+	op          string
+	left, right *jpOperand
+}
+
+func (e *jpComparison) test(root, current *Node) bool {
+	a, b := e.left.value(root, current), e.right.value(root, current)
+	switch e.op {
+	case "==":
+		return jpEqual(a, b)
+	case "!=":
+		return !jpEqual(a, b)
+	case "<":
+		return jpLess(a, b)
+	case "<=":
+		return jpLess(a, b) || jpEqual(a, b)
+	case ">":
+		return jpLess(b, a)
+	case ">=":
+		return jpLess(b, a) || jpEqual(a, b)
+	}
+	return false
+}
+
+func jpEqual(a, b jpValue) bool {
+	if !a.ok || !b.ok {
+		return a.ok == b.ok
+	}
+	return reflect.DeepEqual(a.v, b.v)
+}
+
+func jpLess(a, b jpValue) bool {
+	if !a.ok || !b.ok {
+		return false
+	}
+	switch x := a.v.(type) {
+	case float64:
+		y, ok := b.v.(float64)
+		return ok && x < y
+	case string:
+		y, ok := b.v.(string)
+		return ok && x < y
+	}
+	return false
+}
+
+// parseJSONPath parses the JSONPath expression into its segments.
+func parseJSONPath(s string) ([]*jpSegment, error) {
+	p := &jpParser{s: s}
+	if !p.consume("$") {
+		return nil, p.errorf("expected \"$\"")
+	}
+	segs, err := p.segments()
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.i:])
+	}
+	return segs, nil
+}
+
+type jpParser struct {
+	s string
+	i int
+}
+
+func (p *jpParser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("invalid JSONPath %q at %d, %s, %w", p.s, p.i, fmt.Sprintf(format, a...), ErrInvalid)
+}
+
+func (p *jpParser) consume(tok string) bool {
+	if strings.HasPrefix(p.s[p.i:], tok) {
+		p.i += len(tok)
+		return true
+	}
+	return false
+}
+
+func (p *jpParser) skipSpace() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t' || p.s[p.i] == '\n' || p.s[p.i] == '\r') {
+		p.i++
+	}
+}
+
+// peekSegment reports whether a segment follows, after the whitespace in the filters.
+func (p *jpParser) peekSegment() bool {
+	return p.i < len(p.s) && (p.s[p.i] == '.' || p.s[p.i] == '[')
+}
+
+func (p *jpParser) segments() ([]*jpSegment, error) {
+	var segs []*jpSegment
+	for p.peekSegment() {
+		seg, err := p.segment()
+		if err != nil {
+			return nil, err
+		}
+		segs = append(segs, seg)
+	}
+	return segs, nil
+}
+
+func (p *jpParser) segment() (*jpSegment, error) {
+	seg := &jpSegment{}
+	switch {
+	case p.consume(".."):
+		seg.descendant = true
+		if p.i < len(p.s) && p.s[p.i] == '[' {
+			p.i++
+			return seg, p.bracket(seg)
+		}
+	case p.consume("."):
+	default:
+		p.i++
+		return seg, p.bracket(seg)
+	}
+
+	if p.consume("*") {
+		seg.selectors = []*jpSelector{{kind: jpWildcard}}
+		return seg, nil
+	}
+	start := p.i
+	for p.i < len(p.s) && isNameChar(p.s[p.i], p.i == start) {
+		p.i++
+	}
+	if p.i == start {
+		return nil, p.errorf("expected member name")
+	}
+	seg.selectors = []*jpSelector{{kind: jpName, name: p.s[start:p.i]}}
+	return seg, nil
+}
+
+// isNameChar reports whether the byte is in the shorthand member names, the digits are not first.
+func isNameChar(c byte, first bool) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= 0x80 || !first && c >= '0' && c <= '9'
+}
+
+func (p *jpParser) bracket(seg *jpSegment) error {
+	for {
+		p.skipSpace()
+		sel, err := p.selector()
+		if err != nil {
+			return err
+		}
+		seg.selectors = append(seg.selectors, sel)
+		p.skipSpace()
+		if p.consume("]") {
+			return nil
+		}
+		if !p.consume(",") {
+			return p.errorf("expected \",\" or \"]\"")
+		}
+	}
+}
+
+func (p *jpParser) selector() (*jpSelector, error) {
+	if p.i >= len(p.s) {
+		return nil, p.errorf("expected selector")
+	}
+	switch c := p.s[p.i]; {
+	case c == '\'' || c == '"':
+		name, err := p.stringLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return &jpSelector{kind: jpName, name: name}, nil
+	case c == '*':
+		p.i++
+		return &jpSelector{kind: jpWildcard}, nil
+	case c == '?':
+		p.i++
+		p.skipSpace()
+		expr, err := p.logicalOr()
+		if err != nil {
+			return nil, err
+		}
+		return &jpSelector{kind: jpFilter, filter: expr}, nil
+	}
+
+	sel := &jpSelector{kind: jpIndex}
+	for part := 0; part < 3; part++ {
+		p.skipSpace()
+		if p.i < len(p.s) && (p.s[p.i] == '-' || p.s[p.i] >= '0' && p.s[p.i] <= '9') {
+			v, err := p.integer()
+			if err != nil {
+				return nil, err
+			}
+			sel.slice[part] = &v
+		}
+		p.skipSpace()
+		if part == 2 || !p.consume(":") {
+			break
+		}
+		sel.kind = jpSlice
+	}
+	if sel.kind == jpIndex {
+		if sel.slice[0] == nil {
+			return nil, p.errorf("expected selector")
+		}
+		sel.index = *sel.slice[0]
+	}
+	return sel, nil
+}
+
+func (p *jpParser) integer() (int, error) {
+	start := p.i
+	if p.i < len(p.s) && p.s[p.i] == '-' {
+		p.i++
+	}
+	for p.i < len(p.s) && p.s[p.i] >= '0' && p.s[p.i] <= '9' {
+		p.i++
+	}
+	v, err := strconv.Atoi(p.s[start:p.i])
+	if err != nil {
+		p.i = start
+		return 0, p.errorf("invalid integer")
+	}
+	return v, nil
+}
+
+// stringLiteral parses a string in single or double quotes, with the escapes of the JSON strings
+// and \' in the single quotes.
+func (p *jpParser) stringLiteral() (string, error) {
+	start, quote := p.i, p.s[p.i]
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for p.i++; p.i < len(p.s); p.i++ {
+		switch c := p.s[p.i]; {
+		case c == quote:
+			p.i++
+			buf.WriteByte('"')
+			var str string
+			if err := json.Unmarshal(buf.Bytes(), &str); err != nil {
+				p.i = start
+				return "", p.errorf("invalid string")
+			}
+			return str, nil
+		case c == '\\' && p.i+1 < len(p.s):
+			p.i++
+			if p.s[p.i] != '\'' {
+				buf.WriteByte('\\')
+			}
+			buf.WriteByte(p.s[p.i])
+		case c == '"':
+			buf.WriteString(`\"`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	p.i = start
+	return "", p.errorf("unterminated string")
+}
+
+func (p *jpParser) logicalOr() (jpExpr, error) {
+	var or jpOr
+	for {
+		and, err := p.logicalAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, and)
+		p.skipSpace()
+		if !p.consume("||") {
+			break
+		}
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *jpParser) logicalAnd() (jpExpr, error) {
+	var and jpAnd
+	for {
+		p.skipSpace()
+		expr, err := p.basicExpr()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, expr)
+		p.skipSpace()
+		if !p.consume("&&") {
+			break
+		}
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+var jpComparisonOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func (p *jpParser) basicExpr() (jpExpr, error) {
+	if p.consume("!") {
+		p.skipSpace()
+		expr, err := p.basicExpr()
+		if err != nil {
+			return nil, err
+		}
+		return jpNot{expr}, nil
+	}
+	if p.consume("(") {
+		expr, err := p.logicalOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return nil, p.errorf("expected \")\"")
+		}
+		return expr, nil
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	for _, op := range jpComparisonOps {
+		if !p.consume(op) {
+			continue
+		}
+		p.skipSpace()
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.checkComparable(left); err != nil {
+			return nil, err
+		}
+		if err := p.checkComparable(right); err != nil {
+			return nil, err
+		}
+		return &jpComparison{op, left, right}, nil
+	}
+	if left.query == nil {
+		return nil, p.errorf("expected comparison")
+	}
+	return left.query, nil
+}
+
+// checkComparable checks that the operand of a comparison is a literal or a singular query.
+func (p *jpParser) checkComparable(o *jpOperand) error {
+	if o.query == nil {
+		return nil
+	}
+	for _, seg := range o.query.segs {
+		if !seg.singular() {
+			return p.errorf("non-singular query in comparison")
+		}
+	}
+	return nil
+}
+
+func (p *jpParser) operand() (*jpOperand, error) {
+	if p.i >= len(p.s) {
+		return nil, p.errorf("expected operand")
+	}
+
+	switch c := p.s[p.i]; {
+	case c == '@' || c == '$':
+		p.i++
+		segs, err := p.segments()
+		if err != nil {
+			return nil, err
+		}
+		return &jpOperand{query: &jpQuery{relative: c == '@', segs: segs}}, nil
+	case c == '\'' || c == '"':
+		s, err := p.stringLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return &jpOperand{literal: jpValue{s, true}}, nil
+	}
+
+	switch {
+	case p.consume("true"):
+		return &jpOperand{literal: jpValue{true, true}}, nil
+	case p.consume("false"):
+		return &jpOperand{literal: jpValue{false, true}}, nil
+	case p.consume("null"):
+		return &jpOperand{literal: jpValue{nil, true}}, nil
+	}
+
+	start := p.i
+	for p.i < len(p.s) && strings.IndexByte("+-.0123456789eE", p.s[p.i]) >= 0 {
+		p.i++
+	}
+	if num := []byte(p.s[start:p.i]); isNumber(num) {
+		v, err := strconv.ParseFloat(string(num), 64)
+		if err == nil {
+			return &jpOperand{literal: jpValue{v, true}}, nil
+		}
+	}
+	p.i = start
+	return nil, p.errorf("expected operand")
+}
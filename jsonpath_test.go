@@ -0,0 +1,95 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"store": {
+		"book": [
+			{"category": "reference", "author": "Nigel Rees", "title": "Sayings", "price": 8.95},
+			{"category": "fiction", "author": "Evelyn Waugh", "title": "Sword", "price": 12.99},
+			{"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553", "price": 8.99},
+			{"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord", "isbn": "0-395", "price": 22.99}
+		],
+		"bicycle": {"color": "red", "price": 399},
+		"a/b": null
+	}, "max": 10}`)
+	node := NewNode(doc)
+
+	for i, c := range []struct {
+		query string
+		paths []string
+	}{
+		{`$`, []string{""}},
+		{`$.store.bicycle.color`, []string{"/store/bicycle/color"}},
+		{`$['store']["bicycle"]`, []string{"/store/bicycle"}},
+		{`$.store['a/b']`, []string{"/store/a~1b"}},
+		{`$.store.*`, []string{"/store/book", "/store/bicycle", "/store/a~1b"}},
+		{`$.store.book[*].author`, []string{"/store/book/0/author", "/store/book/1/author",
+			"/store/book/2/author", "/store/book/3/author"}},
+		{`$..author`, []string{"/store/book/0/author", "/store/book/1/author",
+			"/store/book/2/author", "/store/book/3/author"}},
+		{`$.store..price`, []string{"/store/book/0/price", "/store/book/1/price",
+			"/store/book/2/price", "/store/book/3/price", "/store/bicycle/price"}},
+		{`$..book[2]`, []string{"/store/book/2"}},
+		{`$..book[-1]`, []string{"/store/book/3"}},
+		{`$..book[4]`, []string{}},
+		{`$..book[0,1]`, []string{"/store/book/0", "/store/book/1"}},
+		{`$..book[:2]`, []string{"/store/book/0", "/store/book/1"}},
+		{`$..book[1:]`, []string{"/store/book/1", "/store/book/2", "/store/book/3"}},
+		{`$..book[::2]`, []string{"/store/book/0", "/store/book/2"}},
+		{`$..book[::-1]`, []string{"/store/book/3", "/store/book/2", "/store/book/1", "/store/book/0"}},
+		{`$..book[-2:]`, []string{"/store/book/2", "/store/book/3"}},
+		{`$..book[0:4:0]`, []string{}},
+		{`$..book[?@.isbn]`, []string{"/store/book/2", "/store/book/3"}},
+		{`$..book[?(!@.isbn)]`, []string{"/store/book/0", "/store/book/1"}},
+		{`$..book[?(@.price < 10)].title`, []string{"/store/book/0/title", "/store/book/2/title"}},
+		{`$..book[?@.price < $.max]`, []string{"/store/book/0", "/store/book/2"}},
+		{`$..book[?@.price >= 12.99 && @.category == 'fiction']`, []string{"/store/book/1", "/store/book/3"}},
+		{`$..book[?@.author == "Nigel Rees" || @.price > 20]`, []string{"/store/book/0", "/store/book/3"}},
+		{`$..book[?(@.category != 'fiction')]`, []string{"/store/book/0"}},
+		{`$..book[?@.title <= 'Sayings']`, []string{"/store/book/0", "/store/book/2"}},
+		{`$..book[?@.missing == @.other]`, []string{"/store/book/0", "/store/book/1", "/store/book/2", "/store/book/3"}},
+		{`$..book[?@.price == true]`, []string{}},
+		{`$.store[?@.color == 'red']`, []string{"/store/bicycle"}},
+		{`$.store[?@ == null]`, []string{"/store/a~1b"}},
+		{`$..*[?@ == 399]`, []string{"/store/bicycle/price"}},
+		{`$.max.x`, []string{}},
+	} {
+		res, err := node.Query(c.query)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.paths, res.Paths(), "case %d", i)
+	}
+
+	res, err := node.Query(`$.store.bicycle`)
+	assert.Nil(err)
+	assert.Equal(`{"color": "red", "price": 399}`, string(res[0].Value))
+	res, err = node.Query(`$.store['a/b']`)
+	assert.Nil(err)
+	assert.Equal(`null`, string(res[0].Value))
+
+	// the result paths can be patched.
+	res, err = node.Query(`$..book[?@.price > 10]`)
+	assert.Nil(err)
+	assert.Nil(node.Patch(BuildRemovePatch(res), nil))
+	res, err = node.Query(`$..book[*].title`)
+	assert.Nil(err)
+	assert.Equal([]string{"/store/book/0/title", "/store/book/1/title"}, res.Paths())
+	assert.Equal(`"Moby Dick"`, string(res[1].Value))
+
+	for i, s := range []string{
+		``, `store`, `$.`, `$.1a`, `$[`, `$[]`, `$['a'`, `$['a]`, `$[1 2]`, `$[?@.a <]`, `$[?1]`,
+		`$[?(@.a]`, `$[?@..a == 1]`, `$[?@.* == 1]`, `$.a b`, `$[?@.a == 'x\q']`,
+	} {
+		_, err = node.Query(s)
+		assert.ErrorIs(err, ErrInvalid, "case %d", i)
+	}
+}
@@ -0,0 +1,39 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// matchKey returns the key of the object that matches the key by Options.FoldKeys and
+// Options.NormalizeUnicodeKeys, the first one in the order of the object. It returns the key itself
+// if the object has it, or if no key matches.
+func (d *partialDoc) matchKey(key string, options *Options) string {
+	if _, ok := d.obj[key]; ok || options == nil || !options.FoldKeys && !options.NormalizeUnicodeKeys {
+		return key
+	}
+
+	want := options.normalizeKey(key)
+	for _, k := range d.keys {
+		if options.normalizeKey(k) == want {
+			return k
+		}
+	}
+	return key
+}
+
+// normalizeKey returns the key in the NFC form if Options.NormalizeUnicodeKeys is true,
+// and case folded if Options.FoldKeys is true.
+func (o *Options) normalizeKey(key string) string {
+	if o.NormalizeUnicodeKeys {
+		key = norm.NFC.String(key)
+	}
+	if o.FoldKeys {
+		key = strings.ToLower(strings.ToUpper(key))
+	}
+	return key
+}
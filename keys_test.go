@@ -0,0 +1,78 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFoldKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := `{"Name":"John","Meta":{"Tags":["a"]},"name2":1}`
+	options := NewOptions()
+	options.FoldKeys = true
+
+	for i, c := range []struct {
+		patch, res string
+	}{
+		{`[{"op": "replace", "path": "/name", "value": "Jane"}]`, `{"Name":"Jane","Meta":{"Tags":["a"]},"name2":1}`},
+		{`[{"op": "add", "path": "/meta/tags/-", "value": "b"}]`, `{"Name":"John","Meta":{"Tags":["a","b"]},"name2":1}`},
+		{`[{"op": "remove", "path": "/META"}]`, `{"Name":"John","name2":1}`},
+		{`[{"op": "test", "path": "/NAME", "value": "John"}, {"op": "add", "path": "/NAME2", "value": 2}]`,
+			`{"Name":"John","Meta":{"Tags":["a"]},"name2":2}`},
+		{`[{"op": "move", "from": "/meta/tags", "path": "/tags"}]`, `{"Name":"John","Meta":{},"name2":1,"tags":["a"]}`},
+		{`[{"op": "add", "path": "/age", "value": 1}]`, `{"Name":"John","Meta":{"Tags":["a"]},"name2":1,"age":1}`},
+	} {
+		res, err := applyPatchWithOptions(doc, c.patch, options)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.res, res, "case %d", i)
+	}
+
+	// exact matches win.
+	res, err := applyPatchWithOptions(`{"Name":1,"name":2}`, `[{"op": "replace", "path": "/name", "value": 3}]`, options)
+	assert.Nil(err)
+	assert.Equal(`{"Name":1,"name":3}`, res)
+
+	res, err = applyPatchWithOptions(`{"Name":1,"NAME":2}`, `[{"op": "replace", "path": "/name", "value": 3}]`, options)
+	assert.Nil(err)
+	assert.Equal(`{"Name":3,"NAME":2}`, res)
+
+	_, err = applyPatchWithOptions(doc, `[{"op": "replace", "path": "/name", "value": "Jane"}]`, nil)
+	assert.ErrorIs(err, ErrMissing)
+
+	node := NewNode([]byte(`{"items":[{"Kind":"Pod"},{"kind":"pod"},{"KIND":"Service"}]}`))
+	found, err := node.FindChildren(PVs{{Path: "/kind", Value: []byte(`"pod"`)}}, options)
+	assert.Nil(err)
+	assert.Equal([]string{"/items/1"}, found.Paths())
+	found, err = node.FindChildren(PVs{NewExistsPV("/kind")}, options)
+	assert.Nil(err)
+	assert.Equal(3, len(found))
+}
+
+func TestNormalizeUnicodeKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	// the decomposed "cafe\u0301" in the document and the composed "caf\u00e9" in the paths.
+	doc := "{\"cafe\u0301\":1,\"Cafe\u0301\":2}"
+	options := NewOptions()
+	options.NormalizeUnicodeKeys = true
+
+	res, err := applyPatchWithOptions(doc, `[{"op": "replace", "path": "/caf\u00e9", "value": 3}]`, options)
+	assert.Nil(err)
+	assert.Equal("{\"cafe\u0301\":3,\"Cafe\u0301\":2}", res)
+
+	_, err = applyPatchWithOptions(doc, `[{"op": "replace", "path": "/CAF\u00c9", "value": 3}]`, options)
+	assert.ErrorIs(err, ErrMissing)
+
+	options.FoldKeys = true
+	res, err = applyPatchWithOptions(doc, `[{"op": "remove", "path": "/CAF\u00c9"}]`, options)
+	assert.Nil(err)
+	assert.Equal("{\"Cafe\u0301\":2}", res)
+
+	_, err = applyPatchWithOptions(doc, `[{"op": "remove", "path": "/caf\u00e9"}]`, options.StrictRFC6902())
+	assert.ErrorIs(err, ErrMissing)
+}
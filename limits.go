@@ -0,0 +1,266 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NewPatchWithOptions decodes the passed JSON document as an RFC 6902 patch,
+// and checks it against the limits of the passed in Options.
+func NewPatchWithOptions(doc []byte, options *Options) (Patch, error) {
+	if options == nil {
+		return NewPatch(doc)
+	}
+	// a patch is an array of objects, they are not counted in the depth of the values.
+	if options.MaxDepth > 0 {
+		if d := jsonDepth(doc); d > options.MaxDepth+2 {
+			return nil, fmt.Errorf("patch of depth %d exceeds the limit %d, %w", d, options.MaxDepth+2, ErrLimitExceeded)
+		}
+	}
+
+	p, err := NewPatch(doc)
+	if err != nil {
+		return nil, err
+	}
+	if err = options.checkPatch(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// checkPatch checks the patch against the limits of the patch and the values.
+func (o *Options) checkPatch(p Patch) error {
+	if o.MaxPatchOps > 0 && len(p) > o.MaxPatchOps {
+		return patchOpsError(len(p), o.MaxPatchOps)
+	}
+	for i, op := range p {
+		if err := o.checkValue(op); err != nil {
+			return &PathError{Op: op.Op, Path: op.Path, Index: i, Err: err}
+		}
+	}
+	return nil
+}
+
+// checkLimits checks the document and the patch against the limits before the patch is applied.
+func (o *Options) checkLimits(n *Node, p Patch) error {
+	if o.MaxPatchOps > 0 && len(p) > o.MaxPatchOps {
+		return patchOpsError(len(p), o.MaxPatchOps)
+	}
+	return o.checkDocument(n)
+}
+
+// docBounds are the upper bounds of the encoded size and the nesting depth of the document of a node.
+// They are measured once, and grown by the operations applied to the node, so that the document is not
+// scanned again for every patch or read. They are measured again when they exceed a limit, or when the
+// document is changed through another node, such as a parent or a child, see lookupTable.
+type docBounds struct {
+	size  int64
+	depth int
+	// exact is true if the bounds are measured, not grown.
+	exact bool
+	gen   uint64
+}
+
+// checkDocument checks the document of the node against MaxDocumentBytes and MaxDepth,
+// before the document is parsed by a patch or a read.
+func (o *Options) checkDocument(n *Node) error {
+	if o == nil || n == nil || o.MaxDocumentBytes <= 0 && o.MaxDepth <= 0 {
+		return nil
+	}
+
+	b := n.bounds
+	if b == nil || b.gen != n.lookupGen() {
+		b = n.measure()
+	}
+	exceeded := func() bool {
+		return o.MaxDocumentBytes > 0 && b.size > o.MaxDocumentBytes || o.MaxDepth > 0 && b.depth > o.MaxDepth
+	}
+	if exceeded() && !b.exact {
+		b = n.measure()
+	}
+	if !exceeded() {
+		return nil
+	}
+	if o.MaxDocumentBytes > 0 && b.size > o.MaxDocumentBytes {
+		return fmt.Errorf("document of %d bytes exceeds the limit %d, %w", b.size, o.MaxDocumentBytes, ErrLimitExceeded)
+	}
+	return fmt.Errorf("document of depth %d exceeds the limit %d, %w", b.depth, o.MaxDepth, ErrLimitExceeded)
+}
+
+// measure measures the bounds of the document of the node, the frozen nodes are not changed.
+func (n *Node) measure() *docBounds {
+	b := &docBounds{size: n.encodedSize(), depth: n.depth(), exact: true, gen: n.lookupGen()}
+	if !n.frozen {
+		n.bounds = b
+	}
+	return b
+}
+
+// encodedSize returns the size of the encoded JSON of the node.
+func (n *Node) encodedSize() int64 {
+	if n.which == eRaw && n.raw != nil {
+		return int64(len(*n.raw))
+	}
+	data, err := n.MarshalJSON()
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
+
+// grow grows the bounds of the document of the node by the value written by the applied operation.
+func (n *Node) grow(pd container, op Operation, options *Options) {
+	b := n.bounds
+	if b == nil {
+		return
+	}
+	switch {
+	case op.Op == "test" || op.Op == "remove" || predicateOperations[op.Op]:
+		return
+	case op.Path == "" || op.Op != "add" && op.Op != "replace" && op.Op != "copy" && op.Op != "move":
+		// the custom operations can change the document anywhere.
+		n.bounds = nil
+		return
+	}
+
+	con, key := findObject(&pd, lastIndexPath(pd, op.Path, options), options)
+	if key == "-" {
+		if ary, ok := con.(*partialArray); ok && len(*ary) > 0 {
+			key = strconv.Itoa(len(*ary) - 1)
+		}
+	}
+	var val *Node
+	if con != nil {
+		val, _ = con.get(key, options)
+	}
+	if val == nil {
+		n.bounds = nil
+		return
+	}
+
+	// the key, the quotes, the colon and the comma of a member.
+	b.size += int64(len(key)) + 4
+	if op.Op != "move" {
+		b.size += val.encodedSize()
+	}
+	if d := pathDepth(op.Path) + val.depth(); d > b.depth {
+		b.depth = d
+	}
+	b.exact = false
+}
+
+// checkOperation checks the operation against the limits before it is applied.
+func (o *Options) checkOperation(pd container, op Operation) error {
+	if err := o.checkValue(op); err != nil {
+		return err
+	}
+	if o.MaxDepth <= 0 || op.Op != "copy" && op.Op != "move" {
+		return nil
+	}
+
+	con, key := findObject(&pd, op.From, o)
+	if con == nil {
+		return nil
+	}
+	if val, err := con.get(key, o); err == nil {
+		if d := pathDepth(op.Path) + val.depth(); d > o.MaxDepth {
+			return valueDepthError(op.Path, d, o.MaxDepth)
+		}
+	}
+	return nil
+}
+
+func (o *Options) checkValue(op Operation) error {
+	if op.Value == nil {
+		return nil
+	}
+	if o.MaxValueBytes > 0 && int64(len(op.Value)) > o.MaxValueBytes {
+		return fmt.Errorf("value of %d bytes exceeds the limit %d, %w", len(op.Value), o.MaxValueBytes, ErrLimitExceeded)
+	}
+	if o.MaxDepth > 0 {
+		if d := pathDepth(op.Path) + jsonDepth(op.Value); d > o.MaxDepth {
+			return valueDepthError(op.Path, d, o.MaxDepth)
+		}
+	}
+	return nil
+}
+
+func patchOpsError(n, limit int) error {
+	return fmt.Errorf("patch of %d operations exceeds the limit %d, %w", n, limit, ErrLimitExceeded)
+}
+
+func valueDepthError(path string, d, limit int) error {
+	return fmt.Errorf("value at %q of depth %d exceeds the limit %d, %w", path, d, limit, ErrLimitExceeded)
+}
+
+// depth returns the nesting depth of the objects and arrays in the node, 0 for the other values.
+// The children that have not been parsed are scanned without being parsed.
+func (n *Node) depth() int {
+	if n == nil {
+		return 0
+	}
+
+	d := 0
+	switch n.which {
+	case eDoc:
+		for _, v := range n.doc.obj {
+			if vd := v.depth(); vd > d {
+				d = vd
+			}
+		}
+		return d + 1
+	case eAry:
+		for _, v := range n.ary {
+			if vd := v.depth(); vd > d {
+				d = vd
+			}
+		}
+		return d + 1
+	case eRaw:
+		if n.raw != nil {
+			return jsonDepth(*n.raw)
+		}
+	}
+	return 0
+}
+
+// pathDepth returns the number of the containers traversed by the path.
+func pathDepth(path string) int {
+	return strings.Count(path, "/")
+}
+
+// jsonDepth returns the nesting depth of the objects and arrays in the encoded JSON.
+func jsonDepth(data json.RawMessage) int {
+	depth, max := 0, 0
+	inString, escaped := false, false
+	for _, c := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			if depth++; depth > max {
+				max = depth
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return max
+}
@@ -0,0 +1,197 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := `{"a":{"b":[1,{"c":"[[[{{"}]},"d":"x"}`
+	assert.Equal(4, NewNode([]byte(doc)).depth())
+
+	options := NewOptions()
+	options.MaxDepth = 4
+	options.MaxDocumentBytes = int64(len(doc))
+	options.MaxPatchOps = 2
+	options.MaxValueBytes = 8
+
+	res, err := applyPatchWithOptions(doc, `[
+		{"op": "add", "path": "/a/e", "value": {"f":[]}},
+		{"op": "copy", "from": "/a/e", "path": "/g"}
+	]`, options)
+	assert.Nil(err)
+	assert.Equal(`{"a":{"b":[1,{"c":"[[[{{"}],"e":{"f":[]}},"d":"x","g":{"f":[]}}`, res)
+
+	for i, c := range []struct {
+		doc, patch, msg string
+	}{
+		{doc + " ", `[]`, "document of 38 bytes exceeds the limit 37"},
+		{`[[[[[1]]]]]`, `[]`, "document of depth 5 exceeds the limit 4"},
+		{doc, `[{"op": "test", "path": "/d", "value": "x"}, {"op": "test", "path": "/d", "value": "x"},
+			{"op": "test", "path": "/d", "value": "x"}]`, "patch of 3 operations exceeds the limit 2"},
+		{doc, `[{"op": "add", "path": "/d", "value": "123456789"}]`, "operation 0, value of 11 bytes exceeds the limit 8"},
+		{doc, `[{"op": "add", "path": "/a/b/1/c", "value": [[]]}]`,
+			`operation 0, value at "/a/b/1/c" of depth 6 exceeds the limit 4`},
+		{doc, `[{"op": "add", "path": "/x", "value": 1}, {"op": "move", "from": "/a", "path": "/a/b/0"}]`,
+			`operation 1, value at "/a/b/0" of depth 6 exceeds the limit 4`},
+	} {
+		_, err := applyPatchWithOptions(c.doc, c.patch, options)
+		assert.ErrorIs(err, ErrLimitExceeded, "case %d", i)
+		assert.Equal(c.msg+", limit exceeded", err.Error(), "case %d", i)
+	}
+
+	p, err := NewPatchWithOptions([]byte(`[{"op": "add", "path": "/a/b", "value": [{}]}]`), options)
+	assert.Nil(err)
+	assert.Equal(1, len(p))
+
+	_, err = NewPatchWithOptions([]byte(`[{"op": "add", "path": "/a/b/c", "value": [{}]}]`), options)
+	assert.ErrorIs(err, ErrLimitExceeded)
+	_, err = NewPatchWithOptions([]byte(`[{"op": "add", "path": "", "value": `+strings.Repeat("[", 100)+`]}]`), options)
+	assert.Equal("patch of depth 102 exceeds the limit 6, limit exceeded", err.Error())
+	_, err = NewPatchWithOptions([]byte(`[{"op": "test", "path": ""}, {"op": "test", "path": ""}, {"op": "test", "path": ""}]`),
+		options)
+	assert.ErrorIs(err, ErrLimitExceeded)
+	_, err = NewPatchWithOptions([]byte(`[{"op": "test", "path": ""}, {"op": "test", "path": ""}, {"op": "test", "path": ""}]`),
+		nil)
+	assert.Nil(err)
+}
+
+func TestCopySizeLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := `{"a":"0123456789","b":[],"e":[1,2,3,4,5,6,7]}`
+	options := NewOptions()
+	options.MaxCopyBytes = 12
+	options.AccumulatedCopySizeLimit = 30
+
+	node := NewNode([]byte(doc))
+	assert.Nil(node.Patch(Patch{
+		{Op: "copy", From: "/a", Path: "/b/-"},
+		{Op: "copy", From: "/a", Path: "/b/-"},
+	}, options))
+
+	_, err := applyPatchWithOptions(doc, `[{"op": "copy", "from": "/e", "path": "/b/-"}]`, options)
+	assert.ErrorIs(err, ErrLimitExceeded)
+	assert.Equal(`operation 0, copy operation from path "/e" of 15 bytes exceeds the limit 12, limit exceeded`, err.Error())
+
+	p := Patch{
+		{Op: "copy", From: "/a", Path: "/b/-"},
+		{Op: "move", From: "/b", Path: "/c"},
+		{Op: "copy", From: "/a", Path: "/c/-"},
+		{Op: "copy", From: "/a", Path: "/d"},
+	}
+	err = NewNode([]byte(doc)).Patch(p, options)
+	assert.ErrorIs(err, ErrLimitExceeded)
+	var pe *PathError
+	assert.ErrorAs(err, &pe)
+	assert.Equal(3, pe.Index)
+	var ce *AccumulatedCopySizeError
+	assert.ErrorAs(err, &ce)
+	assert.Equal("/a", ce.From)
+	assert.Equal("/d", ce.Path)
+	assert.Equal(`operation 3, unable to copy from path "/a" to "/d", the accumulated size increase of copy is 36, exceeding the limit 30`,
+		err.Error())
+
+	// the limits apply to every call.
+	options.MaxCopyBytes = 0
+	options.AccumulatedCopySizeLimit = 0
+	assert.Nil(NewNode([]byte(doc)).Patch(p, options))
+	assert.Nil(node.Patch(Patch{{Op: "copy", From: "/e", Path: "/f"}}, options))
+}
+
+func TestDocumentLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("parsed document", func(t *testing.T) {
+		options := NewOptions()
+		options.MaxDocumentBytes = 20
+
+		node := NewNode([]byte(`{"a":[]}`))
+		assert.Nil(node.Patch(Patch{{Op: "add", Path: "/a/-", Value: json.RawMessage(`"0123456789"`)}}, nil))
+		assert.Nil(node.Patch(Patch{{Op: "add", Path: "/a/-", Value: json.RawMessage(`"0123456789"`)}}, options))
+		err := node.Patch(Patch{{Op: "test", Path: "/a/0", Value: json.RawMessage(`"0123456789"`)}}, options)
+		assert.ErrorIs(err, ErrLimitExceeded)
+		assert.Equal("document of 33 bytes exceeds the limit 20, limit exceeded", err.Error())
+	})
+
+	t.Run("incremental bounds", func(t *testing.T) {
+		options := NewOptions()
+		options.MaxDepth = 4
+		options.MaxDocumentBytes = 1000
+
+		node := NewNode([]byte(`{"a":{}}`))
+		assert.Nil(node.Patch(Patch{{Op: "add", Path: "/a/b", Value: json.RawMessage(`{"c":[1]}`)}}, options))
+		b := node.bounds
+		assert.NotNil(b)
+		assert.False(b.exact)
+		assert.Equal(4, b.depth)
+		assert.GreaterOrEqual(b.size, node.encodedSize())
+
+		// the bounds are grown, not measured again.
+		assert.Nil(node.Patch(Patch{
+			{Op: "copy", From: "/a/b/c", Path: "/d"},
+			{Op: "move", From: "/d", Path: "/e"},
+		}, options))
+		assert.Same(b, node.bounds)
+		assert.Equal(4, b.depth)
+		assert.GreaterOrEqual(b.size, node.encodedSize())
+
+		// the bounds are measured again when they exceed a limit.
+		options.MaxDocumentBytes = node.encodedSize()
+		assert.Nil(node.Patch(Patch{{Op: "test", Path: "/e", Value: json.RawMessage(`[1]`)}}, options))
+		assert.NotSame(b, node.bounds)
+		assert.True(node.bounds.exact)
+		assert.Equal(node.encodedSize(), node.bounds.size)
+	})
+
+	t.Run("read paths", func(t *testing.T) {
+		options := NewOptions()
+		options.MaxDepth = 4
+		doc := []byte(`{"a":[[[[1]]]],"b":1}`)
+		tests := []*PV{{Path: "/b", Value: json.RawMessage(`1`)}}
+
+		_, err := NewNode(doc).GetChild("/b", options)
+		assert.ErrorIs(err, ErrLimitExceeded)
+		var pe *PathError
+		assert.ErrorAs(err, &pe)
+		assert.Equal("get", pe.Op)
+		_, _, _, err = Resolve(NewNode(doc), "/b", options)
+		assert.ErrorIs(err, ErrLimitExceeded)
+		_, err = NewNode(doc).FindChildren(tests, options)
+		assert.ErrorIs(err, ErrLimitExceeded)
+		_, err = NewNode(doc).FindChildNodes(tests, options)
+		assert.ErrorIs(err, ErrLimitExceeded)
+		_, _, err = NewNode(doc).FindChildrenPage(tests, "", 10, options)
+		assert.ErrorIs(err, ErrLimitExceeded)
+		_, err = FindInDocs(map[string][]byte{"x": doc}, tests, options)
+		assert.ErrorIs(err, ErrLimitExceeded)
+
+		options.MaxDepth = 5
+		_, err = NewNode(doc).GetChild("/b", options)
+		assert.Nil(err)
+		res, err := NewNode(doc).FindChildren(tests, options)
+		assert.Nil(err)
+		assert.Equal(1, len(res))
+	})
+
+	t.Run("changed by a child", func(t *testing.T) {
+		options := NewOptions()
+		options.MaxDocumentBytes = 30
+
+		root := NewNode([]byte(`{"a":{"b":1}}`))
+		child, err := root.GetChild("/a", options)
+		assert.Nil(err)
+		assert.Nil(child.Patch(Patch{{Op: "add", Path: "/c", Value: json.RawMessage(`"0123456789012345"`)}}, nil))
+		_, err = root.GetChild("/a", options)
+		assert.ErrorIs(err, ErrLimitExceeded)
+		assert.Contains(err.Error(), "document of 36 bytes exceeds the limit 30")
+	})
+}
@@ -0,0 +1,112 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// lookupTable caches the containers of the parents of the paths resolved in the nodes of a document.
+// It is shared by the document and the children resolved from it, see shareLookups, and it is safe for
+// the concurrent reads of a frozen node.
+type lookupTable struct {
+	// gen counts the changes of the document. The cached entries of the nodes that are not frozen are only
+	// used in the generation that cached them, so that the changes made through their parents or their
+	// children are never missed. The frozen nodes can't be changed, their entries are always used.
+	gen     uint64
+	mu      sync.Mutex
+	entries map[lookupKey]lookupEntry
+}
+
+type lookupKey struct {
+	node *Node
+	path string
+}
+
+type lookupEntry struct {
+	options *Options
+	gen     uint64
+	con     container
+	key     string
+}
+
+// changed invalidates the cached entries of the document of the node.
+func (n *Node) changed() {
+	if n != nil && n.lookups != nil {
+		atomic.AddUint64(&n.lookups.gen, 1)
+	}
+}
+
+// lookupGen returns the generation of the document of the node, see lookupTable.
+func (n *Node) lookupGen() uint64 {
+	if n == nil || n.lookups == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&n.lookups.gen)
+}
+
+// shareLookups shares the lookup cache of the node with its child, so that the changes made through
+// either of them invalidate the entries of both. The frozen nodes have their cache created by Freeze.
+func (n *Node) shareLookups(child *Node) {
+	if child == nil || child == n || child.frozen || n.frozen {
+		return
+	}
+	if n.lookups == nil {
+		n.lookups = &lookupTable{}
+	}
+	child.lookups = n.lookups
+}
+
+// ClearLookupCache clears the cache of the paths resolved in the node and the children sharing its cache,
+// see Options.LookupCacheSize.
+func (n *Node) ClearLookupCache() {
+	if n == nil || n.lookups == nil {
+		return
+	}
+	n.lookups.mu.Lock()
+	n.lookups.entries = nil
+	n.lookups.mu.Unlock()
+}
+
+// lookup returns the container of the parent of the path in the node and the key of the path in it,
+// from the cache if Options.LookupCacheSize is greater than 0. The entries are cached with the options
+// that resolved them.
+func (n *Node) lookup(pd container, path string, options *Options) (container, string) {
+	if options.LookupCacheSize <= 0 {
+		return findObject(&pd, lastIndexPath(pd, path, options), options)
+	}
+	if n.lookups == nil {
+		// the frozen nodes have their cache created by Freeze, or no cache.
+		if n.frozen {
+			return findObject(&pd, lastIndexPath(pd, path, options), options)
+		}
+		n.lookups = &lookupTable{}
+	}
+
+	t := n.lookups
+	var gen uint64
+	if !n.frozen {
+		gen = atomic.LoadUint64(&t.gen)
+	}
+	k := lookupKey{n, path}
+	t.mu.Lock()
+	e, ok := t.entries[k]
+	t.mu.Unlock()
+	if ok && e.options == options && e.gen == gen {
+		return e.con, e.key
+	}
+
+	con, key := findObject(&pd, lastIndexPath(pd, path, options), options)
+	if con == nil {
+		return nil, ""
+	}
+	t.mu.Lock()
+	if t.entries == nil || len(t.entries) >= options.LookupCacheSize {
+		t.entries = make(map[lookupKey]lookupEntry)
+	}
+	t.entries[k] = lookupEntry{options, gen, con, key}
+	t.mu.Unlock()
+	return con, key
+}
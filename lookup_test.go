@@ -0,0 +1,233 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupCache(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(`{"a":{"b":{"c":1}},"l":[1,2,3]}`))
+	stats := &ApplyStats{}
+	options := NewOptions()
+	options.LookupCacheSize = 2
+	options.Stats = stats
+
+	v, err := node.GetValue("/a/b/c", options)
+	assert.Nil(err)
+	assert.Equal(`1`, string(v))
+	assert.Equal(int64(3), stats.NodesVisited)
+	v, err = node.GetValue("/a/b/c", options)
+	assert.Nil(err)
+	assert.Equal(`1`, string(v))
+	assert.Equal(int64(3), stats.NodesVisited)
+
+	// the cached entries are used with their options only.
+	v, err = node.GetValue("/a/b/c", &Options{Stats: stats})
+	assert.Nil(err)
+	assert.Equal(`1`, string(v))
+	assert.Equal(int64(6), stats.NodesVisited)
+
+	// the patches of the node clear the cache.
+	assert.Nil(node.Patch(Patch{NewReplaceOperation("/a", []byte(`{"b":{"c":2}}`))}, nil))
+	v, err = node.GetValue("/a/b/c", options)
+	assert.Nil(err)
+	assert.Equal(`2`, string(v))
+	assert.Equal(int64(9), stats.NodesVisited)
+
+	assert.Nil(node.PatchAt("/a/b", Patch{NewReplaceOperation("/c", []byte(`3`))}, nil))
+	v, err = node.GetValue("/a/b/c", options)
+	assert.Nil(err)
+	assert.Equal(`3`, string(v))
+
+	// the patches of the children invalidate the cache.
+	v, err = node.GetValue("/l/-", options)
+	assert.Nil(err)
+	assert.Equal(`3`, string(v))
+	l, err := node.GetChild("/l", options)
+	assert.Nil(err)
+	assert.Nil(l.Patch(Patch{NewReplaceOperation("", []byte(`{}`))}, nil))
+	_, err = node.GetValue("/l/-", options)
+	assert.ErrorIs(err, ErrMissing)
+
+	// the cache is cleared when it is full.
+	node.ClearLookupCache()
+	for _, path := range []string{"/a", "/a/b", "/a/b/c", "/l"} {
+		_, err = node.GetValue(path, options)
+		assert.Nil(err)
+	}
+	assert.Equal(2, len(node.lookups.entries))
+
+	_, err = node.GetValue("/x/y", options)
+	assert.ErrorIs(err, ErrMissing)
+	assert.Nil(node.UnmarshalJSON([]byte(`{"a":{"b":{"c":4}}}`)))
+	assert.Nil(node.lookups.entries)
+	v, err = node.GetValue("/a/b/c", options)
+	assert.Nil(err)
+	assert.Equal(`4`, string(v))
+}
+
+func TestLookupCacheParentPatch(t *testing.T) {
+	assert := assert.New(t)
+
+	options := NewOptions()
+	options.LookupCacheSize = 10
+
+	root := NewNode([]byte(`{"a":{"b":{"c":1}},"l":[[1],[2]]}`))
+	c, err := root.GetChild("/a", options)
+	assert.Nil(err)
+	v, err := c.GetValue("/b/c", options)
+	assert.Nil(err)
+	assert.Equal(`1`, string(v))
+	assert.Nil(root.Patch(Patch{NewReplaceOperation("/a/b", []byte(`{"c":2}`))}, nil))
+	assert.Equal(`{"b":{"c":2}}`, mustJSONString(c))
+	v, err = c.GetValue("/b/c", options)
+	assert.Nil(err)
+	assert.Equal(`2`, string(v))
+
+	l, err := root.GetChild("/l", options)
+	assert.Nil(err)
+	v, err = l.GetValue("/1/0", options)
+	assert.Nil(err)
+	assert.Equal(`2`, string(v))
+	assert.Nil(root.Patch(Patch{NewRemoveOperation("/l/0")}, nil))
+	_, err = l.GetValue("/1/0", options)
+	assert.ErrorIs(err, ErrMissing)
+	v, err = l.GetValue("/0/0", options)
+	assert.Nil(err)
+	assert.Equal(`2`, string(v))
+	assert.Nil(root.Patch(Patch{NewReplaceOperation("/l/0", []byte(`[3]`))}, nil))
+	v, err = l.GetValue("/0/0", options)
+	assert.Nil(err)
+	assert.Equal(`3`, string(v))
+}
+
+func TestLookupCacheDocuments(t *testing.T) {
+	assert := assert.New(t)
+
+	stats := &ApplyStats{}
+	options := NewOptions()
+	options.LookupCacheSize = 10
+	options.Stats = stats
+
+	a := NewNode([]byte(`{"a":{"b":{"c":1}}}`))
+	b := NewNode([]byte(`{"a":{"b":{"c":1}}}`))
+	_, err := a.GetValue("/a/b/c", options)
+	assert.Nil(err)
+	_, err = b.GetValue("/a/b/c", options)
+	assert.Nil(err)
+	assert.Equal(int64(6), stats.NodesVisited)
+
+	// the changes of a document don't evict the entries of the others.
+	assert.Nil(b.Patch(Patch{NewReplaceOperation("/a/b/c", []byte(`2`))}, nil))
+	v, err := a.GetValue("/a/b/c", options)
+	assert.Nil(err)
+	assert.Equal(`1`, string(v))
+	assert.Equal(int64(6), stats.NodesVisited)
+	assert.Equal(1, len(a.lookups.entries))
+	v, err = b.GetValue("/a/b/c", options)
+	assert.Nil(err)
+	assert.Equal(`2`, string(v))
+	assert.Equal(int64(9), stats.NodesVisited)
+
+	// the children resolved without the cache share the cache of the document.
+	c, err := a.GetChild("/a", nil)
+	assert.Nil(err)
+	assert.True(c.lookups == a.lookups)
+	_, err = c.GetValue("/b/c", options)
+	assert.Nil(err)
+	assert.Nil(a.Patch(Patch{NewRemoveOperation("/a/b")}, nil))
+	_, err = c.GetValue("/b/c", options)
+	assert.ErrorIs(err, ErrMissing)
+
+	// the matched children share the cache of the document.
+	d := NewNode([]byte(`{"items":[{"id":1}]}`))
+	v, err = d.GetValue("/items/0/id", options)
+	assert.Nil(err)
+	assert.Equal(`1`, string(v))
+	res, err := d.FindChildNodes([]*PV{{"/id", []byte(`1`)}}, options)
+	assert.Nil(err)
+	assert.Equal(1, len(res))
+	assert.True(res[0].Node.lookups == d.lookups)
+	assert.Nil(res[0].Node.Patch(Patch{NewReplaceOperation("", []byte(`{"id":2}`))}, nil))
+	v, err = d.GetValue("/items/0/id", options)
+	assert.Nil(err)
+	assert.Equal(`2`, string(v))
+
+	// the removed children are detached.
+	b.ClearLookupCache()
+	c, err = b.Remove("/a", options)
+	assert.Nil(err)
+	assert.Nil(c.lookups)
+}
+
+func TestLookupCacheRedact(t *testing.T) {
+	assert := assert.New(t)
+
+	options := NewOptions()
+	options.LookupCacheSize = 10
+
+	node := NewNode([]byte(`{"a":{"b":"pii"},"c":1}`))
+	v, err := node.GetValue("/a/b", options)
+	assert.Nil(err)
+	assert.Equal(`"pii"`, string(v))
+	_, err = node.Redact([]string{"/a"}, nil, options)
+	assert.Nil(err)
+	assert.Equal(`{"c":1}`, mustJSONString(node))
+	_, err = node.GetValue("/a/b", options)
+	assert.ErrorIs(err, ErrMissing)
+
+	node = NewNode([]byte(`{"users":[{"id":1,"p":{"email":"x"}}]}`))
+	user, err := node.GetChild("/users/0", options)
+	assert.Nil(err)
+	v, err = user.GetValue("/p/email", options)
+	assert.Nil(err)
+	assert.Equal(`"x"`, string(v))
+	v, err = node.GetValue("/users/0/p/email", options)
+	assert.Nil(err)
+	assert.Equal(`"x"`, string(v))
+	_, err = node.RedactChildren([]*PV{{Path: "/id", Value: []byte(`1`)}}, []string{"/p"}, nil, options)
+	assert.Nil(err)
+	_, err = user.GetValue("/p/email", options)
+	assert.ErrorIs(err, ErrMissing)
+	_, err = node.GetValue("/users/0/p/email", options)
+	assert.ErrorIs(err, ErrMissing)
+}
+
+func TestLookupCacheFrozen(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(`{"a":{"b":[1,2,3]}}`))
+	assert.Nil(node.Freeze())
+	options := NewOptions()
+	options.LookupCacheSize = 10
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				v, err := node.GetValue("/a/b/-", options)
+				assert.Nil(err)
+				assert.Equal(`3`, string(v))
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(1, len(node.lookups.entries))
+
+	// the frozen children have no cache.
+	child, err := node.GetChild("/a", options)
+	assert.Nil(err)
+	v, err := child.GetValue("/b/0", options)
+	assert.Nil(err)
+	assert.Equal(`1`, string(v))
+	assert.Nil(child.lookups)
+}
@@ -0,0 +1,206 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ArrayMergeStrategy is how MergePatchWithOptions merges the arrays of a merge patch into the arrays of a document.
+type ArrayMergeStrategy string
+
+// The strategies to merge arrays.
+const (
+	// ArrayReplace replaces the arrays, as RFC 7386.
+	ArrayReplace ArrayMergeStrategy = "replace"
+	// ArrayAppend appends the elements of the patch to the arrays.
+	ArrayAppend ArrayMergeStrategy = "append"
+	// ArrayMergeByIndex merges the elements of the patch into the elements at the same indices,
+	// a null element removes the element at its index, and the extra elements are appended.
+	ArrayMergeByIndex ArrayMergeStrategy = "merge-by-index"
+	// ArrayMergeByKey merges the object elements of the patch into the object elements with the same value
+	// of MergeOptions.ArrayMergeKey, the other elements are appended.
+	ArrayMergeByKey ArrayMergeStrategy = "merge-by-key"
+)
+
+// MergeOptions specifies options for MergePatchWithOptions.
+type MergeOptions struct {
+	// ArrayMergeStrategy is how the arrays of the patch are merged into the arrays of the document.
+	// Default to "", which means ArrayReplace.
+	ArrayMergeStrategy ArrayMergeStrategy
+	// ArrayMergeKey is the member of the array elements that identifies them for ArrayMergeByKey, such as "id".
+	ArrayMergeKey string
+}
+
+// MergePatch applies the JSON merge patch (RFC 7386) to a JSON document, and returns the new document.
+// The members of the patch with null values are removed from the document,
+// the other members are merged recursively, and the values that are not objects replace the targets.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	return MergePatchWithOptions(doc, patch, nil)
+}
+
+// MergePatchWithOptions applies the JSON merge patch to a JSON document with the passed in MergeOptions,
+// and returns the new document.
+func MergePatchWithOptions(doc, patch []byte, options *MergeOptions) ([]byte, error) {
+	if !json.Valid(doc) {
+		return nil, errors.New("invalid JSON document")
+	}
+	if !json.Valid(patch) {
+		return nil, errors.New("invalid JSON merge patch")
+	}
+	if options == nil {
+		options = &MergeOptions{}
+	}
+	switch options.ArrayMergeStrategy {
+	case "", ArrayReplace, ArrayAppend, ArrayMergeByIndex:
+	case ArrayMergeByKey:
+		if options.ArrayMergeKey == "" {
+			return nil, errors.New("merge-by-key strategy requires ArrayMergeKey")
+		}
+	default:
+		return nil, fmt.Errorf("unexpected array merge strategy %q", options.ArrayMergeStrategy)
+	}
+
+	return mergePatch(NewNode(doc), NewNode(patch), options).MarshalJSON()
+}
+
+// mergePatch merges the patch into the target node, it returns the merged node.
+func mergePatch(target, patch *Node, options *MergeOptions) *Node {
+	if target != nil {
+		target.intoContainer()
+	}
+	patch.intoContainer()
+	if patch.which == eAry && target != nil && target.which == eAry {
+		return mergeArray(target, patch, options)
+	}
+	if patch.which != eDoc {
+		return patch
+	}
+	if target == nil || target.which != eDoc {
+		target = &Node{doc: &partialDoc{obj: make(map[string]*Node, len(patch.doc.keys))}, which: eDoc}
+	}
+
+	opts := NewOptions()
+	for _, k := range patch.doc.keys {
+		v := patch.doc.obj[k]
+		if v.isNull() {
+			if _, ok := target.doc.obj[k]; ok {
+				target.doc.remove(k, opts)
+			}
+			continue
+		}
+		target.doc.set(k, mergePatch(target.doc.obj[k], v, options), opts)
+	}
+	return target
+}
+
+// mergeArray merges the patch array into the target array with the strategy of the options.
+func mergeArray(target, patch *Node, options *MergeOptions) *Node {
+	var res partialArray
+	switch options.ArrayMergeStrategy {
+	case ArrayAppend:
+		res = append(res, target.ary...)
+		for _, v := range patch.ary {
+			res = append(res, mergePatch(nil, v, options))
+		}
+
+	case ArrayMergeByIndex:
+		for i, v := range patch.ary {
+			switch {
+			case i >= len(target.ary):
+				if !v.isNull() {
+					res = append(res, mergePatch(nil, v, options))
+				}
+			case !v.isNull():
+				res = append(res, mergePatch(target.ary[i], v, options))
+			}
+		}
+		if len(target.ary) > len(patch.ary) {
+			res = append(res, target.ary[len(patch.ary):]...)
+		}
+
+	case ArrayMergeByKey:
+		res = append(res, target.ary...)
+		for _, v := range patch.ary {
+			idx := -1
+			if id := arrayMergeID(v, options.ArrayMergeKey); id != nil {
+				for i, item := range res {
+					if iid := arrayMergeID(item, options.ArrayMergeKey); iid != nil && iid.Equal(id) {
+						idx = i
+						break
+					}
+				}
+			}
+			if idx >= 0 {
+				res[idx] = mergePatch(res[idx], v, options)
+			} else {
+				res = append(res, mergePatch(nil, v, options))
+			}
+		}
+
+	default:
+		return patch
+	}
+	return &Node{ary: res, which: eAry}
+}
+
+// arrayMergeID returns the value of the key member of an object element, or nil.
+func arrayMergeID(n *Node, key string) *Node {
+	if n.intoContainer(); n.which != eDoc {
+		return nil
+	}
+	return n.doc.obj[key]
+}
+
+// MergeMergePatches combines two JSON merge patches (RFC 7386) into one patch,
+// applying it is equivalent to applying the patch a and then the patch b.
+// The null values of both patches are kept, so the members deleted by them are still deleted.
+//
+// A patch can not replace a member with an object, so an error is returned
+// if the patch a sets a member to a value that is not an object (including null) and the patch b merges an object into it.
+func MergeMergePatches(a, b []byte) ([]byte, error) {
+	if !json.Valid(a) || !json.Valid(b) {
+		return nil, errors.New("invalid JSON merge patch")
+	}
+
+	res, err := mergeMergePatches(NewNode(a), NewNode(b), "")
+	if err != nil {
+		return nil, err
+	}
+	return res.MarshalJSON()
+}
+
+// mergeMergePatches combines the patch b into the patch a at the path.
+func mergeMergePatches(a, b *Node, path string) (*Node, error) {
+	if b.isNull() {
+		return b, nil
+	}
+	if b.intoContainer(); b.which != eDoc {
+		return b, nil
+	}
+	if a.isNull() {
+		return nil, fmt.Errorf("merge patches can not be combined at %q, object merged into a value that is not an object",
+			path)
+	}
+	if a.intoContainer(); a.which != eDoc {
+		return nil, fmt.Errorf("merge patches can not be combined at %q, object merged into a value that is not an object",
+			path)
+	}
+
+	opts := NewOptions()
+	for _, k := range b.doc.keys {
+		v := b.doc.obj[k]
+		if av, ok := a.doc.obj[k]; ok {
+			m, err := mergeMergePatches(av, v, path+"/"+encodePatchKey(k))
+			if err != nil {
+				return nil, err
+			}
+			v = m
+		}
+		a.doc.set(k, v, opts)
+	}
+	return a, nil
+}
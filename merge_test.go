@@ -0,0 +1,121 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatch(t *testing.T) {
+	assert := assert.New(t)
+
+	// RFC 7386, Appendix A
+	for i, c := range []struct {
+		doc, patch, result string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		// RFC 7386, Section 3
+		{`{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"],"content":"This will be unchanged"}`,
+			`{"title":"Hello!","phoneNumber":"+01-123-456-7890","author":{"familyName":null},"tags":["example"]}`,
+			`{"title":"Hello!","author":{"givenName":"John"},"tags":["example"],"content":"This will be unchanged","phoneNumber":"+01-123-456-7890"}`},
+	} {
+		res, err := MergePatch([]byte(c.doc), []byte(c.patch))
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.result, string(res), "case %d", i)
+	}
+
+	_, err := MergePatch([]byte(`{`), []byte(`{}`))
+	assert.NotNil(err)
+	_, err = MergePatch([]byte(`{}`), []byte(`{`))
+	assert.NotNil(err)
+}
+
+func TestMergePatchWithOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	for i, c := range []struct {
+		options            *MergeOptions
+		doc, patch, result string
+	}{
+		{nil, `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+		{&MergeOptions{ArrayMergeStrategy: ArrayReplace}, `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+		{&MergeOptions{ArrayMergeStrategy: ArrayAppend}, `{"a":[1,2]}`, `{"a":[3,{"b":null}]}`, `{"a":[1,2,3,{}]}`},
+		{&MergeOptions{ArrayMergeStrategy: ArrayAppend}, `{"a":"x"}`, `{"a":[3]}`, `{"a":[3]}`},
+		{&MergeOptions{ArrayMergeStrategy: ArrayAppend}, `[1]`, `[2]`, `[1,2]`},
+		{&MergeOptions{ArrayMergeStrategy: ArrayMergeByIndex},
+			`{"a":[{"b":1,"c":2},3,4]}`, `{"a":[{"c":null,"d":5}]}`, `{"a":[{"b":1,"d":5},3,4]}`},
+		{&MergeOptions{ArrayMergeStrategy: ArrayMergeByIndex}, `{"a":[1,2,3]}`, `{"a":[0,null]}`, `{"a":[0,3]}`},
+		{&MergeOptions{ArrayMergeStrategy: ArrayMergeByIndex}, `{"a":[1]}`, `{"a":[2,3,null]}`, `{"a":[2,3]}`},
+		{&MergeOptions{ArrayMergeStrategy: ArrayMergeByIndex}, `{"a":[[1,2]]}`, `{"a":[[9]]}`, `{"a":[[9,2]]}`},
+		{&MergeOptions{ArrayMergeStrategy: ArrayMergeByKey, ArrayMergeKey: "id"},
+			`{"a":[{"id":1,"v":"x"},{"id":2,"v":"y"}]}`,
+			`{"a":[{"id":2,"v":"z","w":null},{"id":3,"v":"w"},4]}`,
+			`{"a":[{"id":1,"v":"x"},{"id":2,"v":"z"},{"id":3,"v":"w"},4]}`},
+		{&MergeOptions{ArrayMergeStrategy: ArrayMergeByKey, ArrayMergeKey: "id"},
+			`{"a":[{"id":1,"v":{"x":1,"y":2}}]}`, `{"a":[{"id":1,"v":{"y":null}}]}`, `{"a":[{"id":1,"v":{"x":1}}]}`},
+	} {
+		res, err := MergePatchWithOptions([]byte(c.doc), []byte(c.patch), c.options)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.result, string(res), "case %d", i)
+	}
+
+	_, err := MergePatchWithOptions([]byte(`{}`), []byte(`{}`), &MergeOptions{ArrayMergeStrategy: ArrayMergeByKey})
+	assert.ErrorContains(err, "requires ArrayMergeKey")
+	_, err = MergePatchWithOptions([]byte(`{}`), []byte(`{}`), &MergeOptions{ArrayMergeStrategy: "zip"})
+	assert.ErrorContains(err, `unexpected array merge strategy "zip"`)
+}
+
+func TestMergeMergePatches(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := `{"a":{"b":1,"c":2},"d":[1],"e":"x","f":{"g":1}}`
+	for i, c := range []struct {
+		a, b, result string
+	}{
+		{`{"a":{"b":3}}`, `{"a":{"c":null}}`, `{"a":{"b":3,"c":null}}`},
+		{`{"a":{"b":null}}`, `{"a":{"b":4}}`, `{"a":{"b":4}}`},
+		{`{"e":"y"}`, `{"e":null}`, `{"e":null}`},
+		{`{"f":null}`, `{"d":[2]}`, `{"f":null,"d":[2]}`},
+		{`{"a":{"x":{"y":1}}}`, `{"a":"z"}`, `{"a":"z"}`},
+		{`{"a":{"x":{"y":1,"z":null}}}`, `{"a":{"x":{"y":null}}}`, `{"a":{"x":{"y":null,"z":null}}}`},
+		{`{"a":1}`, `[1]`, `[1]`},
+		{`{}`, `{"h":{"i":null}}`, `{"h":{"i":null}}`},
+	} {
+		res, err := MergeMergePatches([]byte(c.a), []byte(c.b))
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.result, string(res), "case %d", i)
+
+		// applying the combined patch is equivalent to applying the patches one by one
+		step, err := MergePatch([]byte(doc), []byte(c.a))
+		assert.Nil(err, "case %d", i)
+		step, err = MergePatch(step, []byte(c.b))
+		assert.Nil(err, "case %d", i)
+		once, err := MergePatch([]byte(doc), res)
+		assert.Nil(err, "case %d", i)
+		assert.JSONEq(string(step), string(once), "case %d", i)
+	}
+
+	_, err := MergeMergePatches([]byte(`{"a":{"b":null}}`), []byte(`{"a":{"b":{"c":1}}}`))
+	assert.ErrorContains(err, `merge patches can not be combined at "/a/b"`)
+	_, err = MergeMergePatches([]byte(`[1]`), []byte(`{}`))
+	assert.ErrorContains(err, `merge patches can not be combined at ""`)
+	_, err = MergeMergePatches([]byte(`{`), []byte(`{}`))
+	assert.ErrorContains(err, "invalid JSON merge patch")
+}
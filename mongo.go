@@ -0,0 +1,214 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ToMongoUpdate translates the patch into a MongoDB update document, so that the patch can be applied
+// by the database without reading the document. The result maps the update operators to their fields,
+// such as {"$set": {"a.b": 1}, "$unset": {"c": ""}}, it can be converted into a bson.M.
+//
+// The paths are translated into dot notation, and the numeric reference tokens are treated as array indices:
+//   - "add" sets the field, it pushes to the array for the "-" token and inserts at the position for an index,
+//   - "replace" sets the field, "remove" unsets the field, and "move" renames the field,
+//   - "inc" (a custom operation with a numeric value) increments the field.
+//
+// The values are decoded with integers as int64. An error is returned for the operations that can not be
+// translated: "test", "copy", the root path, removing or moving array elements, and the field names
+// with "." or a leading "$". MongoDB rejects the updates that change overlapping paths with different operators,
+// so an error wrapping ErrTransformConflict is returned for them.
+func (p Patch) ToMongoUpdate() (map[string]interface{}, error) {
+	res := make(map[string]interface{})
+	fieldsOf := func(operator string) map[string]interface{} {
+		fields, ok := res[operator].(map[string]interface{})
+		if !ok {
+			fields = make(map[string]interface{})
+			res[operator] = fields
+		}
+		return fields
+	}
+
+	// touch records the path changed by the operator, the changes of the same path by "$set", "$unset" and
+	// "$push" (without position) are combined, the other changes of overlapping paths conflict.
+	var touched []mongoTouch
+	touch := func(i int, operator, path string, exclusive bool) (string, error) {
+		field, err := mongoField(path)
+		if err != nil {
+			return "", err
+		}
+		for _, t := range touched {
+			if pathsOverlap(t.path, path) &&
+				(t.operator != operator || t.path != path || t.exclusive || exclusive) {
+				return "", fmt.Errorf("path %q conflicts with operation %d, %w", path, t.index, ErrTransformConflict)
+			}
+		}
+		touched = append(touched, mongoTouch{i, operator, path, exclusive})
+		return field, nil
+	}
+
+	for i, op := range p {
+		if err := mongoUpdateOp(i, op, fieldsOf, touch); err != nil {
+			return nil, &PathError{Op: op.Op, Path: op.Path, Index: i, Err: err}
+		}
+	}
+	return res, nil
+}
+
+// mongoUpdateOp translates the operation of index i into the fields of the update operators.
+func mongoUpdateOp(i int, op Operation,
+	fieldsOf func(operator string) map[string]interface{},
+	touch func(i int, operator, path string, exclusive bool) (string, error),
+) error {
+	switch op.Op {
+	case "add", "replace", "inc":
+		v, err := mongoValue(op.Value)
+		if err != nil {
+			return fmt.Errorf("%s operation has invalid value, %w", op.Op, err)
+		}
+		parent, key := splitLastPath(op.Path)
+		switch {
+		case op.Op == "inc":
+			switch v.(type) {
+			case int64, float64:
+			default:
+				return fmt.Errorf("inc operation has non-numeric value %s", op.Value)
+			}
+			field, err := touch(i, "$inc", op.Path, true)
+			if err != nil {
+				return err
+			}
+			fieldsOf("$inc")[field] = v
+
+		case op.Op == "add" && key == "-":
+			field, err := touch(i, "$push", parent, false)
+			if err != nil {
+				return err
+			}
+			push := fieldsOf("$push")
+			if each, ok := push[field].(map[string]interface{}); ok {
+				each["$each"] = append(each["$each"].([]interface{}), v)
+			} else {
+				push[field] = map[string]interface{}{"$each": []interface{}{v}}
+			}
+
+		case op.Op == "add" && isArrayIndex(key):
+			field, err := touch(i, "$push", parent, true)
+			if err != nil {
+				return err
+			}
+			idx, _ := strconv.Atoi(key)
+			fieldsOf("$push")[field] = map[string]interface{}{"$each": []interface{}{v}, "$position": idx}
+
+		default:
+			field, err := touch(i, "$set", op.Path, false)
+			if err != nil {
+				return err
+			}
+			fieldsOf("$set")[field] = v
+		}
+
+	case "remove":
+		if _, key := splitLastPath(op.Path); isArrayIndex(key) {
+			return fmt.Errorf("removing array element %q can not be translated", op.Path)
+		}
+		field, err := touch(i, "$unset", op.Path, false)
+		if err != nil {
+			return err
+		}
+		fieldsOf("$unset")[field] = ""
+
+	case "move":
+		_, fkey := splitLastPath(op.From)
+		_, key := splitLastPath(op.Path)
+		if isArrayIndex(fkey) || isArrayIndex(key) {
+			return fmt.Errorf("moving array element can not be translated")
+		}
+		from, err := touch(i, "$rename", op.From, true)
+		if err != nil {
+			return err
+		}
+		to, err := touch(i, "$rename", op.Path, true)
+		if err != nil {
+			return err
+		}
+		fieldsOf("$rename")[from] = to
+
+	default:
+		return fmt.Errorf("%s operation can not be translated", op.Op)
+	}
+	return nil
+}
+
+type mongoTouch struct {
+	index     int
+	operator  string
+	path      string
+	exclusive bool
+}
+
+// splitLastPath splits the JSON pointer into the parent path and the last reference token.
+func splitLastPath(path string) (string, string) {
+	i := strings.LastIndexByte(path, '/')
+	if i < 0 {
+		return "", path
+	}
+	return path[:i], path[i+1:]
+}
+
+// mongoField translates the JSON pointer into the dot notation of MongoDB.
+func mongoField(path string) (string, error) {
+	if path == "" || path[0] != '/' {
+		return "", fmt.Errorf("path %q can not be translated", path)
+	}
+	fields := strings.Split(path[1:], "/")
+	for i, key := range fields {
+		f := decodePatchKey(key)
+		if f == "" || strings.Contains(f, ".") || strings.HasPrefix(f, "$") {
+			return "", fmt.Errorf("path %q can not be translated, invalid field name %q", path, f)
+		}
+		fields[i] = f
+	}
+	return strings.Join(fields, "."), nil
+}
+
+// mongoValue decodes the JSON value, the integers are decoded as int64.
+func mongoValue(data json.RawMessage) (interface{}, error) {
+	if data == nil {
+		return nil, fmt.Errorf("missing value")
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return mongoNumbers(v), nil
+}
+
+// mongoNumbers converts the json.Number values into int64 or float64.
+func mongoNumbers(v interface{}) interface{} {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range val {
+			val[k] = mongoNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range val {
+			val[i] = mongoNumbers(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,76 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToMongoUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := NewPatch([]byte(`[
+		{"op":"replace","path":"/name","value":"a"},
+		{"op":"add","path":"/user/profile","value":{"age":18,"score":1.5}},
+		{"op":"add","path":"/tags/-","value":"x"},
+		{"op":"add","path":"/tags/-","value":"y"},
+		{"op":"add","path":"/items/0","value":{"id":1}},
+		{"op":"replace","path":"/points/2","value":3},
+		{"op":"remove","path":"/a~1b"},
+		{"op":"move","from":"/old","path":"/new"},
+		{"op":"inc","path":"/count","value":-2},
+		{"op":"replace","path":"/name","value":"b"}
+	]`))
+	assert.Nil(err)
+	res, err := p.ToMongoUpdate()
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{
+		"$set": map[string]interface{}{
+			"name":         "b",
+			"user.profile": map[string]interface{}{"age": int64(18), "score": 1.5},
+			"points.2":     int64(3),
+		},
+		"$push": map[string]interface{}{
+			"tags":  map[string]interface{}{"$each": []interface{}{"x", "y"}},
+			"items": map[string]interface{}{"$each": []interface{}{map[string]interface{}{"id": int64(1)}}, "$position": 0},
+		},
+		"$unset":  map[string]interface{}{"a/b": ""},
+		"$rename": map[string]interface{}{"old": "new"},
+		"$inc":    map[string]interface{}{"count": int64(-2)},
+	}, res)
+
+	res, err = Patch{}.ToMongoUpdate()
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{}, res)
+
+	for i, c := range []struct {
+		patch, err string
+	}{
+		{`[{"op":"test","path":"/a","value":1}]`, "test operation can not be translated"},
+		{`[{"op":"copy","from":"/a","path":"/b"}]`, "copy operation can not be translated"},
+		{`[{"op":"replace","path":"","value":{}}]`, `path "" can not be translated`},
+		{`[{"op":"remove","path":"/tags/1"}]`, `removing array element "/tags/1" can not be translated`},
+		{`[{"op":"move","from":"/tags/1","path":"/a"}]`, "moving array element can not be translated"},
+		{`[{"op":"add","path":"/a.b","value":1}]`, `invalid field name "a.b"`},
+		{`[{"op":"add","path":"/$a","value":1}]`, `invalid field name "$a"`},
+		{`[{"op":"add","path":"/a"}]`, "add operation has invalid value, missing value"},
+		{`[{"op":"inc","path":"/a","value":"1"}]`, `inc operation has non-numeric value "1"`},
+		{`[{"op":"replace","path":"/a","value":1},{"op":"remove","path":"/a"}]`,
+			`operation 1, path "/a" conflicts with operation 0, conflicting operations`},
+		{`[{"op":"replace","path":"/a/b","value":1},{"op":"replace","path":"/a","value":{}}]`,
+			`path "/a" conflicts with operation 0`},
+		{`[{"op":"add","path":"/a/-","value":1},{"op":"add","path":"/a/0","value":2}]`,
+			`path "/a" conflicts with operation 0`},
+		{`[{"op":"inc","path":"/a","value":1},{"op":"inc","path":"/a","value":2}]`,
+			`path "/a" conflicts with operation 0`},
+		{`[{"op":"move","from":"/a","path":"/a/b"}]`, `path "/a/b" conflicts with operation 0`},
+	} {
+		p, err := NewPatch([]byte(c.patch))
+		assert.Nil(err, "case %d", i)
+		_, err = p.ToMongoUpdate()
+		assert.ErrorContains(err, c.err, "case %d", i)
+	}
+}
@@ -0,0 +1,114 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// OpKind is the name of a JSON-Patch operation.
+type OpKind string
+
+// The operations defined in RFC 6902.
+const (
+	OpAdd     OpKind = "add"
+	OpRemove  OpKind = "remove"
+	OpReplace OpKind = "replace"
+	OpMove    OpKind = "move"
+	OpCopy    OpKind = "copy"
+	OpTest    OpKind = "test"
+)
+
+// NewAddOperation returns an "add" operation.
+func NewAddOperation(path string, value json.RawMessage) Operation {
+	return Operation{Op: string(OpAdd), Path: path, Value: value}
+}
+
+// NewRemoveOperation returns a "remove" operation.
+func NewRemoveOperation(path string) Operation {
+	return Operation{Op: string(OpRemove), Path: path}
+}
+
+// NewReplaceOperation returns a "replace" operation.
+func NewReplaceOperation(path string, value json.RawMessage) Operation {
+	return Operation{Op: string(OpReplace), Path: path, Value: value}
+}
+
+// NewMoveOperation returns a "move" operation.
+func NewMoveOperation(from, path string) Operation {
+	return Operation{Op: string(OpMove), From: from, Path: path}
+}
+
+// NewCopyOperation returns a "copy" operation.
+func NewCopyOperation(from, path string) Operation {
+	return Operation{Op: string(OpCopy), From: from, Path: path}
+}
+
+// NewTestOperation returns a "test" operation.
+func NewTestOperation(path string, value json.RawMessage) Operation {
+	return Operation{Op: string(OpTest), Path: path, Value: value}
+}
+
+// Kind returns the kind of the operation.
+func (op Operation) Kind() OpKind {
+	return OpKind(op.Op)
+}
+
+// ValueNode returns the value of the operation as a Node, or nil if the operation has no value.
+func (op Operation) ValueNode() *Node {
+	if op.Value == nil {
+		return nil
+	}
+	return NewNode(op.Value)
+}
+
+// Skeleton returns a copy of the patch with the values, and the values of the "splice" operations,
+// replaced by the placeholders of their types: "[null]", "[boolean]", "[number]", "[string]", "[object]"
+// or "[array]". The operations and paths are kept, so the skeleton can be logged without leaking the values.
+func (p Patch) Skeleton() Patch {
+	if p == nil {
+		return nil
+	}
+
+	s := make(Patch, len(p))
+	for i, op := range p {
+		if op.Value != nil {
+			op.Value = typePlaceholder(op.Value)
+		}
+		if op.Values != nil {
+			values := make([]json.RawMessage, len(op.Values))
+			for j, v := range op.Values {
+				values[j] = typePlaceholder(v)
+			}
+			op.Values = values
+		}
+		op.Apply = op.Apply.Skeleton()
+		s[i] = op
+	}
+	return s
+}
+
+// typePlaceholder returns the placeholder of the type of the raw encoded value, such as "[string]".
+func typePlaceholder(raw json.RawMessage) json.RawMessage {
+	return json.RawMessage(`"[` + string(valueType(raw)) + `]"`)
+}
+
+// valueType returns the JSON type of the raw encoded value.
+func valueType(raw json.RawMessage) Kind {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case isNull(raw):
+		return KindNull
+	case raw[0] == '{':
+		return KindObject
+	case raw[0] == '[':
+		return KindArray
+	case raw[0] == '"':
+		return KindString
+	case raw[0] == 't' || raw[0] == 'f':
+		return KindBoolean
+	}
+	return KindNumber
+}
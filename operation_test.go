@@ -0,0 +1,78 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperationConstructors(t *testing.T) {
+	assert := assert.New(t)
+
+	p := Patch{
+		NewTestOperation("/name", []byte(`"John"`)),
+		NewReplaceOperation("/name", []byte(`"Jane"`)),
+		NewAddOperation("/tags/-", []byte(`"b"`)),
+		NewCopyOperation("/tags/0", "/first"),
+		NewMoveOperation("/age", "/meta/age"),
+		NewRemoveOperation("/height"),
+	}
+	assert.Equal(`[{"op":"test","path":"/name","value":"John"},`+
+		`{"op":"replace","path":"/name","value":"Jane"},`+
+		`{"op":"add","path":"/tags/-","value":"b"},`+
+		`{"op":"copy","path":"/first","from":"/tags/0"},`+
+		`{"op":"move","path":"/meta/age","from":"/age"},`+
+		`{"op":"remove","path":"/height"}]`, mustJSONString(p))
+
+	res, err := p.Apply([]byte(`{"name":"John","age":24,"height":3.21,"tags":["a"],"meta":{}}`))
+	assert.Nil(err)
+	assert.Equal(`{"name":"Jane","tags":["a","b"],"meta":{"age":24},"first":"a"}`, string(res))
+
+	assert.Equal(OpTest, p[0].Kind())
+	assert.Equal(OpReplace, p[1].Kind())
+	assert.Equal(OpAdd, p[2].Kind())
+	assert.Equal(OpCopy, p[3].Kind())
+	assert.Equal(OpMove, p[4].Kind())
+	assert.Equal(OpRemove, p[5].Kind())
+
+	assert.Equal(`"Jane"`, mustJSONString(p[1].ValueNode()))
+	assert.Nil(p[5].ValueNode())
+	assert.Equal(`null`, mustJSONString(NewAddOperation("/a", []byte(`null`)).ValueNode()))
+}
+
+func TestPatchSkeleton(t *testing.T) {
+	assert := assert.New(t)
+
+	p := Patch{
+		NewTestOperation("/name", []byte(`"John"`)),
+		NewReplaceOperation("/age", []byte(` 24 `)),
+		NewAddOperation("/tags/-", []byte(`{"a":1}`)),
+		NewAddOperation("/list", []byte(`[1]`)),
+		NewAddOperation("/ok", []byte(`true`)),
+		NewAddOperation("/none", []byte(`null`)),
+		NewMoveOperation("/age", "/meta/age"),
+		NewRemoveOperation("/height"),
+		{Op: "not", Path: "/meta", Apply: Patch{{Op: "starts", Path: "/id", Value: []byte(`"x-"`)}}},
+		{Op: "splice", Path: "/list/0", Remove: 1, Values: []json.RawMessage{[]byte(`"secret"`), []byte(`2`)}},
+	}
+	assert.Equal(`[{"op":"test","path":"/name","value":"[string]"},`+
+		`{"op":"replace","path":"/age","value":"[number]"},`+
+		`{"op":"add","path":"/tags/-","value":"[object]"},`+
+		`{"op":"add","path":"/list","value":"[array]"},`+
+		`{"op":"add","path":"/ok","value":"[boolean]"},`+
+		`{"op":"add","path":"/none","value":"[null]"},`+
+		`{"op":"move","path":"/meta/age","from":"/age"},`+
+		`{"op":"remove","path":"/height"},`+
+		`{"op":"not","path":"/meta","apply":[{"op":"starts","path":"/id","value":"[string]"}]},`+
+		`{"op":"splice","path":"/list/0","remove":1,"values":["[string]","[number]"]}]`,
+		mustJSONString(p.Skeleton()))
+
+	assert.Equal(`"John"`, string(p[0].Value))
+	assert.Equal(`"x-"`, string(p[8].Apply[0].Value))
+	assert.Equal(`"secret"`, string(p[9].Values[0]))
+	assert.Nil(Patch(nil).Skeleton())
+}
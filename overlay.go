@@ -0,0 +1,270 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OverlayView applies the patch to the document, and returns the document with every affected
+// node replaced by an annotation object, such as:
+//
+//	{"$op": "replace", "$old": "John", "$new": "Jane"}
+//
+// An "add" annotation has no "$old" unless it overwrites an existing member, a "remove" annotation
+// has no "$new". The destination of "move" and "copy" operations carries "$from", and the source
+// of a "move" operation carries "$to". Removed array elements are kept in place as annotations.
+// "$old" is the value before the operation, changes inside an annotated value are merged into its "$new".
+func OverlayView(doc []byte, p Patch) ([]byte, error) {
+	o := &overlay{
+		res:     NewNode(doc),
+		marks:   make(map[*Node]*overlayMark),
+		options: NewOptions(),
+	}
+	o.view = o.res.Clone()
+
+	for i, op := range p {
+		if err := o.apply(op); err != nil {
+			return nil, fmt.Errorf("unable to overlay operation %d, %w", i, err)
+		}
+	}
+	return o.view.MarshalJSON()
+}
+
+type overlay struct {
+	res     *Node // the patched document
+	view    *Node // the annotated document
+	marks   map[*Node]*overlayMark
+	options *Options
+}
+
+type overlayMark struct {
+	op      string
+	old     *Node
+	removed bool
+}
+
+func (o *overlay) apply(op Operation) error {
+	switch op.Op {
+	case "add", "replace":
+		old := o.get(op.Path, op.findPath)
+		if err := o.res.Patch(Patch{op}, o.options); err != nil {
+			return err
+		}
+		return o.annotateSet(op.Op, op.Path, "", old, NewNode(op.Value))
+
+	case "copy":
+		val, old := o.get(op.From, op.findFrom), o.get(op.Path, op.findPath)
+		if err := o.res.Patch(Patch{op}, o.options); err != nil {
+			return err
+		}
+		return o.annotateSet(op.Op, op.Path, op.From, old, val)
+
+	case "remove":
+		old := o.get(op.Path, op.findPath)
+		if err := o.res.Patch(Patch{op}, o.options); err != nil {
+			return err
+		}
+		return o.annotateRemove(op.Op, op.Path, "", old)
+
+	case "move":
+		val, old := o.get(op.From, op.findFrom), o.get(op.Path, op.findPath)
+		if err := o.res.Patch(Patch{op}, o.options); err != nil {
+			return err
+		}
+		// the view is not changed yet, so "from" is resolved in the coordinates before the operation,
+		// and "path" is resolved after the source is removed from the view, as a "move" operation does.
+		if err := o.annotateRemove(op.Op, op.From, op.Path, val); err != nil {
+			return err
+		}
+		return o.annotateSet(op.Op, op.Path, op.From, old, val.Clone())
+
+	default:
+		return o.res.Patch(Patch{op}, o.options)
+	}
+}
+
+// get returns a copy of the value at the path in the patched document, or nil if it is missing.
+// The path is found by find, the findPath or the findFrom of the operation, as the operation finds it.
+func (o *overlay) get(path string, find func(*container, *Options) (container, string)) *Node {
+	if path == "" {
+		return o.res.Clone()
+	}
+	pd, _ := o.res.intoContainer()
+	if pd == nil {
+		return nil
+	}
+	con, key := find(&pd, o.options)
+	if con == nil {
+		return nil
+	}
+	n, err := con.get(key, o.options)
+	if err != nil {
+		return nil
+	}
+	return n.Clone()
+}
+
+func (o *overlay) annotateSet(op, path, from string, old, val *Node) error {
+	if path == "" {
+		if _, ok := o.marks[o.view]; ok {
+			o.view.doc.set("$new", val, o.options)
+			return nil
+		}
+		o.view = o.mark(op, old, val, "$from", from)
+		return nil
+	}
+
+	con, key, through, err := o.resolve(path, op != "replace")
+	if err != nil || through {
+		return err
+	}
+
+	if _, ok := con.(*partialArray); ok && op != "replace" {
+		return con.add(key, o.mark(op, nil, val, "$from", from), o.options)
+	}
+
+	if prev, err := con.get(key, o.options); err == nil {
+		if m, ok := o.marks[prev]; ok {
+			if old = m.old; old == nil && !m.removed {
+				// still a new value for the original document.
+				op = m.op
+			}
+		}
+	}
+	return con.set(key, o.mark(op, old, val, "$from", from), o.options)
+}
+
+func (o *overlay) annotateRemove(op, path, to string, old *Node) error {
+	con, key, through, err := o.resolve(path, false)
+	if err != nil || through {
+		return err
+	}
+
+	prev, err := con.get(key, o.options)
+	if err != nil {
+		return err
+	}
+	if m, ok := o.marks[prev]; ok {
+		if m.old == nil {
+			// the value was added by the patch, so it disappears from the view.
+			return con.remove(key, o.options)
+		}
+		old = m.old
+	}
+
+	n := o.mark(op, old, nil, "$to", to)
+	o.marks[n].removed = true
+	return con.set(key, n, o.options)
+}
+
+// resolve walks the view along the path, where array indexes are in the coordinates of the patched
+// document (removed elements are skipped). It returns the container and key in the view.
+// If the path goes through an annotation, it refreshes the "$new" of the annotation
+// from the patched document and returns through as true.
+func (o *overlay) resolve(path string, insert bool) (con container, key string, through bool, err error) {
+	keys := splitKeys(path)
+	if len(keys) == 0 {
+		return nil, "", false, fmt.Errorf("invalid path %q, %w", path, ErrInvalid)
+	}
+
+	node := o.view
+	for i, key := range keys {
+		if _, ok := o.marks[node]; ok {
+			prefix := pathPrefix(path, i)
+			node.doc.set("$new", o.get(prefix, Operation{Path: prefix}.findPath), o.options)
+			return nil, "", true, nil
+		}
+
+		if con, err = node.intoContainer(); con == nil {
+			if err == nil {
+				err = ErrInvalid
+			}
+			return nil, "", false, fmt.Errorf("unable to resolve %q in the view, %w", path, err)
+		}
+
+		last := i == len(keys)-1
+		if ary, ok := con.(*partialArray); ok {
+			if key, err = o.viewIndex(*ary, key, last && insert); err != nil {
+				return nil, "", false, err
+			}
+		}
+		if last {
+			return con, key, false, nil
+		}
+		if node, err = con.get(key, o.options); err != nil {
+			return nil, "", false, err
+		}
+	}
+	return nil, "", false, fmt.Errorf("invalid path %q, %w", path, ErrInvalid)
+}
+
+// pathPrefix returns the prefix of the path with the first n tokens.
+func pathPrefix(path string, n int) string {
+	end := 0
+	for ; n > 0; n-- {
+		end += strings.IndexByte(path[end+1:], '/') + 1
+	}
+	if end == 0 {
+		return ""
+	}
+	return path[:end]
+}
+
+// viewIndex maps the key of an element in the patched document to the index in the view. The key is
+// resolved in the elements that are not removed, as the operations resolve it, insert as "add".
+func (o *overlay) viewIndex(ary partialArray, key string, insert bool) (string, error) {
+	live := make(partialArray, 0, len(ary))
+	for _, e := range ary {
+		if m, ok := o.marks[e]; !ok || !m.removed {
+			live = append(live, e)
+		}
+	}
+
+	var idx int
+	var err error
+	if insert {
+		idx, err = live.insertIndex(key, o.options)
+	} else {
+		idx, err = live.index(key, o.options)
+	}
+	if err != nil {
+		return "", err
+	}
+	if idx == len(live) {
+		return strconv.Itoa(len(ary)), nil
+	}
+	for vi, e := range ary {
+		if e == live[idx] {
+			return strconv.Itoa(vi), nil
+		}
+	}
+	return strconv.Itoa(len(ary)), nil
+}
+
+func (o *overlay) mark(op string, old, new *Node, pathKey, path string) *Node {
+	d := &partialDoc{obj: make(map[string]*Node, 4)}
+	d.set("$op", jsonString(op), o.options)
+	if path != "" {
+		d.set(pathKey, jsonString(path), o.options)
+	}
+	if old != nil {
+		d.set("$old", old, o.options)
+	}
+	if new != nil {
+		d.set("$new", new, o.options)
+	}
+
+	n := &Node{doc: d, which: eDoc}
+	o.marks[n] = &overlayMark{op: op, old: old}
+	return n
+}
+
+func jsonString(s string) *Node {
+	data, _ := json.Marshal(s)
+	return NewNode(data)
+}
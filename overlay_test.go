@@ -0,0 +1,93 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverlayView(t *testing.T) {
+	cases := []struct {
+		doc, patch, result string
+	}{
+		{
+			`{"name":"John","age":24}`,
+			`[{"op": "replace", "path": "/name", "value": "Jane"}]`,
+			`{"name":{"$op":"replace","$old":"John","$new":"Jane"},"age":24}`,
+		},
+		{
+			`{"name":"John","age":24}`,
+			`[{"op": "remove", "path": "/age"}, {"op": "add", "path": "/tags", "value": ["a"]}]`,
+			`{"name":"John","age":{"$op":"remove","$old":24},"tags":{"$op":"add","$new":["a"]}}`,
+		},
+		{
+			`{"name":"John"}`,
+			`[{"op": "add", "path": "/name", "value": "Jane"}, {"op": "test", "path": "/name", "value": "Jane"}]`,
+			`{"name":{"$op":"add","$old":"John","$new":"Jane"}}`,
+		},
+		{
+			`{"list":[1,2,3]}`,
+			`[{"op": "remove", "path": "/list/0"}, {"op": "replace", "path": "/list/0", "value": 4},
+			  {"op": "add", "path": "/list/-", "value": 5}, {"op": "add", "path": "/list/1", "value": 6}]`,
+			`{"list":[{"$op":"remove","$old":1},{"$op":"replace","$old":2,"$new":4},{"$op":"add","$new":6},3,{"$op":"add","$new":5}]}`,
+		},
+		{
+			`{"list":[1,2]}`,
+			`[{"op": "add", "path": "/list/0", "value": 0}, {"op": "remove", "path": "/list/0"}]`,
+			`{"list":[1,2]}`,
+		},
+		{
+			`{"a":{"b":1}}`,
+			`[{"op": "add", "path": "/c", "value": {"d":1}}, {"op": "add", "path": "/c/e", "value": 2},
+			  {"op": "replace", "path": "/c", "value": {}}]`,
+			`{"a":{"b":1},"c":{"$op":"add","$new":{}}}`,
+		},
+		{
+			`{"a":{"b":1},"x":[1]}`,
+			`[{"op": "replace", "path": "/a", "value": {"c":1}}, {"op": "add", "path": "/a/d", "value": 2},
+			  {"op": "add", "path": "/x/0", "value": 0}]`,
+			`{"a":{"$op":"replace","$old":{"b":1},"$new":{"c":1,"d":2}},"x":[{"$op":"add","$new":0},1]}`,
+		},
+		{
+			`{"a":{"b":1},"c":{}}`,
+			`[{"op": "move", "from": "/a/b", "path": "/c/d"}, {"op": "copy", "from": "/c/d", "path": "/e"}]`,
+			`{"a":{"b":{"$op":"move","$to":"/c/d","$old":1}},"c":{"d":{"$op":"move","$from":"/a/b","$new":1}},` +
+				`"e":{"$op":"copy","$from":"/c/d","$new":1}}`,
+		},
+		{
+			`{"list":[1,2,3]}`,
+			`[{"op": "remove", "path": "/list/0"}, {"op": "replace", "path": "/list/-1", "value": 4},
+			  {"op": "add", "path": "/list/-1", "value": 5}, {"op": "add", "path": "/list/-3", "value": 6}]`,
+			`{"list":[{"$op":"remove","$old":1},2,{"$op":"add","$new":6},{"$op":"replace","$old":3,"$new":4},{"$op":"add","$new":5}]}`,
+		},
+		{
+			`{"a":1}`,
+			`[{"op": "replace", "path": "", "value": [1]}, {"op": "add", "path": "/-", "value": 2}]`,
+			`{"$op":"replace","$old":{"a":1},"$new":[1,2]}`,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			p, err := NewPatch([]byte(c.patch))
+			assert.Nil(t, err)
+			res, err := OverlayView([]byte(c.doc), p)
+			assert.Nil(t, err)
+			assert.Equal(t, c.result, string(res))
+		})
+	}
+
+	_, err := OverlayView([]byte(`{"a":1}`), Patch{{Op: "remove", Path: "/b"}})
+	assert.ErrorIs(t, err, ErrMissing)
+	var pe *PathError
+	assert.ErrorAs(t, err, &pe)
+	assert.Equal(t, "/b", pe.Path)
+	_, err = OverlayView([]byte(`{"a":1}`), Patch{{Op: "test", Path: "/a", Value: []byte(`2`)}})
+	assert.ErrorIs(t, err, ErrTestFailed)
+	_, err = OverlayView([]byte(`{"a":[1]}`), Patch{{Op: "add", Path: "/a/2", Value: []byte(`2`)}})
+	assert.ErrorIs(t, err, ErrInvalidIndex)
+}
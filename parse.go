@@ -0,0 +1,344 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sync"
+	"unicode/utf8"
+)
+
+// The documents are validated once, then the containers are split into their members by a byte scanner
+// that trusts the validity, so every level is parsed without decoding its subtrees, and the members
+// share the buffer of the document.
+
+// checkValid validates the raw JSON of the node once.
+func (n *Node) checkValid() error {
+	if n.valid {
+		return nil
+	}
+	if err := checkValidJSON(*n.raw); err != nil {
+		return err
+	}
+	n.valid = true
+	return nil
+}
+
+// checkValidJSON returns the syntax error of the invalid JSON data.
+func checkValidJSON(data []byte) error {
+	if json.Valid(data) {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return errors.New("invalid JSON data")
+}
+
+// parseObject parses the valid JSON object into its members, the last member is taken for duplicate keys.
+func parseObject(data []byte) *partialDoc {
+	d := &partialDoc{obj: make(map[string]*Node)}
+	i := skipSpace(data, 0) + 1 // '{'
+	for {
+		i = skipSpace(data, i)
+		if data[i] == '}' {
+			return d
+		}
+
+		end := scanValue(data, i)
+		key := parseKey(data[i:end])
+		i = skipSpace(data, end) + 1 // ':'
+		i = skipSpace(data, i)
+		end = scanValue(data, i)
+
+		if _, ok := d.obj[key]; ok {
+			if d.dup == "" {
+				d.dup = key
+			}
+		} else {
+			d.keys = append(d.keys, key)
+		}
+		d.obj[key] = parsedNode(data[i:end])
+
+		i = skipSpace(data, end)
+		if data[i] == ',' {
+			i++
+		}
+	}
+}
+
+// parseArray parses the valid JSON array into its elements.
+func parseArray(data []byte) partialArray {
+	ary := partialArray{}
+	i := skipSpace(data, 0) + 1 // '['
+	for {
+		i = skipSpace(data, i)
+		if data[i] == ']' {
+			return ary
+		}
+
+		end := scanValue(data, i)
+		ary = append(ary, parsedNode(data[i:end]))
+
+		i = skipSpace(data, end)
+		if data[i] == ',' {
+			i++
+		}
+	}
+}
+
+// parsedNode returns the node of a value parsed from a valid document, or nil for null as json.Unmarshal.
+func parsedNode(data []byte) *Node {
+	if isNull(data) {
+		return nil
+	}
+	raw := json.RawMessage(data[:len(data):len(data)])
+	return &Node{raw: &raw, valid: true}
+}
+
+// parseKey returns the string of the valid JSON string.
+func parseKey(data []byte) string {
+	if bytes.IndexByte(data, '\\') < 0 {
+		return string(data[1 : len(data)-1])
+	}
+	var key string
+	json.Unmarshal(data, &key)
+	return key
+}
+
+func skipSpace(data []byte, i int) int {
+	for ; i < len(data); i++ {
+		switch data[i] {
+		case ' ', '\t', '\n', '\r':
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// scanValue returns the end of the valid JSON value that starts at i.
+func scanValue(data []byte, i int) int {
+	switch data[i] {
+	case '"':
+		return scanString(data, i)
+	case '{', '[':
+		depth := 0
+		for ; i < len(data); i++ {
+			switch data[i] {
+			case '"':
+				i = scanString(data, i) - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				if depth--; depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return i
+	default:
+		for ; i < len(data); i++ {
+			switch data[i] {
+			case ',', '}', ']', ' ', '\t', '\n', '\r':
+				return i
+			}
+		}
+		return i
+	}
+}
+
+// scanString returns the end of the valid JSON string that starts at i.
+func scanString(data []byte, i int) int {
+	for i++; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return i
+}
+
+// jsonWriter writes the compact JSON encoding of the nodes, with the same escaping as json.Marshal.
+type jsonWriter struct {
+	bytes.Buffer
+	// frames are the objects and arrays being written by writeRaw, they are reused.
+	frames []rawFrame
+}
+
+// rawFrame is an object or array being written by writeRaw, keys are the ranges of the keys of an object.
+type rawFrame struct {
+	object    bool
+	expectKey bool
+	keys      [][2]int
+	set       map[string]struct{}
+}
+
+// maxKeysScan is the number of keys of an object that are compared one by one to find duplicate keys,
+// a set is used for larger objects.
+const maxKeysScan = 16
+
+// writeRaw writes the compacted valid raw JSON. It returns false, and writes nothing, if an object
+// of the data has duplicate keys or escaped keys, which need to be parsed.
+func (w *jsonWriter) writeRaw(data []byte) bool {
+	start := w.Len()
+	w.frames = w.frames[:0]
+	for i := 0; i < len(data); {
+		switch c := data[i]; c {
+		case ' ', '\t', '\n', '\r':
+			i++
+
+		case '"':
+			end := scanString(data, i)
+			if n := len(w.frames); n > 0 && w.frames[n-1].expectKey {
+				if !w.addKey(&w.frames[n-1], data, i+1, end-1) {
+					w.Truncate(start)
+					return false
+				}
+			}
+			w.writeEscaped(data[i:end])
+			i = end
+
+		case '{', '[':
+			if n := len(w.frames); n < cap(w.frames) {
+				w.frames = w.frames[:n+1]
+				f := &w.frames[n]
+				f.object, f.expectKey, f.keys, f.set = c == '{', c == '{', f.keys[:0], nil
+			} else {
+				w.frames = append(w.frames, rawFrame{object: c == '{', expectKey: c == '{'})
+			}
+			w.WriteByte(c)
+			i++
+
+		case '}', ']':
+			w.frames = w.frames[:len(w.frames)-1]
+			w.WriteByte(c)
+			i++
+
+		case ',':
+			if n := len(w.frames); n > 0 && w.frames[n-1].object {
+				w.frames[n-1].expectKey = true
+			}
+			w.WriteByte(c)
+			i++
+
+		case ':':
+			w.WriteByte(c)
+			i++
+
+		default:
+			end := i + 1
+			for end < len(data) && !isDelimiter(data[end]) {
+				end++
+			}
+			w.Write(data[i:end])
+			i = end
+		}
+	}
+	return true
+}
+
+// addKey adds the key data[s:e] to the object frame, it returns false if the key is escaped or duplicated.
+func (w *jsonWriter) addKey(f *rawFrame, data []byte, s, e int) bool {
+	f.expectKey = false
+	key := data[s:e]
+	if bytes.IndexByte(key, '\\') >= 0 {
+		return false
+	}
+
+	if f.set != nil {
+		if _, ok := f.set[string(key)]; ok {
+			return false
+		}
+		f.set[string(key)] = struct{}{}
+		return true
+	}
+	for _, k := range f.keys {
+		if bytes.Equal(data[k[0]:k[1]], key) {
+			return false
+		}
+	}
+	f.keys = append(f.keys, [2]int{s, e})
+	if len(f.keys) > maxKeysScan {
+		f.set = make(map[string]struct{}, 2*len(f.keys))
+		for _, k := range f.keys {
+			f.set[string(data[k[0]:k[1]])] = struct{}{}
+		}
+	}
+	return true
+}
+
+// writeEscaped writes the JSON string with <, >, &, U+2028 and U+2029 escaped, as json.Marshal.
+func (w *jsonWriter) writeEscaped(data []byte) {
+	last := 0
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if c == '<' || c == '>' || c == '&' {
+			w.Write(data[last:i])
+			w.WriteString(`\u00`)
+			w.WriteByte(hexDigits[c>>4])
+			w.WriteByte(hexDigits[c&0xf])
+			last = i + 1
+		} else if c == 0xe2 && i+2 < len(data) && data[i+1] == 0x80 && data[i+2]&^1 == 0xa8 {
+			w.Write(data[last:i])
+			w.WriteString(`\u202`)
+			w.WriteByte(hexDigits[data[i+2]&0xf])
+			last = i + 3
+			i += 2
+		}
+	}
+	w.Write(data[last:])
+}
+
+const hexDigits = "0123456789abcdef"
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case ',', '}', ']', ':', ' ', '\t', '\n', '\r':
+		return true
+	}
+	return false
+}
+
+// writeString writes the JSON string of s with the same escaping as json.Marshal.
+func (w *jsonWriter) writeString(s string) error {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c >= utf8.RuneSelf || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			data, err := json.Marshal(s)
+			if err != nil {
+				return err
+			}
+			w.Write(data)
+			return nil
+		}
+	}
+	w.WriteByte('"')
+	w.WriteString(s)
+	w.WriteByte('"')
+	return nil
+}
+
+// maxPooledBuffer is the capacity limit of the writers put back to the pool,
+// so that a few large documents don't keep the memory.
+const maxPooledBuffer = 4 << 20
+
+var writerPool = sync.Pool{
+	New: func() interface{} { return new(jsonWriter) },
+}
+
+func getWriter() *jsonWriter {
+	return writerPool.Get().(*jsonWriter)
+}
+
+func putWriter(w *jsonWriter) {
+	if w.Cap() <= maxPooledBuffer {
+		w.Reset()
+		writerPool.Put(w)
+	}
+}
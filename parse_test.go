@@ -0,0 +1,136 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeMarshalJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	for i, c := range []string{
+		`null`,
+		` 1.50e+10 `,
+		`"a<b>&c  "`,
+		"\"\u2028 \u2029 \u00e2\"",
+		`{ "a" : [ 1 , "x\"}]" , { } , [ ] , null ] , "b":{"c" :true}}`,
+		`[{"a<>":"&"}, "\\", "\n\t", 18446744073709551616]`,
+		`{"k0":0,"k1":1,"k2":2,"k3":3,"k4":4,"k5":5,"k6":6,"k7":7,"k8":8,"k9":9,` +
+			`"k10":10,"k11":11,"k12":12,"k13":13,"k14":14,"k15":15,"k16":16,"k17":17,"k18":18}`,
+	} {
+		expected, err := json.Marshal(json.RawMessage(c))
+		assert.Nil(err, "case %d", i)
+
+		res, err := NewNode([]byte(c)).MarshalJSON()
+		assert.Nil(err, "case %d", i)
+		assert.Equal(string(expected), string(res), "case %d", i)
+
+		// the parsed nodes are encoded as the raw nodes
+		node := NewNode([]byte(c))
+		node.intoContainer()
+		res, err = node.MarshalJSON()
+		assert.Nil(err, "case %d", i)
+		assert.Equal(string(expected), string(res), "case %d", i)
+	}
+
+	// the last members are taken for the duplicate keys, in the unparsed subtrees too
+	for i, c := range []struct {
+		doc, result string
+	}{
+		{`{"a":1,"b":2,"a":3}`, `{"a":3,"b":2}`},
+		{`[{"x":{"a":1,"a":{"b":1,"b":2}}}]`, `[{"x":{"a":{"b":2}}}]`},
+		{`{"k0":0,"k1":1,"k2":2,"k3":3,"k4":4,"k5":5,"k6":6,"k7":7,"k8":8,"k9":9,` +
+			`"k10":10,"k11":11,"k12":12,"k13":13,"k14":14,"k15":15,"k16":16,"k17":17,"k3":18}`,
+			`{"k0":0,"k1":1,"k2":2,"k3":18,"k4":4,"k5":5,"k6":6,"k7":7,"k8":8,"k9":9,` +
+				`"k10":10,"k11":11,"k12":12,"k13":13,"k14":14,"k15":15,"k16":16,"k17":17}`},
+		{`{"a":1,"a":2}`, `{"a":2}`},
+		{`{"<":1}`, `{"\u003c":1}`},
+	} {
+		res, err := NewNode([]byte(c.doc)).MarshalJSON()
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.result, string(res), "case %d", i)
+	}
+
+	for i, c := range []string{`{"a":}`, `[1,]`, `{"a":1`, `tru`, `"x`} {
+		_, err := NewNode([]byte(c)).MarshalJSON()
+		assert.NotNil(err, "case %d", i)
+		_, err = NewNode([]byte(c)).GetChild("/a", nil)
+		assert.NotNil(err, "case %d", i)
+	}
+}
+
+func TestNodeAppendJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Implements((*json.Marshaler)(nil), &Node{})
+	assert.Implements((*json.Unmarshaler)(nil), &Node{})
+
+	node := NewNode([]byte(`{ "a" : [1, 2] }`))
+	buf := make([]byte, 0, 64)
+	buf = append(buf, "data: "...)
+	res, err := node.AppendJSON(buf)
+	assert.Nil(err)
+	assert.Equal(`data: {"a":[1,2]}`, string(res))
+	assert.Equal(&buf[0], &res[0])
+
+	res, err = NewNode([]byte(`{"a":`)).AppendJSON(buf)
+	assert.NotNil(err)
+	assert.Equal(`data: `, string(res))
+
+	var nilNode *Node
+	res, err = nilNode.AppendJSON(nil)
+	assert.Nil(err)
+	assert.Equal(`null`, string(res))
+
+	// the *Node fields of the structs.
+	type response struct {
+		ID   int   `json:"id"`
+		Data *Node `json:"data"`
+		None *Node `json:"none"`
+	}
+	assert.Nil(node.Patch(Patch{NewAddOperation("/b", []byte(`"x"`))}, nil))
+	data, err := json.Marshal(response{1, node, nil})
+	assert.Nil(err)
+	assert.Equal(`{"id":1,"data":{"a":[1,2],"b":"x"},"none":null}`, string(data))
+
+	var r response
+	assert.Nil(json.Unmarshal([]byte(`{"id":2,"data":{"c" : [ 3 ]},"none":null}`), &r))
+	assert.Equal(2, r.ID)
+	assert.Nil(r.None)
+	v, err := r.Data.GetValue("/c/0", nil)
+	assert.Nil(err)
+	assert.Equal(`3`, string(v))
+	assert.Nil(r.Data.Patch(Patch{NewAddOperation("/d", []byte(`true`))}, nil))
+	data, err = json.Marshal(&r)
+	assert.Nil(err)
+	assert.Equal(`{"id":2,"data":{"c":[3],"d":true},"none":null}`, string(data))
+}
+
+func TestNodeParse(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(` { "a\"b" : { "c" : [ 1 , null , "}]" ] } , "d" : null } `))
+	v, err := node.GetValue(`/a"b/c/2`, nil)
+	assert.Nil(err)
+	assert.Equal(`"}]"`, string(v))
+	v, err = node.GetValue(`/a"b/c/1`, nil)
+	assert.Nil(err)
+	assert.Equal(`null`, string(v))
+	v, err = node.GetValue(`/d`, nil)
+	assert.Nil(err)
+	assert.Equal(`null`, string(v))
+	assert.Equal([]string{`a"b`, "d"}, node.doc.keys)
+
+	// the children don't share the buffer of the data unmarshaled again
+	child, err := node.GetChild(`/a"b/c`, nil)
+	assert.Nil(err)
+	assert.Nil(node.UnmarshalJSON([]byte(` { "x" : [ 2 , 3 , "4" ] , "y" : 1 } `)))
+	v, err = child.MarshalJSON()
+	assert.Nil(err)
+	assert.Equal(`[1,null,"}]"]`, string(v))
+}
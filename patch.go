@@ -42,8 +42,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var (
@@ -57,9 +59,14 @@ var (
 )
 
 var (
-	ErrMissing      = errors.New("missing value")
-	ErrInvalid      = errors.New("invalid node detected")
-	ErrInvalidIndex = errors.New("invalid index referenced")
+	ErrMissing       = errors.New("missing value")
+	ErrInvalid       = errors.New("invalid node detected")
+	ErrInvalidIndex  = errors.New("invalid index referenced")
+	ErrTestFailed    = errors.New("test operation failed")
+	ErrDuplicateKey  = errors.New("duplicate key detected")
+	ErrLimitExceeded = errors.New("limit exceeded")
+	ErrFrozen        = errors.New("node is frozen")
+	ErrCyclic        = errors.New("cyclic move detected")
 )
 
 const (
@@ -72,6 +79,7 @@ const (
 var (
 	rawJSONArray  = []byte(`[]`)
 	rawJSONObject = []byte(`{}`)
+	rawJSONNull   = []byte(`null`)
 	startObject   = json.Delim('{')
 	endObject     = json.Delim('}')
 	startArray    = json.Delim('[')
@@ -89,6 +97,24 @@ type Operation struct {
 	Path  string          `json:"path"`
 	From  string          `json:"from,omitempty"`
 	Value json.RawMessage `json:"value,omitempty"`
+
+	// IgnoreCase and Apply are the members of the JSON Predicate operations,
+	// they are only used when Options.EnablePredicates is true.
+	IgnoreCase bool  `json:"ignore_case,omitempty"`
+	Apply      Patch `json:"apply,omitempty"`
+
+	// Pos and Len are the offset and the length in runes of the text of the string operations,
+	// they are only used when Options.EnableStringOps is true.
+	Pos int `json:"pos,omitempty"`
+	Len int `json:"len,omitempty"`
+
+	// Remove and Values are the number of the removed elements and the inserted elements of the "splice"
+	// operation, they are only used when Options.EnableSplice is true.
+	Remove int               `json:"remove,omitempty"`
+	Values []json.RawMessage `json:"values,omitempty"`
+
+	// compiled is the prepared operation of a CompiledPatch.
+	compiled *compiledOp
 }
 
 // Patch is an ordered collection of Operations.
@@ -101,15 +127,243 @@ type Options struct {
 	// allowing negative indices to mean indices starting at the end of an array.
 	// Default to true.
 	SupportNegativeIndices bool
+	// SupportSliceIndices instructs GetChild, GetValue and the other read methods of Node to support
+	// the slices of arrays as the last reference tokens of the paths, such as "/items/1:4",
+	// which return the arrays of the selected elements. The start and the end of a slice are optional,
+	// they are clamped to the length of the array, and they count from the end of the array if negative
+	// and SupportNegativeIndices is true. Default to false.
+	SupportSliceIndices bool
 	// AccumulatedCopySizeLimit limits the total size increase in bytes caused by
 	// "copy" operations in a patch.
 	AccumulatedCopySizeLimit int64
+	// LookupCacheSize enables the cache of the paths resolved by GetChild, GetValue and the other read
+	// methods of Node, for the hot lookups of the same paths in the same node: up to LookupCacheSize
+	// paths are cached with the containers of their parents, a document shares the cache with the children
+	// resolved from it, and the cache is cleared when it is full, and when the node is patched. The cached
+	// paths of the nodes that are not frozen are resolved again after any change of their document, such as
+	// the patches of their children or their parents, the changes of the other documents don't affect them,
+	// and the frozen nodes keep their cached paths. Default to 0, which means no cache.
+	LookupCacheSize int
+	// MaxDepth limits the nesting depth of objects and arrays in the document to patch or to read by
+	// the GetChild, Resolve and FindChildren methods, in the values of the operations and where they are
+	// placed in the document.
+	// Default to 0, which means no limit.
+	MaxDepth int
+	// MaxDocumentBytes limits the size of the encoded document to patch or to read, as MaxDepth, whether it
+	// is parsed or not. The size and the depth of a parsed document are tracked as the patches change it.
+	// Default to 0, which means no limit.
+	MaxDocumentBytes int64
+	// MaxPatchOps limits the number of operations of a patch.
+	// Default to 0, which means no limit.
+	MaxPatchOps int
+	// MaxValueBytes limits the size of the encoded value of an operation.
+	// Default to 0, which means no limit.
+	MaxValueBytes int64
+	// MaxCopyBytes limits the size of the encoded value copied by each "copy" operation,
+	// as AccumulatedCopySizeLimit limits their total size in a patch.
+	// Default to 0, which means no limit.
+	MaxCopyBytes int64
 	// AllowMissingPathOnRemove indicates whether to fail "remove" operations when the target path is missing.
 	// Default to false.
 	AllowMissingPathOnRemove bool
 	// EnsurePathExistsOnAdd instructs json-patch to recursively create the missing parts of path on "add" operation.
 	// Default to false.
 	EnsurePathExistsOnAdd bool
+	// AppendBeyondArrayLength instructs json-patch to append the value of "add", "move" and "copy"
+	// operations to the array instead of failing when the index is beyond the length of the array.
+	// Default to false.
+	AppendBeyondArrayLength bool
+	// ConvertNullIntermediates instructs json-patch to convert the null values traversed by the path of
+	// "add", "move" and "copy" operations into arrays (if the next part of path is an array index) or objects.
+	// Default to false.
+	ConvertNullIntermediates bool
+	// PreserveKeyOrder instructs json-patch to keep the original order of object keys where the patch
+	// would otherwise change it: a removed key that is added again takes its original place, and
+	// an object replacing another object keeps the order of the keys they share (recursively).
+	// New keys are always appended at the end.
+	// Default to false.
+	PreserveKeyOrder bool
+	// UseNumber instructs json-patch to compare numbers by their exact decimal values instead of
+	// their float64 approximations in the "less" and "more" predicates, so large integers and
+	// high-precision decimals are not rounded. Numbers are always kept byte-for-byte in the document
+	// unless they are modified by an operation.
+	// Default to false.
+	UseNumber bool
+	// RejectDuplicateKeys instructs json-patch to fail on the objects with duplicate member names
+	// in the document and in the values of the operations, instead of taking the last member.
+	// Default to false.
+	RejectDuplicateKeys bool
+	// TestSubset instructs json-patch to pass the "test" operations whose object values are matched by
+	// the values in the document partially: an object in the document matches an object of the value
+	// if it has all the members of the object, with matching values, and maybe other members.
+	// The arrays match if they have the same length and matching elements, the other values are
+	// compared as usual. Default to false.
+	TestSubset bool
+	// FoldKeys instructs json-patch to match the object member names case-insensitively when they are not
+	// matched exactly, such as for the documents from the sources with inconsistent key casing.
+	// The operations write to the matched members, new members are added with the names of the paths.
+	// Default to false.
+	FoldKeys bool
+	// NormalizeUnicodeKeys instructs json-patch to match the object member names by their Unicode NFC forms
+	// when they are not matched exactly, so the composed and decomposed forms of the same names match,
+	// as FoldKeys. Default to false.
+	NormalizeUnicodeKeys bool
+	// NullIsAbsent instructs json-patch to treat the object members of null values as missing:
+	// GetChild fails with ErrMissing for them, FindChildren does not match them by "exists" and
+	// matches the missing members by null values, the "test" operations of null values pass for
+	// the missing parents, and the "add" operations of null values remove the members instead of
+	// adding them. The null elements of arrays are kept. Default to false.
+	NullIsAbsent bool
+	// AllowComments instructs json-patch to accept the "//" and "/* */" comments in the document to patch,
+	// as in the JSONC config files, such as tsconfig.json. The comments are dropped from the patched document.
+	// Default to false.
+	AllowComments bool
+	// AllowTrailingCommas instructs json-patch to accept a comma after the last member of an object or
+	// the last element of an array in the document to patch, as in JSON5. Default to false.
+	AllowTrailingCommas bool
+	// EnablePredicates enables the JSON Predicate operations ("contains", "defined", "undefined", "starts",
+	// "ends", "less", "more", "in", "matches", "type", "and", "or" and "not") as extension operations,
+	// see https://datatracker.ietf.org/doc/html/draft-snell-json-test-07.
+	// Default to false.
+	EnablePredicates bool
+	// EnableIncrement enables the "inc" extension operation, which adds the number of its value to the number
+	// at its path, such as {"op": "inc", "path": "/count", "value": 5}, so concurrent counters don't need
+	// to read and replace the values. The missing members of objects are added as the value.
+	// The integers are added exactly, at any size, the other numbers as float64, failing on overflow.
+	// Default to false.
+	EnableIncrement bool
+	// EnableStringOps enables the string extension operations, which edit the string at their path
+	// with the string of their value, such as for the collaborative editing of the text fields:
+	// "str-append" appends the value, "str-insert" inserts the value at the rune offset "pos" and
+	// "str-replace" replaces the "len" runes at the rune offset "pos" with the value.
+	// Default to false.
+	EnableStringOps bool
+	// EnableSplice enables the "splice" extension operation, which removes the "remove" elements of
+	// an array at the index of its path and inserts the "values" there, in a single pass over the array,
+	// such as {"op": "splice", "path": "/items/3", "remove": 2, "values": [1, 2, 3]}.
+	// The index is resolved as the index of an "add" operation. Default to false.
+	EnableSplice bool
+	// RecoverPanics instructs json-patch to recover from the panics raised while applying an operation,
+	// such as in a custom operation handler, and return them as *PanicError.
+	// Default to false.
+	RecoverPanics bool
+	// ContinueOnError instructs json-patch to attempt every operation of a patch even if some of them fail,
+	// the failures are returned together as a MultiError. The failed operations are skipped,
+	// the changes they made before failing are rolled back.
+	// Default to false.
+	ContinueOnError bool
+	// QueryParallelism is the maximum number of documents FindInDocs searches concurrently.
+	// Default to 0, which means the documents are searched sequentially.
+	QueryParallelism int
+	// ApplyParallelism is the maximum number of documents ApplyAll patches concurrently.
+	// Default to 0, which means runtime.GOMAXPROCS(0).
+	ApplyParallelism int
+	// OnChange is called with the change of every applied operation, so the changes of a large patch
+	// can be consumed incrementally instead of being accumulated.
+	// Default to nil.
+	OnChange func(Change)
+	// BeforeApply is called with every operation and its change before it is applied, such as to authorize
+	// the modified paths. The change has the resolved paths, the old value, and the new value to be written
+	// by the "add", "replace", "copy" and "move" operations. The operation fails with the returned error,
+	// or is skipped if Options.ContinueOnError is true.
+	// Default to nil.
+	BeforeApply func(op Operation, c Change) error
+	// AfterApply is called with every applied operation and its change, as OnChange, such as for audit trails.
+	// Default to nil.
+	AfterApply func(op Operation, c Change)
+	// OnWarning is called with the non-fatal conditions of the lenient options while applying a patch,
+	// such as the skipped "remove" operations of missing paths, the created or converted intermediates
+	// and the non-standard negative indices.
+	// Default to nil.
+	OnWarning func(Warning)
+	// ValueValidator is called with the path and the value of every "add" and "replace" operation
+	// before the document is modified, such as to check the value against the schema of the path,
+	// so that the patched documents stay valid without validating the whole documents.
+	// The operation fails with the returned error. The path is the path of the operation as is,
+	// it may have the "-" token or negative indices, and a missing value is passed as null.
+	// It is called with the results of the "inc" and the string operations too, at their paths,
+	// and with every value inserted by the "splice" operations, at the index of the value.
+	// Default to nil.
+	ValueValidator func(path string, value json.RawMessage) error
+	// Stats collects the cost of the applied patches if it is not nil, see ApplyStats.
+	// Default to nil.
+	Stats *ApplyStats
+	// Clock provides the current time to the features that stamp times.
+	// Default to nil, which means time.Now.
+	Clock Clock
+
+	operations map[string]OperationHandler
+	// applying is the operation being applied, for the warnings.
+	applying *Warning
+}
+
+// Warning is a non-fatal condition encountered while applying an operation of a patch.
+type Warning struct {
+	// Index is the index of the operation in the patch.
+	Index int `json:"index"`
+	// Op and Path are the name and the path of the operation.
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	// Message describes the condition.
+	Message string `json:"message"`
+}
+
+// warnf reports a warning of the operation being applied to OnWarning.
+func (o *Options) warnf(format string, a ...interface{}) {
+	if o.OnWarning == nil || o.applying == nil {
+		return
+	}
+	w := *o.applying
+	w.Message = fmt.Sprintf(format, a...)
+	o.OnWarning(w)
+}
+
+// OperationHandler applies a custom operation to the document.
+// The handler can use the methods of the node, such as GetChild and Patch, to mutate the document.
+type OperationHandler func(doc *Node, op Operation, options *Options) error
+
+// RegisterOperation registers a handler for a custom operation in the options,
+// so the operation can be applied alongside the RFC 6902 operations.
+// The RFC 6902 operations can not be overridden. A nil handler unregisters the operation.
+func (o *Options) RegisterOperation(name string, fn OperationHandler) {
+	if fn == nil {
+		delete(o.operations, name)
+		return
+	}
+	if o.operations == nil {
+		o.operations = make(map[string]OperationHandler)
+	}
+	o.operations[name] = fn
+}
+
+// extension returns the handler of the extension operation, a custom operation registered in the options
+// or an operation enabled by them, such as a JSON Predicate operation.
+func (o *Options) extension(name string) (OperationHandler, bool) {
+	switch {
+	case o.EnablePredicates && predicateOperations[name]:
+		return applyPredicate, true
+	case o.EnableIncrement && name == "inc":
+		return applyIncrement, true
+	case o.EnableStringOps && stringOperations[name]:
+		return applyStringOp, true
+	case o.EnableSplice && name == "splice":
+		return applySplice, true
+	}
+	fn, ok := o.operations[name]
+	return fn, ok
+}
+
+// Clock provides the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// Now returns the current time of the Clock in options, or time.Now if no Clock is set.
+func (o *Options) Now() time.Time {
+	if o == nil || o.Clock == nil {
+		return time.Now()
+	}
+	return o.Clock.Now()
 }
 
 // NewOptions creates a default set of options for calls to ApplyWithOptions.
@@ -119,9 +373,46 @@ func NewOptions() *Options {
 		AccumulatedCopySizeLimit: AccumulatedCopySizeLimit,
 		AllowMissingPathOnRemove: false,
 		EnsurePathExistsOnAdd:    false,
+		ConvertNullIntermediates: false,
 	}
 }
 
+// StrictRFC6902 sets the options to the exact semantics of RFC 6902 for interoperability:
+// no negative indices, no missing paths on "remove", no intermediates created or converted on "add",
+// no indices beyond the length of arrays, no partial matches of "test" and no extension operations,
+// the registered operations are unregistered. It returns the options.
+func (o *Options) StrictRFC6902() *Options {
+	o.SupportNegativeIndices = false
+	o.AllowMissingPathOnRemove = false
+	o.EnsurePathExistsOnAdd = false
+	o.AppendBeyondArrayLength = false
+	o.ConvertNullIntermediates = false
+	o.EnablePredicates = false
+	o.EnableIncrement = false
+	o.EnableStringOps = false
+	o.EnableSplice = false
+	o.operations = nil
+	o.TestSubset = false
+	o.ContinueOnError = false
+	o.FoldKeys = false
+	o.NormalizeUnicodeKeys = false
+	o.NullIsAbsent = false
+	return o
+}
+
+// Lenient sets the options to the forgiving semantics: negative indices are supported, "remove" operations
+// of missing paths are skipped, missing and null intermediates are created on "add" and values are appended
+// to the arrays for the indices beyond their length. It returns the options.
+// The deviations from RFC 6902 are reported to OnWarning.
+func (o *Options) Lenient() *Options {
+	o.SupportNegativeIndices = true
+	o.AllowMissingPathOnRemove = true
+	o.EnsurePathExistsOnAdd = true
+	o.AppendBeyondArrayLength = true
+	o.ConvertNullIntermediates = true
+	return o
+}
+
 // NewPatch decodes the passed JSON document as an RFC 6902 patch.
 func NewPatch(doc []byte) (Patch, error) {
 	var p Patch
@@ -146,15 +437,113 @@ func (p Patch) ApplyWithOptions(doc []byte, options *Options) ([]byte, error) {
 	if err := node.Patch(p, options); err != nil {
 		return nil, err
 	}
-	return node.MarshalJSON()
+	return marshalPatched(node, doc, options)
+}
+
+// ApplyAtomic mutates a JSON document according to the patch and the passed in Options.
+// It returns the new document, or an error without any partially patched document if an operation fails.
+func (p Patch) ApplyAtomic(doc []byte, options *Options) ([]byte, error) {
+	node := NewNode(doc)
+	if err := node.PatchAtomic(p, options); err != nil {
+		return nil, err
+	}
+	return marshalPatched(node, doc, options)
+}
+
+// ApplyAt mutates the subtree at basePath of a JSON document according to the patch and the passed in Options,
+// the paths of the patch are relative to basePath, as if the subtree were the document root.
+// It returns the new document.
+func ApplyAt(doc []byte, basePath string, p Patch, options *Options) ([]byte, error) {
+	node := NewNode(doc)
+	if err := node.PatchAt(basePath, p, options); err != nil {
+		return nil, err
+	}
+	return marshalPatched(node, doc, options)
+}
+
+// ApplyConditional mutates a JSON document according to the patch if the integer version at versionPath
+// equals expected, and increments the version, for the optimistic concurrency of document stores.
+// It returns the new document, or an error wrapping ErrTestFailed if the version doesn't match.
+func ApplyConditional(doc []byte, p Patch, versionPath string, expected json.RawMessage, options *Options) ([]byte, error) {
+	expected = bytes.TrimSpace(expected)
+	v, err := strconv.ParseInt(string(expected), 10, 64)
+	if err != nil || v == math.MaxInt64 {
+		return nil, fmt.Errorf("unable to bump version %q at %q, %w", expected, versionPath, ErrInvalid)
+	}
+
+	cp := make(Patch, 0, len(p)+2)
+	cp = append(cp, Operation{Op: "test", Path: versionPath, Value: expected})
+	cp = append(cp, p...)
+	cp = append(cp, Operation{Op: "replace", Path: versionPath, Value: strconv.AppendInt(nil, v+1, 10)})
+	return cp.ApplyAtomic(doc, options)
+}
+
+// ApplyForEach mutates every element of the array at arrayPath of a JSON document according to the patch
+// and the passed in Options, the paths of the patch are relative to the elements.
+// It returns the new document, or an error if the patch fails on any of the elements.
+func ApplyForEach(doc []byte, arrayPath string, p Patch, options *Options) ([]byte, error) {
+	node := NewNode(doc)
+	ary := node
+	if arrayPath != "" {
+		var err error
+		if ary, err = node.GetChild(arrayPath, options); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := ary.intoContainer(); err != nil || ary.which != eAry {
+		return nil, fmt.Errorf("unable to apply for each element of %q, not an array", arrayPath)
+	}
+	for i, elem := range ary.ary {
+		if err := elem.Patch(p, options); err != nil {
+			return nil, fmt.Errorf("unable to apply for element %d of %q, %w", i, arrayPath, err)
+		}
+	}
+	return marshalPatched(node, doc, options)
+}
+
+// marshalPatched returns the encoding of the node patched from doc, and counts the bytes in Options.Stats.
+func marshalPatched(node *Node, doc []byte, options *Options) ([]byte, error) {
+	res, err := node.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	options.stats().patched(doc, res)
+	return res, nil
+}
+
+// Validate checks that the patch applies to the JSON document with the passed in Options,
+// including path existence, index bounds and test assertions, without producing the modified document.
+// Every operation is checked against the document as modified by the operations before it.
+func (p Patch) Validate(doc []byte, options *Options) error {
+	return NewNode(doc).Patch(p, options)
 }
 
 // Node represents a lazy parsing JSON document.
+// Scalar values are kept as their original lexemes, so numbers are emitted exactly
+// as they appeared in the input (exponent form, trailing zeros, big integers) unless they are modified.
+//
+// A Node is not safe for concurrent use: even the reads parse the containers on demand and keep them
+// in the node. After Freeze, the node is read-only and can be read by multiple goroutines concurrently.
 type Node struct {
 	raw   *json.RawMessage
 	doc   *partialDoc
 	ary   partialArray
 	which int
+	// valid is true if raw is known to be valid JSON, such as the children parsed from a valid document.
+	valid bool
+	// frozen is true if the node and its descendants are parsed and read-only, see Freeze.
+	frozen bool
+	// reformat is true for the values of the operations and their children, which are laid out
+	// by MarshalIndent instead of being written as they are.
+	reformat bool
+	// watch is the watchers of the changes of the node, see OnChange.
+	watch *watchList
+	// lookups is the cache of the paths resolved by GetChild, see Options.LookupCacheSize.
+	lookups *lookupTable
+	// bounds is the size and the depth of the document of the node checked against the limits,
+	// see Options.MaxDocumentBytes and Options.MaxDepth.
+	bounds *docBounds
 }
 
 // NewNode returns a new Node with the given raw encoded JSON document.
@@ -172,77 +561,358 @@ func NewNode(doc json.RawMessage) *Node {
 
 // String returns a string representation of the node.
 func (n *Node) String() string {
-	if n.raw == nil || isNull(*n.raw) {
+	if n.isNull() {
 		return "<nil>"
 	}
+	data, err := n.MarshalJSON()
+	if err != nil {
+		return fmt.Sprintf("<error: %v>", err)
+	}
 	var v interface{}
-	if err := json.Unmarshal(*n.raw, &v); err != nil {
+	if err := json.Unmarshal(data, &v); err != nil {
 		return fmt.Sprintf("<error: %v>", err)
 	}
 	return fmt.Sprintf("%v", v)
 }
 
 // Patch applies the given patch to the node.
+// The node is patched in place, it may be left partially patched if an operation fails,
+// use PatchAtomic to avoid that.
 func (n *Node) Patch(p Patch, options *Options) error {
+	return n.patch(p, options, nil)
+}
+
+// PatchAt applies the given patch to the child node at basePath, the paths of the patch are relative to basePath.
+func (n *Node) PatchAt(basePath string, p Patch, options *Options) error {
+	if basePath == "" {
+		return n.Patch(p, options)
+	}
+
+	child, err := n.GetChild(basePath, options)
+	if err != nil {
+		return err
+	}
+	if child != nil && child.frozen {
+		return fmt.Errorf("unable to patch node, %w", ErrFrozen)
+	}
+	n.ClearLookupCache()
+	return child.patch(p, options, n.watching(basePath))
+}
+
+// PatchAtomic applies the given patch to the node. The patch is applied to a copy of the node,
+// which replaces the node only if all operations succeed, so the node is never left partially patched.
+func (n *Node) PatchAtomic(p Patch, options *Options) error {
+	if n != nil && n.frozen {
+		return fmt.Errorf("unable to patch node, %w", ErrFrozen)
+	}
+	c := n.cloneShared()
+	if n.bounds != nil && n.bounds.gen == n.lookupGen() {
+		// the copy has the same document, without the cache.
+		b := *n.bounds
+		b.gen = 0
+		c.bounds = &b
+	}
+	var changes []Change
+	var observe func(Change)
+	if notify := n.watching(""); notify != nil {
+		observe = func(ch Change) { changes = append(changes, ch) }
+		defer func() {
+			for _, ch := range changes {
+				notify(ch)
+			}
+		}()
+	}
+	if err := c.patch(p, options, observe); err != nil {
+		changes = nil
+		return err
+	}
+	c.watch = n.watch
+	*n = *c
+	return nil
+}
+
+// patch applies the given patch to the node, observe is called with the change of every applied operation.
+func (n *Node) patch(p Patch, options *Options, observe func(Change)) error {
+	if n != nil && n.frozen {
+		return fmt.Errorf("unable to patch node, %w", ErrFrozen)
+	}
+	if options == nil {
+		options = NewOptions()
+	}
+	n.ClearLookupCache()
+	n.tolerate(options)
+	// the limits are checked before the document is parsed.
+	if err := options.checkLimits(n, p); err != nil {
+		return err
+	}
+
 	pd, err := n.intoContainer()
 	switch {
 	case err != nil:
-		return fmt.Errorf("unexpected node %q, %v", n.String(), err)
+		return fmt.Errorf("unexpected node %q, %w", n.String(), err)
 	case pd == nil:
 		return fmt.Errorf("unexpected node %q", n.String())
 	}
+	observe = chainObserve(chainObserve(observe, n.watching("")), options.OnChange)
+	if fn := options.AfterApply; fn != nil {
+		observe = chainObserve(observe, func(c Change) { fn(p[c.Index], c) })
+	}
 
-	if options == nil {
-		options = NewOptions()
+	if options.RejectDuplicateKeys {
+		if err = n.checkDuplicateKeys(""); err != nil {
+			return err
+		}
+	}
+
+	if options.OnWarning != nil {
+		// the copy tracks the operation being applied, without changing the caller's options.
+		o := *options
+		options = &o
 	}
+
 	var accumulatedCopySize int64
-	for _, op := range p {
-		switch op.Op {
-		case "add":
-			err = p.add(&pd, op, options)
-		case "remove":
-			err = p.remove(&pd, op, options)
-		case "replace":
-			err = p.replace(&pd, op, options)
-		case "move":
-			err = p.move(&pd, op, options)
-		case "test":
-			err = p.test(&pd, op, options)
-		case "copy":
-			err = p.copy(&pd, op, &accumulatedCopySize, options)
-		default:
-			err = fmt.Errorf("unexpected operation %q", op.Op)
+	var errs MultiError
+	for i, op := range p {
+		var c Change
+		if observe != nil || options.BeforeApply != nil {
+			c = beginChange(pd, i, op, options)
+		}
+		if options.OnWarning != nil {
+			options.applying = &Warning{Index: i, Op: op.Op, Path: op.Path}
+			warnNegativeIndices(pd, op, options)
+		}
+
+		var snapshot container
+		if options.ContinueOnError && options.writesPartially(op) {
+			snapshot = cloneContainer(pd)
+		}
+		if err = options.beforeApply(pd, op, c); err == nil {
+			if options.RecoverPanics {
+				err = p.applyRecover(n, &pd, i, op, &accumulatedCopySize, options)
+			} else {
+				err = p.applyOp(n, &pd, op, &accumulatedCopySize, options)
+			}
+			n.changed()
 		}
 
 		if err != nil {
+			if _, ok := err.(*PanicError); !ok {
+				err = &PathError{Op: op.Op, Path: op.Path, Index: i, Err: err}
+			}
+			if options.ContinueOnError {
+				// the failed operation is rolled back, so it is skipped as a whole.
+				if snapshot != nil {
+					pd = snapshot
+				}
+				errs = append(errs, err)
+				continue
+			}
 			return err
 		}
+		options.stats().applied()
+		n.grow(pd, op, options)
+		if observe != nil {
+			endChange(pd, &c, op, options)
+			observe(c)
+		}
 	}
-	switch n.which {
-	case eDoc:
-		n.doc = pd.(*partialDoc)
-	case eAry:
-		n.ary = *(pd.(*partialArray))
+	n.setContainer(pd)
+	if n.bounds != nil {
+		n.bounds.gen = n.lookupGen()
+	}
+	if len(errs) > 0 {
+		return errs
 	}
 	return nil
 }
 
+// writesPartially indicates if the operation may change the document before it fails, such as
+// the removed "from" of a "move" operation or the intermediates created or converted for the path.
+func (o *Options) writesPartially(op Operation) bool {
+	switch op.Op {
+	case "move":
+		return true
+	case "add", "copy":
+		return o.EnsurePathExistsOnAdd || o.ConvertNullIntermediates
+	}
+	_, ok := o.operations[op.Op]
+	return ok
+}
+
+// cloneContainer returns a copy of the container that shares the frozen descendants, see cloneShared.
+func cloneContainer(pd container) container {
+	switch v := pd.(type) {
+	case *partialDoc:
+		return v.copyWith((*Node).cloneShared)
+	case *partialArray:
+		ary := v.copyWith((*Node).cloneShared)
+		return &ary
+	}
+	return pd
+}
+
+// warnNegativeIndices warns about the non-standard negative indices in the paths of the operation.
+func warnNegativeIndices(pd container, op Operation, options *Options) {
+	if !options.SupportNegativeIndices {
+		return
+	}
+	if resolved := resolvePath(pd, op.Path, false, options); resolved != op.Path {
+		options.warnf("negative index in path %q is non-standard, resolved to %q", op.Path, resolved)
+	}
+	if op.From != "" {
+		if resolved := resolvePath(pd, op.From, false, options); resolved != op.From {
+			options.warnf("negative index in from %q is non-standard, resolved to %q", op.From, resolved)
+		}
+	}
+}
+
+func (p Patch) applyOp(n *Node, pd *container, op Operation, accumulatedCopySize *int64, options *Options) error {
+	if err := checkFrozen(*pd, op, options); err != nil {
+		return err
+	}
+	if err := options.checkOperation(*pd, op); err != nil {
+		return err
+	}
+	if op.Op == "add" || op.Op == "replace" {
+		if err := options.validateValue(op.Op, op.Path, op.Value); err != nil {
+			return err
+		}
+	}
+	if options.RejectDuplicateKeys && op.Value != nil {
+		if err := NewNode(op.Value).checkDuplicateKeys(""); err != nil {
+			return fmt.Errorf("%s operation has invalid value, %w", op.Op, err)
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return p.add(pd, op, options)
+	case "remove":
+		return p.remove(pd, op, options)
+	case "replace":
+		return p.replace(pd, op, options)
+	case "move":
+		return p.move(pd, op, options)
+	case "test":
+		return p.test(pd, op, options)
+	case "copy":
+		return p.copy(pd, op, accumulatedCopySize, options)
+	}
+
+	fn, ok := options.extension(op.Op)
+	if op.compiled != nil && op.compiled.handler != nil {
+		fn, ok = op.compiled.handler, true
+	}
+	if !ok {
+		return fmt.Errorf("unexpected operation %q", op.Op)
+	}
+	// the handler works on the node, so sync the node before it and the container after it.
+	n.setContainer(*pd)
+	if err := fn(n, op, options); err != nil {
+		return err
+	}
+	var err error
+	*pd, err = n.intoContainer()
+	return err
+}
+
+// validateValue calls Options.ValueValidator with the value the operation writes at the path.
+func (o *Options) validateValue(op, path string, value json.RawMessage) error {
+	if o.ValueValidator == nil {
+		return nil
+	}
+	if value == nil {
+		value = rawJSONNull
+	}
+	if err := o.ValueValidator(path, value); err != nil {
+		return fmt.Errorf("%s operation has invalid value, %w", op, err)
+	}
+	return nil
+}
+
+func (p Patch) applyRecover(
+	n *Node, pd *container, i int, op Operation, accumulatedCopySize *int64, options *Options,
+) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Index: i, Op: op.Op, Path: op.Path, Value: r}
+		}
+	}()
+	return p.applyOp(n, pd, op, accumulatedCopySize, options)
+}
+
+// setContainer sets the container of the node,
+// the root may have been replaced by a different container type.
+func (n *Node) setContainer(pd container) {
+	n.changed()
+	switch v := pd.(type) {
+	case *partialDoc:
+		n.doc, n.ary, n.which = v, nil, eDoc
+	case *partialArray:
+		n.doc, n.ary, n.which = nil, *v, eAry
+	}
+}
+
 // MarshalJSON implements the json.Marshaler interface.
+// The subtrees that have not been parsed are written as they are, compacted, without being decoded.
+// With MarshalJSON and UnmarshalJSON, which encoding/json/v2 calls too, the *Node fields of structs
+// are encoded and decoded as their JSON documents, without json.RawMessage copies. The fields must be *Node:
+// the methods of *Node are not called for the Node fields of the structs that are not addressable.
 func (n *Node) MarshalJSON() ([]byte, error) {
+	return n.AppendJSON(nil)
+}
+
+// AppendJSON appends the compact JSON encoding of the node to dst, as MarshalJSON,
+// and returns the extended buffer, so the buffers of the callers can be reused.
+// dst is returned unchanged with the error.
+func (n *Node) AppendJSON(dst []byte) ([]byte, error) {
+	w := getWriter()
+	defer putWriter(w)
+	if err := n.writeJSON(w); err != nil {
+		return dst, err
+	}
+	return append(dst, w.Bytes()...), nil
+}
+
+// writeJSON writes the compact JSON encoding of the node, with the same escaping as json.Marshal.
+func (n *Node) writeJSON(w *jsonWriter) error {
 	if n == nil {
-		return []byte("null"), nil
+		w.WriteString("null")
+		return nil
 	}
 
-	n.intoContainer()
 	switch n.which {
-	case eOther:
-		return json.Marshal(n.raw)
+	case eRaw, eOther:
+		if n.raw == nil {
+			return ErrInvalid
+		}
+		if err := n.checkValid(); err != nil {
+			return err
+		}
+		if w.writeRaw(*n.raw) {
+			return nil
+		}
+		// the objects with duplicate or escaped keys are parsed to take the last members.
+		if _, err := n.intoContainer(); err != nil {
+			return err
+		}
+		return n.writeJSON(w)
 	case eDoc:
-		return json.Marshal(n.doc)
+		return n.doc.writeJSON(w)
 	case eAry:
-		return json.Marshal(n.ary)
+		w.WriteByte('[')
+		for i, v := range n.ary {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := v.writeJSON(w); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+		return nil
 	default:
-		return nil, errors.New("unknown node type")
+		return errors.New("unknown node type")
 	}
 }
 
@@ -251,20 +921,54 @@ func (n *Node) UnmarshalJSON(data []byte) error {
 	if n == nil {
 		return errors.New("nil node")
 	}
+	if n.frozen {
+		return fmt.Errorf("unable to unmarshal node, %w", ErrFrozen)
+	}
 
 	if !json.Valid(data) {
 		return errors.New("invalid JSON data")
 	}
 
-	if n.raw == nil {
-		raw := make(json.RawMessage, len(data))
-		n.raw = &raw
-	}
-	*n.raw = append((*n.raw)[0:0], data...)
-	n.which = eRaw
+	// the children parsed from the old data may share its buffer, so it is not reused.
+	raw := make(json.RawMessage, len(data))
+	copy(raw, data)
+	n.raw, n.valid = &raw, true
+	n.doc, n.ary, n.which = nil, nil, eRaw
+	n.bounds = nil
+	n.changed()
+	n.ClearLookupCache()
 	return nil
 }
 
+// Clone returns a deep copy of the node, including the children that have already been parsed.
+// The returned node shares nothing with the original, so it can be patched without
+// affecting the original node.
+func (n *Node) Clone() *Node {
+	return n.copyWith((*Node).Clone)
+}
+
+// copyWith returns a copy of the node with the children copied by fn.
+func (n *Node) copyWith(fn func(*Node) *Node) *Node {
+	if n == nil {
+		return nil
+	}
+
+	c := &Node{which: n.which, valid: n.valid, reformat: n.reformat}
+	if n.raw != nil {
+		raw := make(json.RawMessage, len(*n.raw))
+		copy(raw, *n.raw)
+		c.raw = &raw
+	}
+
+	switch n.which {
+	case eDoc:
+		c.doc = n.doc.copyWith(fn)
+	case eAry:
+		c.ary = n.ary.copyWith(fn)
+	}
+	return c
+}
+
 type container interface {
 	get(key string, options *Options) (*Node, error)
 	set(key string, val *Node, options *Options) error
@@ -275,75 +979,77 @@ type container interface {
 type partialDoc struct {
 	keys []string
 	obj  map[string]*Node
+	// dup is the first duplicate key in the JSON data, the last member has been taken.
+	dup string
+	// order is the order of the keys before the first removal, for Options.PreserveKeyOrder.
+	order []string
 }
 
 type partialArray []*Node
 
 func (d *partialDoc) MarshalJSON() ([]byte, error) {
-	var buf bytes.Buffer
-	if _, err := buf.WriteString("{"); err != nil {
+	w := getWriter()
+	defer putWriter(w)
+	if err := d.writeJSON(w); err != nil {
 		return nil, err
 	}
+	return append([]byte(nil), w.Bytes()...), nil
+}
+
+func (d *partialDoc) writeJSON(w *jsonWriter) error {
+	w.WriteByte('{')
 	for i, k := range d.keys {
 		if i > 0 {
-			if _, err := buf.WriteString(","); err != nil {
-				return nil, err
-			}
+			w.WriteByte(',')
 		}
-		key, err := json.Marshal(k)
-		if err != nil {
-			return nil, err
-		}
-		if _, err := buf.Write(key); err != nil {
-			return nil, err
-		}
-		if _, err := buf.WriteString(":"); err != nil {
-			return nil, err
-		}
-		value, err := json.Marshal(d.obj[k])
-		if err != nil {
-			return nil, err
+		if err := w.writeString(k); err != nil {
+			return err
 		}
-		if _, err := buf.Write(value); err != nil {
-			return nil, err
+		w.WriteByte(':')
+		if err := d.obj[k].writeJSON(w); err != nil {
+			return err
 		}
 	}
-	if _, err := buf.WriteString("}"); err != nil {
-		return nil, err
-	}
-	return buf.Bytes(), nil
+	w.WriteByte('}')
+	return nil
 }
 
 func (d *partialDoc) UnmarshalJSON(data []byte) error {
-	if err := json.Unmarshal(data, &d.obj); err != nil {
+	if err := checkValidJSON(data); err != nil {
 		return err
 	}
-	buffer := bytes.NewBuffer(data)
-	de := json.NewDecoder(buffer)
-	if t, err := de.Token(); err != nil {
-		return err
-	} else if t != startObject {
-		return fmt.Errorf("unexpected JSON token %v in document node", t)
+	if checkWhich(data) != eDoc {
+		return fmt.Errorf("unexpected JSON data %q in document node", data)
 	}
+	*d = *parseObject(data)
+	return nil
+}
 
-	for de.More() {
-		k, err := de.Token()
-		if err != nil {
-			return err
-		}
-		key, ok := k.(string)
-		if !ok {
-			return fmt.Errorf("unexpected JSON token %v as document node key", k)
-		}
-		if err := skipValue(de); err != nil {
-			return err
-		}
-		d.keys = append(d.keys, key)
+// copyWith returns a copy of the object with the members copied by fn.
+func (d *partialDoc) copyWith(fn func(*Node) *Node) *partialDoc {
+	if d == nil {
+		return nil
 	}
-	return nil
+
+	c := &partialDoc{
+		keys: make([]string, len(d.keys)),
+		obj:  make(map[string]*Node, len(d.obj)),
+		dup:  d.dup,
+	}
+	copy(c.keys, d.keys)
+	if d.order != nil {
+		c.order = make([]string, len(d.order))
+		copy(c.order, d.order)
+	}
+	for k, v := range d.obj {
+		c.obj[k] = fn(v)
+	}
+	return c
 }
 
 func (d *partialDoc) set(key string, val *Node, options *Options) error {
+	key = d.matchKey(key, options)
+	preserve := options != nil && options.PreserveKeyOrder
 	found := false
 	for _, k := range d.keys {
 		if k == key {
@@ -351,11 +1057,74 @@ func (d *partialDoc) set(key string, val *Node, options *Options) error {
 			break
 		}
 	}
-	if !found {
-		d.keys = append(d.keys, key)
+	switch {
+	case found:
+		if preserve {
+			keepKeyOrder(d.obj[key], val)
+		}
+	case preserve && d.order != nil:
+		d.insertKey(key)
+	default:
+		d.keys = append(d.keys, key)
+	}
+	d.obj[key] = val
+	return nil
+}
+
+// insertKey inserts a new key at its place in the original order, or at the end if it is not original.
+func (d *partialDoc) insertKey(key string) {
+	pos := indexOfKey(d.order, key)
+	if pos < 0 {
+		d.keys = append(d.keys, key)
+		return
+	}
+
+	idx := len(d.keys)
+	for i, k := range d.keys {
+		if p := indexOfKey(d.order, k); p < 0 || p > pos {
+			idx = i
+			break
+		}
+	}
+	d.keys = append(d.keys, "")
+	copy(d.keys[idx+1:], d.keys[idx:])
+	d.keys[idx] = key
+}
+
+func indexOfKey(keys []string, key string) int {
+	for i, k := range keys {
+		if k == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// keepKeyOrder reorders the keys of val, which replaces old, to follow the keys of old.
+func keepKeyOrder(old, val *Node) {
+	if old == nil || val == nil {
+		return
+	}
+	if c, _ := old.intoContainer(); c == nil || old.which != eDoc {
+		return
+	}
+	if c, _ := val.intoContainer(); c == nil || val.which != eDoc {
+		return
+	}
+
+	keys := make([]string, 0, len(val.doc.keys))
+	for _, k := range old.doc.keys {
+		if _, ok := val.doc.obj[k]; ok {
+			keys = append(keys, k)
+			keepKeyOrder(old.doc.obj[k], val.doc.obj[k])
+		}
+	}
+	for _, k := range val.doc.keys {
+		if _, ok := old.doc.obj[k]; !ok {
+			keys = append(keys, k)
+		}
 	}
-	d.obj[key] = val
-	return nil
+	val.doc.keys = keys
 }
 
 func (d *partialDoc) add(key string, val *Node, options *Options) error {
@@ -363,9 +1132,9 @@ func (d *partialDoc) add(key string, val *Node, options *Options) error {
 }
 
 func (d *partialDoc) get(key string, options *Options) (*Node, error) {
-	v, ok := d.obj[key]
+	v, ok := d.obj[d.matchKey(key, options)]
 	if !ok {
-		return nil, fmt.Errorf("unable to get nonexistent key %q, %v", key, ErrMissing)
+		return nil, fmt.Errorf("unable to get nonexistent key %q, %w", key, ErrMissing)
 	}
 	if v == nil {
 		v = NewNode(nil)
@@ -374,12 +1143,18 @@ func (d *partialDoc) get(key string, options *Options) (*Node, error) {
 }
 
 func (d *partialDoc) remove(key string, options *Options) error {
+	key = d.matchKey(key, options)
 	_, ok := d.obj[key]
 	if !ok {
 		if options.AllowMissingPathOnRemove {
 			return nil
 		}
-		return fmt.Errorf("unable to remove nonexistent key %q, %v", key, ErrMissing)
+		return fmt.Errorf("unable to remove nonexistent key %q, %w", key, ErrMissing)
+	}
+
+	if options.PreserveKeyOrder && d.order == nil {
+		d.order = make([]string, len(d.keys))
+		copy(d.order, d.keys)
 	}
 
 	idx := -1
@@ -394,6 +1169,23 @@ func (d *partialDoc) remove(key string, options *Options) error {
 	return nil
 }
 
+func (d partialArray) clone() partialArray {
+	return d.copyWith((*Node).Clone)
+}
+
+// copyWith returns a copy of the array with the elements copied by fn.
+func (d partialArray) copyWith(fn func(*Node) *Node) partialArray {
+	if d == nil {
+		return nil
+	}
+
+	c := make(partialArray, len(d))
+	for i, v := range d {
+		c[i] = fn(v)
+	}
+	return c
+}
+
 // set should only be used to implement the "replace" operation, so "key" must
 // be an already existing index in "d".
 func (d *partialArray) set(key string, val *Node, options *Options) error {
@@ -403,13 +1195,20 @@ func (d *partialArray) set(key string, val *Node, options *Options) error {
 	}
 
 	sz := len(*d)
+	if idx >= sz {
+		return fmt.Errorf("unable to access invalid index %s, %w", key, ErrInvalidIndex)
+	}
+
 	if idx < 0 {
 		if !options.SupportNegativeIndices || idx < -sz {
-			return fmt.Errorf("unable to access invalid index %s, %v", key, ErrInvalidIndex)
+			return fmt.Errorf("unable to access invalid index %s, %w", key, ErrInvalidIndex)
 		}
 		idx += sz
 	}
 
+	if options.PreserveKeyOrder {
+		keepKeyOrder((*d)[idx], val)
+	}
 	(*d)[idx] = val
 	return nil
 }
@@ -420,49 +1219,53 @@ func (d *partialArray) add(key string, val *Node, options *Options) error {
 		return nil
 	}
 
-	idx, err := strconv.Atoi(key)
+	idx, err := d.insertIndex(key, options)
 	if err != nil {
-		return fmt.Errorf("value was not a proper array index %s, %v", key, err)
-	}
-
-	sz := len(*d) + 1
-	if idx >= sz {
-		return fmt.Errorf("unable to access invalid index %s, %v", key, ErrInvalidIndex)
-	}
-
-	if idx < 0 {
-		if !options.SupportNegativeIndices || idx < -sz {
-			return fmt.Errorf("unable to access invalid index %s, %v", key, ErrInvalidIndex)
-		}
-		idx += sz
+		return err
 	}
 
 	cur := *d
-	ary := make([]*Node, sz)
+	ary := make([]*Node, len(cur)+1)
 	copy(ary[0:idx], cur[0:idx])
 	ary[idx] = val
 	copy(ary[idx+1:], cur[idx:])
+	options.stats().shifted(len(cur) - idx)
 
 	*d = ary
 	return nil
 }
 
-func (d *partialArray) get(key string, options *Options) (*Node, error) {
+// insertIndex returns the index of the element inserted by the key as add.
+func (d *partialArray) insertIndex(key string, options *Options) (int, error) {
+	sz := len(*d) + 1
+	if key == "-" {
+		return sz - 1, nil
+	}
+
 	idx, err := strconv.Atoi(key)
 	if err != nil {
-		return nil, err
+		return 0, fmt.Errorf("value was not a proper array index %s, %w", key, err)
+	}
+	if idx >= sz {
+		if !options.AppendBeyondArrayLength {
+			return 0, fmt.Errorf("unable to access invalid index %s, %w", key, ErrInvalidIndex)
+		}
+		options.warnf("index %s beyond the length of array %d, appended", key, sz-1)
+		idx = sz - 1
 	}
-
-	sz := len(*d)
 	if idx < 0 {
 		if !options.SupportNegativeIndices || idx < -sz {
-			return nil, fmt.Errorf("unable to access invalid index %s, %v", key, ErrInvalidIndex)
+			return 0, fmt.Errorf("unable to access invalid index %s, %w", key, ErrInvalidIndex)
 		}
 		idx += sz
 	}
+	return idx, nil
+}
 
-	if idx >= sz {
-		return nil, fmt.Errorf("unable to access invalid index %s, %v", key, ErrInvalidIndex)
+func (d *partialArray) get(key string, options *Options) (*Node, error) {
+	idx, err := d.index(key, options)
+	if err != nil {
+		return nil, err
 	}
 	v := (*d)[idx]
 	if v == nil {
@@ -471,6 +1274,31 @@ func (d *partialArray) get(key string, options *Options) (*Node, error) {
 	return v, nil
 }
 
+// index returns the index of the existing element addressed by the key as get.
+func (d *partialArray) index(key string, options *Options) (int, error) {
+	if key == "-" {
+		// "-" refers to the nonexistent element after the last one.
+		return 0, fmt.Errorf("unable to access invalid index %s, %w", key, ErrInvalidIndex)
+	}
+	idx, err := strconv.Atoi(key)
+	if err != nil {
+		return 0, err
+	}
+
+	sz := len(*d)
+	if idx < 0 {
+		if !options.SupportNegativeIndices || idx < -sz {
+			return 0, fmt.Errorf("unable to access invalid index %s, %w", key, ErrInvalidIndex)
+		}
+		idx += sz
+	}
+
+	if idx >= sz {
+		return 0, fmt.Errorf("unable to access invalid index %s, %w", key, ErrInvalidIndex)
+	}
+	return idx, nil
+}
+
 func (d *partialArray) remove(key string, options *Options) error {
 	idx, err := strconv.Atoi(key)
 	if err != nil {
@@ -482,18 +1310,18 @@ func (d *partialArray) remove(key string, options *Options) error {
 		if options.AllowMissingPathOnRemove {
 			return nil
 		}
-		return fmt.Errorf("unable to access invalid index %s, %v", key, ErrInvalidIndex)
+		return fmt.Errorf("unable to access invalid index %s, %w", key, ErrInvalidIndex)
 	}
 
 	if idx < 0 {
 		if !options.SupportNegativeIndices {
-			return fmt.Errorf("unable to access invalid index %s, %v", key, ErrInvalidIndex)
+			return fmt.Errorf("unable to access invalid index %s, %w", key, ErrInvalidIndex)
 		}
 		if idx < -sz {
 			if options.AllowMissingPathOnRemove {
 				return nil
 			}
-			return fmt.Errorf("unable to access invalid index %s, %v", key, ErrInvalidIndex)
+			return fmt.Errorf("unable to access invalid index %s, %w", key, ErrInvalidIndex)
 		}
 		idx += sz
 	}
@@ -502,12 +1330,17 @@ func (d *partialArray) remove(key string, options *Options) error {
 	ary := make([]*Node, sz-1)
 	copy(ary[0:idx], cur[0:idx])
 	copy(ary[idx:], cur[idx+1:])
+	options.stats().shifted(len(cur) - idx - 1)
 
 	*d = ary
 	return nil
 }
 
 func (n *Node) intoContainer() (container, error) {
+	if n == nil {
+		return nil, ErrInvalid
+	}
+
 	switch n.which {
 	case eDoc:
 		return n.doc, nil
@@ -524,23 +1357,125 @@ func (n *Node) intoContainer() (container, error) {
 
 	switch checkWhich(*n.raw) {
 	case eDoc:
-		if err := json.Unmarshal(*n.raw, &n.doc); err != nil {
+		if err := n.checkValid(); err != nil {
 			return nil, err
 		}
+		n.doc = parseObject(*n.raw)
 		n.which = eDoc
+		if n.reformat {
+			for _, v := range n.doc.obj {
+				v.setReformat()
+			}
+		}
 		return n.doc, nil
 	case eAry:
-		if err := json.Unmarshal(*n.raw, &n.ary); err != nil {
+		if err := n.checkValid(); err != nil {
 			return nil, err
 		}
+		n.ary = parseArray(*n.raw)
 		n.which = eAry
+		if n.reformat {
+			for _, v := range n.ary {
+				v.setReformat()
+			}
+		}
 		return &n.ary, nil
 	}
 	return nil, ErrInvalid
 }
 
+// checkDuplicateKeys returns an error if an object in the node, at any depth, has duplicate keys.
+func (n *Node) checkDuplicateKeys(path string) error {
+	if n == nil {
+		return nil
+	}
+
+	switch n.which {
+	case eDoc:
+		if n.doc.dup != "" {
+			return duplicateKeyError(path, n.doc.dup)
+		}
+		for _, k := range n.doc.keys {
+			if err := n.doc.obj[k].checkDuplicateKeys(path + "/" + encodePatchKey(k)); err != nil {
+				return err
+			}
+		}
+	case eAry:
+		for i, v := range n.ary {
+			if err := v.checkDuplicateKeys(path + "/" + strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+	case eRaw:
+		if n.raw != nil && checkWhich(*n.raw) != eOther {
+			de := json.NewDecoder(bytes.NewReader(*n.raw))
+			return scanDuplicateKeys(de, path)
+		}
+	}
+	return nil
+}
+
+func scanDuplicateKeys(de *json.Decoder, path string) error {
+	t, err := de.Token()
+	if err != nil {
+		return err
+	}
+
+	switch t {
+	case startObject:
+		seen := make(map[string]struct{})
+		for de.More() {
+			k, err := de.Token()
+			if err != nil {
+				return err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return fmt.Errorf("unexpected JSON token %v as document node key", k)
+			}
+			if _, ok := seen[key]; ok {
+				return duplicateKeyError(path, key)
+			}
+			seen[key] = struct{}{}
+			if err = scanDuplicateKeys(de, path+"/"+encodePatchKey(key)); err != nil {
+				return err
+			}
+		}
+	case startArray:
+		for i := 0; de.More(); i++ {
+			if err = scanDuplicateKeys(de, path+"/"+strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+	// the end of the object or the array.
+	_, err = de.Token()
+	return err
+}
+
+func duplicateKeyError(path, key string) error {
+	return fmt.Errorf("duplicate key %q in object %q, %w", key, path, ErrDuplicateKey)
+}
+
+// rawJSON returns the raw encoded JSON of the node, it falls back to MarshalJSON
+// for the nodes created without raw data.
+func (n *Node) rawJSON() (json.RawMessage, error) {
+	if n.raw != nil {
+		return *n.raw, nil
+	}
+	return n.MarshalJSON()
+}
+
 func (n *Node) isNull() bool {
-	if n == nil || n.raw == nil {
+	if n == nil {
+		return true
+	}
+	if n.which == eDoc || n.which == eAry {
+		return false
+	}
+	if n.raw == nil {
 		return true
 	}
 	return isNull(*n.raw)
@@ -598,94 +1533,152 @@ func (n *Node) Equal(o *Node) bool {
 }
 
 func (p Patch) add(doc *container, op Operation, options *Options) error {
+	if options.ConvertNullIntermediates {
+		if err := convertNullIntermediates(doc, op.Path, options); err != nil {
+			return err
+		}
+	}
+
+	// adding to the root replaces the whole document, as RFC 6902 section 4.1.
+	if op.Path == "" {
+		return replaceRoot(doc, op, options)
+	}
+
 	if options.EnsurePathExistsOnAdd {
 		if err := ensurePathExists(doc, op.Path, options); err != nil {
 			return err
 		}
 	}
 
-	con, key := findObject(doc, op.Path, options)
+	con, key := op.findPath(doc, options)
 	if con == nil {
-		return fmt.Errorf("add operation does not apply for %q, %v", op.Path, ErrMissing)
+		return fmt.Errorf("add operation does not apply for %q, %w", op.Path, ErrMissing)
+	}
+
+	if d, ok := con.(*partialDoc); ok && options.NullIsAbsent && isNull(op.Value) {
+		if _, ok := d.obj[d.matchKey(key, options)]; !ok {
+			return nil
+		}
+		return d.remove(key, options)
 	}
 
-	if err := con.add(key, NewNode(op.Value), options); err != nil {
-		return fmt.Errorf("add operation does not apply for %q, %v", op.Path, err)
+	if err := con.add(key, op.valueNode(), options); err != nil {
+		return fmt.Errorf("add operation does not apply for %q, %w", op.Path, err)
 	}
 
 	return nil
 }
 
 func (p Patch) remove(doc *container, op Operation, options *Options) error {
-	con, key := findObject(doc, op.Path, options)
+	con, key := op.findPath(doc, options)
 	if con == nil {
 		if options.AllowMissingPathOnRemove {
+			options.warnf("skipped removing missing path %q", op.Path)
 			return nil
 		}
-		return fmt.Errorf("remove operation does not apply for %q, %v", op.Path, ErrMissing)
+		return fmt.Errorf("remove operation does not apply for %q, %w", op.Path, ErrMissing)
 	}
 
+	missing := false
+	if options.AllowMissingPathOnRemove && options.OnWarning != nil {
+		_, err := con.get(key, options)
+		missing = err != nil
+	}
 	if err := con.remove(key, options); err != nil {
-		return fmt.Errorf("remove operation does not apply for %q, %v", op.Path, err)
+		return fmt.Errorf("remove operation does not apply for %q, %w", op.Path, err)
+	}
+	if missing {
+		options.warnf("skipped removing missing path %q", op.Path)
 	}
 	return nil
 }
 
 func (p Patch) replace(doc *container, op Operation, options *Options) error {
 	if op.Path == "" {
-		val := NewNode(op.Value)
-		val.intoContainer()
+		return replaceRoot(doc, op, options)
+	}
 
-		switch val.which {
-		case eAry:
-			*doc = &val.ary
-		case eDoc:
-			*doc = val.doc
-		case eOther:
-			return errors.New("replace operation hit impossible case")
-		}
+	con, key := op.findPath(doc, options)
+	if con == nil {
+		return fmt.Errorf("replace operation does not apply for %q, %w", op.Path, ErrMissing)
+	}
 
-		return nil
+	if _, err := con.get(key, options); err != nil {
+		return fmt.Errorf("replace operation does not apply for %q, %w", op.Path, err)
 	}
 
-	con, key := findObject(doc, op.Path, options)
-	if con == nil {
-		return fmt.Errorf("replace operation does not apply for %q, %v", op.Path, ErrMissing)
+	if err := con.set(key, op.valueNode(), options); err != nil {
+		return fmt.Errorf("replace operation does not apply for %q, %w", op.Path, err)
 	}
+	return nil
+}
 
-	_, ok := con.get(key, options)
-	if ok != nil {
-		return fmt.Errorf("replace operation does not apply for %q, %v", op.Path, ErrMissing)
+// replaceRoot replaces the whole document with the value of the operation.
+func replaceRoot(doc *container, op Operation, options *Options) error {
+	val := op.valueNode()
+	val.intoContainer()
+	if pd, ok := (*doc).(*partialDoc); ok && options.PreserveKeyOrder {
+		keepKeyOrder(&Node{doc: pd, which: eDoc}, val)
 	}
 
-	if err := con.set(key, NewNode(op.Value), options); err != nil {
-		return fmt.Errorf("replace operation does not apply for %q, %v", op.Path, err)
+	switch val.which {
+	case eAry:
+		*doc = &val.ary
+	case eDoc:
+		*doc = val.doc
+	case eOther:
+		return fmt.Errorf("%s operation hit impossible case", op.Op)
 	}
+
 	return nil
 }
 
 func (p Patch) move(doc *container, op Operation, options *Options) error {
-	con, key := findObject(doc, op.From, options)
+	if err := checkMoveCycle(*doc, op, options); err != nil {
+		return err
+	}
+
+	con, key := op.findFrom(doc, options)
 	if con == nil {
-		return fmt.Errorf("move operation does not apply for from %q, %v", op.From, ErrMissing)
+		return fmt.Errorf("move operation does not apply for from %q, %w", op.From, ErrMissing)
 	}
 
 	val, err := con.get(key, options)
 	if err != nil {
-		return fmt.Errorf("move operation does not apply for from %q, %v", op.From, err)
+		return fmt.Errorf("move operation does not apply for from %q, %w", op.From, err)
 	}
 
 	if err = con.remove(key, options); err != nil {
-		return fmt.Errorf("move operation does not apply for from %q, %v", op.From, err)
+		return fmt.Errorf("move operation does not apply for from %q, %w", op.From, err)
+	}
+
+	if options.ConvertNullIntermediates {
+		if err := convertNullIntermediates(doc, op.Path, options); err != nil {
+			return err
+		}
 	}
 
-	con, key = findObject(doc, op.Path, options)
+	con, key = op.findPath(doc, options)
 	if con == nil {
-		return fmt.Errorf("move operation does not apply for path %q, %v", op.Path, ErrMissing)
+		return fmt.Errorf("move operation does not apply for path %q, %w", op.Path, ErrMissing)
 	}
 
 	if err = con.add(key, val, options); err != nil {
-		return fmt.Errorf("move operation does not apply for path %q, %v", op.Path, err)
+		return fmt.Errorf("move operation does not apply for path %q, %w", op.Path, err)
+	}
+	return nil
+}
+
+// checkMoveCycle returns an error if "from" of the move operation is a proper prefix of "path",
+// a value can not be moved into its own child.
+func checkMoveCycle(pd container, op Operation, options *Options) error {
+	from, path := op.From, op.Path
+	if strings.Contains(from, "/-") || strings.Contains(path, "/-") {
+		from, path = resolvePath(pd, from, false, options), resolvePath(pd, path, false, options)
+	}
+	if from != path && hasPathPrefix(path, from) {
+		return fmt.Errorf("move operation does not apply for from %q, a proper prefix of path %q, %w",
+			op.From, op.Path, ErrCyclic)
 	}
 	return nil
 }
@@ -703,74 +1696,128 @@ func (p Patch) test(doc *container, op Operation, options *Options) error {
 			self.which = eAry
 		}
 
-		if self.Equal(NewNode(op.Value)) {
+		if options.matches(&self, op.testValue()) {
 			return nil
 		}
 
-		return fmt.Errorf("test operation for path %q failed, not equal", op.Path)
+		return testFailedf("test operation for path %q failed, not equal", op.Path)
 	}
 
-	con, key := findObject(doc, op.Path, options)
+	con, key := op.findPath(doc, options)
 	if con == nil {
-		return fmt.Errorf("test operation for path %q failed, %v", op.Path, ErrMissing)
+		if options.NullIsAbsent && isNull(op.Value) {
+			return nil
+		}
+		return testFailedf("test operation for path %q failed, %v", op.Path, ErrMissing)
 	}
 
 	val, err := con.get(key, options)
-	if err != nil && !strings.Contains(err.Error(), ErrMissing.Error()) {
-		return fmt.Errorf("test operation for path %q failed, %v", op.Path, err)
+	if err != nil && !errors.Is(err, ErrMissing) {
+		return fmt.Errorf("test operation for path %q failed, %w", op.Path, err)
 	}
 
 	if val == nil || val.isNull() {
 		if isNull(op.Value) {
 			return nil
 		}
-		return fmt.Errorf("test operation for path %q failed, expected %q, got nil",
+		return testFailedf("test operation for path %q failed, expected %q, got nil",
 			op.Path, NewNode(op.Value).String())
 
 	} else if op.Value == nil {
-		return fmt.Errorf("test operation for path %q failed, expected nil, got %q",
+		return testFailedf("test operation for path %q failed, expected nil, got %q",
 			op.Path, val.String())
 	}
 
-	if val.Equal(NewNode(op.Value)) {
+	if options.matches(val, op.testValue()) {
 		return nil
 	}
 
-	return fmt.Errorf("test operation for path %q failed, expected %q, got %q",
+	return testFailedf("test operation for path %q failed, expected %q, got %q",
 		op.Path, NewNode(op.Value).String(), val.String())
 }
 
+// matches indicates if the value in the document matches the value of a "test" operation.
+func (o *Options) matches(val, want *Node) bool {
+	if o.TestSubset {
+		return val.superset(want)
+	}
+	return val.Equal(want)
+}
+
+// superset indicates if the node matches the node o partially, see Options.TestSubset.
+func (n *Node) superset(o *Node) bool {
+	if n.isNull() || o.isNull() {
+		return n.isNull() && o.isNull()
+	}
+
+	n.intoContainer()
+	o.intoContainer()
+	switch {
+	case n.which == eDoc && o.which == eDoc:
+		for key, ov := range o.doc.obj {
+			nv, ok := n.doc.obj[key]
+			if !ok || !nv.superset(ov) {
+				return false
+			}
+		}
+		return true
+	case n.which == eAry && o.which == eAry:
+		if len(n.ary) != len(o.ary) {
+			return false
+		}
+		for i, ov := range o.ary {
+			if !n.ary[i].superset(ov) {
+				return false
+			}
+		}
+		return true
+	}
+	return n.Equal(o)
+}
+
 func (p Patch) copy(doc *container, op Operation, accumulatedCopySize *int64, options *Options) error {
-	con, key := findObject(doc, op.From, options)
+	con, key := op.findFrom(doc, options)
 
 	if con == nil {
-		return fmt.Errorf("copy operation does not apply for from path %q, %v", op.From, ErrMissing)
+		return fmt.Errorf("copy operation does not apply for from path %q, %w", op.From, ErrMissing)
 	}
 
 	val, err := con.get(key, options)
 	if err != nil {
-		return fmt.Errorf("copy operation does not apply for from path %q, %v", op.From, err)
+		return fmt.Errorf("copy operation does not apply for from path %q, %w", op.From, err)
+	}
+
+	if options.ConvertNullIntermediates {
+		if err := convertNullIntermediates(doc, op.Path, options); err != nil {
+			return err
+		}
 	}
 
-	con, key = findObject(doc, op.Path, options)
+	con, key = op.findPath(doc, options)
 	if con == nil {
-		return fmt.Errorf("copy operation does not apply for path %q, %v", op.Path, ErrMissing)
+		return fmt.Errorf("copy operation does not apply for path %q, %w", op.Path, ErrMissing)
 	}
 
 	valCopy, sz, err := deepCopy(val)
 	if err != nil {
-		return fmt.Errorf("copy operation does not apply for path %q while performing deep copy, %v",
+		return fmt.Errorf("copy operation does not apply for path %q while performing deep copy, %w",
 			op.Path, err)
 	}
 
+	if options.MaxCopyBytes > 0 && int64(sz) > options.MaxCopyBytes {
+		return fmt.Errorf("copy operation from path %q of %d bytes exceeds the limit %d, %w",
+			op.From, sz, options.MaxCopyBytes, ErrLimitExceeded)
+	}
 	(*accumulatedCopySize) += int64(sz)
 	if options.AccumulatedCopySizeLimit > 0 && *accumulatedCopySize > options.AccumulatedCopySizeLimit {
-		return NewAccumulatedCopySizeError(options.AccumulatedCopySizeLimit, *accumulatedCopySize)
+		e := NewAccumulatedCopySizeError(options.AccumulatedCopySizeLimit, *accumulatedCopySize)
+		e.From, e.Path = op.From, op.Path
+		return e
 	}
 
 	err = con.add(key, valCopy, options)
 	if err != nil {
-		return fmt.Errorf("copy operation does not apply for path %q while adding value during copy, %v",
+		return fmt.Errorf("copy operation does not apply for path %q while adding value during copy, %w",
 			op.Path, err)
 	}
 
@@ -778,18 +1825,20 @@ func (p Patch) copy(doc *container, op Operation, accumulatedCopySize *int64, op
 }
 
 func findObject(pd *container, path string, options *Options) (container, string) {
-	doc := *pd
+	return findObjectByKeys(pd, splitKeys(path), options)
+}
 
-	split := strings.Split(path, "/")
-	if len(split) < 2 {
+// findObjectByKeys returns the container of the last key of the path split into the unescaped keys.
+func findObjectByKeys(pd *container, keys []string, options *Options) (container, string) {
+	if len(keys) == 0 {
 		return nil, ""
 	}
 
-	parts := split[1 : len(split)-1]
-	key := split[len(split)-1]
-
-	for _, part := range parts {
-		next, ok := doc.get(decodePatchKey(part), options)
+	doc := *pd
+	stats := options.stats()
+	stats.visited(1)
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := doc.get(key, options)
 		if next == nil || ok != nil {
 			return nil, ""
 		}
@@ -797,8 +1846,9 @@ func findObject(pd *container, path string, options *Options) (container, string
 		if doc == nil {
 			return nil, ""
 		}
+		stats.visited(1)
 	}
-	return doc, decodePatchKey(key)
+	return doc, keys[len(keys)-1]
 }
 
 // Given a document and a path to a key, walk the path and create all missing elements
@@ -841,18 +1891,19 @@ func ensurePathExists(pd *container, path string, options *Options) error {
 			if arrIndex, err = strconv.Atoi(parts[pi+1]); err == nil || parts[pi+1] == "-" {
 				if arrIndex < 0 {
 					if !options.SupportNegativeIndices {
-						return fmt.Errorf("unable to ensure path for invalid index %d, %v",
+						return fmt.Errorf("unable to ensure path for invalid index %d, %w",
 							arrIndex, ErrInvalidIndex)
 					}
 
 					if arrIndex < -1 {
-						return fmt.Errorf("unable to ensure path for invalid index %d: %v",
+						return fmt.Errorf("unable to ensure path for invalid index %d: %w",
 							arrIndex, ErrInvalidIndex)
 					}
 
 					arrIndex = 0
 				}
 
+				options.warnf("created missing array %q", "/"+strings.Join(parts[:pi+1], "/"))
 				node := NewNode(rawJSONArray)
 				doc.add(part, node, options)
 				doc, _ = node.intoContainer()
@@ -862,6 +1913,7 @@ func ensurePathExists(pd *container, path string, options *Options) error {
 					doc.add(strconv.Itoa(i), NewNode(nil), options)
 				}
 			} else {
+				options.warnf("created missing object %q", "/"+strings.Join(parts[:pi+1], "/"))
 				node := NewNode(rawJSONObject)
 				doc.add(part, node, options)
 				doc, _ = node.intoContainer()
@@ -869,7 +1921,7 @@ func ensurePathExists(pd *container, path string, options *Options) error {
 		} else {
 			doc, err = target.intoContainer()
 			if doc == nil {
-				return fmt.Errorf("unable to ensure path for invalid target %q, %v", target.String(), err)
+				return fmt.Errorf("unable to ensure path for invalid target %q, %w", target.String(), err)
 			}
 		}
 	}
@@ -877,6 +1929,44 @@ func ensurePathExists(pd *container, path string, options *Options) error {
 	return nil
 }
 
+// Given a document and a path to a key, walk the path and convert all null elements
+// into objects and arrays as needed. Missing elements are left to the caller.
+func convertNullIntermediates(pd *container, path string, options *Options) error {
+	doc := *pd
+	split := strings.Split(path, "/")
+	if len(split) < 3 {
+		return nil
+	}
+
+	parts := split[1:]
+	for pi, part := range parts[:len(parts)-1] {
+		key := decodePatchKey(part)
+		target, err := doc.get(key, options)
+		if err != nil {
+			return nil
+		}
+
+		if target.isNull() {
+			// Check if the next part is a numeric index or "-".
+			// If yes, then create an array, otherwise, create an object.
+			raw, typ := rawJSONObject, "object"
+			if _, err = strconv.Atoi(parts[pi+1]); err == nil || parts[pi+1] == "-" {
+				raw, typ = rawJSONArray, "array"
+			}
+			options.warnf("converted null %q into %s", "/"+strings.Join(parts[:pi+1], "/"), typ)
+			target = NewNode(raw)
+			if err = doc.set(key, target, options); err != nil {
+				return fmt.Errorf("unable to convert null for %q, %w", part, err)
+			}
+		}
+
+		if doc, _ = target.intoContainer(); doc == nil {
+			return nil
+		}
+	}
+	return nil
+}
+
 func deepCopy(src *Node) (*Node, int, error) {
 	if src == nil {
 		return nil, 0, nil
@@ -886,7 +1976,7 @@ func deepCopy(src *Node) (*Node, int, error) {
 		return nil, 0, err
 	}
 	sz := len(a)
-	return NewNode(a), sz, nil
+	return &Node{raw: (*json.RawMessage)(&a), valid: true}, sz, nil
 }
 
 func skipValue(de *json.Decoder) error {
@@ -966,10 +2056,111 @@ func encodePatchKey(k string) string {
 	return rfc6901Encoder.Replace(k)
 }
 
+// PathError is an error type returned when an operation of a patch fails, or when a path
+// can not be resolved by GetChild. It wraps the cause, so that errors.Is can match the
+// sentinel errors such as ErrMissing, ErrInvalidIndex and ErrTestFailed.
+type PathError struct {
+	// Op is the name of the operation, it is "get" for GetChild.
+	Op string
+	// Path is the path of the operation.
+	Path string
+	// Index is the index of the operation in the patch, it is -1 for GetChild.
+	Index int
+	// Err is the cause of the error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *PathError) Error() string {
+	if e.Index < 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("operation %d, %v", e.Index, e.Err)
+}
+
+// Unwrap returns the cause of the error.
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError is an error type returned when operations of a patch fail and Options.ContinueOnError is true.
+// The errors are *PathError or *PanicError, in the order of the operations.
+type MultiError []error
+
+// Error implements the error interface.
+func (e MultiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d operations failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Is reports whether any of the errors matches the target, so that errors.Is can match any of them.
+func (e MultiError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches the target, and if so, sets the target to it
+// and returns true, so that errors.As can match any of them.
+func (e MultiError) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapError is an error with its own message that wraps a sentinel error.
+type wrapError struct {
+	msg string
+	err error
+}
+
+func (e *wrapError) Error() string {
+	return e.msg
+}
+
+func (e *wrapError) Unwrap() error {
+	return e.err
+}
+
+// testFailedf formats an error that wraps ErrTestFailed.
+func testFailedf(format string, a ...interface{}) error {
+	return &wrapError{fmt.Sprintf(format, a...), ErrTestFailed}
+}
+
+// PanicError is an error type returned when an operation panics and Options.RecoverPanics is true.
+type PanicError struct {
+	// Index is the index of the operation in the patch.
+	Index int
+	// Op and Path are the name and the path of the operation.
+	Op   string
+	Path string
+	// Value is the value recovered from the panic.
+	Value interface{}
+}
+
+// Error implements the error interface.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("operation %d %q for path %q panicked, %v", e.Index, e.Op, e.Path, e.Value)
+}
+
 // AccumulatedCopySizeError is an error type returned when the accumulated size
 // increase caused by copy operations in a patch operation has exceeded the
-// limit.
+// limit. It wraps ErrLimitExceeded, and it is wrapped in a PathError with the
+// index of the copy operation that exceeded the limit.
 type AccumulatedCopySizeError struct {
+	// From and Path are the paths of the copy operation that exceeded the limit.
+	From string
+	Path string
+
 	limit       int64
 	accumulated int64
 }
@@ -981,7 +2172,17 @@ func NewAccumulatedCopySizeError(l, a int64) *AccumulatedCopySizeError {
 
 // Error implements the error interface.
 func (a *AccumulatedCopySizeError) Error() string {
+	if a.From != "" || a.Path != "" {
+		return fmt.Sprintf(
+			"unable to copy from path %q to %q, the accumulated size increase of copy is %d, exceeding the limit %d",
+			a.From, a.Path, a.accumulated, a.limit)
+	}
 	return fmt.Sprintf(
 		"unable to copy, the accumulated size increase of copy is %d, exceeding the limit %d",
 		a.accumulated, a.limit)
 }
+
+// Unwrap returns ErrLimitExceeded.
+func (a *AccumulatedCopySizeError) Unwrap() error {
+	return ErrLimitExceeded
+}
@@ -40,11 +40,13 @@ package jsonpatch
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -1071,3 +1073,1006 @@ func TestNullNode(t *testing.T) {
 	assert.False(n.Equal(NewNode([]byte(`{}`))))
 	assert.Equal(`{"key":null}`, mustJSONString(n))
 }
+
+func TestNodeClone(t *testing.T) {
+	assert := assert.New(t)
+
+	var n *Node
+	assert.Nil(n.Clone())
+
+	doc := `{"name":"John","tags":["a","b"],"meta":{"age":24,"x":null}}`
+	n = NewNode([]byte(doc))
+	c := n.Clone()
+	assert.True(n.Equal(c))
+
+	// partially parsed children
+	v, err := n.GetValue("/meta/age", nil)
+	assert.Nil(err)
+	assert.Equal(`24`, string(v))
+	c = n.Clone()
+	assert.True(n.Equal(c))
+
+	p, err := NewPatch([]byte(`[
+		{"op": "replace", "path": "/meta/age", "value": 25},
+		{"op": "add", "path": "/tags/-", "value": "c"},
+		{"op": "remove", "path": "/name"}
+	]`))
+	assert.Nil(err)
+	assert.Nil(c.Patch(p, nil))
+	assert.Equal(`{"tags":["a","b","c"],"meta":{"age":25,"x":null}}`, mustJSONString(c))
+	assert.Equal(`{"name":"John","tags":["a","b"],"meta":{"age":24,"x":null}}`, mustJSONString(n))
+
+	// patch the original, the clone should not change
+	assert.Nil(n.Patch(Patch{{Op: "add", Path: "/meta/x", Value: []byte(`1`)}}, nil))
+	assert.Equal(`{"name":"John","tags":["a","b"],"meta":{"age":24,"x":1}}`, mustJSONString(n))
+	assert.Equal(`{"tags":["a","b","c"],"meta":{"age":25,"x":null}}`, mustJSONString(c))
+}
+
+func TestConvertNullIntermediates(t *testing.T) {
+	cases := []struct {
+		doc, patch, result string
+		ensurePathExists   bool
+	}{
+		{
+			`{"a":null}`,
+			`[{"op": "add", "path": "/a/b", "value": 1}]`,
+			`{"a":{"b":1}}`,
+			false,
+		},
+		{
+			`{"a":null}`,
+			`[{"op": "add", "path": "/a/-", "value": 1}]`,
+			`{"a":[1]}`,
+			false,
+		},
+		{
+			`{"a":[null,{"b":null}]}`,
+			`[{"op": "add", "path": "/a/0/0", "value": 1}, {"op": "add", "path": "/a/1/b/c", "value": 2}]`,
+			`{"a":[[1],{"b":{"c":2}}]}`,
+			false,
+		},
+		{
+			`{"a":null,"x":1}`,
+			`[{"op": "move", "from": "/x", "path": "/a/b"}]`,
+			`{"a":{"b":1}}`,
+			false,
+		},
+		{
+			`{"a":{"b":null}}`,
+			`[{"op": "add", "path": "/a/b/1/c", "value": 1}]`,
+			`{"a":{"b":[null,{"c":1}]}}`,
+			true,
+		},
+		{
+			`{"a":{"b":null},"x":1}`,
+			`[{"op": "copy", "from": "/x", "path": "/a/b/c"}]`,
+			`{"a":{"b":{"c":1}},"x":1}`,
+			false,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			options := NewOptions()
+			_, err := applyPatchWithOptions(c.doc, c.patch, options)
+			assert.NotNil(t, err)
+
+			options.ConvertNullIntermediates = true
+			options.EnsurePathExistsOnAdd = c.ensurePathExists
+			res, err := applyPatchWithOptions(c.doc, c.patch, options)
+			assert.Nil(t, err)
+			assert.Equal(t, c.result, res)
+		})
+	}
+}
+
+func TestReplaceRootWithOtherContainer(t *testing.T) {
+	assert := assert.New(t)
+
+	n := NewNode([]byte(`{"a":1}`))
+	assert.Nil(n.Patch(Patch{{Op: "replace", Path: "", Value: []byte(`[1]`)}}, nil))
+	assert.Equal(`[1]`, mustJSONString(n))
+	assert.Nil(n.Patch(Patch{{Op: "add", Path: "/-", Value: []byte(`2`)}}, nil))
+	assert.Equal(`[1,2]`, mustJSONString(n))
+	assert.Nil(n.Patch(Patch{{Op: "replace", Path: "", Value: []byte(`{"b":2}`)}}, nil))
+	assert.Equal(`{"b":2}`, mustJSONString(n))
+}
+
+func TestOptionsNow(t *testing.T) {
+	assert := assert.New(t)
+
+	var options *Options
+	assert.WithinDuration(time.Now(), options.Now(), time.Second)
+	options = NewOptions()
+	assert.WithinDuration(time.Now(), options.Now(), time.Second)
+
+	options.Clock = &testClock{t: time.Unix(1000, 0)}
+	assert.Equal(time.Unix(1001, 0), options.Now())
+}
+
+func TestTestSubset(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"status":{"phase":"Running","ready":true,"pods":[{"name":"a","ip":"10.0.0.1"},{"name":"b"}],"note":null},"n":1}`)
+	options := NewOptions()
+	options.TestSubset = true
+
+	for i, c := range []struct {
+		path, value string
+		ok          bool
+	}{
+		{"/status", `{"phase": "Running"}`, true},
+		{"/status", `{}`, true},
+		{"/status", `{"phase": "Pending"}`, false},
+		{"/status", `{"phase": "Running", "extra": 1}`, false},
+		{"/status", `{"pods": [{"name": "a"}, {}]}`, true},
+		{"/status", `{"pods": [{"name": "a"}]}`, false},
+		{"/status", `{"pods": [{"name": "b"}, {"name": "a"}]}`, false},
+		{"/status", `{"note": null}`, true},
+		{"/status", `{"ready": null}`, false},
+		{"/status/phase", `"Running"`, true},
+		{"/n", `1`, true},
+		{"/n", `{}`, false},
+		{"", `{"status": {"ready": true}}`, true},
+		{"", `{"status": {"ready": false}}`, false},
+	} {
+		p := Patch{{Op: "test", Path: c.path, Value: json.RawMessage(c.value)}}
+		_, err := p.ApplyWithOptions(doc, options)
+		if c.ok {
+			assert.Nil(err, "case %d", i)
+		} else {
+			assert.ErrorIs(err, ErrTestFailed, "case %d", i)
+		}
+	}
+
+	_, err := Patch{{Op: "test", Path: "/status", Value: json.RawMessage(`{"phase": "Running"}`)}}.Apply(doc)
+	assert.ErrorIs(err, ErrTestFailed)
+}
+
+func TestMoveCycle(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"a":{"b":{"c":1}},"l":[{"x":1},{"x":2}]}`)
+	for i, c := range []struct {
+		from, path string
+	}{
+		{"/a", "/a/b"},
+		{"/a", "/a/b/c"},
+		{"", "/a"},
+		{"/l/1", "/l/1/y"},
+		{"/l/-1", "/l/1/y"},
+		{"/l/1", "/l/-1/y"},
+	} {
+		_, err := Patch{{Op: "move", From: c.from, Path: c.path}}.Apply(doc)
+		assert.ErrorIs(err, ErrCyclic, "case %d", i)
+	}
+
+	for i, c := range []struct {
+		from, path, res string
+	}{
+		{"/a", "/a", `{"l":[{"x":1},{"x":2}],"a":{"b":{"c":1}}}`},
+		{"/a/b", "/a", `{"a":{"c":1},"l":[{"x":1},{"x":2}]}`},
+		{"/a", "/ab", `{"l":[{"x":1},{"x":2}],"ab":{"b":{"c":1}}}`},
+		{"/l/1", "/l/0/y", `{"a":{"b":{"c":1}},"l":[{"x":1,"y":{"x":2}}]}`},
+	} {
+		res, err := Patch{{Op: "move", From: c.from, Path: c.path}}.Apply(doc)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.res, string(res), "case %d", i)
+	}
+
+	_, err := DecodePatchStrict([]byte(`[{"op": "move", "from": "/a", "path": "/a/b"}]`), nil)
+	assert.ErrorIs(err, ErrCyclic)
+}
+
+func TestCopyNoAliasing(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(`{"a":{"b":{"c":[1]}}}`))
+	assert.Nil(node.Patch(Patch{{Op: "copy", From: "/a", Path: "/a/b/d"}}, nil))
+	assert.Nil(node.Patch(Patch{
+		{Op: "add", Path: "/a/b/d/b/c/-", Value: json.RawMessage(`2`)},
+		{Op: "copy", From: "/a/b/c", Path: "/e"},
+		{Op: "add", Path: "/e/-", Value: json.RawMessage(`3`)},
+	}, nil))
+
+	assert.Equal(`{"a":{"b":{"c":[1],"d":{"b":{"c":[1,2]}}}},"e":[1,3]}`, mustJSONString(node))
+}
+
+func TestValueValidator(t *testing.T) {
+	assert := assert.New(t)
+
+	var calls []string
+	options := NewOptions()
+	options.ValueValidator = func(path string, value json.RawMessage) error {
+		calls = append(calls, path+"="+string(value))
+		if path == "/age" {
+			var age int
+			if err := json.Unmarshal(value, &age); err != nil || age < 0 {
+				return fmt.Errorf("age must be a non-negative integer")
+			}
+		}
+		return nil
+	}
+
+	res, err := applyPatchWithOptions(`{"name":"a","age":1,"tags":[]}`, `[
+		{"op": "replace", "path": "/age", "value": 2},
+		{"op": "add", "path": "/tags/-", "value": "x"},
+		{"op": "remove", "path": "/name"},
+		{"op": "copy", "from": "/age", "path": "/size"},
+		{"op": "add", "path": "/name"}
+	]`, options)
+	assert.NoError(err)
+	assert.Equal(`{"age":2,"tags":["x"],"size":2,"name":null}`, res)
+	assert.Equal([]string{"/age=2", `/tags/-="x"`, "/name=null"}, calls)
+
+	doc := []byte(`{"age":1}`)
+	node := NewNode(doc)
+	err = node.Patch(Patch{
+		{Op: "add", Path: "/name", Value: []byte(`"b"`)},
+		{Op: "replace", Path: "/age", Value: []byte(`-1`)},
+	}, options)
+	assert.ErrorContains(err, "operation 1, replace operation has invalid value, age must be a non-negative integer")
+
+	_, err = Patch{NewReplaceOperation("/age", []byte(`"1"`))}.ApplyAtomic(doc, options)
+	assert.Error(err)
+
+	// the values written by the extension operations are validated too.
+	calls = nil
+	options = NewOptions()
+	options.EnableIncrement = true
+	options.EnableStringOps = true
+	options.EnableSplice = true
+	options.ValueValidator = func(path string, value json.RawMessage) error {
+		calls = append(calls, path+"="+string(value))
+		if string(value) == `"bad"` || string(value) == `100` {
+			return ErrInvalid
+		}
+		return nil
+	}
+	doc = []byte(`{"s":"ba","n":99,"l":[1,2]}`)
+	for i, c := range []struct {
+		patch string
+		calls []string
+	}{
+		{`[{"op": "splice", "path": "/l/1", "values": [3, "bad"]}]`, []string{"/l/1=3", `/l/2="bad"`}},
+		{`[{"op": "str-append", "path": "/s", "value": "d"}]`, []string{`/s="bad"`}},
+		{`[{"op": "inc", "path": "/n", "value": 1}]`, []string{"/n=100"}},
+	} {
+		calls = nil
+		_, err := applyPatchWithOptions(string(doc), c.patch, options)
+		assert.ErrorIs(err, ErrInvalid, "case %d", i)
+		assert.Equal(c.calls, calls, "case %d", i)
+	}
+	res, err = applyPatchWithOptions(string(doc), `[
+		{"op": "splice", "path": "/l/-", "values": [3]},
+		{"op": "str-insert", "path": "/s", "pos": 0, "value": "a"},
+		{"op": "inc", "path": "/n", "value": -1}
+	]`, options)
+	assert.NoError(err)
+	assert.Equal(`{"s":"aba","n":98,"l":[1,2,3]}`, res)
+}
+
+func TestRegisterOperation(t *testing.T) {
+	assert := assert.New(t)
+
+	options := NewOptions()
+	options.RegisterOperation("inc", func(doc *Node, op Operation, options *Options) error {
+		val, err := doc.GetValue(op.Path, options)
+		if err != nil {
+			return err
+		}
+		var a, b int
+		if err = json.Unmarshal(val, &a); err != nil {
+			return err
+		}
+		if err = json.Unmarshal(op.Value, &b); err != nil {
+			return err
+		}
+		return doc.Patch(Patch{NewReplaceOperation(op.Path, []byte(strconv.Itoa(a+b)))}, options)
+	})
+	options.RegisterOperation("reset", func(doc *Node, op Operation, options *Options) error {
+		return doc.Patch(Patch{NewReplaceOperation("", op.Value)}, options)
+	})
+
+	res, err := applyPatchWithOptions(`{"count":1}`, `[
+		{"op": "inc", "path": "/count", "value": 2},
+		{"op": "add", "path": "/name", "value": "a"},
+		{"op": "inc", "path": "/count", "value": 3}
+	]`, options)
+	assert.Nil(err)
+	assert.Equal(`{"count":6,"name":"a"}`, res)
+
+	res, err = applyPatchWithOptions(`{"count":1}`, `[
+		{"op": "replace", "path": "", "value": [1]},
+		{"op": "inc", "path": "/0", "value": 2},
+		{"op": "reset", "path": "", "value": {"count":0}},
+		{"op": "inc", "path": "/count", "value": 1}
+	]`, options)
+	assert.Nil(err)
+	assert.Equal(`{"count":1}`, res)
+
+	_, err = applyPatchWithOptions(`{"count":1}`, `[{"op": "inc", "path": "/x", "value": 2}]`, options)
+	assert.NotNil(err)
+
+	c := Capabilities(options)
+	assert.Equal([]string{"inc", "reset"}, c.Extensions)
+
+	options.RegisterOperation("inc", nil)
+	_, err = applyPatchWithOptions(`{"count":1}`, `[{"op": "inc", "path": "/count", "value": 2}]`, options)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), `unexpected operation "inc"`)
+	_, err = applyPatch(`{"count":1}`, `[{"op": "reset", "path": "", "value": {}}]`)
+	assert.NotNil(err)
+}
+
+func TestPreserveNumberLexemes(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := `{"a":1.50e+10,"b":[1.0,-0.0,1E-7],"c":{"big":123456789012345678901234567890,"f":0.1000}}`
+	p := Patch{
+		NewAddOperation("/d", []byte(`1E+2`)),
+		NewReplaceOperation("/c/f", []byte(` 2.000 `)),
+		NewCopyOperation("/a", "/e"),
+		NewMoveOperation("/b", "/g"),
+	}
+	res, err := p.Apply([]byte(doc))
+	assert.Nil(err)
+	assert.Equal(`{"a":1.50e+10,"c":{"big":123456789012345678901234567890,"f":2.000},"d":1E+2,"e":1.50e+10,`+
+		`"g":[1.0,-0.0,1E-7]}`, string(res))
+
+	v, err := GetValueByPath([]byte(doc), "/c/big")
+	assert.Nil(err)
+	assert.Equal(`123456789012345678901234567890`, string(v))
+}
+
+func TestPatchValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"name":"John","tags":["a"]}`)
+	assert.Nil(Patch{
+		NewTestOperation("/name", []byte(`"John"`)),
+		NewAddOperation("/tags/1", []byte(`"b"`)),
+		NewRemoveOperation("/tags/1"),
+	}.Validate(doc, nil))
+
+	assert.NotNil(Patch{NewTestOperation("/name", []byte(`"Jane"`))}.Validate(doc, nil))
+	assert.NotNil(Patch{NewAddOperation("/tags/2", []byte(`"b"`))}.Validate(doc, nil))
+	assert.NotNil(Patch{NewRemoveOperation("/age")}.Validate(doc, nil))
+	assert.NotNil(Patch{NewRemoveOperation("/tags/0"), NewRemoveOperation("/tags/0")}.Validate(doc, nil))
+
+	options := NewOptions()
+	options.AllowMissingPathOnRemove = true
+	assert.Nil(Patch{NewRemoveOperation("/age")}.Validate(doc, options))
+	assert.Equal(`{"name":"John","tags":["a"]}`, string(doc))
+}
+
+func TestPanicFree(t *testing.T) {
+	assert := assert.New(t)
+
+	var n *Node
+	assert.NotNil(n.Patch(Patch{}, nil))
+	_, err := n.GetChild("/a", nil)
+	assert.NotNil(err)
+	_, err = n.GetValue("/a", nil)
+	assert.NotNil(err)
+	res, err := n.FindChildren(PVs{{"/a", nil}}, nil)
+	assert.Nil(err)
+	assert.Nil(res)
+	assert.Equal("<nil>", n.String())
+
+	arr := partialArray{}
+	assert.NotNil(arr.set("3", nil, NewOptions()))
+
+	n = NewNode([]byte(`{"a":1}`))
+	_, err = n.GetValue("/a", nil)
+	assert.Nil(err)
+	assert.Nil(n.UnmarshalJSON([]byte(`{"b":1}`)))
+	assert.Equal(`{"b":1}`, mustJSONString(n))
+
+	for i, c := range []struct{ doc, patch string }{
+		{`{"a":`, `[{"op": "add", "path": "/a", "value": 1}]`},
+		{`{}`, `[{"op": "add", "path": "/a", "value": {}}, {"op": "add", "path": "/a/b/c", "value": 1}]`},
+		{`{}`, `[{"op": "replace", "path": "", "value": 1}]`},
+		{`{"a":{}}`, `[{"op": "move", "from": "/a", "path": "/a/b"}]`},
+		{`{"a":1}`, `[{"op": "add", "path": "/a/b", "value": 1}]`},
+		{`[]`, `[{"op": "replace", "path": "/0", "value": 1}]`},
+		{`[]`, `[{"op": "unknown", "path": "/0"}]`},
+	} {
+		_, err := applyPatch(c.doc, c.patch)
+		assert.NotNil(err, "case %d", i)
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	assert := assert.New(t)
+
+	options := NewOptions()
+	options.RegisterOperation("boom", func(doc *Node, op Operation, options *Options) error {
+		panic("boom")
+	})
+
+	assert.Panics(func() {
+		_, _ = applyPatchWithOptions(`{}`, `[{"op": "boom", "path": "/a"}]`, options)
+	})
+
+	options.RecoverPanics = true
+	_, err := applyPatchWithOptions(`{}`, `[{"op": "add", "path": "/a", "value": 1}, {"op": "boom", "path": "/a"}]`, options)
+	var pe *PanicError
+	assert.ErrorAs(err, &pe)
+	assert.Equal(1, pe.Index)
+	assert.Equal("boom", pe.Op)
+	assert.Equal("/a", pe.Path)
+	assert.Equal("boom", pe.Value)
+	assert.Equal(`operation 1 "boom" for path "/a" panicked, boom`, err.Error())
+}
+
+func TestPathError(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"a":[1,2],"b":"x"}`)
+	cases := []struct {
+		patch    string
+		index    int
+		op, path string
+		err      error
+	}{
+		{`[{"op":"test","path":"/b","value":"x"},{"op":"remove","path":"/c"}]`, 1, "remove", "/c", ErrMissing},
+		{`[{"op":"add","path":"/a/5","value":1}]`, 0, "add", "/a/5", ErrInvalidIndex},
+		{`[{"op":"replace","path":"/a/-3","value":1}]`, 0, "replace", "/a/-3", ErrInvalidIndex},
+		{`[{"op":"test","path":"/b","value":"y"}]`, 0, "test", "/b", ErrTestFailed},
+		{`[{"op":"test","path":"/c","value":"y"}]`, 0, "test", "/c", ErrTestFailed},
+		{`[{"op":"test","path":"","value":{}}]`, 0, "test", "", ErrTestFailed},
+		{`[{"op":"move","from":"/c","path":"/d"}]`, 0, "move", "/d", ErrMissing},
+	}
+	for _, c := range cases {
+		p, err := NewPatch([]byte(c.patch))
+		assert.Nil(err)
+		_, err = p.Apply(doc)
+		assert.True(errors.Is(err, c.err), c.patch)
+
+		var pe *PathError
+		assert.True(errors.As(err, &pe), c.patch)
+		assert.Equal(c.index, pe.Index)
+		assert.Equal(c.op, pe.Op)
+		assert.Equal(c.path, pe.Path)
+	}
+
+	_, err := Patch{{Op: "remove", Path: "/c"}}.Apply(doc)
+	assert.Equal(`operation 0, remove operation does not apply for "/c", unable to remove nonexistent key "c", missing value`,
+		err.Error())
+
+	_, err = NewNode(doc).GetChild("/a/3", nil)
+	var pe *PathError
+	assert.True(errors.As(err, &pe))
+	assert.Equal(PathError{Op: "get", Path: "/a/3", Index: -1, Err: pe.Err}, *pe)
+	assert.True(errors.Is(err, ErrInvalidIndex))
+
+	_, err = NewNode(doc).GetChild("/c/d", nil)
+	assert.True(errors.Is(err, ErrMissing))
+	assert.Equal(`unable to get child node by path "/c/d", missing value`, err.Error())
+}
+
+func TestContinueOnError(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"a":[1,2],"b":"x"}`)
+	p := Patch{
+		{Op: "remove", Path: "/c"},
+		{Op: "replace", Path: "/b", Value: []byte(`"y"`)},
+		{Op: "test", Path: "/b", Value: []byte(`"x"`)},
+		{Op: "add", Path: "/a/-", Value: []byte(`3`)},
+		{Op: "add", Path: "/a/9", Value: []byte(`4`)},
+	}
+
+	_, err := p.Apply(doc)
+	var pe *PathError
+	assert.True(errors.As(err, &pe))
+	assert.Equal(0, pe.Index)
+
+	options := NewOptions()
+	options.ContinueOnError = true
+	_, err = p.ApplyWithOptions(doc, options)
+	var me MultiError
+	assert.True(errors.As(err, &me))
+	assert.Equal(3, len(me))
+	for i, index := range []int{0, 2, 4} {
+		assert.True(errors.As(me[i], &pe))
+		assert.Equal(index, pe.Index)
+	}
+	assert.True(errors.Is(err, ErrMissing))
+	assert.True(errors.Is(err, ErrTestFailed))
+	assert.True(errors.Is(err, ErrInvalidIndex))
+	assert.Contains(err.Error(), "3 operations failed: operation 0, ")
+	// the errors are matched by the methods, on all the supported Go versions.
+	assert.True(me.Is(ErrMissing))
+	assert.True(me.Is(ErrTestFailed))
+	assert.False(me.Is(ErrFrozen))
+	pe = nil
+	assert.True(me.As(&pe))
+	assert.Equal(0, pe.Index)
+	var pae *PanicError
+	assert.False(me.As(&pae))
+
+	node := NewNode(doc)
+	assert.NotNil(node.Patch(p, options))
+	assert.Equal(`{"a":[1,2,3],"b":"y"}`, mustJSONString(node))
+
+	res, err := p[1:3].ApplyWithOptions(doc, options)
+	assert.NotNil(err)
+	assert.Nil(res)
+	res, err = p[3:4].ApplyWithOptions(doc, options)
+	assert.Nil(err)
+	assert.Equal(`{"a":[1,2,3],"b":"x"}`, string(res))
+}
+
+func TestContinueOnErrorRollback(t *testing.T) {
+	assert := assert.New(t)
+
+	options := NewOptions()
+	options.ContinueOnError = true
+
+	// a failed move leaves the document unchanged.
+	node := NewNode([]byte(`{"a":1}`))
+	err := node.Patch(Patch{
+		{Op: "move", From: "/a", Path: "/missing/x"},
+		{Op: "add", Path: "/b", Value: []byte(`2`)},
+	}, options)
+	assert.True(errors.Is(err, ErrMissing))
+	assert.Equal(`{"a":1,"b":2}`, mustJSONString(node))
+
+	node = NewNode([]byte(`{"a":[1,2,3],"b":{"c":[]}}`))
+	err = node.Patch(Patch{
+		{Op: "move", From: "/a/0", Path: "/b/c/5"},
+		{Op: "move", From: "/a/2", Path: "/b/c/-"},
+	}, options)
+	assert.True(errors.Is(err, ErrInvalidIndex))
+	assert.Equal(`{"a":[1,2],"b":{"c":[3]}}`, mustJSONString(node))
+
+	// the created or converted intermediates of a failed operation are rolled back.
+	options.EnsurePathExistsOnAdd = true
+	node = NewNode([]byte(`{"a":{}}`))
+	err = node.Patch(Patch{
+		{Op: "add", Path: "/a/b/c/-2", Value: []byte(`1`)},
+		{Op: "add", Path: "/a/d", Value: []byte(`2`)},
+	}, options)
+	assert.NotNil(err)
+	assert.Equal(`{"a":{"d":2}}`, mustJSONString(node))
+
+	options.EnsurePathExistsOnAdd = false
+	options.ConvertNullIntermediates = true
+	node = NewNode([]byte(`{"a":null,"b":1}`))
+	err = node.Patch(Patch{
+		{Op: "copy", From: "/b", Path: "/a/c/d"},
+		{Op: "copy", From: "/b", Path: "/e"},
+	}, options)
+	assert.NotNil(err)
+	assert.Equal(`{"a":null,"b":1,"e":1}`, mustJSONString(node))
+
+	res, err := Patch{
+		{Op: "move", From: "/a", Path: "/missing/x"},
+		{Op: "add", Path: "/b", Value: []byte(`2`)},
+	}.ApplyWithOptions([]byte(`{"a":1}`), options)
+	assert.NotNil(err)
+	assert.Nil(res)
+}
+
+func TestPatchAtomic(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"a":[1,2],"b":{"c":"x"}}`)
+	p := Patch{
+		{Op: "add", Path: "/a/-", Value: []byte(`3`)},
+		{Op: "remove", Path: "/b/c"},
+		{Op: "test", Path: "/b/c", Value: []byte(`"x"`)},
+	}
+
+	node := NewNode(doc)
+	assert.NotNil(node.Patch(p, nil))
+	assert.Equal(`{"a":[1,2,3],"b":{}}`, mustJSONString(node))
+
+	node = NewNode(doc)
+	_, err := node.GetChild("/b/c", nil)
+	assert.Nil(err)
+	err = node.PatchAtomic(p, nil)
+	assert.True(errors.Is(err, ErrTestFailed))
+	assert.Equal(`{"a":[1,2],"b":{"c":"x"}}`, mustJSONString(node))
+
+	options := NewOptions()
+	options.ContinueOnError = true
+	assert.NotNil(node.PatchAtomic(p, options))
+	assert.Equal(`{"a":[1,2],"b":{"c":"x"}}`, mustJSONString(node))
+
+	assert.Nil(node.PatchAtomic(p[:2], nil))
+	assert.Equal(`{"a":[1,2,3],"b":{}}`, mustJSONString(node))
+
+	res, err := p.ApplyAtomic(doc, nil)
+	assert.NotNil(err)
+	assert.Nil(res)
+	res, err = p[:2].ApplyAtomic(doc, nil)
+	assert.Nil(err)
+	assert.Equal(`{"a":[1,2,3],"b":{}}`, string(res))
+}
+
+func TestPatchAt(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"spec":{"name":"John","tags":["a"]},"status":{}}`)
+	p := Patch{
+		NewReplaceOperation("/name", []byte(`"Jane"`)),
+		NewAddOperation("/tags/-", []byte(`"b"`)),
+	}
+
+	res, err := ApplyAt(doc, "/spec", p, nil)
+	assert.Nil(err)
+	assert.Equal(`{"spec":{"name":"Jane","tags":["a","b"]},"status":{}}`, string(res))
+
+	res, err = ApplyAt(doc, "/spec/tags", Patch{NewRemoveOperation("/0")}, nil)
+	assert.Nil(err)
+	assert.Equal(`{"spec":{"name":"John","tags":[]},"status":{}}`, string(res))
+
+	res, err = ApplyAt(doc, "/spec", Patch{NewReplaceOperation("", []byte(`[1]`))}, nil)
+	assert.Nil(err)
+	assert.Equal(`{"spec":[1],"status":{}}`, string(res))
+
+	res, err = ApplyAt(doc, "", Patch{NewRemoveOperation("/status")}, nil)
+	assert.Nil(err)
+	assert.Equal(`{"spec":{"name":"John","tags":["a"]}}`, string(res))
+
+	_, err = ApplyAt(doc, "/meta", p, nil)
+	assert.True(errors.Is(err, ErrMissing))
+	_, err = ApplyAt(doc, "/spec/name", p, nil)
+	assert.NotNil(err)
+	_, err = ApplyAt(doc, "/status", p, nil)
+	assert.True(errors.Is(err, ErrMissing))
+}
+
+func TestApplyConditional(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"version":3,"name":"a"}`)
+	p, err := NewPatch([]byte(`[{"op": "replace", "path": "/name", "value": "b"}]`))
+	assert.Nil(err)
+
+	res, err := ApplyConditional(doc, p, "/version", json.RawMessage(`3`), nil)
+	assert.Nil(err)
+	assert.Equal(`{"version":4,"name":"b"}`, string(res))
+
+	_, err = ApplyConditional(res, p, "/version", json.RawMessage(`3`), nil)
+	assert.ErrorIs(err, ErrTestFailed)
+	_, err = ApplyConditional(doc, p, "/rev", json.RawMessage(`3`), nil)
+	assert.ErrorIs(err, ErrTestFailed)
+	_, err = ApplyConditional(doc, p, "/version", json.RawMessage(`"3"`), nil)
+	assert.ErrorIs(err, ErrInvalid)
+	_, err = ApplyConditional(doc, p, "/version", json.RawMessage(`3.5`), nil)
+	assert.ErrorIs(err, ErrInvalid)
+
+	p, err = NewPatch([]byte(`[{"op": "remove", "path": "/x"}]`))
+	assert.Nil(err)
+	_, err = ApplyConditional(doc, p, "/version", json.RawMessage(`3`), nil)
+	assert.ErrorIs(err, ErrMissing)
+
+	res, err = ApplyConditional([]byte(`{"meta":{"v":0}}`), Patch{}, "/meta/v", json.RawMessage(` 0 `), nil)
+	assert.Nil(err)
+	assert.Equal(`{"meta":{"v":1}}`, string(res))
+}
+
+func TestApplyForEach(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"items":[{"name":"a"},{"name":"b","tags":[]}]}`)
+	p := Patch{
+		NewAddOperation("/done", []byte(`true`)),
+		NewMoveOperation("/name", "/id"),
+	}
+
+	res, err := ApplyForEach(doc, "/items", p, nil)
+	assert.Nil(err)
+	assert.Equal(`{"items":[{"done":true,"id":"a"},{"tags":[],"done":true,"id":"b"}]}`, string(res))
+
+	res, err = ApplyForEach([]byte(`[[1],[2]]`), "", Patch{NewAddOperation("/-", []byte(`0`))}, nil)
+	assert.Nil(err)
+	assert.Equal(`[[1,0],[2,0]]`, string(res))
+
+	_, err = ApplyForEach(doc, "/items", Patch{NewRemoveOperation("/tags")}, nil)
+	assert.True(errors.Is(err, ErrMissing))
+	assert.Contains(err.Error(), `unable to apply for element 0 of "/items"`)
+
+	_, err = ApplyForEach(doc, "/items/0", p, nil)
+	assert.NotNil(err)
+	_, err = ApplyForEach(doc, "/list", p, nil)
+	assert.True(errors.Is(err, ErrMissing))
+}
+
+func TestOnWarning(t *testing.T) {
+	assert := assert.New(t)
+
+	var warnings []Warning
+	options := NewOptions()
+	options.AllowMissingPathOnRemove = true
+	options.EnsurePathExistsOnAdd = true
+	options.ConvertNullIntermediates = true
+	options.OnWarning = func(w Warning) {
+		warnings = append(warnings, w)
+	}
+
+	p := Patch{
+		NewRemoveOperation("/age"),
+		NewRemoveOperation("/tags/5"),
+		NewAddOperation("/meta/labels/app", []byte(`"web"`)),
+		NewAddOperation("/none/0", []byte(`1`)),
+		NewRemoveOperation("/tags/-1"),
+		NewRemoveOperation("/name"),
+	}
+	res, err := p.ApplyWithOptions([]byte(`{"name":"John","tags":["a","b"],"none":null}`), options)
+	assert.Nil(err)
+	assert.Equal(`{"tags":["a"],"none":[1],"meta":{"labels":{"app":"web"}}}`, string(res))
+	assert.Equal([]Warning{
+		{Index: 0, Op: "remove", Path: "/age", Message: `skipped removing missing path "/age"`},
+		{Index: 1, Op: "remove", Path: "/tags/5", Message: `skipped removing missing path "/tags/5"`},
+		{Index: 2, Op: "add", Path: "/meta/labels/app", Message: `created missing object "/meta"`},
+		{Index: 2, Op: "add", Path: "/meta/labels/app", Message: `created missing object "/meta/labels"`},
+		{Index: 3, Op: "add", Path: "/none/0", Message: `converted null "/none" into array`},
+		{Index: 4, Op: "remove", Path: "/tags/-1",
+			Message: `negative index in path "/tags/-1" is non-standard, resolved to "/tags/1"`},
+	}, warnings)
+	assert.Nil(options.applying)
+
+	warnings = nil
+	_, err = p.ApplyWithOptions([]byte(`{"name":"John","tags":["a","b"],"none":null}`), NewOptions())
+	assert.NotNil(err)
+	assert.Nil(warnings)
+}
+
+func TestPreserveKeyOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"name":"app","version":"1.0.0","scripts":{"build":"go build","test":"go test"},"deps":[{"b":1,"a":2}]}`)
+	p := Patch{
+		NewRemoveOperation("/version"),
+		NewAddOperation("/license", []byte(`"MIT"`)),
+		NewAddOperation("/version", []byte(`"1.1.0"`)),
+		NewReplaceOperation("/scripts", []byte(`{"lint":"go vet","test":"go test ./...","build":"go build ./..."}`)),
+		NewReplaceOperation("/deps/0", []byte(`{"a":3,"b":4}`)),
+	}
+
+	res, err := p.Apply(doc)
+	assert.Nil(err)
+	assert.Equal(`{"name":"app","scripts":{"lint":"go vet","test":"go test ./...","build":"go build ./..."},`+
+		`"deps":[{"a":3,"b":4}],"license":"MIT","version":"1.1.0"}`, string(res))
+
+	options := NewOptions()
+	options.PreserveKeyOrder = true
+	res, err = p.ApplyWithOptions(doc, options)
+	assert.Nil(err)
+	assert.Equal(`{"name":"app","version":"1.1.0","scripts":{"build":"go build ./...","test":"go test ./...","lint":"go vet"},`+
+		`"deps":[{"b":4,"a":3}],"license":"MIT"}`, string(res))
+
+	p = Patch{
+		NewMoveOperation("/name", "/title"),
+		NewMoveOperation("/title", "/name"),
+		NewReplaceOperation("", []byte(`{"kind":"Pod","spec":{"image":"nginx"},"metadata":{"name":"web"}}`)),
+	}
+	res, err = p.ApplyWithOptions([]byte(`{"metadata":{"name":"web"},"name":"x","spec":{}}`), options)
+	assert.Nil(err)
+	assert.Equal(`{"metadata":{"name":"web"},"spec":{"image":"nginx"},"kind":"Pod"}`, string(res))
+}
+
+func TestRejectDuplicateKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := `{"role":"user","meta":[{"a":1,"b":2,"a":3}],"role":"admin"}`
+	patch := `[{"op": "add", "path": "/name", "value": "John"}]`
+
+	res, err := applyPatch(doc, patch)
+	assert.Nil(err)
+	assert.Equal(`{"role":"admin","meta":[{"a":3,"b":2}],"name":"John"}`, res)
+
+	options := NewOptions()
+	options.RejectDuplicateKeys = true
+	_, err = applyPatchWithOptions(`{"role":"user","meta":[{"a":1,"b":2,"a":3}]}`, patch, options)
+	assert.ErrorIs(err, ErrDuplicateKey)
+	assert.Equal(`duplicate key "a" in object "/meta/0", duplicate key detected`, err.Error())
+
+	node := NewNode([]byte(doc))
+	_, err = node.GetChild("/role", nil)
+	assert.Nil(err)
+	p, _ := NewPatch([]byte(patch))
+	err = node.Patch(p, options)
+	assert.ErrorIs(err, ErrDuplicateKey)
+	assert.Equal(`duplicate key "role" in object "", duplicate key detected`, err.Error())
+
+	_, err = applyPatchWithOptions(`{"a":{}}`, `[{"op": "add", "path": "/a/b", "value": {"c":[{"d":1,"d":2}]}}]`, options)
+	assert.ErrorIs(err, ErrDuplicateKey)
+	assert.Equal(`operation 0, add operation has invalid value, duplicate key "d" in object "/c/0", duplicate key detected`,
+		err.Error())
+
+	res, err = applyPatchWithOptions(`{"a":{"b":1}}`, `[{"op": "add", "path": "/a/c", "value": {"b":[1]}}]`, options)
+	assert.Nil(err)
+	assert.Equal(`{"a":{"b":1,"c":{"b":[1]}}}`, res)
+}
+
+func TestOptionProfiles(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := `{"tags":["a","b"],"meta":null}`
+	patch := `[
+		{"op": "remove", "path": "/missing"},
+		{"op": "add", "path": "/tags/5", "value": "c"},
+		{"op": "remove", "path": "/tags/-3"},
+		{"op": "add", "path": "/meta/labels/app", "value": "web"},
+		{"op": "add", "path": "/spec/replicas", "value": 1}
+	]`
+
+	var warnings []string
+	options := NewOptions().Lenient()
+	options.OnWarning = func(w Warning) {
+		warnings = append(warnings, w.Message)
+	}
+	res, err := applyPatchWithOptions(doc, patch, options)
+	assert.Nil(err)
+	assert.Equal(`{"tags":["b","c"],"meta":{"labels":{"app":"web"}},"spec":{"replicas":1}}`, res)
+	assert.Contains(warnings, "index 5 beyond the length of array 2, appended")
+
+	options = NewOptions().Lenient()
+	options.EnablePredicates = true
+	options.EnableIncrement = true
+	options.EnableStringOps = true
+	options.EnableSplice = true
+	options.TestSubset = true
+	options.RegisterOperation("noop", func(doc *Node, op Operation, options *Options) error { return nil })
+	options.StrictRFC6902()
+	assert.False(options.SupportNegativeIndices)
+	for i, op := range []string{
+		`{"op": "remove", "path": "/missing"}`,
+		`{"op": "add", "path": "/tags/3", "value": "c"}`,
+		`{"op": "remove", "path": "/tags/-1"}`,
+		`{"op": "add", "path": "/meta/labels", "value": "web"}`,
+		`{"op": "add", "path": "/spec/replicas", "value": 1}`,
+		`{"op": "defined", "path": "/tags"}`,
+		`{"op": "inc", "path": "/count", "value": 1}`,
+		`{"op": "str-append", "path": "/tags/0", "value": "x"}`,
+		`{"op": "splice", "path": "/tags/0", "values": ["x"]}`,
+		`{"op": "noop", "path": "/tags"}`,
+		`{"op": "test", "path": "", "value": {"tags": ["a", "b"]}}`,
+	} {
+		_, err = applyPatchWithOptions(doc, "["+op+"]", options)
+		assert.NotNil(err, "case %d", i)
+	}
+
+	// both profiles keep the standard behaviors, including the replacement of the root with "add".
+	for _, options := range []*Options{NewOptions().StrictRFC6902(), NewOptions().Lenient()} {
+		res, err = applyPatchWithOptions(doc, `[{"op": "add", "path": "/tags/2", "value": "c"}]`, options)
+		assert.Nil(err)
+		assert.Equal(`{"tags":["a","b","c"],"meta":null}`, res)
+
+		res, err = applyPatchWithOptions(doc, `[{"op": "add", "path": "", "value": {"baz": "qux"}}]`, options)
+		assert.Nil(err)
+		assert.Equal(`{"baz":"qux"}`, res)
+	}
+}
+
+// largeDocument returns a JSON document of about 3 MB.
+func largeDocument() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"kind": "list", "items": [`)
+	for i := 0; i < 20000; i++ {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, `{"id": %d, "name": "item %d", "tags": ["a", "b", "c"], `+
+			`"meta": {"created": "2022-01-01T00:00:00Z", "score": %d.5, "labels": {"app": "web", "tier": "backend"}}}`, i, i, i)
+	}
+	buf.WriteString(`]}`)
+	return buf.Bytes()
+}
+
+func BenchmarkApplyLargeDocument(b *testing.B) {
+	doc := largeDocument()
+	p, err := NewPatch([]byte(`[
+		{"op": "replace", "path": "/items/10000/meta/labels/tier", "value": "frontend"},
+		{"op": "add", "path": "/items/-", "value": {"id": 20000}},
+		{"op": "remove", "path": "/items/0"}
+	]`))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(doc)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Apply(doc); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetValueLargeDocument(b *testing.B) {
+	doc := largeDocument()
+
+	b.SetBytes(int64(len(doc)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := GetValueByPath(doc, "/items/10000/meta"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalLargeDocument(b *testing.B) {
+	doc := largeDocument()
+	node := NewNode(doc)
+	if _, err := node.GetChild("/items/10000/meta/labels", nil); err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(doc)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := node.MarshalJSON(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestNullIsAbsent(t *testing.T) {
+	assert := assert.New(t)
+
+	options := NewOptions()
+	options.NullIsAbsent = true
+
+	cases := []struct {
+		doc, patch, result string
+		ok                 bool
+	}{
+		{`{"a": 1, "b": null}`, `[{"op": "add", "path": "/c", "value": null}]`, `{"a": 1, "b": null}`, true},
+		{`{"a": 1, "b": 2}`, `[{"op": "add", "path": "/b", "value": null}]`, `{"a": 1}`, true},
+		{`{"a": [1]}`, `[{"op": "add", "path": "/a/-", "value": null}]`, `{"a": [1, null]}`, true},
+		{`{"a": 1}`, `[{"op": "test", "path": "/b/c", "value": null}]`, `{"a": 1}`, true},
+		{`{"a": null}`, `[{"op": "test", "path": "/b", "value": null}]`, `{"a": null}`, true},
+		{`{"a": null}`, `[{"op": "test", "path": "/a", "value": 1}]`, ``, false},
+		{`{"a": 1}`, `[{"op": "test", "path": "/a", "value": null}]`, ``, false},
+	}
+	for i, c := range cases {
+		out, err := applyPatchWithOptions(c.doc, c.patch, options)
+		if !c.ok {
+			assert.ErrorIs(err, ErrTestFailed, "case %d", i)
+			continue
+		}
+		assert.Nil(err, "case %d", i)
+		assert.True(compareJSON(c.result, out), "case %d", i)
+	}
+
+	_, err := applyPatch(`{"a": 1}`, `[{"op": "test", "path": "/b/c", "value": null}]`)
+	assert.ErrorIs(err, ErrTestFailed)
+	out, err := applyPatch(`{"a": 1}`, `[{"op": "add", "path": "/b", "value": null}]`)
+	assert.Nil(err)
+	assert.True(compareJSON(`{"a": 1, "b": null}`, out))
+
+	node := NewNode([]byte(`{"a": null, "b": [null], "c": {"d": null}}`))
+	_, err = node.GetChild("/a", options)
+	assert.ErrorIs(err, ErrMissing)
+	_, err = node.GetChild("/c/d", options)
+	assert.ErrorIs(err, ErrMissing)
+	val, err := node.GetChild("/b/0", options)
+	assert.Nil(err)
+	assert.Equal("null", mustJSONString(val))
+	val, err = node.GetChild("/a", nil)
+	assert.Nil(err)
+	assert.Equal("null", mustJSONString(val))
+	val, err = node.GetChildOr("/a", NewNode([]byte(`0`)), options)
+	assert.Nil(err)
+	assert.Equal("0", mustJSONString(val))
+
+	node = NewNode([]byte(`{"items": [{"app": "web"}, {"app": null}, {"tier": "db"}]}`))
+	res, err := node.FindChildren(PVs{NewExistsPV("/app")}, options)
+	assert.Nil(err)
+	assert.Equal([]string{"/items/0"}, res.Paths())
+	res, err = node.FindChildren(PVs{{Path: "/app", Value: nil}}, options)
+	assert.Nil(err)
+	assert.ElementsMatch([]string{"", "/items/1", "/items/2"}, res.Paths())
+
+	assert.False(NewOptions().StrictRFC6902().NullIsAbsent)
+}
@@ -0,0 +1,225 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package patchlog implements an event-sourced JSON document storage on the jsonpatch package.
+//
+// A Log records the patches applied to a base document in order, each patch has a sequence number,
+// the document at any retained sequence number is rebuilt by replaying the patches from the nearest snapshot:
+//
+//	log, _ := patchlog.New([]byte(`{}`), nil)
+//	seq, err := log.Append(patch)
+//	doc, err := log.At(seq)
+//
+// The patches older than the retained ones are squashed into one patch from the base document,
+// so the whole history can still be replayed with ReplayOnto.
+package patchlog
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	jsonpatch "github.com/ldclabs/json-patch"
+)
+
+// DefaultSnapshotInterval is the number of patches between the snapshots if Options.SnapshotInterval is 0.
+const DefaultSnapshotInterval = 64
+
+// Options specifies options for the Log.
+type Options struct {
+	// SnapshotInterval is the number of patches between the snapshots of the document,
+	// At replays at most SnapshotInterval-1 patches. Default to 0, which means DefaultSnapshotInterval.
+	SnapshotInterval int
+	// Retain is the number of the latest patches that are kept when a snapshot is taken,
+	// the older patches are squashed. Default to 0, which means all the patches are kept.
+	Retain int
+	// PatchOptions is used to apply the patches. Default to nil, which means jsonpatch.NewOptions().
+	PatchOptions *jsonpatch.Options
+}
+
+// Log is a log of the patches of a JSON document. It is safe for concurrent use.
+type Log struct {
+	mu       sync.RWMutex
+	options  Options
+	base     []byte
+	squashed jsonpatch.Patch
+	// start is the sequence number of the squashed patches, entries[i] has the sequence number start+i+1.
+	start   uint64
+	entries []*jsonpatch.AnnotatedPatch
+	// snapshots are sorted by sequence number, the first one is at start.
+	snapshots []snapshot
+	head      []byte
+}
+
+type snapshot struct {
+	seq uint64
+	doc []byte
+}
+
+// New creates a Log with the given document as sequence number 0.
+func New(base []byte, options *Options) (*Log, error) {
+	l := &Log{}
+	if options != nil {
+		l.options = *options
+	}
+	if l.options.SnapshotInterval <= 0 {
+		l.options.SnapshotInterval = DefaultSnapshotInterval
+	}
+	if l.options.PatchOptions == nil {
+		l.options.PatchOptions = jsonpatch.NewOptions()
+	}
+
+	head, err := jsonpatch.NewNode(base).MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	l.base, l.head = head, head
+	l.snapshots = []snapshot{{seq: 0, doc: head}}
+	return l, nil
+}
+
+// Seq returns the sequence number of the last patch.
+func (l *Log) Seq() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.seq()
+}
+
+func (l *Log) seq() uint64 {
+	return l.start + uint64(len(l.entries))
+}
+
+// Head returns the sequence number of the last patch and the current document.
+func (l *Log) Head() (uint64, []byte) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.seq(), l.head
+}
+
+// Append applies the patch to the current document and records it with the next sequence number.
+// The log is left untouched if the patch fails to apply.
+func (l *Log) Append(p jsonpatch.Patch) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	head, err := p.ApplyWithOptions(l.head, l.options.PatchOptions)
+	if err != nil {
+		return 0, err
+	}
+
+	seq := l.seq() + 1
+	l.entries = append(l.entries, p.Annotate("", seq, "", l.options.PatchOptions))
+	l.head = head
+	if seq%uint64(l.options.SnapshotInterval) == 0 {
+		l.snapshots = append(l.snapshots, snapshot{seq: seq, doc: head})
+		if r := l.options.Retain; r > 0 && len(l.entries) > r {
+			// the log stays valid if the squashing fails, the patches are squashed at the next snapshot.
+			l.compact(seq - uint64(r))
+		}
+	}
+	return seq, nil
+}
+
+// Entries returns the patches after the given sequence number.
+func (l *Log) Entries(seq uint64) ([]*jsonpatch.AnnotatedPatch, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if seq < l.start || seq > l.seq() {
+		return nil, fmt.Errorf("unable to get entries after sequence number %d, %w", seq, jsonpatch.ErrUnknownRevision)
+	}
+	return append([]*jsonpatch.AnnotatedPatch(nil), l.entries[seq-l.start:]...), nil
+}
+
+// At returns the document at the given sequence number, the patches are replayed from the nearest snapshot.
+// It returns an error wrapping jsonpatch.ErrUnknownRevision if the patches of the sequence number are squashed.
+func (l *Log) At(seq uint64) ([]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.at(seq)
+}
+
+func (l *Log) at(seq uint64) ([]byte, error) {
+	if seq < l.start || seq > l.seq() {
+		return nil, fmt.Errorf("unable to get document at sequence number %d, %w", seq, jsonpatch.ErrUnknownRevision)
+	}
+	if seq == l.seq() {
+		return l.head, nil
+	}
+
+	i := sort.Search(len(l.snapshots), func(i int) bool { return l.snapshots[i].seq > seq }) - 1
+	s := l.snapshots[i]
+	if s.seq == seq {
+		return s.doc, nil
+	}
+
+	node := jsonpatch.NewNode(s.doc)
+	for _, e := range l.entries[s.seq-l.start : seq-l.start] {
+		if err := node.Patch(e.Patch, l.options.PatchOptions); err != nil {
+			return nil, err
+		}
+	}
+	return node.MarshalJSON()
+}
+
+// ReplayOnto applies the whole log, the squashed patch and the retained patches in order, to the given document.
+func (l *Log) ReplayOnto(doc []byte) ([]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	node := jsonpatch.NewNode(doc)
+	if err := node.Patch(l.squashed, l.options.PatchOptions); err != nil {
+		return nil, err
+	}
+	for _, e := range l.entries {
+		if err := node.Patch(e.Patch, l.options.PatchOptions); err != nil {
+			return nil, err
+		}
+	}
+	return node.MarshalJSON()
+}
+
+// Squashed returns the patch from the base document to the document at the sequence number
+// of the oldest retained patch.
+func (l *Log) Squashed() (uint64, jsonpatch.Patch) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.start, l.squashed
+}
+
+// Compact squashes the patches up to the given sequence number with Patch.Squash,
+// and drops the snapshots before it.
+func (l *Log) Compact(seq uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.compact(seq)
+}
+
+func (l *Log) compact(seq uint64) error {
+	if seq > l.seq() {
+		seq = l.seq()
+	}
+	if seq <= l.start {
+		return nil
+	}
+
+	doc, err := l.at(seq)
+	if err != nil {
+		return err
+	}
+	ps := make([]jsonpatch.Patch, 0, seq-l.start+1)
+	ps = append(ps, l.squashed)
+	for _, e := range l.entries[:seq-l.start] {
+		ps = append(ps, e.Patch)
+	}
+	squashed, err := jsonpatch.ConcatPatches(ps...).Squash(l.base)
+	if err != nil {
+		return err
+	}
+
+	i := sort.Search(len(l.snapshots), func(i int) bool { return l.snapshots[i].seq > seq })
+	l.snapshots = append([]snapshot{{seq: seq, doc: doc}}, l.snapshots[i:]...)
+	l.entries = append([]*jsonpatch.AnnotatedPatch(nil), l.entries[seq-l.start:]...)
+	l.squashed, l.start = squashed, seq
+	return nil
+}
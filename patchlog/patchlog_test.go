@@ -0,0 +1,117 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package patchlog
+
+import (
+	"fmt"
+	"testing"
+
+	jsonpatch "github.com/ldclabs/json-patch"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustPatch(s string) jsonpatch.Patch {
+	p, err := jsonpatch.NewPatch([]byte(s))
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func TestLog(t *testing.T) {
+	assert := assert.New(t)
+
+	log, err := New([]byte(`{"n": 0, "items": []}`), &Options{SnapshotInterval: 3})
+	assert.Nil(err)
+	assert.Equal(uint64(0), log.Seq())
+
+	for i := 1; i <= 10; i++ {
+		seq, err := log.Append(mustPatch(fmt.Sprintf(
+			`[{"op": "replace", "path": "/n", "value": %d}, {"op": "add", "path": "/items/-", "value": %d}]`, i, i)))
+		assert.Nil(err)
+		assert.Equal(uint64(i), seq)
+	}
+
+	_, err = log.Append(mustPatch(`[{"op": "remove", "path": "/x"}]`))
+	assert.ErrorIs(err, jsonpatch.ErrMissing)
+	seq, head := log.Head()
+	assert.Equal(uint64(10), seq)
+	assert.Equal(`{"n":10,"items":[1,2,3,4,5,6,7,8,9,10]}`, string(head))
+
+	for i, c := range []struct {
+		seq uint64
+		doc string
+	}{
+		{0, `{"n":0,"items":[]}`},
+		{1, `{"n":1,"items":[1]}`},
+		{3, `{"n":3,"items":[1,2,3]}`},
+		{5, `{"n":5,"items":[1,2,3,4,5]}`},
+		{10, `{"n":10,"items":[1,2,3,4,5,6,7,8,9,10]}`},
+	} {
+		doc, err := log.At(c.seq)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.doc, string(doc), "case %d", i)
+	}
+	_, err = log.At(11)
+	assert.ErrorIs(err, jsonpatch.ErrUnknownRevision)
+
+	doc, err := log.ReplayOnto([]byte(`{"n": -1, "items": [0], "x": true}`))
+	assert.Nil(err)
+	assert.Equal(`{"n":10,"items":[0,1,2,3,4,5,6,7,8,9,10],"x":true}`, string(doc))
+
+	es, err := log.Entries(8)
+	assert.Nil(err)
+	assert.Equal(2, len(es))
+	assert.Equal(uint64(9), es[0].Sequence)
+	assert.Equal(uint64(10), es[1].Sequence)
+
+	assert.Nil(log.Compact(4))
+	start, squashed := log.Squashed()
+	assert.Equal(uint64(4), start)
+	res, err := squashed.Apply([]byte(`{"n": 0, "items": []}`))
+	assert.Nil(err)
+	assert.Equal(`{"n":4,"items":[1,2,3,4]}`, string(res))
+
+	_, err = log.At(3)
+	assert.ErrorIs(err, jsonpatch.ErrUnknownRevision)
+	_, err = log.Entries(3)
+	assert.ErrorIs(err, jsonpatch.ErrUnknownRevision)
+	for _, s := range []uint64{4, 5, 7, 10} {
+		doc, err := log.At(s)
+		assert.Nil(err)
+		res, _ := mustPatch(fmt.Sprintf(`[{"op": "replace", "path": "/n", "value": %d}]`, s)).Apply(doc)
+		assert.Equal(string(doc), string(res))
+	}
+
+	doc, err = log.ReplayOnto([]byte(`{"n": 0, "items": []}`))
+	assert.Nil(err)
+	assert.Equal(`{"n":10,"items":[1,2,3,4,5,6,7,8,9,10]}`, string(doc))
+}
+
+func TestLogRetain(t *testing.T) {
+	assert := assert.New(t)
+
+	log, err := New([]byte(`{"items": []}`), &Options{SnapshotInterval: 4, Retain: 2})
+	assert.Nil(err)
+	for i := 1; i <= 9; i++ {
+		_, err := log.Append(mustPatch(fmt.Sprintf(`[{"op": "add", "path": "/items/-", "value": %d}]`, i)))
+		assert.Nil(err)
+	}
+
+	// the patches are squashed at the snapshot 8.
+	start, _ := log.Squashed()
+	assert.Equal(uint64(6), start)
+	_, err = log.At(5)
+	assert.ErrorIs(err, jsonpatch.ErrUnknownRevision)
+	doc, err := log.At(7)
+	assert.Nil(err)
+	assert.Equal(`{"items":[1,2,3,4,5,6,7]}`, string(doc))
+
+	doc, err = log.ReplayOnto([]byte(`{"items": []}`))
+	assert.Nil(err)
+	assert.Equal(`{"items":[1,2,3,4,5,6,7,8,9]}`, string(doc))
+
+	_, err = New([]byte(`{`), nil)
+	assert.NotNil(err)
+}
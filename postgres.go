@@ -0,0 +1,117 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ToPostgres translates the patch into a PostgreSQL expression of the jsonb column, so that the patch can be
+// applied by the database without loading the document:
+//
+//	expr, args, err := p.ToPostgres("data", 1)
+//	db.Exec("UPDATE items SET data = "+expr+" WHERE id = $"+strconv.Itoa(len(args)+1), append(args, id)...)
+//
+// The column is written into the expression as is, so it should be a trusted identifier. The paths and values
+// are passed as the text arguments of the placeholders, numbered from firstArg ($1 if firstArg is less than 1).
+// The operations are translated in order into jsonb_set, jsonb_insert, #- and || expressions,
+// the numeric reference tokens are treated as array indices, and the "-" token appends after the last element.
+// Unlike the patch, the expression doesn't fail on missing paths, PostgreSQL leaves the document unchanged
+// for them, and the "move" and "copy" operations of the missing from paths leave it unchanged too,
+// instead of setting the column to NULL. An error is returned for the "test" operations, the custom operations, and removing the root.
+func (p Patch) ToPostgres(column string, firstArg int) (string, []interface{}, error) {
+	if firstArg < 1 {
+		firstArg = 1
+	}
+	var args []interface{}
+	arg := func(v string, cast string) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(firstArg+len(args)-1) + "::" + cast
+	}
+
+	expr := column
+	for i, op := range p {
+		var err error
+		switch op.Op {
+		case "add", "replace":
+			if op.Value == nil {
+				err = fmt.Errorf("%s operation has no value", op.Op)
+				break
+			}
+			expr = postgresSet(expr, op.Op == "add", op.Path, arg(string(op.Value), "jsonb"), arg)
+		case "remove":
+			if op.Path == "" {
+				err = fmt.Errorf("removing the root can not be translated")
+				break
+			}
+			expr = "(" + expr + " #- " + arg(postgresPath(op.Path), "text[]") + ")"
+		case "move", "copy":
+			// the document is referred more than once, so it is selected in a subquery to not repeat
+			// the expression. #> yields NULL for a missing from path, and jsonb_set and jsonb_insert
+			// yield NULL for it, so the document is kept then.
+			from := arg(postgresPath(op.From), "text[]")
+			doc := "doc"
+			if op.Op == "move" {
+				doc = "(doc #- " + from + ")"
+			}
+			expr = "(SELECT CASE WHEN doc #> " + from + " IS NULL THEN doc ELSE " +
+				postgresSet(doc, true, op.Path, "(doc #> "+from+")", arg) +
+				" END FROM (SELECT " + expr + " AS doc) AS t)"
+		default:
+			err = fmt.Errorf("%s operation can not be translated", op.Op)
+		}
+		if err != nil {
+			return "", nil, &PathError{Op: op.Op, Path: op.Path, Index: i, Err: err}
+		}
+	}
+	return expr, args, nil
+}
+
+// postgresSet returns the expression that adds (or replaces) the value at the path in the expression.
+func postgresSet(expr string, add bool, path, value string, arg func(v string, cast string) string) string {
+	if path == "" {
+		return value
+	}
+
+	parent, key := splitLastPath(path)
+	switch {
+	case !add:
+		return "jsonb_set(" + expr + ", " + arg(postgresPath(path), "text[]") + ", " + value + ", false)"
+	case key == "-" && parent == "":
+		return "(" + expr + " || jsonb_build_array(" + value + "))"
+	case key == "-":
+		return "jsonb_insert(" + expr + ", " + arg(postgresPath(parent+"/-1"), "text[]") + ", " + value + ", true)"
+	case isArrayIndex(key):
+		return "jsonb_insert(" + expr + ", " + arg(postgresPath(path), "text[]") + ", " + value + ")"
+	default:
+		return "jsonb_set(" + expr + ", " + arg(postgresPath(path), "text[]") + ", " + value + ", true)"
+	}
+}
+
+// postgresPath converts the JSON pointer into a PostgreSQL text array literal, such as {"a","0"}.
+func postgresPath(path string) string {
+	if path == "" {
+		return "{}"
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, key := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('"')
+		for _, c := range decodePatchKey(key) {
+			if c == '"' || c == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(c)
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
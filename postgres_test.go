@@ -0,0 +1,80 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToPostgres(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := NewPatch([]byte(`[
+		{"op":"replace","path":"/name","value":"a"},
+		{"op":"add","path":"/user/a\"b~1c","value":{"age":18}},
+		{"op":"add","path":"/tags/-","value":["x"]},
+		{"op":"add","path":"/tags/0","value":"y"},
+		{"op":"remove","path":"/old"}
+	]`))
+	assert.Nil(err)
+	expr, args, err := p.ToPostgres("data", 2)
+	assert.Nil(err)
+	assert.Equal(`(jsonb_insert(jsonb_insert(jsonb_set(jsonb_set(data, $3::text[], $2::jsonb, false), `+
+		`$5::text[], $4::jsonb, true), $7::text[], $6::jsonb, true), $9::text[], $8::jsonb) #- $10::text[])`, expr)
+	assert.Equal([]interface{}{
+		`"a"`, `{"name"}`,
+		`{"age":18}`, `{"user","a\"b/c"}`,
+		`["x"]`, `{"tags","-1"}`,
+		`"y"`, `{"tags","0"}`,
+		`{"old"}`,
+	}, args)
+
+	p, err = NewPatch([]byte(`[
+		{"op":"move","from":"/a","path":"/b"},
+		{"op":"copy","from":"/b","path":"/c/-"}
+	]`))
+	assert.Nil(err)
+	expr, args, err = p.ToPostgres("t.doc", 0)
+	assert.Nil(err)
+	assert.Equal(`(SELECT CASE WHEN doc #> $3::text[] IS NULL THEN doc ELSE `+
+		`jsonb_insert(doc, $4::text[], (doc #> $3::text[]), true) END FROM `+
+		`(SELECT (SELECT CASE WHEN doc #> $1::text[] IS NULL THEN doc ELSE `+
+		`jsonb_set((doc #- $1::text[]), $2::text[], (doc #> $1::text[]), true) END FROM `+
+		`(SELECT t.doc AS doc) AS t) AS doc) AS t)`, expr)
+	assert.Equal([]interface{}{`{"a"}`, `{"b"}`, `{"b"}`, `{"c","-1"}`}, args)
+
+	// the missing from paths keep the document, a copy to the root too.
+	p, err = NewPatch([]byte(`[{"op":"copy","from":"/a","path":""}]`))
+	assert.Nil(err)
+	expr, args, err = p.ToPostgres("data", 1)
+	assert.Nil(err)
+	assert.Equal(`(SELECT CASE WHEN doc #> $1::text[] IS NULL THEN doc ELSE (doc #> $1::text[]) END FROM `+
+		`(SELECT data AS doc) AS t)`, expr)
+	assert.Equal([]interface{}{`{"a"}`}, args)
+
+	p, err = NewPatch([]byte(`[
+		{"op":"add","path":"/-","value":1},
+		{"op":"replace","path":"","value":[1]}
+	]`))
+	assert.Nil(err)
+	expr, args, err = p.ToPostgres("data", 1)
+	assert.Nil(err)
+	assert.Equal(`$2::jsonb`, expr)
+	assert.Equal([]interface{}{`1`, `[1]`}, args)
+
+	for i, c := range []struct {
+		patch, err string
+	}{
+		{`[{"op":"test","path":"/a","value":1}]`, "test operation can not be translated"},
+		{`[{"op":"remove","path":""}]`, "removing the root can not be translated"},
+		{`[{"op":"add","path":"/a"}]`, "add operation has no value"},
+	} {
+		p, err := NewPatch([]byte(c.patch))
+		assert.Nil(err, "case %d", i)
+		_, _, err = p.ToPostgres("data", 1)
+		assert.ErrorContains(err, c.err, "case %d", i)
+	}
+}
@@ -0,0 +1,288 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// predicateOperations are the JSON Predicate operations enabled by Options.EnablePredicates,
+// "test" is handled as a predicate only when it is applied by "and", "or" and "not".
+var predicateOperations = map[string]bool{
+	"contains":  true,
+	"defined":   true,
+	"undefined": true,
+	"starts":    true,
+	"ends":      true,
+	"less":      true,
+	"more":      true,
+	"in":        true,
+	"matches":   true,
+	"type":      true,
+	"and":       true,
+	"or":        true,
+	"not":       true,
+}
+
+var (
+	langPattern      = regexp.MustCompile(`^[a-zA-Z]{1,8}(-[a-zA-Z0-9]{1,8})*$`)
+	langRangePattern = regexp.MustCompile(`^(\*|[a-zA-Z]{1,8})(-(\*|[a-zA-Z0-9]{1,8}))*$`)
+)
+
+func applyPredicate(doc *Node, op Operation, options *Options) error {
+	ok, err := evalPredicate(doc, op, "", options)
+	switch {
+	case err != nil:
+		return err
+	case !ok:
+		return testFailedf("%s operation for path %q failed", op.Op, op.Path)
+	}
+	return nil
+}
+
+// evalPredicate evaluates the predicate operation, the path of the operation is relative to base.
+func evalPredicate(doc *Node, op Operation, base string, options *Options) (bool, error) {
+	path := base + op.Path
+
+	switch op.Op {
+	case "and", "or", "not":
+		if len(op.Apply) == 0 {
+			return false, fmt.Errorf("%s operation for path %q has nothing to apply", op.Op, path)
+		}
+		for _, sub := range op.Apply {
+			ok, err := evalPredicate(doc, sub, path, options)
+			switch {
+			case err != nil:
+				return false, err
+			case op.Op == "and" && !ok, op.Op == "not" && ok:
+				return false, nil
+			case op.Op == "or" && ok:
+				return true, nil
+			}
+		}
+		return op.Op != "or", nil
+	}
+
+	var target *Node
+	if path == "" {
+		target = doc
+	} else if n, err := doc.GetChild(path, options); err == nil {
+		target = n
+	}
+
+	switch op.Op {
+	case "defined":
+		return target != nil, nil
+	case "undefined":
+		return target == nil, nil
+	case "type":
+		var typ string
+		if err := json.Unmarshal(op.Value, &typ); err != nil {
+			return false, fmt.Errorf("type operation for path %q has invalid value, %v", path, err)
+		}
+		return predicateType(target, typ)
+	}
+
+	if target == nil {
+		return false, nil
+	}
+
+	switch op.Op {
+	case "test":
+		if !op.IgnoreCase {
+			return target.Equal(NewNode(op.Value)), nil
+		}
+		a, aok := predicateString(target)
+		b, bok := predicateString(NewNode(op.Value))
+		if !aok || !bok {
+			return target.Equal(NewNode(op.Value)), nil
+		}
+		return strings.EqualFold(a, b), nil
+
+	case "contains", "starts", "ends":
+		if _, err := target.intoContainer(); err == nil && op.Op == "contains" && target.which == eAry {
+			for _, v := range target.ary {
+				if v.Equal(NewNode(op.Value)) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+
+		a, aok := predicateString(target)
+		b, bok := predicateString(NewNode(op.Value))
+		if !bok {
+			return false, fmt.Errorf("%s operation for path %q has invalid value, expected string", op.Op, path)
+		}
+		if !aok {
+			return false, nil
+		}
+		if op.IgnoreCase {
+			a, b = strings.ToLower(a), strings.ToLower(b)
+		}
+		switch op.Op {
+		case "contains":
+			return strings.Contains(a, b), nil
+		case "starts":
+			return strings.HasPrefix(a, b), nil
+		default:
+			return strings.HasSuffix(a, b), nil
+		}
+
+	case "less", "more":
+		if options.UseNumber {
+			b, ok := NewNode(op.Value).number()
+			if !ok {
+				return false, fmt.Errorf("%s operation for path %q has invalid value, expected number", op.Op, path)
+			}
+			a, ok := target.number()
+			if !ok {
+				return false, nil
+			}
+			if op.Op == "less" {
+				return a.Cmp(b) < 0, nil
+			}
+			return a.Cmp(b) > 0, nil
+		}
+
+		var a, b float64
+		if err := json.Unmarshal(op.Value, &b); err != nil {
+			return false, fmt.Errorf("%s operation for path %q has invalid value, %v", op.Op, path, err)
+		}
+		raw, err := target.MarshalJSON()
+		if err != nil {
+			return false, err
+		}
+		if err = json.Unmarshal(raw, &a); err != nil {
+			return false, nil
+		}
+		if op.Op == "less" {
+			return a < b, nil
+		}
+		return a > b, nil
+
+	case "in":
+		var values []json.RawMessage
+		if err := json.Unmarshal(op.Value, &values); err != nil {
+			return false, fmt.Errorf("in operation for path %q has invalid value, %v", path, err)
+		}
+		a, aok := predicateString(target)
+		for _, v := range values {
+			vn := NewNode(v)
+			if op.IgnoreCase && aok {
+				if b, bok := predicateString(vn); bok && strings.EqualFold(a, b) {
+					return true, nil
+				}
+			}
+			if target.Equal(vn) {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case "matches":
+		var expr string
+		if err := json.Unmarshal(op.Value, &expr); err != nil {
+			return false, fmt.Errorf("matches operation for path %q has invalid value, %v", path, err)
+		}
+		if op.IgnoreCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return false, fmt.Errorf("matches operation for path %q has invalid value, %v", path, err)
+		}
+		a, ok := predicateString(target)
+		return ok && re.MatchString(a), nil
+	}
+
+	return false, fmt.Errorf("unexpected predicate operation %q", op.Op)
+}
+
+func predicateString(n *Node) (string, bool) {
+	raw, err := n.MarshalJSON()
+	if err != nil {
+		return "", false
+	}
+	var s string
+	if raw = bytes.TrimSpace(raw); len(raw) == 0 || raw[0] != '"' || json.Unmarshal(raw, &s) != nil {
+		return "", false
+	}
+	return s, true
+}
+
+func predicateType(n *Node, typ string) (bool, error) {
+	switch typ {
+	case "undefined", "null", "boolean", "object", "array", "number", "string",
+		"date-time", "date", "time", "lang", "lang-range", "iri", "absolute-iri":
+	default:
+		return false, fmt.Errorf("unexpected type %q", typ)
+	}
+
+	if n == nil {
+		return typ == "undefined", nil
+	}
+
+	raw, err := n.MarshalJSON()
+	if err != nil {
+		return false, err
+	}
+	raw = bytes.TrimSpace(raw)
+
+	switch typ {
+	case "undefined":
+		return false, nil
+	case "null":
+		return isNull(raw), nil
+	case "boolean":
+		return string(raw) == "true" || string(raw) == "false", nil
+	case "object":
+		return checkWhich(raw) == eDoc, nil
+	case "array":
+		return checkWhich(raw) == eAry, nil
+	case "number":
+		var v json.Number
+		return !isNull(raw) && raw[0] != '"' && json.Unmarshal(raw, &v) == nil, nil
+	case "string":
+		_, ok := predicateString(n)
+		return ok, nil
+	}
+
+	s, ok := predicateString(n)
+	if !ok {
+		return false, nil
+	}
+
+	switch typ {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, s)
+		return err == nil, nil
+	case "date":
+		_, err = time.Parse("2006-01-02", s)
+		return err == nil, nil
+	case "time":
+		_, err = time.Parse("15:04:05Z07:00", s)
+		if err != nil {
+			_, err = time.Parse("15:04:05.999999999Z07:00", s)
+		}
+		return err == nil, nil
+	case "lang":
+		return langPattern.MatchString(s), nil
+	case "lang-range":
+		return langRangePattern.MatchString(s), nil
+	case "iri":
+		_, err = url.Parse(s)
+		return err == nil, nil
+	case "absolute-iri":
+		u, err := url.Parse(s)
+		return err == nil && u.IsAbs(), nil
+	}
+	return false, nil
+}
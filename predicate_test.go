@@ -0,0 +1,153 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPredicates(t *testing.T) {
+	doc := `{
+		"name": "John Smith",
+		"age": 24,
+		"tags": ["a", "b"],
+		"meta": {"lang": "en-US", "site": "https://example.com", "created": "2022-10-01T10:00:00Z", "code": null}
+	}`
+
+	cases := []struct {
+		patch string
+		pass  bool
+	}{
+		{`[{"op": "contains", "path": "/name", "value": "Smith"}]`, true},
+		{`[{"op": "contains", "path": "/name", "value": "smith"}]`, false},
+		{`[{"op": "contains", "path": "/name", "value": "smith", "ignore_case": true}]`, true},
+		{`[{"op": "contains", "path": "/tags", "value": "b"}]`, true},
+		{`[{"op": "contains", "path": "/tags", "value": "c"}]`, false},
+		{`[{"op": "contains", "path": "/age", "value": "2"}]`, false},
+		{`[{"op": "defined", "path": "/meta/code"}]`, true},
+		{`[{"op": "defined", "path": "/meta/none"}]`, false},
+		{`[{"op": "undefined", "path": "/meta/none"}]`, true},
+		{`[{"op": "undefined", "path": "/age"}]`, false},
+		{`[{"op": "starts", "path": "/name", "value": "John"}]`, true},
+		{`[{"op": "starts", "path": "/name", "value": "JOHN", "ignore_case": true}]`, true},
+		{`[{"op": "ends", "path": "/name", "value": "Smith"}]`, true},
+		{`[{"op": "ends", "path": "/name", "value": "John"}]`, false},
+		{`[{"op": "less", "path": "/age", "value": 25}]`, true},
+		{`[{"op": "less", "path": "/age", "value": 24}]`, false},
+		{`[{"op": "more", "path": "/age", "value": 23.5}]`, true},
+		{`[{"op": "more", "path": "/name", "value": 1}]`, false},
+		{`[{"op": "in", "path": "/age", "value": [1, 24]}]`, true},
+		{`[{"op": "in", "path": "/tags/0", "value": ["A", "B"]}]`, false},
+		{`[{"op": "in", "path": "/tags/0", "value": ["A", "B"], "ignore_case": true}]`, true},
+		{`[{"op": "matches", "path": "/name", "value": "^J\\w+ S"}]`, true},
+		{`[{"op": "matches", "path": "/name", "value": "^j"}]`, false},
+		{`[{"op": "matches", "path": "/name", "value": "^j", "ignore_case": true}]`, true},
+		{`[{"op": "type", "path": "/age", "value": "number"}]`, true},
+		{`[{"op": "type", "path": "/name", "value": "string"}]`, true},
+		{`[{"op": "type", "path": "/tags", "value": "array"}]`, true},
+		{`[{"op": "type", "path": "/meta", "value": "object"}]`, true},
+		{`[{"op": "type", "path": "", "value": "object"}]`, true},
+		{`[{"op": "type", "path": "/meta/code", "value": "null"}]`, true},
+		{`[{"op": "type", "path": "/meta/code", "value": "number"}]`, false},
+		{`[{"op": "type", "path": "/meta/code", "value": "string"}]`, false},
+		{`[{"op": "type", "path": "/meta/none", "value": "undefined"}]`, true},
+		{`[{"op": "type", "path": "/meta/lang", "value": "lang"}]`, true},
+		{`[{"op": "type", "path": "/meta/lang", "value": "lang-range"}]`, true},
+		{`[{"op": "type", "path": "/meta/site", "value": "absolute-iri"}]`, true},
+		{`[{"op": "type", "path": "/meta/lang", "value": "absolute-iri"}]`, false},
+		{`[{"op": "type", "path": "/meta/created", "value": "date-time"}]`, true},
+		{`[{"op": "type", "path": "/meta/created", "value": "date"}]`, false},
+		{`[{"op": "and", "path": "/meta", "apply": [
+			{"op": "defined", "path": "/lang"},
+			{"op": "test", "path": "/lang", "value": "EN-us", "ignore_case": true}
+		]}]`, true},
+		{`[{"op": "and", "path": "/meta", "apply": [
+			{"op": "defined", "path": "/lang"},
+			{"op": "test", "path": "/lang", "value": "EN-us"}
+		]}]`, false},
+		{`[{"op": "or", "path": "", "apply": [
+			{"op": "undefined", "path": "/age"},
+			{"op": "less", "path": "/age", "value": 30}
+		]}]`, true},
+		{`[{"op": "not", "path": "/tags", "apply": [
+			{"op": "test", "path": "/0", "value": "b"},
+			{"op": "contains", "path": "", "value": "c"}
+		]}]`, true},
+		{`[{"op": "not", "path": "/tags", "apply": [{"op": "test", "path": "/0", "value": "a"}]}]`, false},
+		{`[{"op": "contains", "path": "/name", "value": "John"}, {"op": "replace", "path": "/age", "value": 25}]`, true},
+	}
+
+	options := NewOptions()
+	for i, c := range cases {
+		_, err := applyPatch(doc, c.patch)
+		assert.NotNil(t, err, "case %d", i)
+
+		options.EnablePredicates = true
+		_, err = applyPatchWithOptions(doc, c.patch, options)
+		if c.pass {
+			assert.Nil(t, err, "case %d", i)
+		} else {
+			assert.NotNil(t, err, "case %d", i)
+		}
+	}
+
+	for i, patch := range []string{
+		`[{"op": "and", "path": "/meta", "apply": []}]`,
+		`[{"op": "type", "path": "/age", "value": "unknown"}]`,
+		`[{"op": "type", "path": "/name", "value": "unknown"}]`,
+		`[{"op": "matches", "path": "/name", "value": "("}]`,
+		`[{"op": "less", "path": "/age", "value": "a"}]`,
+		`[{"op": "in", "path": "/age", "value": 1}]`,
+		`[{"op": "or", "path": "", "apply": [{"op": "add", "path": "/a", "value": 1}]}]`,
+	} {
+		_, err := applyPatchWithOptions(doc, patch, options)
+		assert.NotNil(t, err, "case %d", i)
+	}
+
+	c := Capabilities(options)
+	assert.Contains(t, c.Extensions, "contains")
+	assert.Contains(t, c.Extensions, "not")
+}
+
+func TestUseNumber(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := `{"id":12345678901234567891,"price":0.10000000000000000001,"n":1.0}`
+	options := NewOptions()
+	options.EnablePredicates = true
+
+	patch := `[
+		{"op": "copy", "from": "/id", "path": "/ref"},
+		{"op": "move", "from": "/price", "path": "/cost"},
+		{"op": "add", "path": "/big", "value": 1e400}
+	]`
+	res, err := applyPatchWithOptions(doc, patch, options)
+	assert.Nil(err)
+	assert.Equal(`{"id":12345678901234567891,"n":1.0,"ref":12345678901234567891,"cost":0.10000000000000000001,"big":1e400}`, res)
+
+	cases := []struct {
+		patch            string
+		float, useNumber bool
+	}{
+		{`[{"op": "more", "path": "/id", "value": 12345678901234567890}]`, false, true},
+		{`[{"op": "less", "path": "/id", "value": 12345678901234567892}]`, false, true},
+		{`[{"op": "more", "path": "/price", "value": 0.1}]`, false, true},
+		{`[{"op": "less", "path": "/n", "value": 1.5}]`, true, true},
+		{`[{"op": "more", "path": "/n", "value": 1}]`, false, false},
+	}
+	for i, c := range cases {
+		options.UseNumber = false
+		_, err := applyPatchWithOptions(doc, c.patch, options)
+		assert.Equal(c.float, err == nil, "case %d", i)
+
+		options.UseNumber = true
+		_, err = applyPatchWithOptions(doc, c.patch, options)
+		assert.Equal(c.useNumber, err == nil, "case %d", i)
+	}
+
+	_, err = applyPatchWithOptions(doc, `[{"op": "less", "path": "/id", "value": "a"}]`, options)
+	assert.NotNil(err)
+}
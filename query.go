@@ -5,7 +5,9 @@ package jsonpatch
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -16,23 +18,171 @@ func GetValueByPath(doc []byte, path string) ([]byte, error) {
 }
 
 // GetChild returns the child node of a given path in the node.
+// If Options.SupportNegativeIndices is true, negative array indices count from the end of arrays,
+// and the "-" token addresses the last element of an array.
 func (n *Node) GetChild(path string, options *Options) (*Node, error) {
+	if options == nil {
+		options = NewOptions()
+	}
+	if err := options.checkDocument(n); err != nil {
+		return nil, &PathError{Op: "get", Path: path, Index: -1, Err: err}
+	}
 	pd, err := n.intoContainer()
 	switch {
 	case err != nil:
-		return nil, fmt.Errorf("unexpected node %q, %v", n.String(), err)
+		return nil, &PathError{Op: "get", Path: path, Index: -1,
+			Err: fmt.Errorf("unexpected node %q, %w", n.String(), err)}
 	case pd == nil:
-		return nil, fmt.Errorf("unexpected node %q", n.String())
+		return nil, &PathError{Op: "get", Path: path, Index: -1,
+			Err: fmt.Errorf("unexpected node %q", n.String())}
+	}
+
+	con, key := n.lookup(pd, path, options)
+	if con == nil {
+		return nil, &PathError{Op: "get", Path: path, Index: -1,
+			Err: fmt.Errorf("unable to get child node by path %q, %w", path, ErrMissing)}
+	}
+	if ary, ok := con.(*partialArray); ok && options.SupportSliceIndices && strings.Contains(key, ":") {
+		child, err := ary.slice(key, options)
+		if err != nil {
+			return nil, &PathError{Op: "get", Path: path, Index: -1, Err: err}
+		}
+		return child, nil
+	}
+	child, err := con.get(key, options)
+	if err != nil {
+		return nil, &PathError{Op: "get", Path: path, Index: -1, Err: err}
+	}
+	if _, ok := con.(*partialDoc); ok && options.NullIsAbsent && child.isNull() {
+		return nil, &PathError{Op: "get", Path: path, Index: -1,
+			Err: fmt.Errorf("unable to get null key %q, %w", key, ErrMissing)}
+	}
+	n.shareLookups(child)
+	return child, nil
+}
+
+// slice returns a new array node of the copies of the elements selected by the slice key "start:end".
+func (d *partialArray) slice(key string, options *Options) (*Node, error) {
+	i := strings.IndexByte(key, ':')
+	sz := len(*d)
+	bound := func(s string, def int) (int, error) {
+		if s == "" {
+			return def, nil
+		}
+		idx, err := strconv.Atoi(s)
+		if err != nil || s[0] == '+' {
+			return 0, fmt.Errorf("unable to access invalid slice %s, %w", key, ErrInvalidIndex)
+		}
+		if idx < 0 {
+			if !options.SupportNegativeIndices {
+				return 0, fmt.Errorf("unable to access invalid slice %s, %w", key, ErrInvalidIndex)
+			}
+			idx += sz
+		}
+		if idx < 0 {
+			return 0, nil
+		}
+		if idx > sz {
+			return sz, nil
+		}
+		return idx, nil
+	}
+
+	start, err := bound(key[:i], 0)
+	if err != nil {
+		return nil, err
+	}
+	end, err := bound(key[i+1:], sz)
+	if err != nil {
+		return nil, err
+	}
+	if end < start {
+		end = start
 	}
+	ary := (*d)[start:end].clone()
+	if ary == nil {
+		ary = partialArray{}
+	}
+	return &Node{ary: ary, which: eAry}, nil
+}
+
+// lastIndexPath replaces the "-" tokens of the arrays in the path with the index of their last elements
+// if Options.SupportNegativeIndices is true, so that the read paths can address the last elements.
+func lastIndexPath(pd container, path string, options *Options) string {
+	if !options.SupportNegativeIndices || !strings.Contains(path, "/-") {
+		return path
+	}
+
+	parts := strings.Split(path, "/")
+	doc := pd
+	for i := 1; i < len(parts); i++ {
+		if ary, ok := doc.(*partialArray); ok && parts[i] == "-" && len(*ary) > 0 {
+			parts[i] = strconv.Itoa(len(*ary) - 1)
+		}
 
+		if i == len(parts)-1 {
+			break
+		}
+		next, err := doc.get(decodePatchKey(parts[i]), options)
+		if err != nil {
+			break
+		}
+		if doc, _ = next.intoContainer(); doc == nil {
+			break
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// Resolve resolves the path in the node with the same rules as the operations of a patch.
+// It returns the parent node of the path, the unescaped key of the path in the parent,
+// and the target node at the path. The target is nil if the parent exists but has no value at the key,
+// as the target of an "add" operation. For the empty path, the parent is nil and the target is the node.
+func Resolve(n *Node, path string, options *Options) (parent *Node, key string, target *Node, err error) {
+	if path == "" {
+		return nil, "", n, nil
+	}
+
+	parts := strings.Split(path, "/")
+	if parts[0] != "" {
+		return nil, "", nil, &PathError{Op: "resolve", Path: path, Index: -1,
+			Err: fmt.Errorf("invalid JSON pointer %q", path)}
+	}
 	if options == nil {
 		options = NewOptions()
 	}
-	con, key := findObject(&pd, path, options)
-	if con == nil {
-		return nil, fmt.Errorf("unable to get child node by path %q, %v", path, ErrMissing)
+	if err := options.checkDocument(n); err != nil {
+		return nil, "", nil, &PathError{Op: "resolve", Path: path, Index: -1, Err: err}
 	}
-	return con.get(key, options)
+
+	parent = n
+	last := len(parts) - 1
+	for i, part := range parts[1:] {
+		pd, err := parent.intoContainer()
+		if pd == nil {
+			if err == nil {
+				err = ErrInvalid
+			}
+			return nil, "", nil, &PathError{Op: "resolve", Path: path, Index: -1,
+				Err: fmt.Errorf("unable to resolve %q, %w", "/"+strings.Join(parts[1:i+1], "/"), err)}
+		}
+
+		key = decodePatchKey(part)
+		next, err := pd.get(key, options)
+		if i+1 == last {
+			if err != nil {
+				next = nil
+			}
+			n.shareLookups(parent)
+			n.shareLookups(next)
+			return parent, key, next, nil
+		}
+		if err != nil {
+			return nil, "", nil, &PathError{Op: "resolve", Path: path, Index: -1, Err: err}
+		}
+		parent = next
+	}
+	return
 }
 
 // GetValue returns the child node of a given path in the node.
@@ -44,8 +194,110 @@ func (n *Node) GetValue(path string, options *Options) (json.RawMessage, error)
 	return cn.MarshalJSON()
 }
 
+// Kind is the JSON type of a value.
+type Kind string
+
+// The JSON types.
+const (
+	KindNull    Kind = "null"
+	KindBoolean Kind = "boolean"
+	KindNumber  Kind = "number"
+	KindString  Kind = "string"
+	KindObject  Kind = "object"
+	KindArray   Kind = "array"
+)
+
+// Has indicates whether the node has a value at the given path, a null value counts.
+// The empty path refers to the node itself.
+func (n *Node) Has(path string, options *Options) bool {
+	if path == "" {
+		return n != nil
+	}
+	_, err := n.GetChild(path, options)
+	return err == nil
+}
+
+// TypeOf returns the JSON type of the value at the given path in the node,
+// without decoding the value. The empty path refers to the node itself.
+func (n *Node) TypeOf(path string) (Kind, error) {
+	if path == "" {
+		return n.kind(), nil
+	}
+	cn, err := n.GetChild(path, nil)
+	if err != nil {
+		return "", err
+	}
+	return cn.kind(), nil
+}
+
+func (n *Node) kind() Kind {
+	switch {
+	case n == nil:
+		return KindNull
+	case n.which == eDoc:
+		return KindObject
+	case n.which == eAry:
+		return KindArray
+	case n.raw == nil:
+		return KindNull
+	}
+	return valueType(*n.raw)
+}
+
+// GetChildOr returns the child node of a given path in the node,
+// or def if the path is missing or the array index is out of range.
+func (n *Node) GetChildOr(path string, def *Node, options *Options) (*Node, error) {
+	cn, err := n.GetChild(path, options)
+	if err != nil {
+		if isMissing(err) {
+			return def, nil
+		}
+		return nil, err
+	}
+	return cn, nil
+}
+
+// GetValueOr returns the value of a given path in the node,
+// or def if the path is missing or the array index is out of range.
+func (n *Node) GetValueOr(path string, def json.RawMessage, options *Options) (json.RawMessage, error) {
+	cn, err := n.GetChild(path, options)
+	if err != nil {
+		if isMissing(err) {
+			return def, nil
+		}
+		return nil, err
+	}
+	return cn.MarshalJSON()
+}
+
+func isMissing(err error) bool {
+	return errors.Is(err, ErrMissing) || errors.Is(err, ErrInvalidIndex)
+}
+
+// Remove removes the child node of a given path in the node, and returns the removed node.
+// It returns nil without error if the path is missing and Options.AllowMissingPathOnRemove is true.
+func (n *Node) Remove(path string, options *Options) (*Node, error) {
+	if options == nil {
+		options = NewOptions()
+	}
+
+	child, err := n.GetChild(path, options)
+	if err != nil {
+		if options.AllowMissingPathOnRemove && errors.Is(err, ErrMissing) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if err = n.Patch(Patch{NewRemoveOperation(path)}, options); err != nil {
+		return nil, err
+	}
+	// the removed child is detached from the node.
+	child.lookups = nil
+	return child, nil
+}
+
 // FindChildren returns the children nodes that pass the given test operations in the node.
-func (n *Node) FindChildren(tests []*PV, options *Options) (result []*PV, err error) {
+func (n *Node) FindChildren(tests []*PV, options *Options) (result PVs, err error) {
 	if len(tests) == 0 {
 		return
 	}
@@ -54,38 +306,303 @@ func (n *Node) FindChildren(tests []*PV, options *Options) (result []*PV, err er
 		options = NewOptions()
 	}
 
-	subpaths, err := toSubpaths(tests[0].Path)
+	qs, err := compileQueries(tests)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := findChildNodes(n, NewNode(tests[0].Value), "", subpaths, options)
+	res, err := n.findChildren(qs, options)
 	if err != nil {
 		return nil, err
 	}
-	for _, test := range tests[1:] {
-		subpaths, err := toSubpaths(test.Path)
+	for _, r := range res {
+		result = append(result, r.pv)
+	}
+	return
+}
+
+// MatchedNode represents a child node that passes the test operations of FindChildNodes, with its path.
+type MatchedNode struct {
+	Path string
+	Node *Node
+}
+
+// FindChildNodes returns the children nodes that pass the given test operations in the node, as FindChildren,
+// but with the parsed nodes instead of their raw encoded JSON values. The nodes are the children in the node,
+// so they can be navigated and patched without being parsed again, and patching them changes the node.
+// Use PatchAt with their paths to notify the watchers of the node.
+func (n *Node) FindChildNodes(tests []*PV, options *Options) ([]*MatchedNode, error) {
+	if len(tests) == 0 {
+		return nil, nil
+	}
+
+	if options == nil {
+		options = NewOptions()
+	}
+
+	qs, err := compileQueries(tests)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := n.findChildren(qs, options)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*MatchedNode, 0, len(res))
+	for _, r := range res {
+		n.shareLookups(r.node)
+		result = append(result, &MatchedNode{r.pv.Path, r.node})
+	}
+	return result, nil
+}
+
+// FindChildrenPage returns a page of at most pageSize children nodes that pass the given test operations
+// in the node, as FindChildren, and the cursor of the next page. The children are in the order of their paths,
+// the array elements by index and the object members by name, and a page starts after the path of the cursor,
+// so the pages of a document that changes between the calls neither repeat nor miss the unchanged children,
+// except for the elements shifted in the arrays. The cursor of the first page is "", the next cursor is the path
+// of the last child of a full page ("#" for the node itself), and "" after the last page.
+func (n *Node) FindChildrenPage(tests []*PV, cursor string, pageSize int, options *Options) (PVs, string, error) {
+	if pageSize <= 0 {
+		return nil, "", fmt.Errorf("invalid page size %d, %w", pageSize, ErrInvalid)
+	}
+	var after []string
+	switch {
+	case cursor == "#":
+		after = []string{}
+	case cursor != "":
+		parts, err := toSubpaths(cursor)
 		if err != nil {
-			return nil, err
+			return nil, "", fmt.Errorf("invalid cursor %q, %w", cursor, ErrInvalid)
 		}
-		rs := make([]*nodePV, 0, len(res))
-		v := NewNode(test.Value)
-		for _, r := range res {
-			if assertObject(r.node, subpaths, v, options) {
-				rs = append(rs, r)
+		after = make([]string, len(parts))
+		for i, part := range parts {
+			after[i] = decodePatchKey(part)
+		}
+	}
+	if len(tests) == 0 {
+		return nil, "", nil
+	}
+
+	if options == nil {
+		options = NewOptions()
+	}
+
+	qs, err := compileQueries(tests)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := options.checkDocument(n); err != nil {
+		return nil, "", err
+	}
+
+	p := &childrenPage{qs: qs, size: pageSize, lookups: &lookupCache{}, options: options}
+	if err := p.visit(n, "", after); err != nil {
+		return nil, "", err
+	}
+	if len(p.result) < pageSize {
+		return p.result, "", nil
+	}
+	next := p.result[len(p.result)-1].Path
+	if next == "" {
+		next = "#"
+	}
+	return p.result, next, nil
+}
+
+// childrenPage collects a page of FindChildrenPage.
+type childrenPage struct {
+	qs      []*query
+	size    int
+	lookups *lookupCache
+	options *Options
+	result  PVs
+}
+
+// visit visits the node and its descendants in the order of their paths, after is the remaining keys of
+// the cursor from the node, nil if the node is after the cursor.
+func (p *childrenPage) visit(node *Node, path string, after []string) error {
+	if _, err := node.intoContainer(); err != nil {
+		return nil
+	}
+
+	if after == nil && p.matches(node) {
+		raw, err := node.rawJSON()
+		if err != nil {
+			return err
+		}
+		p.result = append(p.result, &PV{Path: path, Value: raw})
+	}
+
+	var cursor string
+	if len(after) > 0 {
+		cursor = after[0]
+	}
+	child := func(k string, c *Node, cmp int) error {
+		switch {
+		case c == nil || len(p.result) >= p.size:
+			return nil
+		case len(after) == 0 || cmp > 0:
+			return p.visit(c, path+"/"+encodePatchKey(k), nil)
+		case cmp == 0:
+			return p.visit(c, path+"/"+encodePatchKey(k), after[1:])
+		}
+		return nil
+	}
+
+	if node.which == eAry {
+		idx, err := strconv.Atoi(cursor)
+		if err != nil {
+			idx = -1
+		}
+		for i, c := range node.ary {
+			cmp := 1
+			if i < idx {
+				cmp = -1
+			} else if i == idx {
+				cmp = 0
+			}
+			if err := child(strconv.Itoa(i), c, cmp); err != nil {
+				return err
 			}
 		}
+		return nil
+	}
 
-		res = rs
-		if len(res) == 0 {
-			break
+	keys := make([]string, len(node.doc.keys))
+	copy(keys, node.doc.keys)
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := child(k, node.doc.obj[k], strings.Compare(k, cursor)); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	for _, r := range res {
-		result = append(result, r.pv)
+func (p *childrenPage) matches(node *Node) bool {
+	return assertObjects(node, p.qs, p.lookups, p.options)
+}
+
+func (n *Node) findChildren(qs []*query, options *Options) ([]*nodePV, error) {
+	if err := options.checkDocument(n); err != nil {
+		return nil, err
 	}
-	return
+	return findChildNodes(n, qs, "", &lookupCache{}, options)
+}
+
+// DocPV represents a node with a path and a raw encoded JSON value in the document of DocID.
+type DocPV struct {
+	DocID string          `json:"doc"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// FindInDocs returns the children nodes that pass the given test operations in the documents,
+// addressed by the document IDs and the paths in the documents. The result is ordered by
+// the document IDs. The test operations are compiled once and shared across the documents,
+// the documents are searched concurrently if Options.QueryParallelism is greater than 1.
+func FindInDocs(docs map[string][]byte, tests []*PV, options *Options) ([]*DocPV, error) {
+	if len(tests) == 0 || len(docs) == 0 {
+		return nil, nil
+	}
+
+	if options == nil {
+		options = NewOptions()
+	}
+
+	qs, err := compileQueries(tests)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(docs))
+	for id := range docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	results := make([][]*nodePV, len(ids))
+	errs := make([]error, len(ids))
+	search := func(i int) {
+		results[i], errs[i] = NewNode(docs[ids[i]]).findChildren(qs, options)
+	}
+
+	parallel(len(ids), options.QueryParallelism, search)
+
+	var result []*DocPV
+	for i, id := range ids {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("unable to search document %q, %w", id, errs[i])
+		}
+		for _, r := range results[i] {
+			result = append(result, &DocPV{id, r.pv.Path, r.pv.Value})
+		}
+	}
+	return result, nil
+}
+
+// RemoveChildren removes the children nodes that pass the given test operations in the node,
+// and returns the removed children. The node itself is not a child, it is skipped if it passes the tests.
+// The children are removed as PatchAtomic, so the node is never left partially changed.
+func (n *Node) RemoveChildren(tests []*PV, options *Options) (PVs, error) {
+	result, err := n.FindChildren(tests, options)
+	if result = childrenOnly(result); err != nil || len(result) == 0 {
+		return nil, err
+	}
+
+	if err = n.PatchAtomic(BuildRemovePatch(result), options); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ReplaceChildren replaces the children nodes that pass the given test operations in the node
+// with the given value, and returns the replaced children. The node itself is not a child,
+// it is skipped if it passes the tests. The children are replaced as PatchAtomic.
+func (n *Node) ReplaceChildren(tests []*PV, value json.RawMessage, options *Options) (PVs, error) {
+	result, err := n.FindChildren(tests, options)
+	if result = childrenOnly(result); err != nil || len(result) == 0 {
+		return nil, err
+	}
+
+	if err = n.PatchAtomic(BuildReplacePatch(result, value), options); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// childrenOnly returns the result of FindChildren without the node itself.
+func childrenOnly(pvs PVs) PVs {
+	res := pvs[:0]
+	for _, pv := range pvs {
+		if pv.Path != "" {
+			res = append(res, pv)
+		}
+	}
+	return res
+}
+
+// BuildRemovePatch returns a patch that removes the nodes of the given FindChildren result.
+// The operations are in reverse order of the result, so that children are removed before
+// their parents and array elements are removed from the tail.
+func BuildRemovePatch(pvs PVs) Patch {
+	p := make(Patch, 0, len(pvs))
+	for i := len(pvs) - 1; i >= 0; i-- {
+		p = append(p, Operation{Op: "remove", Path: pvs[i].Path})
+	}
+	return p
+}
+
+// BuildReplacePatch returns a patch that replaces the nodes of the given FindChildren result
+// with the given value. The operations are in reverse order of the result.
+func BuildReplacePatch(pvs PVs, value json.RawMessage) Patch {
+	p := make(Patch, 0, len(pvs))
+	for i := len(pvs) - 1; i >= 0; i-- {
+		p = append(p, Operation{Op: "replace", Path: pvs[i].Path, Value: value})
+	}
+	return p
 }
 
 // PV represents a node with a path and a raw encoded JSON value.
@@ -94,9 +611,116 @@ type PV struct {
 	Value json.RawMessage `json:"value"`
 }
 
+// existsValue is the value of the tests of NewExistsPV, it is told from the same JSON by its identity.
+var existsValue = json.RawMessage(`{"$exists":true}`)
+
+// NewExistsPV returns a test of FindChildren that passes if the path exists in the child node,
+// with any value. It is encoded in JSON by PVs as {"path": path, "exists": true}.
+func NewExistsPV(path string) *PV {
+	return &PV{path, existsValue}
+}
+
+// isExists indicates if the test is created by NewExistsPV.
+func (pv *PV) isExists() bool {
+	return len(pv.Value) == len(existsValue) && &pv.Value[0] == &existsValue[0]
+}
+
+// existsPV is the JSON encoding of the tests of NewExistsPV.
+type existsPV struct {
+	Path   string `json:"path"`
+	Exists bool   `json:"exists"`
+}
+
 // PVs represents a list of PV.
 type PVs []*PV
 
+// Paths returns the paths of the list.
+func (pvs PVs) Paths() []string {
+	paths := make([]string, len(pvs))
+	for i, pv := range pvs {
+		paths[i] = pv.Path
+	}
+	return paths
+}
+
+// Values returns the values of the list.
+func (pvs PVs) Values() []json.RawMessage {
+	values := make([]json.RawMessage, len(pvs))
+	for i, pv := range pvs {
+		values[i] = pv.Value
+	}
+	return values
+}
+
+// ToMap returns the values of the list keyed by their paths, the last value of a path wins.
+func (pvs PVs) ToMap() map[string]json.RawMessage {
+	m := make(map[string]json.RawMessage, len(pvs))
+	for _, pv := range pvs {
+		m[pv.Path] = pv.Value
+	}
+	return m
+}
+
+// SortByDepth sorts the list by the depth of the paths, so that parents are before their children.
+// The order of the paths of the same depth is kept.
+func (pvs PVs) SortByDepth() {
+	sort.SliceStable(pvs, func(i, j int) bool {
+		return pathDepth(pvs[i].Path) < pathDepth(pvs[j].Path)
+	})
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+// A nil list is encoded as an empty array, and a nil value as null.
+func (pvs PVs) MarshalJSON() ([]byte, error) {
+	if pvs == nil {
+		return []byte("[]"), nil
+	}
+	list := make([]interface{}, len(pvs))
+	for i, pv := range pvs {
+		switch {
+		case pv == nil:
+			return nil, fmt.Errorf("PV %d is nil, %w", i, ErrInvalid)
+		case pv.isExists():
+			list[i] = existsPV{pv.Path, true}
+		default:
+			list[i] = pv
+		}
+	}
+	return json.Marshal(list)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// The paths must be JSON Pointers, and a missing value is decoded as null.
+// The members with "exists": true are decoded as the tests of NewExistsPV.
+func (pvs *PVs) UnmarshalJSON(data []byte) error {
+	var list []*struct {
+		PV
+		Exists bool `json:"exists"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	res := make(PVs, len(list))
+	for i, item := range list {
+		if item == nil {
+			return fmt.Errorf("PV %d is null, %w", i, ErrInvalid)
+		}
+		pv := &item.PV
+		if pv.Path != "" && pv.Path[0] != '/' {
+			return fmt.Errorf("PV %d has invalid path %q, %w", i, pv.Path, ErrInvalid)
+		}
+		switch {
+		case item.Exists:
+			pv.Value = existsValue
+		case pv.Value == nil:
+			pv.Value = json.RawMessage("null")
+		}
+		res[i] = pv
+	}
+	*pvs = res
+	return nil
+}
+
 type nodePV struct {
 	pv   *PV
 	node *Node
@@ -110,17 +734,123 @@ func toSubpaths(s string) ([]string, error) {
 	return subpaths[1:], nil
 }
 
+// compileQueries compiles the test operations of FindChildren. The values of the queries are
+// fully parsed, so that the queries can be shared by concurrent searches.
+func compileQueries(tests []*PV) ([]*query, error) {
+	qs := make([]*query, 0, len(tests))
+	for _, test := range tests {
+		subpaths, err := toSubpaths(test.Path)
+		if err != nil {
+			return nil, err
+		}
+		v := NewNode(test.Value)
+		v.parseAll()
+		qs = append(qs, &query{test.Path, subpaths, v, test.isExists(), 0})
+	}
+	shareSubpaths(qs)
+	return qs, nil
+}
+
+// query is a compiled test operation of FindChildren.
+type query struct {
+	path     string
+	subpaths []string
+	value    *Node
+	exists   bool
+	// shared is the number of the leading subpaths of the parent that other queries share,
+	// only the containers resolved by them are memoized in the lookupCache.
+	shared int
+}
+
+// shareSubpaths sets the subpaths of the parents that the queries share.
+func shareSubpaths(qs []*query) {
+	for i, q := range qs {
+		parent := q.subpaths[:len(q.subpaths)-1]
+		for j, o := range qs {
+			if i == j {
+				continue
+			}
+			k := 0
+			for k < len(parent) && k < len(o.subpaths)-1 && parent[k] == o.subpaths[k] {
+				k++
+			}
+			if k > q.shared {
+				q.shared = k
+			}
+		}
+	}
+}
+
+// lookupSubpath is a container resolved by a subpath from the candidate node of lookupCache.
+type lookupSubpath struct {
+	path string
+	doc  container
+}
+
+// lookupCache memoizes the containers resolved by subpaths from a candidate node during a query,
+// so that tests sharing subpaths don't resolve them again. Only the subpaths shared by the tests
+// are memoized, see query.shared, and they are dropped when the next candidate node is looked up.
+// A nil container is cached for a missing subpath.
+type lookupCache struct {
+	node     *Node
+	subpaths []lookupSubpath
+}
+
+// container returns the container resolved by the parts from the node,
+// path is the query path that parts are a prefix of, and the first shared parts are memoized.
+func (c *lookupCache) container(node *Node, path string, parts []string, shared int, options *Options) container {
+	if len(parts) == 0 {
+		doc, _ := node.intoContainer()
+		return doc
+	}
+
+	if c.node != node {
+		c.node = node
+		c.subpaths = c.subpaths[:0]
+	}
+
+	var key string
+	memoized := len(parts) <= shared
+	if memoized {
+		end := 0
+		for _, part := range parts {
+			end += 1 + len(part)
+		}
+		key = path[:end]
+		for _, s := range c.subpaths {
+			if s.path == key {
+				return s.doc
+			}
+		}
+	}
+
+	var doc container
+	last := len(parts) - 1
+	if parent := c.container(node, path, parts[:last], shared, options); parent != nil {
+		if next, err := parent.get(decodePatchKey(parts[last]), options); err == nil && next != nil {
+			doc, _ = next.intoContainer()
+		}
+	}
+	if memoized {
+		c.subpaths = append(c.subpaths, lookupSubpath{key, doc})
+	}
+	return doc
+}
+
 func findChildNodes(
-	node, value *Node, parentpath string, subpaths []string, options *Options,
+	node *Node, qs []*query, parentpath string, lookups *lookupCache, options *Options,
 ) (res []*nodePV, err error) {
 
-	node.intoContainer()
-	if node.which == eOther {
+	if _, e := node.intoContainer(); e != nil {
 		return
 	}
 
-	if assertObject(node, subpaths, value, options) {
-		res = append(res, &nodePV{&PV{parentpath, *node.raw}, node})
+	if assertObjects(node, qs, lookups, options) {
+		raw, e := node.rawJSON()
+		if e != nil {
+			return nil, e
+		}
+		res = append(res, &nodePV{&PV{parentpath, raw}, node})
 	}
 
 	if node.which == eAry {
@@ -129,7 +859,7 @@ func findChildNodes(
 				continue
 			}
 			r, e := findChildNodes(
-				n, value, parentpath+"/"+strconv.Itoa(i), subpaths, options)
+				n, qs, parentpath+"/"+strconv.Itoa(i), lookups, options)
 			if e != nil {
 				return nil, e
 			}
@@ -143,7 +873,7 @@ func findChildNodes(
 				continue
 			}
 			r, e := findChildNodes(
-				n, value, parentpath+"/"+encodePatchKey(k), subpaths, options)
+				n, qs, parentpath+"/"+encodePatchKey(k), lookups, options)
 			if e != nil {
 				return nil, e
 			}
@@ -155,33 +885,51 @@ func findChildNodes(
 	return
 }
 
-func assertObject(node *Node, subpaths []string, value *Node, options *Options) bool {
-	last := len(subpaths) - 1
-	doc, _ := node.intoContainer()
-	if doc == nil {
-		return false
+// parseAll parses the node and its descendants.
+func (n *Node) parseAll() {
+	if _, err := n.intoContainer(); err != nil {
+		return
 	}
-
-	for i, part := range subpaths {
-		next, ok := doc.get(decodePatchKey(part), options)
-		if ok != nil {
-			return false
+	switch n.which {
+	case eDoc:
+		for _, v := range n.doc.obj {
+			v.parseAll()
 		}
-		if i == last {
-			if next == nil {
-				return value.isNull()
-			}
-			return next.Equal(value)
+	case eAry:
+		for _, v := range n.ary {
+			v.parseAll()
 		}
+	}
+}
 
-		if next == nil {
+// assertObjects asserts the tests on the node one by one, so that they share the lookups of the node.
+func assertObjects(node *Node, qs []*query, lookups *lookupCache, options *Options) bool {
+	for _, q := range qs {
+		if !assertObject(node, q, lookups, options) {
 			return false
 		}
+	}
+	return true
+}
 
-		doc, _ = next.intoContainer()
-		if doc == nil {
-			return false
-		}
+func assertObject(node *Node, q *query, lookups *lookupCache, options *Options) bool {
+	last := len(q.subpaths) - 1
+	doc := lookups.container(node, q.path, q.subpaths[:last], q.shared, options)
+	if doc == nil {
+		return false
+	}
+
+	next, err := doc.get(decodePatchKey(q.subpaths[last]), options)
+	if _, ok := doc.(*partialDoc); ok && options != nil && options.NullIsAbsent && (err != nil || next.isNull()) {
+		return !q.exists && q.value.isNull()
+	}
+	switch {
+	case err != nil:
+		return false
+	case q.exists:
+		return true
+	case next == nil:
+		return q.value.isNull()
 	}
-	return false
+	return next.Equal(q.value)
 }
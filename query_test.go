@@ -4,8 +4,14 @@
 package jsonpatch
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 type GetValueCase struct {
@@ -90,6 +96,36 @@ var GetValueCases = []GetValueCase{
 		nil,
 		`unable to get nonexistent key "fooo", missing value`,
 	},
+	{
+		`{ "foo": [ "a", 2, "c" ] }`,
+		"/foo/-1",
+		[]byte(`"c"`),
+		"",
+	},
+	{
+		`{ "foo": [ "a", 2, "c" ] }`,
+		"/foo/-3",
+		[]byte(`"a"`),
+		"",
+	},
+	{
+		`{ "foo": [ "a", 2, "c" ] }`,
+		"/foo/-4",
+		nil,
+		"unable to access invalid index -4, invalid index referenced",
+	},
+	{
+		`{ "foo": [ "a", 2, {"-": [1, 2]} ] }`,
+		"/foo/-/-/-",
+		[]byte(`2`),
+		"",
+	},
+	{
+		`{ "foo": [ ] }`,
+		"/foo/-",
+		nil,
+		"invalid index referenced",
+	},
 }
 
 func TestGetValueByPath(t *testing.T) {
@@ -110,6 +146,78 @@ func TestGetValueByPath(t *testing.T) {
 	}
 }
 
+func TestGetChildLastIndex(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(`{"items":[{"id":1},{"id":2}]}`))
+	v, err := node.GetValue("/items/-/id", nil)
+	assert.Nil(err)
+	assert.Equal(`2`, string(v))
+
+	options := NewOptions()
+	options.SupportNegativeIndices = false
+	_, err = node.GetValue("/items/-/id", options)
+	assert.ErrorIs(err, ErrMissing)
+	_, err = node.GetValue("/items/-1", options)
+	assert.ErrorIs(err, ErrInvalidIndex)
+
+	// the patch operations keep the RFC 6902 meaning of "-".
+	_, err = node.GetChild("/items/-", nil)
+	assert.Nil(err)
+	assert.NotNil(node.Patch(Patch{NewTestOperation("/items/-", []byte(`{"id":2}`))}, nil))
+}
+
+func TestGetSlice(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(`{"items":[0, 1, {"id":2}, 3, null],"a:b":1}`))
+	options := NewOptions()
+	options.SupportSliceIndices = true
+
+	for i, c := range []struct {
+		path, value string
+	}{
+		{"/items/1:4", `[1,{"id":2},3]`},
+		{"/items/:2", `[0,1]`},
+		{"/items/3:", `[3,null]`},
+		{"/items/:", `[0,1,{"id":2},3,null]`},
+		{"/items/-2:", `[3,null]`},
+		{"/items/:-3", `[0,1]`},
+		{"/items/2:100", `[{"id":2},3,null]`},
+		{"/items/-100:1", `[0]`},
+		{"/items/4:2", `[]`},
+		{"/items/5:", `[]`},
+		{"/a:b", `1`},
+	} {
+		v, err := node.GetValue(c.path, options)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.value, string(v), "case %d", i)
+	}
+
+	for i, path := range []string{"/items/1:a", "/items/1:2:3", "/items/+1:2", "/items/1:4/0"} {
+		_, err := node.GetValue(path, options)
+		assert.NotNil(err, "case %d", i)
+	}
+
+	// the slice is a copy of the elements.
+	child, err := node.GetChild("/items/2:3", options)
+	assert.Nil(err)
+	assert.Nil(child.Patch(Patch{NewReplaceOperation("/0/id", []byte(`3`))}, nil))
+	assert.Equal(`[{"id":3}]`, mustJSONString(child))
+	v, err := node.GetValue("/items/2", options)
+	assert.Nil(err)
+	assert.Equal(`{"id":2}`, string(v))
+
+	options.SupportNegativeIndices = false
+	_, err = node.GetValue("/items/-2:", options)
+	assert.ErrorIs(err, ErrInvalidIndex)
+
+	options.SupportSliceIndices = false
+	_, err = node.GetValue("/items/1:4", options)
+	assert.NotNil(err)
+	assert.False(node.Has("/items/1:4", nil))
+}
+
 type FindChildrenCase struct {
 	doc    []byte
 	tests  []*PV
@@ -381,3 +489,578 @@ func TestFindChildren(t *testing.T) {
 		}
 	}
 }
+
+func TestRemoveAndReplaceChildren(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"items": [
+		{"id": 1, "status": "done"},
+		{"id": 2, "status": "todo", "sub": [{"id": 3, "status": "done"}]},
+		{"id": 4, "status": "done"}
+	]}`)
+	tests := PVs{{"/status", []byte(`"done"`)}}
+
+	node := NewNode(doc)
+	res, err := node.RemoveChildren(tests, nil)
+	assert.Nil(err)
+	assert.Equal(3, len(res))
+	assert.Equal(`{"items":[{"id":2,"status":"todo","sub":[]}]}`, mustJSONString(node))
+
+	res, err = node.RemoveChildren(tests, nil)
+	assert.Nil(err)
+	assert.Equal(0, len(res))
+
+	node = NewNode(doc)
+	res, err = node.ReplaceChildren(tests, []byte(`null`), nil)
+	assert.Nil(err)
+	assert.Equal(3, len(res))
+	assert.Equal(`{"items":[null,{"id":2,"status":"todo","sub":[null]},null]}`, mustJSONString(node))
+
+	node = NewNode(doc)
+	res, err = node.ReplaceChildren(PVs{{"/id", []byte(`2`)}}, []byte(`{"id":2}`), nil)
+	assert.Nil(err)
+	assert.Equal(1, len(res))
+	assert.Equal("/items/1", res[0].Path)
+	assert.Equal(`{"items":[{"id":1,"status":"done"},{"id":2},{"id":4,"status":"done"}]}`, mustJSONString(node))
+
+	_, err = NewNode(doc).RemoveChildren(PVs{{"status", nil}}, nil)
+	assert.NotNil(err)
+
+	// the node itself is not a child.
+	doc = []byte(`{"status":"done","items":[{"status":"done"},{"status":"x"}]}`)
+	node = NewNode(doc)
+	res, err = node.RemoveChildren(tests, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"/items/0"}, res.Paths())
+	assert.Equal(`{"status":"done","items":[{"status":"x"}]}`, mustJSONString(node))
+
+	node = NewNode(doc)
+	res, err = node.ReplaceChildren(tests, []byte(`1`), nil)
+	assert.Nil(err)
+	assert.Equal([]string{"/items/0"}, res.Paths())
+	assert.Equal(`{"status":"done","items":[1,{"status":"x"}]}`, mustJSONString(node))
+
+	// the node is not changed if a child can't be removed.
+	node = NewNode([]byte(`{"items":[{"status":"done"},{"status":"done"}]}`))
+	options := NewOptions()
+	options.BeforeApply = func(op Operation, c Change) error {
+		if op.Path == "/items/0" {
+			return ErrInvalid
+		}
+		return nil
+	}
+	_, err = node.RemoveChildren(tests, options)
+	assert.ErrorIs(err, ErrInvalid)
+	assert.Equal(`{"items":[{"status":"done"},{"status":"done"}]}`, mustJSONString(node))
+}
+
+func TestBuildPatchFromPVs(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"items": [{"id": 1, "status": "done"}, {"id": 2, "status": "todo"}, {"id": 3, "status": "done"}]}`)
+	node := NewNode(doc)
+	res, err := node.FindChildren(PVs{{"/status", []byte(`"done"`)}}, nil)
+	assert.Nil(err)
+
+	p := BuildRemovePatch(res)
+	assert.Equal(`[{"op":"remove","path":"/items/2"},{"op":"remove","path":"/items/0"}]`, mustJSONString(p))
+	out, err := p.Apply(doc)
+	assert.Nil(err)
+	assert.Equal(`{"items":[{"id":2,"status":"todo"}]}`, string(out))
+
+	p = BuildReplacePatch(res, []byte(`{}`))
+	assert.Equal(`[{"op":"replace","path":"/items/2","value":{}},{"op":"replace","path":"/items/0","value":{}}]`,
+		mustJSONString(p))
+	out, err = p.Apply(doc)
+	assert.Nil(err)
+	assert.Equal(`{"items":[{},{"id":2,"status":"todo"},{}]}`, string(out))
+
+	assert.Equal(Patch{}, BuildRemovePatch(nil))
+	assert.Equal(Patch{}, BuildReplacePatch(nil, nil))
+}
+
+func TestFindChildrenPage(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"status": "done", "items": [
+		{"id": 1, "status": "done"},
+		{"id": 2, "status": "todo", "sub": [{"id": 3, "status": "done"}]},
+		{"id": 4, "status": "done"}
+	], "b": {"status": "done"}, "a": [{"status": "done"}]}`)
+	node := NewNode(doc)
+	tests := PVs{{Path: "/status", Value: []byte(`"done"`)}}
+
+	res, next, err := node.FindChildrenPage(tests, "", 2, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"", "/a/0"}, res.Paths())
+	assert.Equal("/a/0", next)
+
+	var paths []string
+	cursor := ""
+	for {
+		res, next, err := node.FindChildrenPage(tests, cursor, 1, nil)
+		assert.Nil(err)
+		paths = append(paths, res.Paths()...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal([]string{"", "/a/0", "/b", "/items/0", "/items/1/sub/0", "/items/2"}, paths)
+
+	res, next, err = node.FindChildrenPage(tests, "", 100, nil)
+	assert.Nil(err)
+	assert.Equal(paths, res.Paths())
+	assert.Equal("", next)
+
+	res, next, err = node.FindChildrenPage(tests, "#", 2, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"/a/0", "/b"}, res.Paths())
+	assert.Equal("/b", next)
+	assert.Equal(`{"status": "done"}`, string(res[1].Value))
+
+	// the document changes between the pages.
+	assert.Nil(node.Patch(Patch{
+		NewRemoveOperation("/b"),
+		NewAddOperation("/c", []byte(`{"status": "done"}`)),
+		NewAddOperation("/a/-", []byte(`{"status": "done"}`)),
+	}, nil))
+	res, next, err = node.FindChildrenPage(tests, "/b", 2, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"/c", "/items/0"}, res.Paths())
+	assert.Equal("/items/0", next)
+	res, next, err = node.FindChildrenPage(tests, "/items/1/sub/5", 2, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"/items/2"}, res.Paths())
+	assert.Equal("", next)
+
+	res, next, err = node.FindChildrenPage(nil, "", 2, nil)
+	assert.Nil(err)
+	assert.Equal(0, len(res))
+	assert.Equal("", next)
+
+	_, _, err = node.FindChildrenPage(tests, "", 0, nil)
+	assert.ErrorIs(err, ErrInvalid)
+	_, _, err = node.FindChildrenPage(tests, "a", 1, nil)
+	assert.ErrorIs(err, ErrInvalid)
+}
+
+func TestFindChildrenExists(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"items": [
+		{"metadata": {"labels": {"app": "web"}}},
+		{"metadata": {"labels": {"app": null}}},
+		{"metadata": {"labels": {"tier": "db"}}},
+		{"metadata": {"labels": {"app": {"name": "api"}}}}
+	]}`)
+	node := NewNode(doc)
+	res, err := node.FindChildren(PVs{NewExistsPV("/metadata/labels/app")}, nil)
+	assert.Nil(err)
+	assert.ElementsMatch([]string{"/items/0", "/items/1", "/items/3"}, res.Paths())
+
+	res, err = node.FindChildren(PVs{{"/metadata/labels/app", nil}}, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"/items/1"}, res.Paths())
+
+	// the value of NewExistsPV in a document is matched as a value.
+	res, err = node.FindChildren(PVs{{"/metadata/labels/app", []byte(`{"$exists":true}`)}}, nil)
+	assert.Nil(err)
+	assert.Equal(0, len(res))
+
+	res, err = node.FindChildren(PVs{
+		NewExistsPV("/metadata/labels/app"),
+		{"/metadata/labels/app/name", []byte(`"api"`)},
+	}, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"/items/3"}, res.Paths())
+
+	var tests PVs
+	assert.Nil(json.Unmarshal([]byte(`[{"path":"/labels/tier","exists":true},{"path":"/labels/app"}]`), &tests))
+	assert.Equal(PVs{NewExistsPV("/labels/tier"), {"/labels/app", []byte(`null`)}}, tests)
+	data, err := json.Marshal(tests)
+	assert.Nil(err)
+	assert.Equal(`[{"path":"/labels/tier","exists":true},{"path":"/labels/app","value":null}]`, string(data))
+	res, err = node.FindChildren(tests[:1], nil)
+	assert.Nil(err)
+	assert.Equal([]string{"/items/2/metadata"}, res.Paths())
+}
+
+func TestFindChildNodes(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"items": [{"id": 1, "status": "done", "tags": ["a"]}, {"id": 2, "status": "todo"}]}`)
+	node := NewNode(doc)
+	res, err := node.FindChildNodes(PVs{{"/status", []byte(`"done"`)}}, nil)
+	assert.Nil(err)
+	assert.Equal(1, len(res))
+	assert.Equal("/items/0", res[0].Path)
+
+	tags, err := res[0].Node.GetChild("/tags", nil)
+	assert.Nil(err)
+	assert.Equal(`["a"]`, mustJSONString(tags))
+	assert.Nil(res[0].Node.Patch(Patch{
+		NewReplaceOperation("/status", []byte(`"archived"`)),
+		NewAddOperation("/tags/-", []byte(`"b"`)),
+	}, nil))
+	data, err := node.MarshalJSON()
+	assert.Nil(err)
+	assert.Equal(`{"items":[{"id":1,"status":"archived","tags":["a","b"]},{"id":2,"status":"todo"}]}`, string(data))
+
+	res, err = node.FindChildNodes(PVs{{"/status", []byte(`"done"`)}}, nil)
+	assert.Nil(err)
+	assert.Equal([]*MatchedNode{}, res)
+
+	res, err = node.FindChildNodes(nil, nil)
+	assert.Nil(err)
+	assert.Nil(res)
+
+	_, err = node.FindChildNodes(PVs{{"status", []byte(`"done"`)}}, nil)
+	assert.NotNil(err)
+}
+
+func TestPVs(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"items": [{"id": 1, "status": "done"}, {"id": 2, "sub": {"status": "done"}}]}`)
+	res, err := NewNode(doc).FindChildren(PVs{{"/status", []byte(`"done"`)}}, nil)
+	assert.Nil(err)
+	res = append(res, &PV{"", []byte(`1`)}, &PV{"/items/0", []byte(`2`)})
+	assert.Equal(4, len(res))
+
+	res.SortByDepth()
+	assert.Equal([]string{"", "/items/0", "/items/0", "/items/1/sub"}, res.Paths())
+	assert.Equal([]json.RawMessage{[]byte(`1`), []byte(`{"id": 1, "status": "done"}`), []byte(`2`),
+		[]byte(`{"status": "done"}`)}, res.Values())
+	assert.Equal(map[string]json.RawMessage{
+		"":             []byte(`1`),
+		"/items/0":     []byte(`2`),
+		"/items/1/sub": []byte(`{"status": "done"}`),
+	}, res.ToMap())
+
+	data, err := json.Marshal(res[:2])
+	assert.Nil(err)
+	assert.Equal(`[{"path":"","value":1},{"path":"/items/0","value":{"id":1,"status":"done"}}]`, string(data))
+
+	var pvs PVs
+	data, err = json.Marshal(pvs)
+	assert.Nil(err)
+	assert.Equal(`[]`, string(data))
+	assert.Equal([]string{}, pvs.Paths())
+	assert.Equal(map[string]json.RawMessage{}, pvs.ToMap())
+	_, err = json.Marshal(PVs{nil})
+	assert.ErrorIs(err, ErrInvalid)
+	data, err = json.Marshal(PVs{{Path: "/a"}})
+	assert.Nil(err)
+	assert.Equal(`[{"path":"/a","value":null}]`, string(data))
+
+	assert.Nil(json.Unmarshal([]byte(`[{"path":"/a"},{"path":"","value":null},{"path":"/b","value":[1]}]`), &pvs))
+	assert.Equal(PVs{{"/a", []byte(`null`)}, {"", []byte(`null`)}, {"/b", []byte(`[1]`)}}, pvs)
+	data, err = json.Marshal(pvs)
+	assert.Nil(err)
+	assert.Equal(`[{"path":"/a","value":null},{"path":"","value":null},{"path":"/b","value":[1]}]`, string(data))
+
+	for i, s := range []string{`{}`, `[null]`, `[{"path":"a"}]`, `[{"path":1}]`} {
+		assert.NotNil(json.Unmarshal([]byte(s), &pvs), "case %d", i)
+	}
+	assert.ErrorIs(json.Unmarshal([]byte(`[{"path":"a"}]`), &pvs), ErrInvalid)
+}
+
+func TestFindChildrenLookupCache(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(`{"items": [
+		{"kind": "pod", "meta": {"labels": {"app": "web", "tier": "1"}}},
+		{"kind": "pod", "meta": {"labels": {"app": "db", "tier": "1"}}},
+		{"kind": "pod", "meta": 1}
+	]}`))
+	res, err := node.FindChildren([]*PV{
+		{"/kind", []byte(`"pod"`)},
+		{"/meta/labels/tier", []byte(`"1"`)},
+		{"/meta/labels/app", []byte(`"web"`)},
+	}, nil)
+	assert.Nil(err)
+	assert.Equal(1, len(res))
+	assert.Equal("/items/0", res[0].Path)
+
+	item, err := node.GetChild("/items/0", nil)
+	assert.Nil(err)
+	lookups := &lookupCache{}
+	q := &query{"/meta/labels/app", []string{"meta", "labels", "app"}, NewNode([]byte(`"web"`)), false, 2}
+	assert.True(assertObject(item, q, lookups, nil))
+	assert.Equal(2, len(lookups.subpaths))
+	assert.Equal("/meta/labels", lookups.subpaths[1].path)
+	assert.NotNil(lookups.subpaths[1].doc)
+
+	q = &query{"/meta/labels/tier", []string{"meta", "labels", "tier"}, NewNode([]byte(`"1"`)), false, 2}
+	assert.True(assertObject(item, q, lookups, nil))
+	assert.Equal(2, len(lookups.subpaths))
+
+	item, err = node.GetChild("/items/2", nil)
+	assert.Nil(err)
+	assert.False(assertObject(item, q, lookups, nil))
+	// the lookups of the previous node are dropped.
+	assert.Equal(item, lookups.node)
+	assert.Equal(2, len(lookups.subpaths))
+	assert.Equal("/meta/labels", lookups.subpaths[1].path)
+	assert.Nil(lookups.subpaths[1].doc)
+
+	// only the subpaths shared by the tests are memoized.
+	qs, err := compileQueries([]*PV{
+		{"/kind", []byte(`"pod"`)},
+		{"/meta/labels/tier", []byte(`"1"`)},
+		{"/meta/labels/app", []byte(`"web"`)},
+		{"/meta/name", []byte(`"x"`)},
+	})
+	assert.Nil(err)
+	for i, shared := range []int{0, 2, 2, 1} {
+		assert.Equal(shared, qs[i].shared, "case %d", i)
+	}
+	lookups = &lookupCache{}
+	assert.True(assertObject(item, qs[0], lookups, nil))
+	assert.Equal(0, len(lookups.subpaths))
+	qs, err = compileQueries([]*PV{{"/meta/labels/app", []byte(`"web"`)}})
+	assert.Nil(err)
+	assert.Equal(0, qs[0].shared)
+	assert.False(assertObject(item, qs[0], lookups, nil))
+	assert.Equal(0, len(lookups.subpaths))
+}
+
+func BenchmarkFindChildren(b *testing.B) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"items": [`)
+	for i := 0; i < 5000; i++ {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, `{"id": %d, "spec": {"template": {"meta": {"labels": {"app": "web", "tier": "%d", "zone": "z%d"}}}}}`,
+			i, i%3, i%2)
+	}
+	buf.WriteString(`]}`)
+	node := NewNode(buf.Bytes())
+	node.parseAll()
+
+	run := func(b *testing.B, tests []*PV, memo bool) {
+		qs, err := compileQueries(tests)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if !memo {
+			for _, q := range qs {
+				q.shared = 0
+			}
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := node.findChildren(qs, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	single := []*PV{{"/spec/template/meta/labels/app", []byte(`"web"`)}}
+	shared := []*PV{
+		{"/spec/template/meta/labels/app", []byte(`"web"`)},
+		{"/spec/template/meta/labels/tier", []byte(`"1"`)},
+		{"/spec/template/meta/labels/zone", []byte(`"z1"`)},
+	}
+	b.Run("SingleTest", func(b *testing.B) { run(b, single, true) })
+	b.Run("SharedSubpaths", func(b *testing.B) { run(b, shared, true) })
+	b.Run("SharedSubpathsNoMemo", func(b *testing.B) { run(b, shared, false) })
+}
+
+func TestFindInDocs(t *testing.T) {
+	assert := assert.New(t)
+
+	docs := map[string][]byte{
+		"b": []byte(`{"items": [{"kind": "pod", "name": "b0"}, {"kind": "svc", "name": "b1"}]}`),
+		"a": []byte(`{"kind": "pod", "meta": {"tags": ["x", {"y": 1}]}}`),
+		"c": []byte(`{"kind": "svc"}`),
+		"d": []byte(`[{"kind": "pod", "meta": {"tags": ["x", {"y": 1}]}}]`),
+	}
+	tests := []*PV{
+		{"/kind", []byte(`"pod"`)},
+	}
+
+	for _, parallelism := range []int{0, 1, 2, 8} {
+		options := NewOptions()
+		options.QueryParallelism = parallelism
+		res, err := FindInDocs(docs, tests, options)
+		assert.Nil(err)
+		assert.Equal([]*DocPV{
+			{"a", "", []byte(`{"kind": "pod", "meta": {"tags": ["x", {"y": 1}]}}`)},
+			{"b", "/items/0", []byte(`{"kind": "pod", "name": "b0"}`)},
+			{"d", "/0", []byte(`{"kind": "pod", "meta": {"tags": ["x", {"y": 1}]}}`)},
+		}, res)
+
+		res, err = FindInDocs(docs, []*PV{
+			{"/kind", []byte(`"pod"`)},
+			{"/meta/tags", []byte(`["x", {"y": 1}]`)},
+		}, options)
+		assert.Nil(err)
+		assert.Equal(2, len(res))
+		assert.Equal("a", res[0].DocID)
+		assert.Equal("d", res[1].DocID)
+	}
+
+	res, err := FindInDocs(nil, tests, nil)
+	assert.Nil(err)
+	assert.Nil(res)
+
+	_, err = FindInDocs(docs, []*PV{{"kind", []byte(`"pod"`)}}, nil)
+	assert.NotNil(err)
+}
+
+func TestGetValueOr(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(`{"server":{"port":8080,"hosts":["a"]},"debug":null}`))
+	for _, c := range []struct {
+		path, value string
+	}{
+		{"/server/port", `8080`},
+		{"/server/timeout", `30`},
+		{"/server/hosts/0", `"a"`},
+		{"/server/hosts/1", `30`},
+		{"/server/port/x", `30`},
+		{"/client/port", `30`},
+		{"/debug", `null`},
+	} {
+		v, err := node.GetValueOr(c.path, []byte(`30`), nil)
+		assert.Nil(err, c.path)
+		assert.Equal(c.value, string(v), c.path)
+	}
+
+	def := NewNode([]byte(`{}`))
+	child, err := node.GetChildOr("/client", def, nil)
+	assert.Nil(err)
+	assert.Same(def, child)
+	child, err = node.GetChildOr("/server/hosts", def, nil)
+	assert.Nil(err)
+	assert.Equal(`["a"]`, mustJSONString(child))
+
+	_, err = node.GetValueOr("/server/hosts/x", nil, nil)
+	assert.NotNil(err)
+	_, err = NewNode([]byte(`1`)).GetChildOr("/a", def, nil)
+	assert.NotNil(err)
+}
+
+func TestHasAndTypeOf(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(`{"a":{"b":[1,"x",true,null]},"c":{}}`))
+	_, err := node.GetChild("/c", nil)
+	assert.Nil(err)
+
+	for _, c := range []struct {
+		path string
+		kind Kind
+	}{
+		{"", KindObject},
+		{"/a", KindObject},
+		{"/a/b", KindArray},
+		{"/a/b/0", KindNumber},
+		{"/a/b/1", KindString},
+		{"/a/b/2", KindBoolean},
+		{"/a/b/-1", KindNull},
+		{"/c", KindObject},
+	} {
+		assert.True(node.Has(c.path, nil), c.path)
+		kind, err := node.TypeOf(c.path)
+		assert.Nil(err, c.path)
+		assert.Equal(c.kind, kind, c.path)
+	}
+
+	assert.False(node.Has("/x", nil))
+	assert.False(node.Has("/a/b/4", nil))
+	options := NewOptions()
+	options.SupportNegativeIndices = false
+	assert.False(node.Has("/a/b/-1", options))
+
+	_, err = node.TypeOf("/a/x")
+	assert.ErrorIs(err, ErrMissing)
+}
+
+func TestNodeRemove(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(`{"queue":[{"id":1},{"id":2},{"id":3}],"name":"q"}`))
+	job, err := node.Remove("/queue/0", nil)
+	assert.Nil(err)
+	assert.Equal(`{"id":1}`, mustJSONString(job))
+
+	job, err = node.Remove("/queue/-1", nil)
+	assert.Nil(err)
+	assert.Equal(`{"id":3}`, mustJSONString(job))
+	assert.Equal(`{"queue":[{"id":2}],"name":"q"}`, mustJSONString(node))
+
+	_, err = node.Remove("/queue/1", nil)
+	assert.True(errors.Is(err, ErrInvalidIndex))
+	_, err = node.Remove("/age", nil)
+	assert.True(errors.Is(err, ErrMissing))
+
+	options := NewOptions()
+	options.AllowMissingPathOnRemove = true
+	job, err = node.Remove("/age", options)
+	assert.Nil(err)
+	assert.Nil(job)
+
+	name, err := node.Remove("/name", nil)
+	assert.Nil(err)
+	assert.Equal(`"q"`, mustJSONString(name))
+	assert.Equal(`{"queue":[{"id":2}]}`, mustJSONString(node))
+}
+
+func TestResolve(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(`{"a":{"b/c":[1,{"d":2}]},"e":null}`))
+
+	parent, key, target, err := Resolve(node, "/a/b~1c/1", nil)
+	assert.Nil(err)
+	assert.Equal(`[1,{"d":2}]`, mustJSONString(parent))
+	assert.Equal("1", key)
+	assert.Equal(`{"d":2}`, mustJSONString(target))
+
+	parent, key, target, err = Resolve(node, "/a/b~1c/-1", nil)
+	assert.Nil(err)
+	assert.Equal("-1", key)
+	assert.Equal(`{"d":2}`, mustJSONString(target))
+
+	options := NewOptions()
+	options.SupportNegativeIndices = false
+	_, key, target, err = Resolve(node, "/a/b~1c/-1", options)
+	assert.Nil(err)
+	assert.Equal("-1", key)
+	assert.Nil(target)
+
+	parent, key, target, err = Resolve(node, "/a/x", nil)
+	assert.Nil(err)
+	assert.Equal(`{"b/c":[1,{"d":2}]}`, mustJSONString(parent))
+	assert.Equal("x", key)
+	assert.Nil(target)
+
+	parent, key, target, err = Resolve(node, "", nil)
+	assert.Nil(err)
+	assert.Nil(parent)
+	assert.Equal("", key)
+	assert.Equal(node, target)
+
+	_, _, target, err = Resolve(node, "/e", nil)
+	assert.Nil(err)
+	assert.Equal(`null`, mustJSONString(target))
+
+	_, _, _, err = Resolve(node, "/x/y", nil)
+	assert.True(errors.Is(err, ErrMissing))
+	_, _, _, err = Resolve(node, "/e/y", nil)
+	assert.True(errors.Is(err, ErrInvalid))
+	_, _, _, err = Resolve(node, "a", nil)
+	assert.NotNil(err)
+
+	// the parent is the node in the document
+	parent, key, _, err = Resolve(node, "/a/b~1c/0", nil)
+	assert.Nil(err)
+	assert.Nil(parent.Patch(Patch{NewReplaceOperation("/"+key, []byte(`0`))}, nil))
+	assert.Equal(`{"a":{"b/c":[0,{"d":2}]},"e":null}`, mustJSONString(node))
+}
@@ -0,0 +1,260 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Redact replaces the values at the paths in the node with the replacement, or removes them if the
+// replacement is nil, such as to scrub the personal data of a document before it is logged.
+// The "*" token of a path matches all the members of an object or the elements of an array,
+// such as "/users/*/email". The missing paths are ignored. The paths are applied in a single traversal
+// of the node, and it returns the number of the redacted values.
+func (n *Node) Redact(paths []string, replacement json.RawMessage, options *Options) (int, error) {
+	if n != nil && n.frozen {
+		return 0, fmt.Errorf("unable to redact node, %w", ErrFrozen)
+	}
+	if options == nil {
+		options = NewOptions()
+	}
+
+	t, err := newRedactTrie(paths)
+	if err != nil {
+		return 0, err
+	}
+	n.ClearLookupCache()
+	defer n.changed()
+	n.bounds = nil
+	return n.redact(t, replacement, options, n.watching(""))
+}
+
+// redact redacts the paths of the trie in the node, observe is called with the change of every redacted value.
+func (n *Node) redact(t *redactTrie, replacement json.RawMessage, options *Options, observe func(Change)) (int, error) {
+	if len(t.children) == 0 {
+		return 0, nil
+	}
+	pd, _ := n.intoContainer()
+	if pd == nil {
+		return 0, nil
+	}
+	r := &redactor{replacement: replacement, options: options, observe: observe}
+	err := r.redact(pd, []*redactTrie{t}, "")
+	return r.count, err
+}
+
+// RedactChildren redacts the paths in the children nodes that pass the given test operations in the node,
+// as Redact with the paths relative to the children. The children themselves are redacted as PatchAtomic
+// if there are no paths, the node itself is skipped then. It returns the number of the redacted values.
+func (n *Node) RedactChildren(tests []*PV, paths []string, replacement json.RawMessage, options *Options) (int, error) {
+	if n != nil && n.frozen {
+		return 0, fmt.Errorf("unable to redact node, %w", ErrFrozen)
+	}
+	if len(tests) == 0 {
+		return 0, nil
+	}
+	if options == nil {
+		options = NewOptions()
+	}
+
+	if len(paths) == 0 {
+		result, err := n.FindChildren(tests, options)
+		if result = childrenOnly(result); err != nil || len(result) == 0 {
+			return 0, err
+		}
+		p := BuildReplacePatch(result, replacement)
+		if replacement == nil {
+			p = BuildRemovePatch(result)
+		}
+		if err = n.PatchAtomic(p, options); err != nil {
+			return 0, err
+		}
+		return len(result), nil
+	}
+
+	t, err := newRedactTrie(paths)
+	if err != nil {
+		return 0, err
+	}
+	qs, err := compileQueries(tests)
+	if err != nil {
+		return 0, err
+	}
+	res, err := n.findChildren(qs, options)
+	if err != nil {
+		return 0, err
+	}
+
+	n.ClearLookupCache()
+	defer n.changed()
+	n.bounds = nil
+	count := 0
+	for _, r := range res {
+		if r.node.frozen {
+			return count, fmt.Errorf("unable to redact child %q, %w", r.pv.Path, ErrFrozen)
+		}
+		r.node.ClearLookupCache()
+		observe := chainObserve(n.watching(r.pv.Path), r.node.watching(""))
+		c, err := r.node.redact(t, replacement, options, observe)
+		if err != nil {
+			return count, fmt.Errorf("unable to redact child %q, %w", r.pv.Path, err)
+		}
+		count += c
+	}
+	return count, nil
+}
+
+// redactTrie is the tree of the reference tokens of the redacted paths.
+type redactTrie struct {
+	children map[string]*redactTrie
+	// leaf is true if a path ends at the node.
+	leaf bool
+}
+
+func newRedactTrie(paths []string) (*redactTrie, error) {
+	root := &redactTrie{}
+	for _, path := range paths {
+		if path == "" {
+			return nil, errors.New("unable to redact the root")
+		}
+		keys, err := ParsePath(path)
+		if err != nil {
+			return nil, err
+		}
+
+		t := root
+		for _, key := range keys {
+			if t.children == nil {
+				t.children = make(map[string]*redactTrie)
+			}
+			next := t.children[key]
+			if next == nil {
+				next = &redactTrie{}
+				t.children[key] = next
+			}
+			t = next
+		}
+		t.leaf = true
+	}
+	return root, nil
+}
+
+type redactor struct {
+	replacement json.RawMessage
+	options     *Options
+	observe     func(Change)
+	count       int
+}
+
+// redact redacts the members of the container at the path matched by the tries.
+func (r *redactor) redact(pd container, tries []*redactTrie, path string) error {
+	switch con := pd.(type) {
+	case *partialDoc:
+		// the keys are copied, the removed members change them.
+		keys := append([]string(nil), con.keys...)
+		for _, key := range keys {
+			if err := r.redactMember(pd, key, r.match(tries, key), path); err != nil {
+				return err
+			}
+		}
+
+	case *partialArray:
+		var removed []int
+		for i := range *con {
+			key := strconv.Itoa(i)
+			ts := r.match(tries, key)
+			if r.replacement == nil && isLeaf(ts) {
+				removed = append(removed, i)
+				continue
+			}
+			if err := r.redactMember(pd, key, ts, path); err != nil {
+				return err
+			}
+		}
+		// the elements are removed from the tail, so that the indices don't shift.
+		sort.Sort(sort.Reverse(sort.IntSlice(removed)))
+		for _, i := range removed {
+			if err := r.redactValue(pd, strconv.Itoa(i), path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// match returns the tries of the member key.
+func (r *redactor) match(tries []*redactTrie, key string) []*redactTrie {
+	var ts []*redactTrie
+	for _, t := range tries {
+		if next := t.children[key]; next != nil {
+			ts = append(ts, next)
+		}
+		if next := t.children["*"]; next != nil && key != "*" {
+			ts = append(ts, next)
+		}
+	}
+	return ts
+}
+
+// redactMember redacts the member key of the container if a path ends at it, or its descendants.
+func (r *redactor) redactMember(pd container, key string, tries []*redactTrie, path string) error {
+	if len(tries) == 0 {
+		return nil
+	}
+	if isLeaf(tries) {
+		return r.redactValue(pd, key, path)
+	}
+
+	child, err := pd.get(key, r.options)
+	if err != nil || child == nil {
+		return nil
+	}
+	if child.frozen {
+		return fmt.Errorf("unable to redact child %q, %w", path+"/"+encodePatchKey(key), ErrFrozen)
+	}
+	if con, _ := child.intoContainer(); con != nil {
+		return r.redact(con, tries, path+"/"+encodePatchKey(key))
+	}
+	return nil
+}
+
+// redactValue replaces or removes the member key of the container at the path.
+func (r *redactor) redactValue(pd container, key string, path string) error {
+	c := Change{Op: "replace", Path: path + "/" + encodePatchKey(key)}
+	if r.observe != nil {
+		if old, err := pd.get(key, r.options); err == nil {
+			c.Old, _ = old.MarshalJSON()
+		}
+	}
+
+	var err error
+	if r.replacement == nil {
+		c.Op = "remove"
+		err = pd.remove(key, r.options)
+	} else {
+		c.New = r.replacement
+		err = pd.set(key, NewNode(r.replacement), r.options)
+	}
+	if err != nil {
+		return err
+	}
+	r.count++
+	if r.observe != nil {
+		r.observe(c)
+	}
+	return nil
+}
+
+func isLeaf(tries []*redactTrie) bool {
+	for _, t := range tries {
+		if t.leaf {
+			return true
+		}
+	}
+	return false
+}
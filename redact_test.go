@@ -0,0 +1,104 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeRedact(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := `{"users":[{"name":"a","email":"a@x","phones":["1","2"]},{"name":"b","email":"b@x"}],"token":"t","meta":{"*":1}}`
+	for i, c := range []struct {
+		paths       []string
+		replacement string
+		count       int
+		result      string
+	}{
+		{[]string{"/users/*/email", "/token"}, `"***"`, 3,
+			`{"users":[{"name":"a","email":"***","phones":["1","2"]},{"name":"b","email":"***"}],"token":"***","meta":{"*":1}}`},
+		{[]string{"/users/*/email", "/token", "/missing", "/users/5/name"}, ``, 3,
+			`{"users":[{"name":"a","phones":["1","2"]},{"name":"b"}],"meta":{"*":1}}`},
+		{[]string{"/users/0/phones/*"}, ``, 2,
+			`{"users":[{"name":"a","email":"a@x","phones":[]},{"name":"b","email":"b@x"}],"token":"t","meta":{"*":1}}`},
+		{[]string{"/users/0/phones/*", "/users/0/phones/1"}, `null`, 2,
+			`{"users":[{"name":"a","email":"a@x","phones":[null,null]},{"name":"b","email":"b@x"}],"token":"t","meta":{"*":1}}`},
+		{[]string{"/users/1", "/users/*/name"}, ``, 2,
+			`{"users":[{"email":"a@x","phones":["1","2"]}],"token":"t","meta":{"*":1}}`},
+		{[]string{"/users/*", "/users/0/name"}, `{}`, 2,
+			`{"users":[{},{}],"token":"t","meta":{"*":1}}`},
+		{[]string{"/meta/*"}, `0`, 1,
+			`{"users":[{"name":"a","email":"a@x","phones":["1","2"]},{"name":"b","email":"b@x"}],"token":"t","meta":{"*":0}}`},
+		{[]string{"/token/*", "/users/*/name/x"}, `0`, 0, doc},
+		{nil, `0`, 0, doc},
+	} {
+		node := NewNode([]byte(doc))
+		var replacement []byte
+		if c.replacement != "" {
+			replacement = []byte(c.replacement)
+		}
+		count, err := node.Redact(c.paths, replacement, nil)
+		assert.NoError(err, "case %d", i)
+		assert.Equal(c.count, count, "case %d", i)
+		data, err := node.MarshalJSON()
+		assert.NoError(err, "case %d", i)
+		assert.Equal(c.result, string(data), "case %d", i)
+	}
+
+	node := NewNode([]byte(doc))
+	_, err := node.Redact([]string{""}, nil, nil)
+	assert.ErrorContains(err, "unable to redact the root")
+	_, err = node.Redact([]string{"token"}, nil, nil)
+	assert.ErrorContains(err, "invalid JSON pointer")
+
+	assert.NoError(node.Freeze())
+	_, err = node.Redact([]string{"/token"}, nil, nil)
+	assert.ErrorIs(err, ErrFrozen)
+}
+
+func TestNodeRedactChildren(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := `{"events":[{"type":"login","user":{"email":"a@x","ip":"1.1.1.1"}},{"type":"view","user":{"email":"b@x"}}]}`
+
+	node := NewNode([]byte(doc))
+	count, err := node.RedactChildren([]*PV{{Path: "/type", Value: []byte(`"login"`)}},
+		[]string{"/user/email", "/user/ip"}, []byte(`"***"`), nil)
+	assert.NoError(err)
+	assert.Equal(2, count)
+	data, err := node.MarshalJSON()
+	assert.NoError(err)
+	assert.Equal(`{"events":[{"type":"login","user":{"email":"***","ip":"***"}},{"type":"view","user":{"email":"b@x"}}]}`, string(data))
+
+	node = NewNode([]byte(doc))
+	count, err = node.RedactChildren([]*PV{{Path: "/email", Value: []byte(`"b@x"`)}}, nil, nil, nil)
+	assert.NoError(err)
+	assert.Equal(1, count)
+	data, err = node.MarshalJSON()
+	assert.NoError(err)
+	assert.Equal(`{"events":[{"type":"login","user":{"email":"a@x","ip":"1.1.1.1"}},{"type":"view"}]}`, string(data))
+
+	count, err = node.RedactChildren([]*PV{{Path: "/type", Value: []byte(`"view"`)}}, []string{"/user"}, nil, nil)
+	assert.NoError(err)
+	assert.Equal(0, count)
+
+	count, err = node.RedactChildren(nil, []string{"/user"}, nil, nil)
+	assert.NoError(err)
+	assert.Equal(0, count)
+
+	_, err = node.RedactChildren([]*PV{{Path: "type", Value: []byte(`"view"`)}}, nil, nil, nil)
+	assert.Error(err)
+
+	// the node itself is not redacted as a child.
+	node = NewNode([]byte(`{"type":"login","events":[{"type":"login"},{"type":"view"}]}`))
+	count, err = node.RedactChildren([]*PV{{Path: "/type", Value: []byte(`"login"`)}}, nil, nil, nil)
+	assert.NoError(err)
+	assert.Equal(1, count)
+	data, err = node.MarshalJSON()
+	assert.NoError(err)
+	assert.Equal(`{"type":"login","events":[{"type":"view"}]}`, string(data))
+}
@@ -0,0 +1,123 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Style is the output style of FormatPatch.
+type Style int
+
+const (
+	// StylePlain writes one line per operation, such as `replace /name: "a" -> "b"`.
+	StylePlain Style = iota
+	// StyleUnified writes a hunk per operation as the unified diff, with the old values on the "-" lines
+	// and the new values on the "+" lines.
+	StyleUnified
+	// StyleColor is StyleUnified with the ANSI colors of the terminals.
+	StyleColor
+)
+
+// The ANSI escape codes of StyleColor.
+const (
+	ansiReset = "\x1b[0m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiCyan  = "\x1b[36m"
+)
+
+// FormatPatch returns the human readable description of the changes the patch makes to the document,
+// with the values before and after every operation, such as for the CLI tools and the review bots.
+// The paths are resolved against the document. If an operation fails, the description ends with its error.
+func FormatPatch(p Patch, doc []byte, style Style) string {
+	f := &patchFormatter{style: style}
+	if err := NewNode(doc).patch(p, nil, f.change); err != nil {
+		f.error(err)
+	}
+	return f.String()
+}
+
+// patchFormatter writes the changes of a patch in a style.
+type patchFormatter struct {
+	strings.Builder
+	style Style
+}
+
+func (f *patchFormatter) change(c Change) {
+	if f.style == StylePlain {
+		f.WriteString(c.Op)
+		f.WriteByte(' ')
+		if c.From != "" {
+			f.WriteString(c.From)
+			f.WriteString(" -> ")
+		}
+		f.WriteString(c.Path)
+		f.WriteString(": ")
+		switch {
+		case c.Op == "test":
+			f.WriteString(formatValue(c.Old))
+		case c.Op == "move" || c.Old == nil:
+			f.WriteString(formatValue(c.New))
+		case c.New == nil:
+			f.WriteString(formatValue(c.Old))
+		default:
+			f.WriteString(formatValue(c.Old))
+			f.WriteString(" -> ")
+			f.WriteString(formatValue(c.New))
+		}
+		f.WriteByte('\n')
+		return
+	}
+
+	header := "@@ " + c.Op + " "
+	if c.From != "" {
+		header += c.From + " -> "
+	}
+	f.line(ansiCyan, header+c.Path+" @@")
+	switch {
+	case c.Op == "test":
+		f.line("", "  "+formatValue(c.Old))
+	case c.Op == "move":
+		f.line(ansiRed, "- "+c.From+": "+formatValue(c.Old))
+		f.line(ansiGreen, "+ "+c.Path+": "+formatValue(c.New))
+	default:
+		if c.Old != nil {
+			f.line(ansiRed, "- "+formatValue(c.Old))
+		}
+		if c.New != nil {
+			f.line(ansiGreen, "+ "+formatValue(c.New))
+		}
+	}
+}
+
+func (f *patchFormatter) error(err error) {
+	if f.style == StylePlain {
+		f.WriteString("error: " + err.Error() + "\n")
+		return
+	}
+	f.line(ansiRed, "! "+err.Error())
+}
+
+// line writes a line, in the color for StyleColor.
+func (f *patchFormatter) line(color, s string) {
+	if f.style == StyleColor && color != "" {
+		f.WriteString(color + s + ansiReset + "\n")
+		return
+	}
+	f.WriteString(s + "\n")
+}
+
+// formatValue returns the compact JSON of the value, or "undefined" for no value.
+func formatValue(v json.RawMessage) string {
+	if v == nil {
+		return "undefined"
+	}
+	data, err := NewNode(v).MarshalJSON()
+	if err != nil {
+		return string(v)
+	}
+	return string(data)
+}
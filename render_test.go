@@ -0,0 +1,66 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatPatch(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"name":"app","tags":["a"],"db":{"host":"localhost"},"id":1}`)
+	p, err := NewPatch([]byte(`[
+		{"op": "test", "path": "/id", "value": 1},
+		{"op": "replace", "path": "/name", "value": "web"},
+		{"op": "add", "path": "/tags/-", "value": "b"},
+		{"op": "remove", "path": "/db/host"},
+		{"op": "move", "from": "/tags/0", "path": "/first"},
+		{"op": "copy", "from": "/name", "path": "/alias"}
+	]`))
+	assert.Nil(err)
+
+	assert.Equal(`test /id: 1
+replace /name: "app" -> "web"
+add /tags/1: "b"
+remove /db/host: "localhost"
+move /tags/0 -> /first: "a"
+copy /name -> /alias: "web"
+`, FormatPatch(p, doc, StylePlain))
+
+	assert.Equal(`@@ test /id @@
+  1
+@@ replace /name @@
+- "app"
++ "web"
+@@ add /tags/1 @@
++ "b"
+@@ remove /db/host @@
+- "localhost"
+@@ move /tags/0 -> /first @@
+- /tags/0: "a"
++ /first: "a"
+@@ copy /name -> /alias @@
++ "web"
+`, FormatPatch(p, doc, StyleUnified))
+
+	assert.Equal("\x1b[36m@@ replace /name @@\x1b[0m\n\x1b[31m- \"app\"\x1b[0m\n\x1b[32m+ \"web\"\x1b[0m\n",
+		FormatPatch(p[1:2], doc, StyleColor))
+
+	p, err = NewPatch([]byte(`[
+		{"op": "add", "path": "/name", "value": {"a": [1, 2]}},
+		{"op": "remove", "path": "/missing"}
+	]`))
+	assert.Nil(err)
+	assert.Equal(`add /name: "app" -> {"a":[1,2]}
+error: operation 1, remove operation does not apply for "/missing", unable to remove nonexistent key "missing", missing value
+`, FormatPatch(p, doc, StylePlain))
+	assert.Equal(`@@ add /name @@
+- "app"
++ {"a":[1,2]}
+! operation 1, remove operation does not apply for "/missing", unable to remove nonexistent key "missing", missing value
+`, FormatPatch(p, doc, StyleUnified))
+}
@@ -0,0 +1,201 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Change is the effect of an applied operation.
+type Change struct {
+	// Index is the index of the operation in the patch.
+	Index int `json:"index"`
+	// Op is the name of the operation.
+	Op string `json:"op"`
+	// Path and From are the paths of the operation, resolved against the document:
+	// "-" and negative array indices are replaced with the actual indices.
+	Path string `json:"path"`
+	From string `json:"from,omitempty"`
+	// Old is the value at Path before the operation (the value at From for "move"),
+	// nil if there was no value.
+	Old json.RawMessage `json:"old,omitempty"`
+	// New is the value at Path after the operation, nil if there is no value.
+	New json.RawMessage `json:"new,omitempty"`
+}
+
+// Kind returns the kind of the operation of the change.
+func (c Change) Kind() OpKind {
+	return OpKind(c.Op)
+}
+
+// ApplyWithReport mutates a JSON document according to the patch and the passed in Options.
+// It returns the new document and the changes made by every operation.
+func (p Patch) ApplyWithReport(doc []byte, options *Options) ([]byte, []Change, error) {
+	node := NewNode(doc)
+	changes := make([]Change, 0, len(p))
+	if err := node.patch(p, options, func(c Change) {
+		changes = append(changes, c)
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	res, err := marshalPatched(node, doc, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, changes, nil
+}
+
+// beginChange starts the change of the operation before it is applied.
+func beginChange(pd container, i int, op Operation, options *Options) Change {
+	c := Change{Index: i, Op: op.Op, Path: op.Path, From: op.From}
+
+	switch op.Op {
+	case "add", "copy", "move":
+		if op.From != "" {
+			c.From = resolvePath(pd, op.From, false, options)
+		}
+		if op.Op == "move" {
+			c.Old = valueAt(pd, op.From, options)
+			break
+		}
+		// adding to an array inserts a new element.
+		if con, _ := findObject(&pd, op.Path, options); con != nil {
+			if _, ok := con.(*partialArray); !ok {
+				c.Old = valueAt(pd, op.Path, options)
+			}
+		}
+
+	default:
+		c.Path = resolvePath(pd, op.Path, false, options)
+		c.Old = valueAt(pd, op.Path, options)
+	}
+	return c
+}
+
+// endChange completes the change of the operation after it is applied.
+func endChange(pd container, c *Change, op Operation, options *Options) {
+	switch op.Op {
+	case "add", "copy", "move":
+		c.Path = resolvePath(pd, op.Path, true, options)
+		c.New = valueAt(pd, c.Path, options)
+	case "remove":
+	case "test":
+		c.New = c.Old
+	default:
+		c.New = valueAt(pd, c.Path, options)
+	}
+}
+
+// valueAt returns the raw encoded JSON value at the path, or nil if the path is missing.
+func valueAt(pd container, path string, options *Options) json.RawMessage {
+	var node *Node
+	if path == "" {
+		node = &Node{which: eOther}
+		node.setContainer(pd)
+	} else {
+		con, key := findObject(&pd, path, options)
+		if con == nil {
+			return nil
+		}
+		val, err := con.get(key, options)
+		if err != nil {
+			return nil
+		}
+		node = val
+	}
+
+	data, err := node.MarshalJSON()
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// resolvePath replaces "-" and negative indices in the path with the actual indices.
+// inserted indicates that the path has been used to insert an element.
+func resolvePath(pd container, path string, inserted bool, options *Options) string {
+	split := strings.Split(path, "/")
+	if len(split) < 2 {
+		return path
+	}
+
+	parts := split[1:]
+	doc := pd
+	for i, part := range parts {
+		if ary, ok := doc.(*partialArray); ok {
+			sz := len(*ary)
+			if part == "-" {
+				if inserted && i == len(parts)-1 {
+					parts[i] = strconv.Itoa(sz - 1)
+				}
+			} else if idx, err := strconv.Atoi(part); err == nil && idx < 0 {
+				parts[i] = strconv.Itoa(idx + sz)
+			}
+		}
+
+		if i == len(parts)-1 {
+			break
+		}
+		next, err := doc.get(decodePatchKey(parts[i]), options)
+		if err != nil {
+			break
+		}
+		if doc, _ = next.intoContainer(); doc == nil {
+			break
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// beforeApply calls Options.BeforeApply with the proposed change of the operation.
+func (o *Options) beforeApply(pd container, op Operation, c Change) error {
+	if o.BeforeApply == nil {
+		return nil
+	}
+	if err := o.BeforeApply(op, proposedChange(pd, c, op, o)); err != nil {
+		return fmt.Errorf("%s operation is rejected, %w", op.Op, err)
+	}
+	return nil
+}
+
+// proposedChange completes the change of the operation before it is applied,
+// with the path where the value will be written and the value.
+func proposedChange(pd container, c Change, op Operation, options *Options) Change {
+	switch op.Op {
+	case "add", "copy", "move":
+		c.Path = resolveInsertPath(pd, op.Path, options)
+	}
+
+	switch op.Op {
+	case "add", "replace":
+		c.New = op.Value
+		if c.New == nil {
+			c.New = rawJSONNull
+		}
+	case "copy":
+		c.New = valueAt(pd, op.From, options)
+	case "move":
+		c.New = c.Old
+	}
+	return c
+}
+
+// resolveInsertPath resolves the path as resolvePath, and replaces the last "-" token of an array
+// with the index of the element to be appended.
+func resolveInsertPath(pd container, path string, options *Options) string {
+	path = resolvePath(pd, path, false, options)
+	if !strings.HasSuffix(path, "/-") {
+		return path
+	}
+	if con, _ := findObject(&pd, path, options); con != nil {
+		if ary, ok := con.(*partialArray); ok {
+			return path[:len(path)-1] + strconv.Itoa(len(*ary))
+		}
+	}
+	return path
+}
@@ -0,0 +1,158 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyWithReport(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"name":"John","tags":["a","b"],"meta":{}}`)
+	p := Patch{
+		{Op: "test", Path: "/name", Value: []byte(`"John"`)},
+		{Op: "replace", Path: "/name", Value: []byte(`"Jane"`)},
+		{Op: "add", Path: "/tags/-", Value: []byte(`"c"`)},
+		{Op: "add", Path: "/tags/0", Value: []byte(`"z"`)},
+		{Op: "remove", Path: "/tags/-1"},
+		{Op: "add", Path: "/meta/a~1b", Value: []byte(`1`)},
+		{Op: "add", Path: "/meta/a~1b", Value: []byte(`2`)},
+		{Op: "copy", From: "/tags/0", Path: "/first"},
+		{Op: "move", From: "/meta/a~1b", Path: "/tags/-"},
+	}
+
+	res, changes, err := p.ApplyWithReport(doc, nil)
+	assert.Nil(err)
+	assert.Equal(`{"name":"Jane","tags":["z","a","b",2],"meta":{},"first":"z"}`, string(res))
+	assert.Equal([]Change{
+		{Index: 0, Op: "test", Path: "/name", Old: []byte(`"John"`), New: []byte(`"John"`)},
+		{Index: 1, Op: "replace", Path: "/name", Old: []byte(`"John"`), New: []byte(`"Jane"`)},
+		{Index: 2, Op: "add", Path: "/tags/2", New: []byte(`"c"`)},
+		{Index: 3, Op: "add", Path: "/tags/0", New: []byte(`"z"`)},
+		{Index: 4, Op: "remove", Path: "/tags/3", Old: []byte(`"c"`)},
+		{Index: 5, Op: "add", Path: "/meta/a~1b", New: []byte(`1`)},
+		{Index: 6, Op: "add", Path: "/meta/a~1b", Old: []byte(`1`), New: []byte(`2`)},
+		{Index: 7, Op: "copy", From: "/tags/0", Path: "/first", New: []byte(`"z"`)},
+		{Index: 8, Op: "move", From: "/meta/a~1b", Path: "/tags/3", Old: []byte(`2`), New: []byte(`2`)},
+	}, changes)
+
+	res, changes, err = Patch{
+		{Op: "replace", Path: "", Value: []byte(`[1]`)},
+	}.ApplyWithReport([]byte(`{"a":1}`), nil)
+	assert.Nil(err)
+	assert.Equal(`[1]`, string(res))
+	assert.Equal([]Change{
+		{Index: 0, Op: "replace", Path: "", Old: []byte(`{"a":1}`), New: []byte(`[1]`)},
+	}, changes)
+
+	_, _, err = Patch{{Op: "remove", Path: "/x"}}.ApplyWithReport([]byte(`{}`), nil)
+	assert.NotNil(err)
+}
+
+func TestOnChange(t *testing.T) {
+	assert := assert.New(t)
+
+	var changes []Change
+	options := NewOptions()
+	options.OnChange = func(c Change) {
+		changes = append(changes, c)
+	}
+
+	p := Patch{
+		{Op: "add", Path: "/tags/-", Value: []byte(`"b"`)},
+		{Op: "remove", Path: "/name"},
+	}
+	res, err := p.ApplyWithOptions([]byte(`{"name":"John","tags":["a"]}`), options)
+	assert.Nil(err)
+	assert.Equal(`{"tags":["a","b"]}`, string(res))
+	assert.Equal([]Change{
+		{Index: 0, Op: "add", Path: "/tags/1", New: []byte(`"b"`)},
+		{Index: 1, Op: "remove", Path: "/name", Old: []byte(`"John"`)},
+	}, changes)
+	assert.Equal(OpAdd, changes[0].Kind())
+	assert.Equal(OpRemove, changes[1].Kind())
+
+	changes = nil
+	_, report, err := p.ApplyWithReport([]byte(`{"name":"John","tags":["a"]}`), options)
+	assert.Nil(err)
+	assert.Equal(report, changes)
+
+	// failed operations are not reported
+	changes = nil
+	options.ContinueOnError = true
+	_, err = p.ApplyWithOptions([]byte(`{"tags":["a"]}`), options)
+	assert.NotNil(err)
+	assert.Equal(1, len(changes))
+	assert.Equal(0, changes[0].Index)
+}
+
+func TestApplyHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	errForbidden := errors.New("forbidden")
+	var before, after []Change
+	options := NewOptions()
+	options.BeforeApply = func(op Operation, c Change) error {
+		before = append(before, c)
+		if strings.HasPrefix(c.Path, "/owner") {
+			return errForbidden
+		}
+		return nil
+	}
+	options.AfterApply = func(op Operation, c Change) {
+		assert.Equal(op.Op, c.Op)
+		after = append(after, c)
+	}
+
+	doc := []byte(`{"owner":"a","name":"x","tags":["a"]}`)
+	p := Patch{
+		{Op: "add", Path: "/tags/-", Value: []byte(`"b"`)},
+		{Op: "replace", Path: "/name", Value: []byte(`"y"`)},
+		{Op: "copy", From: "/name", Path: "/tags/0"},
+		{Op: "move", From: "/tags/-1", Path: "/alias"},
+		{Op: "remove", Path: "/tags/0"},
+	}
+	res, err := p.ApplyWithOptions(doc, options)
+	assert.NoError(err)
+	assert.Equal(`{"owner":"a","name":"y","tags":["a"],"alias":"b"}`, string(res))
+	assert.Equal([]Change{
+		{Index: 0, Op: "add", Path: "/tags/1", New: []byte(`"b"`)},
+		{Index: 1, Op: "replace", Path: "/name", Old: []byte(`"x"`), New: []byte(`"y"`)},
+		{Index: 2, Op: "copy", Path: "/tags/0", From: "/name", New: []byte(`"y"`)},
+		{Index: 3, Op: "move", Path: "/alias", From: "/tags/2", Old: []byte(`"b"`), New: []byte(`"b"`)},
+		{Index: 4, Op: "remove", Path: "/tags/0", Old: []byte(`"y"`)},
+	}, before)
+	assert.Equal([]Change{
+		{Index: 0, Op: "add", Path: "/tags/1", New: []byte(`"b"`)},
+		{Index: 1, Op: "replace", Path: "/name", Old: []byte(`"x"`), New: []byte(`"y"`)},
+		{Index: 2, Op: "copy", Path: "/tags/0", From: "/name", New: []byte(`"y"`)},
+		{Index: 3, Op: "move", Path: "/alias", From: "/tags/2", Old: []byte(`"b"`), New: []byte(`"b"`)},
+		{Index: 4, Op: "remove", Path: "/tags/0", Old: []byte(`"y"`)},
+	}, after)
+
+	// the vetoed operations fail, or are skipped with ContinueOnError.
+	before, after = nil, nil
+	p = Patch{
+		{Op: "replace", Path: "/owner", Value: []byte(`"b"`)},
+		{Op: "remove", Path: "/name"},
+	}
+	_, err = p.ApplyWithOptions(doc, options)
+	assert.ErrorIs(err, errForbidden)
+	assert.ErrorContains(err, "operation 0, replace operation is rejected, forbidden")
+	assert.Len(before, 1)
+	assert.Len(after, 0)
+
+	options.ContinueOnError = true
+	before, after = nil, nil
+	res, err = p.ApplyWithOptions(doc, options)
+	assert.ErrorIs(err, errForbidden)
+	assert.Nil(res)
+	assert.Len(before, 2)
+	assert.Equal([]Change{{Index: 1, Op: "remove", Path: "/name", Old: []byte(`"x"`)}}, after)
+}
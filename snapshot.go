@@ -0,0 +1,74 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Snapshot is an immutable version of a node, returned by Node.Snapshot.
+// It is safe for concurrent use, also while the node it is taken from is patched.
+type Snapshot struct {
+	mu   sync.Mutex
+	root *Node
+}
+
+// Snapshot returns an immutable view of the current version of the node, the later patches of the node
+// don't change it. It is a deep copy of the parsed nodes, which costs time and memory proportional to
+// the number of the parsed nodes for every snapshot, there is no copy-on-write of the unchanged subtrees.
+// Only the raw JSON of the unparsed subtrees and values is shared with the node, it is never modified.
+func (n *Node) Snapshot() *Snapshot {
+	return &Snapshot{root: n.share()}
+}
+
+// GetValue returns the value of a given path in the snapshot.
+func (s *Snapshot) GetValue(path string, options *Options) (json.RawMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.root.GetValue(path, options)
+}
+
+// Has returns true if the path exists in the snapshot.
+func (s *Snapshot) Has(path string, options *Options) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.root.Has(path, options)
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (s *Snapshot) MarshalJSON() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.root.MarshalJSON()
+}
+
+// Node returns a new node of the snapshot, which can be queried and patched without changing the snapshot.
+// As Snapshot, it copies the parsed nodes of the snapshot.
+func (s *Snapshot) Node() *Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.root.share()
+}
+
+// share returns a deep copy of the parsed nodes of the node, that shares the raw JSON with it.
+func (n *Node) share() *Node {
+	if n == nil {
+		return nil
+	}
+
+	c := &Node{which: n.which, valid: n.valid, reformat: n.reformat}
+	if n.raw != nil {
+		raw := *n.raw
+		c.raw = &raw
+	}
+
+	switch n.which {
+	case eDoc:
+		c.doc = n.doc.copyWith((*Node).share)
+	case eAry:
+		c.ary = n.ary.copyWith((*Node).share)
+	}
+	return c
+}
@@ -0,0 +1,109 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeSnapshot(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(`{"a":{"b":[1,2,3]},"c":"x","d":null}`))
+	v1 := node.Snapshot()
+
+	assert.NoError(node.Patch(Patch{
+		{Op: "add", Path: "/a/b/-", Value: []byte(`4`)},
+		{Op: "replace", Path: "/c", Value: []byte(`"y"`)},
+	}, nil))
+	v2 := node.Snapshot()
+
+	assert.NoError(node.Patch(Patch{
+		{Op: "remove", Path: "/a"},
+		{Op: "add", Path: "/e", Value: []byte(`{"f":true}`)},
+	}, nil))
+	v3 := node.Snapshot()
+
+	assert.NoError(node.Patch(Patch{
+		{Op: "replace", Path: "/e/f", Value: []byte(`false`)},
+	}, nil))
+
+	data, err := v1.MarshalJSON()
+	assert.NoError(err)
+	assert.Equal(`{"a":{"b":[1,2,3]},"c":"x","d":null}`, string(data))
+	data, err = v2.MarshalJSON()
+	assert.NoError(err)
+	assert.Equal(`{"a":{"b":[1,2,3,4]},"c":"y","d":null}`, string(data))
+	data, err = v3.MarshalJSON()
+	assert.NoError(err)
+	assert.Equal(`{"c":"y","d":null,"e":{"f":true}}`, string(data))
+	data, err = node.MarshalJSON()
+	assert.NoError(err)
+	assert.Equal(`{"c":"y","d":null,"e":{"f":false}}`, string(data))
+
+	val, err := v1.GetValue("/a/b/2", nil)
+	assert.NoError(err)
+	assert.Equal(`3`, string(val))
+	val, err = v2.GetValue("/d", nil)
+	assert.NoError(err)
+	assert.Equal(`null`, string(val))
+	_, err = v3.GetValue("/a", nil)
+	assert.ErrorIs(err, ErrMissing)
+	assert.True(v2.Has("/a/b/3", nil))
+	assert.False(v1.Has("/a/b/3", nil))
+
+	// the node of a snapshot is patched without changing the snapshot.
+	n2 := v2.Node()
+	assert.NoError(n2.Patch(Patch{{Op: "remove", Path: "/a/b"}}, nil))
+	data, err = n2.MarshalJSON()
+	assert.NoError(err)
+	assert.Equal(`{"a":{},"c":"y","d":null}`, string(data))
+	data, err = v2.MarshalJSON()
+	assert.NoError(err)
+	assert.Equal(`{"a":{"b":[1,2,3,4]},"c":"y","d":null}`, string(data))
+
+	// the raw JSON of the unparsed subtrees is shared.
+	node = NewNode([]byte(`{"a":{"b":1},"c":[2]}`))
+	_, err = node.GetChild("/a", nil)
+	assert.NoError(err)
+	s := node.Snapshot()
+	assert.Same(&(*node.doc.obj["c"].raw)[0], &(*s.root.doc.obj["c"].raw)[0])
+}
+
+func TestNodeSnapshotConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(`{"items":[{"n":0},{"n":1},{"n":2}]}`))
+	var snapshots []*Snapshot
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		s := node.Snapshot()
+		snapshots = append(snapshots, s)
+		wg.Add(1)
+		go func(i int, s *Snapshot) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				val, err := s.GetValue(fmt.Sprintf("/items/%d/n", i%3), nil)
+				assert.NoError(err)
+				assert.Equal(fmt.Sprint(i%3), string(val))
+				assert.True(s.Has(fmt.Sprintf("/items/%d", i+2), nil))
+			}
+		}(i, s)
+
+		assert.NoError(node.Patch(Patch{
+			{Op: "add", Path: "/items/-", Value: []byte(fmt.Sprintf(`{"n":%d}`, i+3))},
+			{Op: "replace", Path: "/items/0/n", Value: []byte(`0`)},
+		}, nil))
+	}
+	wg.Wait()
+
+	for i, s := range snapshots {
+		assert.True(s.Has(fmt.Sprintf("/items/%d", i+2), nil), "case %d", i)
+		assert.False(s.Has(fmt.Sprintf("/items/%d", i+3), nil), "case %d", i)
+	}
+}
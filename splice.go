@@ -0,0 +1,55 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// applySplice applies the "splice" operation enabled by Options.EnableSplice.
+func applySplice(doc *Node, op Operation, options *Options) error {
+	pd, err := doc.intoContainer()
+	if err != nil {
+		return fmt.Errorf("splice operation does not apply for %q, %w", op.Path, err)
+	}
+	con, key := op.findPath(&pd, options)
+	if con == nil || op.Path == "" {
+		return fmt.Errorf("splice operation does not apply for %q, %w", op.Path, ErrMissing)
+	}
+	ary, ok := con.(*partialArray)
+	if !ok {
+		return fmt.Errorf("splice operation for path %q has non-array target, %w", op.Path, ErrInvalid)
+	}
+
+	idx, err := ary.insertIndex(key, options)
+	if err != nil {
+		return err
+	}
+	cur := *ary
+	if op.Remove < 0 || op.Remove > len(cur)-idx {
+		return fmt.Errorf("splice operation for path %q has invalid remove %d, %w", op.Path, op.Remove, ErrInvalidIndex)
+	}
+
+	parent, _ := splitLastPath(op.Path)
+	values := make([]*Node, len(op.Values))
+	for i, v := range op.Values {
+		if err := checkValidJSON(v); err != nil {
+			return fmt.Errorf("splice operation has invalid value %d, %w", i, ErrInvalid)
+		}
+		if err := options.validateValue(op.Op, parent+"/"+strconv.Itoa(idx+i), v); err != nil {
+			return err
+		}
+		values[i] = NewNode(v)
+		values[i].reformat = true
+	}
+
+	res := make(partialArray, 0, len(cur)-op.Remove+len(values))
+	res = append(res, cur[:idx]...)
+	res = append(res, values...)
+	res = append(res, cur[idx+op.Remove:]...)
+	options.stats().shifted(len(cur) - idx - op.Remove)
+	*ary = res
+	return nil
+}
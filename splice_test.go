@@ -0,0 +1,81 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplice(t *testing.T) {
+	assert := assert.New(t)
+
+	options := NewOptions()
+	options.EnableSplice = true
+
+	doc := []byte(`{"items":[0,1,2,3,4],"a":{}}`)
+	for i, c := range []struct {
+		patch, res string
+	}{
+		{`[{"op": "splice", "path": "/items/1", "remove": 2, "values": ["a", "b", "c"]}]`, `{"items":[0,"a","b","c",3,4],"a":{}}`},
+		{`[{"op": "splice", "path": "/items/0", "values": [null, {"x": 1}]}]`, `{"items":[null,{"x":1},0,1,2,3,4],"a":{}}`},
+		{`[{"op": "splice", "path": "/items/3", "remove": 2}]`, `{"items":[0,1,2],"a":{}}`},
+		{`[{"op": "splice", "path": "/items/-", "values": [5, 6]}]`, `{"items":[0,1,2,3,4,5,6],"a":{}}`},
+		{`[{"op": "splice", "path": "/items/5", "values": [5]}]`, `{"items":[0,1,2,3,4,5],"a":{}}`},
+		{`[{"op": "splice", "path": "/items/-2", "remove": 1, "values": [9]}]`, `{"items":[0,1,2,3,9],"a":{}}`},
+		{`[{"op": "splice", "path": "/items/0", "remove": 5}]`, `{"items":[],"a":{}}`},
+		{`[{"op": "splice", "path": "/items/2"}]`, `{"items":[0,1,2,3,4],"a":{}}`},
+	} {
+		p, err := NewPatch([]byte(c.patch))
+		assert.Nil(err, "case %d", i)
+		res, err := p.ApplyWithOptions(doc, options)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.res, string(res), "case %d", i)
+	}
+
+	for i, c := range []struct {
+		patch string
+		err   error
+	}{
+		{`[{"op": "splice", "path": "/items/1", "remove": 5}]`, ErrInvalidIndex},
+		{`[{"op": "splice", "path": "/items/1", "remove": -1}]`, ErrInvalidIndex},
+		{`[{"op": "splice", "path": "/items/6", "values": [1]}]`, ErrInvalidIndex},
+		{`[{"op": "splice", "path": "/items/-7", "values": [1]}]`, ErrInvalidIndex},
+		{`[{"op": "splice", "path": "/a/b", "values": [1]}]`, ErrInvalid},
+		{`[{"op": "splice", "path": "/b/0", "values": [1]}]`, ErrMissing},
+		{`[{"op": "splice", "path": "", "values": [1]}]`, ErrMissing},
+	} {
+		p, err := NewPatch([]byte(c.patch))
+		assert.Nil(err, "case %d", i)
+		_, err = p.ApplyWithOptions(doc, options)
+		assert.ErrorIs(err, c.err, "case %d", i)
+	}
+
+	_, err := Patch{{Op: "splice", Path: "/items/0"}}.Apply(doc)
+	assert.NotNil(err)
+
+	stats := &ApplyStats{}
+	options.Stats = stats
+	values := make([]string, 1000)
+	for i := range values {
+		values[i] = strconv.Itoa(i)
+	}
+	p, err := NewPatch([]byte(`[{"op": "splice", "path": "/items/1", "values": [` + strings.Join(values, ",") + `]}]`))
+	assert.Nil(err)
+	res, err := p.ApplyWithOptions(doc, options)
+	assert.Nil(err)
+	assert.Equal(1005, len(mustGetArray(t, res, "/items")))
+	assert.Equal(int64(4), stats.ArrayShifts)
+}
+
+func mustGetArray(t *testing.T, doc []byte, path string) partialArray {
+	n, err := NewNode(doc).GetChild(path, nil)
+	assert.Nil(t, err)
+	_, err = n.intoContainer()
+	assert.Nil(t, err)
+	return n.ary
+}
@@ -0,0 +1,55 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import "sync/atomic"
+
+// ApplyStats is the cost of the applied patches, collected if it is set as Options.Stats.
+// The counters are added to atomically, so they accumulate across the patches applied with the options,
+// also concurrently as ApplyAll.
+type ApplyStats struct {
+	// OpsApplied is the number of the applied operations.
+	OpsApplied int64
+	// NodesVisited is the number of the containers traversed to resolve the paths of the operations.
+	NodesVisited int64
+	// BytesBefore and BytesAfter are the sizes of the encoded documents before and after the patches,
+	// they are counted by the functions that patch encoded documents, such as Patch.ApplyWithOptions.
+	BytesBefore int64
+	BytesAfter  int64
+	// ArrayShifts is the number of the array elements shifted by the insertions and removals.
+	ArrayShifts int64
+}
+
+// stats returns the stats of the options, or nil.
+func (o *Options) stats() *ApplyStats {
+	if o == nil {
+		return nil
+	}
+	return o.Stats
+}
+
+func (s *ApplyStats) applied() {
+	if s != nil {
+		atomic.AddInt64(&s.OpsApplied, 1)
+	}
+}
+
+func (s *ApplyStats) visited(n int) {
+	if s != nil {
+		atomic.AddInt64(&s.NodesVisited, int64(n))
+	}
+}
+
+func (s *ApplyStats) shifted(n int) {
+	if s != nil && n > 0 {
+		atomic.AddInt64(&s.ArrayShifts, int64(n))
+	}
+}
+
+func (s *ApplyStats) patched(before, after []byte) {
+	if s != nil {
+		atomic.AddInt64(&s.BytesBefore, int64(len(before)))
+		atomic.AddInt64(&s.BytesAfter, int64(len(after)))
+	}
+}
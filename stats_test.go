@@ -0,0 +1,56 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyStats(t *testing.T) {
+	assert := assert.New(t)
+
+	stats := &ApplyStats{}
+	options := NewOptions()
+	options.Stats = stats
+
+	doc := []byte(`{"a":{"b":[1,2,3,4]},"c":1}`)
+	res, err := Patch{
+		{Op: "add", Path: "/a/b/0", Value: []byte(`0`)},
+		{Op: "remove", Path: "/a/b/1"},
+		{Op: "add", Path: "/a/b/-", Value: []byte(`5`)},
+		{Op: "replace", Path: "/c", Value: []byte(`2`)},
+		{Op: "test", Path: "/c", Value: []byte(`2`)},
+	}.ApplyWithOptions(doc, options)
+	assert.NoError(err)
+	assert.Equal(`{"a":{"b":[0,2,3,4,5]},"c":2}`, string(res))
+	assert.Equal(ApplyStats{
+		OpsApplied:   5,
+		NodesVisited: 3*3 + 2*1,
+		BytesBefore:  int64(len(doc)),
+		BytesAfter:   int64(len(res)),
+		ArrayShifts:  4 + 3,
+	}, *stats)
+
+	// the stats accumulate, the failed operations are not applied.
+	_, err = Patch{
+		{Op: "remove", Path: "/a/b/-1"},
+		{Op: "remove", Path: "/x/y"},
+	}.ApplyWithOptions(doc, options)
+	assert.ErrorIs(err, ErrMissing)
+	assert.Equal(int64(6), stats.OpsApplied)
+	assert.Equal(int64(11+3+1), stats.NodesVisited)
+	assert.Equal(int64(len(doc)), stats.BytesBefore)
+
+	stats = &ApplyStats{}
+	options.Stats = stats
+	docs := [][]byte{doc, doc, doc}
+	_, errs := ApplyAll(docs, Patch{{Op: "remove", Path: "/a/b/0"}}, options)
+	assert.Equal([]error{nil, nil, nil}, errs)
+	assert.Equal(int64(3), stats.OpsApplied)
+	assert.Equal(int64(9), stats.ArrayShifts)
+	assert.Equal(int64(3*len(doc)), stats.BytesBefore)
+	assert.Equal(int64(3*(len(doc)-2)), stats.BytesAfter)
+}
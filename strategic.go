@@ -0,0 +1,265 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// StrategicSchema describes how the lists of a document are merged by ApplyStrategicMerge,
+// as the patchStrategy and patchMergeKey struct tags of Kubernetes API types.
+type StrategicSchema struct {
+	// MergeKeys maps the paths of the lists to merge to their merge keys, the lists not in it are replaced.
+	// The paths have no array indices: "/spec/containers/ports" is the "ports" list of every container
+	// in "/spec/containers". The lists of objects are merged by the value of the merge key, such as "name",
+	// and the lists with the empty merge key are merged as sets of primitive values.
+	MergeKeys map[string]string
+}
+
+const (
+	strategicPatchKey        = "$patch"
+	strategicRetainKeys      = "$retainKeys"
+	strategicDeletePrefix    = "$deleteFromPrimitiveList/"
+	strategicOrderPrefix     = "$setElementOrder/"
+	strategicPatchDelete     = "delete"
+	strategicPatchReplace    = "replace"
+	strategicPatchMerge      = "merge"
+	strategicDirectivePrefix = "$"
+)
+
+// ApplyStrategicMerge applies the Kubernetes strategic merge patch to a JSON document, and returns the new document.
+// Like a JSON merge patch (RFC 7386), the objects are merged recursively and null values remove the members,
+// but the lists in the MergeKeys of the schema are merged instead of replaced.
+//
+// The directives "$patch" (with "delete", "replace" or "merge"), "$retainKeys" and
+// "$deleteFromPrimitiveList/<key>" are supported, "$setElementOrder/<key>" is accepted and ignored.
+func ApplyStrategicMerge(doc, patch []byte, schema *StrategicSchema) ([]byte, error) {
+	if !json.Valid(doc) {
+		return nil, errors.New("invalid JSON document")
+	}
+	if !json.Valid(patch) {
+		return nil, errors.New("invalid strategic merge patch")
+	}
+	if schema == nil {
+		schema = &StrategicSchema{}
+	}
+
+	res, err := strategicMerge(NewNode(doc), NewNode(patch), "", schema)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return []byte("{}"), nil
+	}
+	return res.MarshalJSON()
+}
+
+// strategicMerge merges the patch into the target node at the path, it returns the merged node,
+// or nil if the target is deleted.
+func strategicMerge(target, patch *Node, path string, schema *StrategicSchema) (*Node, error) {
+	if patch.intoContainer(); patch.which != eDoc {
+		return patch, nil
+	}
+
+	options := NewOptions()
+	if v, ok := patch.doc.obj[strategicPatchKey]; ok {
+		switch directive := nodeString(v); directive {
+		case strategicPatchDelete:
+			return nil, nil
+		case strategicPatchReplace:
+			res := patch.Clone()
+			res.doc.remove(strategicPatchKey, options)
+			return res, nil
+		case strategicPatchMerge:
+		default:
+			return nil, fmt.Errorf("unexpected %s directive %q at %q", strategicPatchKey, directive, path)
+		}
+	}
+
+	if target != nil {
+		target.intoContainer()
+	}
+	if target == nil || target.which != eDoc {
+		target = &Node{doc: &partialDoc{obj: make(map[string]*Node, len(patch.doc.keys))}, which: eDoc}
+	}
+
+	for _, k := range patch.doc.keys {
+		v := patch.doc.obj[k]
+		switch {
+		case k == strategicPatchKey, k == strategicRetainKeys, strings.HasPrefix(k, strategicOrderPrefix):
+			continue
+
+		case strings.HasPrefix(k, strategicDeletePrefix):
+			field := strings.TrimPrefix(k, strategicDeletePrefix)
+			if list, ok := target.doc.obj[field]; ok {
+				target.doc.set(field, deleteFromList(list, v), options)
+			}
+			continue
+
+		case strings.HasPrefix(k, strategicDirectivePrefix):
+			return nil, fmt.Errorf("unexpected directive %q at %q", k, path)
+
+		case v.isNull():
+			if _, ok := target.doc.obj[k]; ok {
+				target.doc.remove(k, options)
+			}
+			continue
+		}
+
+		child := path + "/" + encodePatchKey(k)
+		var res *Node
+		var err error
+		if v.intoContainer(); v.which == eAry {
+			res, err = strategicMergeList(target.doc.obj[k], v, child, schema)
+		} else {
+			res, err = strategicMerge(target.doc.obj[k], v, child, schema)
+		}
+		switch {
+		case err != nil:
+			return nil, err
+		case res == nil:
+			if _, ok := target.doc.obj[k]; ok {
+				target.doc.remove(k, options)
+			}
+		default:
+			target.doc.set(k, res, options)
+		}
+	}
+
+	if v, ok := patch.doc.obj[strategicRetainKeys]; ok {
+		var keys []string
+		if err := json.Unmarshal(v.rawOrNull(), &keys); err != nil {
+			return nil, fmt.Errorf("invalid %s directive at %q, %w", strategicRetainKeys, path, err)
+		}
+		for _, k := range append([]string(nil), target.doc.keys...) {
+			if indexOfKey(keys, k) < 0 {
+				target.doc.remove(k, options)
+			}
+		}
+	}
+	return target, nil
+}
+
+// strategicMergeList merges the patch list into the target list at the path.
+func strategicMergeList(target, patch *Node, path string, schema *StrategicSchema) (*Node, error) {
+	items := make(partialArray, 0, len(patch.ary))
+	replace := false
+	for _, v := range patch.ary {
+		if v.intoContainer(); v.which == eDoc && len(v.doc.keys) == 1 &&
+			nodeString(v.doc.obj[strategicPatchKey]) == strategicPatchReplace {
+			replace = true
+			continue
+		}
+		items = append(items, v)
+	}
+
+	key, ok := schema.MergeKeys[path]
+	if target != nil {
+		target.intoContainer()
+	}
+	if !ok || replace || target == nil || target.which != eAry {
+		res := make(partialArray, 0, len(items))
+		for _, v := range items {
+			if v.which == eDoc {
+				m, err := strategicMerge(nil, v, path, schema)
+				if err != nil {
+					return nil, err
+				}
+				if m == nil {
+					continue
+				}
+				v = m
+			}
+			res = append(res, v)
+		}
+		return &Node{ary: res, which: eAry}, nil
+	}
+
+	res := make(partialArray, len(target.ary))
+	copy(res, target.ary)
+	if key == "" {
+		for _, v := range items {
+			if indexOfNode(res, v) < 0 {
+				res = append(res, v)
+			}
+		}
+		return &Node{ary: res, which: eAry}, nil
+	}
+
+	for _, v := range items {
+		if v.which != eDoc {
+			return nil, fmt.Errorf("unexpected list element without merge key %q at %q", key, path)
+		}
+		id, ok := v.doc.obj[key]
+		if !ok {
+			return nil, fmt.Errorf("unexpected list element without merge key %q at %q", key, path)
+		}
+
+		idx := -1
+		for i, item := range res {
+			if item.intoContainer(); item.which == eDoc {
+				if iv, ok := item.doc.obj[key]; ok && iv.Equal(id) {
+					idx = i
+					break
+				}
+			}
+		}
+
+		var cur *Node
+		if idx >= 0 {
+			cur = res[idx]
+		}
+		m, err := strategicMerge(cur, v, path, schema)
+		switch {
+		case err != nil:
+			return nil, err
+		case m == nil && idx >= 0:
+			res = append(res[:idx], res[idx+1:]...)
+		case m == nil:
+		case idx >= 0:
+			res[idx] = m
+		default:
+			res = append(res, m)
+		}
+	}
+	return &Node{ary: res, which: eAry}, nil
+}
+
+// deleteFromList returns the list without the values of the patch list.
+func deleteFromList(list, values *Node) *Node {
+	list.intoContainer()
+	values.intoContainer()
+	if list.which != eAry || values.which != eAry {
+		return list
+	}
+
+	res := make(partialArray, 0, len(list.ary))
+	for _, v := range list.ary {
+		if indexOfNode(values.ary, v) < 0 {
+			res = append(res, v)
+		}
+	}
+	return &Node{ary: res, which: eAry}
+}
+
+func indexOfNode(ary partialArray, n *Node) int {
+	for i, v := range ary {
+		if v.Equal(n) {
+			return i
+		}
+	}
+	return -1
+}
+
+// nodeString returns the string value of the node, or "" if it is not a string.
+func nodeString(n *Node) string {
+	var s string
+	if n != nil {
+		json.Unmarshal(n.rawOrNull(), &s)
+	}
+	return s
+}
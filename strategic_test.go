@@ -0,0 +1,82 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyStrategicMerge(t *testing.T) {
+	assert := assert.New(t)
+
+	schema := &StrategicSchema{MergeKeys: map[string]string{
+		"/spec/containers":       "name",
+		"/spec/containers/ports": "containerPort",
+		"/metadata/finalizers":   "",
+	}}
+	doc := `{
+		"metadata": {"name": "web", "labels": {"app": "web", "tier": "front"}, "finalizers": ["a", "b"]},
+		"spec": {
+			"containers": [
+				{"name": "web", "image": "nginx:1.23", "ports": [{"containerPort": 80}, {"containerPort": 443}]},
+				{"name": "sidecar", "image": "envoy"}
+			],
+			"volumes": [{"name": "data"}],
+			"strategy": {"type": "RollingUpdate", "rollingUpdate": {"maxSurge": 1}}
+		}
+	}`
+
+	for i, c := range []struct {
+		patch, result string
+	}{
+		{`{"metadata": {"labels": {"tier": null, "env": "prod"}, "finalizers": ["b", "c"]}}`,
+			`{"metadata":{"name":"web","labels":{"app":"web","env":"prod"},"finalizers":["a","b","c"]},` +
+				`"spec":{"containers":[{"name":"web","image":"nginx:1.23","ports":[{"containerPort":80},{"containerPort":443}]},` +
+				`{"name":"sidecar","image":"envoy"}],"volumes":[{"name":"data"}],` +
+				`"strategy":{"type":"RollingUpdate","rollingUpdate":{"maxSurge":1}}}}`},
+		{`{"spec": {
+			"containers": [
+				{"name": "web", "image": "nginx:1.24", "ports": [{"containerPort": 8080}, {"containerPort": 443, "$patch": "delete"}]},
+				{"name": "sidecar", "$patch": "delete"},
+				{"name": "log", "image": "fluentd", "args": null}
+			],
+			"volumes": [{"name": "cache"}]
+		}}`,
+			`{"metadata":{"name":"web","labels":{"app":"web","tier":"front"},"finalizers":["a","b"]},` +
+				`"spec":{"containers":[{"name":"web","image":"nginx:1.24","ports":[{"containerPort":80},{"containerPort":8080}]},` +
+				`{"name":"log","image":"fluentd"}],"volumes":[{"name":"cache"}],` +
+				`"strategy":{"type":"RollingUpdate","rollingUpdate":{"maxSurge":1}}}}`},
+		{`{"metadata": {"labels": {"$patch": "replace", "app": "api"}, "$deleteFromPrimitiveList/finalizers": ["a"],
+			"$setElementOrder/finalizers": ["b"]},
+		  "spec": {"containers": [{"$patch": "replace"}, {"name": "api"}],
+			"strategy": {"$retainKeys": ["type"], "type": "Recreate"}}}`,
+			`{"metadata":{"name":"web","labels":{"app":"api"},"finalizers":["b"]},` +
+				`"spec":{"containers":[{"name":"api"}],"volumes":[{"name":"data"}],"strategy":{"type":"Recreate"}}}`},
+		{`{"spec": {"$patch": "delete"}, "status": {"phase": "Running", "reason": null}}`,
+			`{"metadata":{"name":"web","labels":{"app":"web","tier":"front"},"finalizers":["a","b"]},"status":{"phase":"Running"}}`},
+	} {
+		res, err := ApplyStrategicMerge([]byte(doc), []byte(c.patch), schema)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.result, string(res), "case %d", i)
+	}
+
+	// without a schema, the lists are replaced as RFC 7386.
+	res, err := ApplyStrategicMerge([]byte(`{"a":[1,2],"b":{"c":1}}`), []byte(`{"a":[3],"b":{"c":null}}`), nil)
+	assert.Nil(err)
+	assert.Equal(`{"a":[3],"b":{}}`, string(res))
+
+	for i, patch := range []string{
+		`{"$patch": "unknown"}`,
+		`{"spec": {"$unknown": 1}}`,
+		`{"spec": {"containers": [{"image": "nginx"}]}}`,
+		`{"spec": {"containers": [1]}}`,
+		`{"spec": {"strategy": {"$retainKeys": "type"}}}`,
+		`{`,
+	} {
+		_, err := ApplyStrategicMerge([]byte(doc), []byte(patch), schema)
+		assert.NotNil(err, "case %d", i)
+	}
+}
@@ -0,0 +1,154 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ApplyToStream applies the patch to each record of the NDJSON (JSON Lines) stream read from r,
+// and writes the patched records to w, one per line. The records are read and written one by one,
+// so the stream is never loaded as a whole. The blank lines are skipped.
+// It stops at the first record that fails, the error has the line number of the record.
+func ApplyToStream(r io.Reader, w io.Writer, patch Patch, options *Options) error {
+	return processStream(r, w, func(node *Node) (*Node, error) {
+		if err := node.Patch(patch, options); err != nil {
+			return nil, err
+		}
+		return node, nil
+	})
+}
+
+// ExtractFromStream writes the values at the path of the records of the NDJSON (JSON Lines) stream read from r
+// to w, one per line. The records without the path are skipped.
+func ExtractFromStream(r io.Reader, w io.Writer, path string, options *Options) error {
+	return processStream(r, w, func(node *Node) (*Node, error) {
+		if path == "" {
+			return node, nil
+		}
+		child, err := node.GetChild(path, options)
+		switch {
+		case isMissing(err):
+			return nil, nil
+		case err != nil:
+			return nil, err
+		case child == nil:
+			return NewNode(nil), nil
+		}
+		return child, nil
+	})
+}
+
+// processStream writes the records transformed by fn, the records are skipped if fn returns nil.
+func processStream(r io.Reader, w io.Writer, fn func(node *Node) (*Node, error)) error {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	for line := 1; ; line++ {
+		data, err := br.ReadBytes('\n')
+		eof := err == io.EOF
+		if err == nil || eof {
+			err = processRecord(bw, bytes.TrimSpace(data), fn)
+		}
+		if err != nil {
+			// the records before the failed one are written.
+			bw.Flush()
+			return fmt.Errorf("line %d, %w", line, err)
+		}
+		if eof {
+			return bw.Flush()
+		}
+	}
+}
+
+// processRecord writes the record transformed by fn to bw.
+func processRecord(bw *bufio.Writer, data []byte, fn func(node *Node) (*Node, error)) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if !json.Valid(data) {
+		return errors.New("invalid JSON record")
+	}
+	node, err := fn(NewNode(data))
+	if err != nil || node == nil {
+		return err
+	}
+	if data, err = node.MarshalJSON(); err != nil {
+		return err
+	}
+	bw.Write(data)
+	return bw.WriteByte('\n')
+}
+
+// GetValueByPathReader returns the value of a given path in the JSON document read from r.
+// The document is scanned token by token and only the value of the path is kept in memory,
+// so a field can be extracted from a very large document without parsing it into a Node.
+// The scanning stops at the end of the value, the rest of the document is not read or validated.
+// Unlike GetValueByPath, the first member is returned for duplicate keys in objects.
+func GetValueByPathReader(r io.Reader, path string) ([]byte, error) {
+	keys, err := ParsePath(path)
+	if err != nil {
+		return nil, &PathError{Op: "get", Path: path, Index: -1, Err: err}
+	}
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	for _, key := range keys {
+		if err := seekChild(dec, key); err != nil {
+			return nil, &PathError{Op: "get", Path: path, Index: -1, Err: err}
+		}
+	}
+
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return nil, &PathError{Op: "get", Path: path, Index: -1, Err: err}
+	}
+	return raw, nil
+}
+
+// seekChild reads the tokens of the decoder until the value of the key in the next object or array.
+func seekChild(dec *json.Decoder, key string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case startObject:
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if k == key {
+				return nil
+			}
+			if err = skipValue(dec); err != nil {
+				return err
+			}
+		}
+		return fmt.Errorf("unable to get child node by key %q, %w", key, ErrMissing)
+
+	case startArray:
+		idx, err := strconv.Atoi(key)
+		if err != nil || idx < 0 || key != strconv.Itoa(idx) {
+			return fmt.Errorf("unable to access invalid index %s, %w", key, ErrInvalidIndex)
+		}
+		for i := 0; dec.More(); i++ {
+			if i == idx {
+				return nil
+			}
+			if err = skipValue(dec); err != nil {
+				return err
+			}
+		}
+		return fmt.Errorf("unable to access invalid index %s, %w", key, ErrInvalidIndex)
+	}
+	return fmt.Errorf("unable to get child node by key %q, %w", key, ErrMissing)
+}
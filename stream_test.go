@@ -0,0 +1,106 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyToStream(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := NewPatch([]byte(`[
+		{"op":"remove","path":"/password"},
+		{"op":"add","path":"/env","value":"prod"}
+	]`))
+	assert.Nil(err)
+
+	var w strings.Builder
+	err = ApplyToStream(strings.NewReader("{\"user\":\"a\",\"password\":\"x\"}\n\n"+
+		"  {\"user\":\"b\", \"password\":\"y\"}\r\n{\"user\":\"c\",\"password\":1}"), &w, p, nil)
+	assert.Nil(err)
+	assert.Equal("{\"user\":\"a\",\"env\":\"prod\"}\n{\"user\":\"b\",\"env\":\"prod\"}\n{\"user\":\"c\",\"env\":\"prod\"}\n",
+		w.String())
+
+	w.Reset()
+	err = ApplyToStream(strings.NewReader("{\"password\":1}\n{\"user\":\"b\"}\n{\"password\":2}\n"), &w, p, nil)
+	assert.ErrorIs(err, ErrMissing)
+	assert.ErrorContains(err, "line 2, operation 0, ")
+	assert.Equal("{\"env\":\"prod\"}\n", w.String())
+
+	w.Reset()
+	err = ApplyToStream(strings.NewReader(""), &w, p, nil)
+	assert.Nil(err)
+	assert.Equal("", w.String())
+}
+
+func TestExtractFromStream(t *testing.T) {
+	assert := assert.New(t)
+
+	input := "{\"user\":{\"name\":\"a\"}}\n{\"user\":{\"name\":null}}\n{\"id\":3}\n[1]\n{\"user\":{\"name\":[1, 2]}}\n"
+	var w strings.Builder
+	err := ExtractFromStream(strings.NewReader(input), &w, "/user/name", nil)
+	assert.Nil(err)
+	assert.Equal("\"a\"\nnull\n[1,2]\n", w.String())
+
+	w.Reset()
+	err = ExtractFromStream(strings.NewReader("1\n\"x\"\n"), &w, "", nil)
+	assert.Nil(err)
+	assert.Equal("1\n\"x\"\n", w.String())
+
+	w.Reset()
+	err = ExtractFromStream(strings.NewReader("{\"user\":1}\n{\"user\":\n"), &w, "", nil)
+	assert.ErrorContains(err, "line 2, invalid JSON record")
+	assert.Equal("{\"user\":1}\n", w.String())
+}
+
+func TestGetValueByPathReader(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := `{"a": {"x": [1, {"y": 2}], "z": "skip"}, "b": [{"c": null}, [1, 2], {"d": {"e": "f"}}], "g": 1 `
+	for i, c := range []struct {
+		path, value string
+	}{
+		{"/a/x/1/y", `2`},
+		{"/a/z", `"skip"`},
+		{"/b/0/c", `null`},
+		{"/b/1", `[1, 2]`},
+		{"/b/2/d", `{"e": "f"}`},
+		{"/g", `1`},
+	} {
+		v, err := GetValueByPathReader(strings.NewReader(doc), c.path)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.value, string(v), "case %d", i)
+	}
+
+	v, err := GetValueByPathReader(strings.NewReader(`{"a~/b":[true]}`), "/a~0~1b/0")
+	assert.Nil(err)
+	assert.Equal(`true`, string(v))
+	v, err = GetValueByPathReader(strings.NewReader(` [1] `), "")
+	assert.Nil(err)
+	assert.Equal(`[1]`, string(v))
+
+	for i, c := range []struct {
+		path string
+		err  error
+	}{
+		{"/x", ErrMissing},
+		{"/a/z/0", ErrMissing},
+		{"/b/3", ErrInvalidIndex},
+		{"/b/-", ErrInvalidIndex},
+		{"/b/01", ErrInvalidIndex},
+		{"/a/x/-1", ErrInvalidIndex},
+	} {
+		_, err := GetValueByPathReader(strings.NewReader(doc+"}"), c.path)
+		assert.ErrorIs(err, c.err, "case %d", i)
+	}
+
+	_, err = GetValueByPathReader(strings.NewReader(doc), "a")
+	assert.ErrorContains(err, `invalid JSON pointer "a"`)
+	_, err = GetValueByPathReader(strings.NewReader(`{"a": [1, `), "/a/3")
+	assert.NotNil(err)
+}
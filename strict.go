@@ -0,0 +1,204 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// maxStrictDepth is the nesting limit of DecodePatchStrict if Options.MaxDepth is 0.
+const maxStrictDepth = 1000
+
+// DecodeError is an error type returned by DecodePatchStrict for a malformed patch.
+type DecodeError struct {
+	// Offset is the byte offset of the error in the patch document.
+	Offset int64
+	// Index is the index of the operation in the patch, it is -1 for the errors out of the operations.
+	Index int
+	// Member is the name of the member of the operation in error, such as "path",
+	// it is empty for the errors of the operation itself.
+	Member string
+	// Err is the cause of the error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *DecodeError) Error() string {
+	switch {
+	case e.Index < 0:
+		return fmt.Sprintf("invalid patch at offset %d, %v", e.Offset, e.Err)
+	case e.Member == "":
+		return fmt.Sprintf("invalid operation %d at offset %d, %v", e.Index, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("invalid operation %d at offset %d, member %q %v", e.Index, e.Offset, e.Member, e.Err)
+}
+
+// Unwrap returns the cause of the error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodePatchStrict decodes the passed JSON document as an RFC 6902 patch, and validates it before
+// it is applied: the document must be an array of objects without duplicate members, the names of
+// the operations must be known (the RFC 6902 operations, and the operations enabled in the options),
+// "path" and "from" must be valid JSON pointers, "from" and "value" must be present for the
+// operations that require them, and "from" of a "move" operation must not be a proper prefix of "path".
+// The nesting depth of the document is limited by Options.MaxDepth, or 1000 if it is 0, and the patch
+// is checked against the other limits of the options as NewPatchWithOptions.
+// The errors are *DecodeError with the position of the malformed operation or member.
+func DecodePatchStrict(doc []byte, options *Options) (Patch, error) {
+	if options == nil {
+		options = NewOptions()
+	}
+	limit := maxStrictDepth
+	if options.MaxDepth > 0 {
+		// a patch is an array of objects, they are not counted in the depth of the values.
+		limit = options.MaxDepth + 2
+	}
+	if i := exceedDepth(doc, limit); i >= 0 {
+		return nil, &DecodeError{Offset: int64(i), Index: -1,
+			Err: fmt.Errorf("nesting depth exceeds the limit %d, %w", limit, ErrLimitExceeded)}
+	}
+	if err := checkValidJSON(doc); err != nil {
+		var offset int64
+		if se, ok := err.(*json.SyntaxError); ok {
+			offset = se.Offset
+		}
+		return nil, &DecodeError{Offset: offset, Index: -1, Err: err}
+	}
+
+	start := skipSpace(doc, 0)
+	if doc[start] != '[' {
+		return nil, &DecodeError{Offset: int64(start), Index: -1, Err: errors.New("patch is not an array")}
+	}
+
+	p := Patch{}
+	var starts []int
+	for i := skipSpace(doc, start+1); doc[i] != ']'; {
+		end := scanValue(doc, i)
+		op, err := decodeStrictOperation(doc, i, end, len(p), options)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, op)
+		starts = append(starts, i)
+
+		if i = skipSpace(doc, end); doc[i] == ',' {
+			i = skipSpace(doc, i+1)
+		}
+	}
+
+	if err := options.checkPatch(p); err != nil {
+		var pe *PathError
+		if errors.As(err, &pe) {
+			return nil, &DecodeError{Offset: int64(starts[pe.Index]), Index: pe.Index, Member: "value", Err: pe.Err}
+		}
+		return nil, &DecodeError{Offset: int64(start), Index: -1, Err: err}
+	}
+	return p, nil
+}
+
+// decodeStrictOperation decodes and validates the operation doc[start:end] of index idx.
+func decodeStrictOperation(doc []byte, start, end, idx int, options *Options) (Operation, error) {
+	var op Operation
+	fail := func(offset int, member string, err error) (Operation, error) {
+		return op, &DecodeError{Offset: int64(offset), Index: idx, Member: member, Err: err}
+	}
+
+	if doc[start] != '{' {
+		return fail(start, "", errors.New("operation is not an object"))
+	}
+	offsets := make(map[string]int)
+	for i := skipSpace(doc, start+1); doc[i] != '}'; {
+		keyEnd := scanValue(doc, i)
+		key := parseKey(doc[i:keyEnd])
+		if _, ok := offsets[key]; ok {
+			return fail(i, key, fmt.Errorf("is duplicated, %w", ErrDuplicateKey))
+		}
+		i = skipSpace(doc, skipSpace(doc, keyEnd)+1) // ':'
+		offsets[key] = i
+
+		if i = skipSpace(doc, scanValue(doc, i)); doc[i] == ',' {
+			i = skipSpace(doc, i+1)
+		}
+	}
+
+	if err := json.Unmarshal(doc[start:end], &op); err != nil {
+		// the valid JSON of the operation only fails on the types of the members.
+		member, offset := "", start
+		if te, ok := err.(*json.UnmarshalTypeError); ok {
+			member, offset = te.Field, offsets[te.Field]
+		}
+		return fail(offset, member, err)
+	}
+
+	if _, ok := offsets["op"]; !ok {
+		return fail(start, "op", errors.New("is missing"))
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		if _, ok := offsets["value"]; !ok {
+			return fail(start, "value", fmt.Errorf("is missing for %s operation", op.Op))
+		}
+	case "move", "copy":
+		if _, ok := offsets["from"]; !ok {
+			return fail(start, "from", fmt.Errorf("is missing for %s operation", op.Op))
+		}
+	case "remove":
+	default:
+		if _, ok := options.extension(op.Op); !ok {
+			return fail(offsets["op"], "op", fmt.Errorf("has unknown operation %q", op.Op))
+		}
+	}
+
+	if _, ok := offsets["path"]; !ok {
+		return fail(start, "path", errors.New("is missing"))
+	}
+	if err := checkPointer(op.Path); err != nil {
+		return fail(offsets["path"], "path", err)
+	}
+	if _, ok := offsets["from"]; ok {
+		if err := checkPointer(op.From); err != nil {
+			return fail(offsets["from"], "from", err)
+		}
+	}
+	if op.Op == "move" && op.From != op.Path && hasPathPrefix(op.Path, op.From) {
+		return fail(offsets["from"], "from", fmt.Errorf("%q is a proper prefix of path %q, %w", op.From, op.Path, ErrCyclic))
+	}
+	return op, nil
+}
+
+// checkPointer returns an error if the pointer is not a valid JSON pointer of RFC 6901.
+func checkPointer(pointer string) error {
+	if pointer != "" && pointer[0] != '/' {
+		return fmt.Errorf("invalid JSON pointer %q, not started with \"/\"", pointer)
+	}
+	for i := 0; i < len(pointer); i++ {
+		if pointer[i] == '~' && (i+1 == len(pointer) || pointer[i+1] != '0' && pointer[i+1] != '1') {
+			return fmt.Errorf("invalid JSON pointer %q, invalid escape at %d", pointer, i)
+		}
+	}
+	return nil
+}
+
+// exceedDepth returns the offset of the first object or array of the JSON data nested deeper than limit,
+// or -1 if there is none.
+func exceedDepth(data []byte, limit int) int {
+	depth := 0
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '"':
+			i = scanString(data, i) - 1
+		case '{', '[':
+			if depth++; depth > limit {
+				return i
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return -1
+}
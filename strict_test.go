@@ -0,0 +1,128 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodePatchStrict(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := DecodePatchStrict([]byte(` [
+		{"op": "add", "path": "/a/~1b", "value": null},
+		{"op": "remove", "path": "/a"},
+		{"op": "replace", "path": "", "value": {}},
+		{"op": "move", "from": "/a", "path": "/b"},
+		{"op": "move", "from": "/a", "path": "/a"},
+		{"op": "copy", "from": "", "path": "/c"},
+		{"op": "test", "path": "/c", "value": [1]}
+	] `), nil)
+	assert.NoError(err)
+	assert.Len(p, 7)
+	assert.Equal(Operation{Op: "add", Path: "/a/~1b", Value: []byte(`null`)}, p[0])
+
+	p, err = DecodePatchStrict([]byte(`[]`), nil)
+	assert.NoError(err)
+	assert.Equal(Patch{}, p)
+
+	for i, c := range []struct {
+		patch  string
+		offset int64
+		index  int
+		member string
+		msg    string
+	}{
+		{``, 0, -1, "", "unexpected end of JSON input"},
+		{`[{"op": "add"`, 13, -1, "", "unexpected end of JSON input"},
+		{`{"op": "add"}`, 0, -1, "", "patch is not an array"},
+		{`[{"op": "add", "path": "/a", "value": 1}, 1]`, 42, 1, "", "operation is not an object"},
+		{`[{"path": "/a"}]`, 1, 0, "op", "is missing"},
+		{`[{"op": "inc", "path": "/a"}]`, 8, 0, "op", `unknown operation "inc"`},
+		{`[{"op": "contains", "path": "/a", "value": "x"}]`, 8, 0, "op", `unknown operation "contains"`},
+		{`[{"op": "add", "value": 1}]`, 1, 0, "path", "is missing"},
+		{`[{"op": "add", "path": "/a"}]`, 1, 0, "value", "is missing for add operation"},
+		{`[{"op": "test", "path": "/a"}]`, 1, 0, "value", "is missing for test operation"},
+		{`[{"op": "move", "path": "/a"}]`, 1, 0, "from", "is missing for move operation"},
+		{`[{"op": "copy", "path": "/a", "from": 1}]`, 38, 0, "from", "cannot unmarshal number"},
+		{`[{"op": "remove", "path": "a"}]`, 26, 0, "path", `invalid JSON pointer "a"`},
+		{`[{"op": "remove", "path": "/a~2"}]`, 26, 0, "path", "invalid escape at 2"},
+		{`[{"op": "remove", "path": "/a~"}]`, 26, 0, "path", "invalid escape at 2"},
+		{`[{"op": "copy", "path": "/a", "from": "b"}]`, 38, 0, "from", `invalid JSON pointer "b"`},
+		{`[{"op": "move", "path": "/a/b", "from": "/a"}]`, 40, 0, "from", "is a proper prefix"},
+		{`[{"op": "remove", "path": "/a", "op": "add"}]`, 32, 0, "op", "duplicate key"},
+		{"[" + strings.Repeat(`{"op":"add","path":"/a","value":1},`, 2) + strings.Repeat("[", 1000) + strings.Repeat("]", 1000) + "]",
+			1000 + 35*2, -1, "", "nesting depth exceeds the limit 1000"},
+	} {
+		_, err := DecodePatchStrict([]byte(c.patch), nil)
+		var de *DecodeError
+		if !assert.True(errors.As(err, &de), "case %d", i) {
+			continue
+		}
+		assert.Equal(c.offset, de.Offset, "case %d", i)
+		assert.Equal(c.index, de.Index, "case %d", i)
+		assert.Equal(c.member, de.Member, "case %d", i)
+		assert.ErrorContains(err, c.msg, "case %d", i)
+	}
+
+	options := NewOptions()
+	options.EnablePredicates = true
+	options.RegisterOperation("inc", func(doc *Node, op Operation, options *Options) error { return nil })
+	p, err = DecodePatchStrict([]byte(`[
+		{"op": "inc", "path": "/a", "value": 1},
+		{"op": "contains", "path": "/b", "value": "x"}
+	]`), options)
+	assert.NoError(err)
+	assert.Len(p, 2)
+
+	options = NewOptions()
+	options.MaxDepth = 2
+	_, err = DecodePatchStrict([]byte(`[{"op": "add", "path": "/a", "value": [[[1]]]}]`), options)
+	assert.ErrorIs(err, ErrLimitExceeded)
+	assert.ErrorContains(err, "invalid patch at offset 40")
+
+	options = NewOptions()
+	options.MaxValueBytes = 3
+	_, err = DecodePatchStrict([]byte(`[{"op": "add", "path": "/a", "value": 1}, {"op": "add", "path": "/a", "value": "abc"}]`), options)
+	assert.ErrorIs(err, ErrLimitExceeded)
+	assert.ErrorContains(err, `invalid operation 1 at offset 42, member "value"`)
+}
+
+func FuzzDecodePatchStrict(f *testing.F) {
+	f.Add([]byte(`[{"op": "add", "path": "/a", "value": {"b": [1, 2]}}]`), []byte(`{"a": 1}`))
+	f.Add([]byte(`[{"op": "move", "from": "/a", "path": "/b/-"}]`), []byte(`{"a": [1], "b": []}`))
+	f.Add([]byte(`[{"op": "test", "path": "/a~1b", "value": null}, {"op": "remove", "path": "/c"}]`), []byte(`[]`))
+
+	f.Fuzz(func(t *testing.T, patch, doc []byte) {
+		p, err := DecodePatchStrict(patch, nil)
+		if err != nil {
+			var de *DecodeError
+			if !errors.As(err, &de) {
+				t.Fatalf("unexpected error type %T", err)
+			}
+			if de.Offset < 0 || de.Offset > int64(len(patch)) {
+				t.Fatalf("invalid error offset %d of %d bytes", de.Offset, len(patch))
+			}
+			return
+		}
+
+		lax, err := NewPatch(patch)
+		if err != nil {
+			t.Fatalf("strict patch is not decoded by NewPatch, %v", err)
+		}
+		if len(lax) != len(p) {
+			t.Fatalf("strict patch of %d operations is decoded as %d operations", len(p), len(lax))
+		}
+		options := NewOptions()
+		options.RecoverPanics = true
+		var pe *PanicError
+		if _, err = p.ApplyWithOptions(doc, options); errors.As(err, &pe) {
+			t.Fatalf("unexpected panic %v", pe)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// stringOperations are the string operations enabled by Options.EnableStringOps.
+var stringOperations = map[string]bool{
+	"str-append":  true,
+	"str-insert":  true,
+	"str-replace": true,
+}
+
+// applyStringOp applies the string operation to the string at the path of the operation.
+func applyStringOp(doc *Node, op Operation, options *Options) error {
+	pd, err := doc.intoContainer()
+	if err != nil {
+		return fmt.Errorf("%s operation does not apply for %q, %w", op.Op, op.Path, err)
+	}
+	con, key := op.findPath(&pd, options)
+	if con == nil || op.Path == "" {
+		return fmt.Errorf("%s operation does not apply for %q, %w", op.Op, op.Path, ErrMissing)
+	}
+	val, err := con.get(key, options)
+	if err != nil {
+		return fmt.Errorf("%s operation does not apply for %q, %w", op.Op, op.Path, err)
+	}
+
+	s, ok := val.stringValue()
+	if !ok {
+		return fmt.Errorf("%s operation for path %q has non-string target, %w", op.Op, op.Path, ErrInvalid)
+	}
+	text, ok := NewNode(op.Value).stringValue()
+	if !ok {
+		return fmt.Errorf("%s operation has non-string value %s, %w", op.Op, op.Value, ErrInvalid)
+	}
+
+	switch op.Op {
+	case "str-append":
+		s += text
+	case "str-insert", "str-replace":
+		start, ok := runeOffset(s, 0, op.Pos)
+		if !ok {
+			return fmt.Errorf("%s operation for path %q has invalid pos %d, %w", op.Op, op.Path, op.Pos, ErrInvalidIndex)
+		}
+		end := start
+		if op.Op == "str-replace" {
+			if end, ok = runeOffset(s, start, op.Len); !ok {
+				return fmt.Errorf("%s operation for path %q has invalid len %d, %w", op.Op, op.Path, op.Len, ErrInvalidIndex)
+			}
+		}
+		s = s[:start] + text + s[end:]
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	raw := json.RawMessage(data)
+	if err := options.validateValue(op.Op, op.Path, raw); err != nil {
+		return err
+	}
+	return con.set(key, &Node{raw: &raw, valid: true, reformat: true}, options)
+}
+
+// stringValue returns the string of the node if it is a JSON string.
+func (n *Node) stringValue() (string, bool) {
+	var s *string
+	if n == nil || n.raw == nil || n.which == eDoc || n.which == eAry || json.Unmarshal(*n.raw, &s) != nil || s == nil {
+		return "", false
+	}
+	return *s, true
+}
+
+// runeOffset returns the byte offset of n runes after the byte offset i of s,
+// n runes must be in s after i.
+func runeOffset(s string, i, n int) (int, bool) {
+	if n < 0 {
+		return 0, false
+	}
+	for ; n > 0; n-- {
+		if i >= len(s) {
+			return 0, false
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return i, true
+}
@@ -0,0 +1,63 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringOps(t *testing.T) {
+	assert := assert.New(t)
+
+	options := NewOptions()
+	options.EnableStringOps = true
+
+	doc := []byte(`{"title":"héllo wörld","tags":["日本"],"n":1,"x":null}`)
+	for i, c := range []struct {
+		patch, res string
+	}{
+		{`[{"op": "str-append", "path": "/title", "value": "!"}]`, `{"title":"héllo wörld!","tags":["日本"],"n":1,"x":null}`},
+		{`[{"op": "str-insert", "path": "/title", "pos": 2, "value": "<>"}]`, `{"title":"hé\u003c\u003ello wörld","tags":["日本"],"n":1,"x":null}`},
+		{`[{"op": "str-insert", "path": "/title", "pos": 11, "value": "s"}]`, `{"title":"héllo wörlds","tags":["日本"],"n":1,"x":null}`},
+		{`[{"op": "str-replace", "path": "/title", "pos": 6, "len": 5, "value": "you"}]`, `{"title":"héllo you","tags":["日本"],"n":1,"x":null}`},
+		{`[{"op": "str-replace", "path": "/title", "pos": 0, "len": 6, "value": ""}]`, `{"title":"wörld","tags":["日本"],"n":1,"x":null}`},
+		{`[{"op": "str-insert", "path": "/tags/0", "pos": 1, "value": "の"}]`, `{"title":"héllo wörld","tags":["日の本"],"n":1,"x":null}`},
+		{`[{"op": "str-append", "path": "/tags/-1", "value": "語"}, {"op": "str-insert", "path": "/tags/0", "value": "新"}]`,
+			`{"title":"héllo wörld","tags":["新日本語"],"n":1,"x":null}`},
+	} {
+		p, err := NewPatch([]byte(c.patch))
+		assert.Nil(err, "case %d", i)
+		res, err := p.ApplyWithOptions(doc, options)
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.res, string(res), "case %d", i)
+	}
+
+	for i, c := range []struct {
+		patch string
+		err   error
+	}{
+		{`[{"op": "str-append", "path": "/n", "value": "!"}]`, ErrInvalid},
+		{`[{"op": "str-append", "path": "/x", "value": "!"}]`, ErrInvalid},
+		{`[{"op": "str-append", "path": "/tags", "value": "!"}]`, ErrInvalid},
+		{`[{"op": "str-append", "path": "/title", "value": 1}]`, ErrInvalid},
+		{`[{"op": "str-append", "path": "/title"}]`, ErrInvalid},
+		{`[{"op": "str-append", "path": "/missing", "value": "!"}]`, ErrMissing},
+		{`[{"op": "str-append", "path": "", "value": "!"}]`, ErrMissing},
+		{`[{"op": "str-insert", "path": "/title", "pos": 12, "value": "!"}]`, ErrInvalidIndex},
+		{`[{"op": "str-insert", "path": "/title", "pos": -1, "value": "!"}]`, ErrInvalidIndex},
+		{`[{"op": "str-replace", "path": "/title", "pos": 10, "len": 2, "value": "!"}]`, ErrInvalidIndex},
+	} {
+		p, err := NewPatch([]byte(c.patch))
+		assert.Nil(err, "case %d", i)
+		_, err = p.ApplyWithOptions(doc, options)
+		assert.ErrorIs(err, c.err, "case %d", i)
+	}
+
+	p := Patch{{Op: "str-append", Path: "/title", Value: []byte(`"!"`)}}
+	_, err := p.Apply(doc)
+	assert.NotNil(err)
+	assert.Contains(Capabilities(options).Extensions, "str-replace")
+}
@@ -0,0 +1,195 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownRevision  = errors.New("unknown revision")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// Delta is the response of a SyncServer to a client at a given revision.
+// Applying Patch to the document at revision From produces the document at
+// revision To, whose checksum is Checksum. Time is when revision To was committed.
+type Delta struct {
+	From     uint64    `json:"from"`
+	To       uint64    `json:"to"`
+	Patch    Patch     `json:"patch"`
+	Checksum string    `json:"checksum"`
+	Time     time.Time `json:"time"`
+}
+
+// SyncServer keeps the revisions of a JSON document as a history of patches,
+// and composes the delta that brings a client from any retained revision to the head.
+// It is safe for concurrent use.
+type SyncServer struct {
+	mu      sync.RWMutex
+	base    uint64
+	history []Patch
+	head    *Node
+	sum     string
+	time    time.Time
+	options *Options
+}
+
+// NewSyncServer creates a SyncServer with the given document as revision 0.
+func NewSyncServer(doc []byte, options *Options) (*SyncServer, error) {
+	if options == nil {
+		options = NewOptions()
+	}
+	head := NewNode(doc)
+	sum, err := head.checksum()
+	if err != nil {
+		return nil, err
+	}
+	return &SyncServer{head: head, sum: sum, time: options.Now(), options: options}, nil
+}
+
+// Revision returns the head revision.
+func (s *SyncServer) Revision() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.base + uint64(len(s.history))
+}
+
+// Head returns the head revision and its document.
+func (s *SyncServer) Head() (uint64, []byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	doc, err := s.head.MarshalJSON()
+	return s.base + uint64(len(s.history)), doc, err
+}
+
+// Commit applies the patch to the head document and records it as a new revision.
+// The head is left untouched if the patch fails to apply.
+func (s *SyncServer) Commit(p Patch) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	head := s.head.Clone()
+	if err := head.Patch(p, s.options); err != nil {
+		return 0, err
+	}
+	sum, err := head.checksum()
+	if err != nil {
+		return 0, err
+	}
+	s.head, s.sum, s.time = head, sum, s.options.Now()
+	s.history = append(s.history, p)
+	return s.base + uint64(len(s.history)), nil
+}
+
+// Delta composes the patches from the given revision to the head revision.
+func (s *SyncServer) Delta(rev uint64) (*Delta, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	head := s.base + uint64(len(s.history))
+	if rev < s.base || rev > head {
+		return nil, fmt.Errorf("unable to compose delta from revision %d, %w", rev, ErrUnknownRevision)
+	}
+
+	d := &Delta{From: rev, To: head, Patch: Patch{}, Checksum: s.sum, Time: s.time}
+	for _, p := range s.history[rev-s.base:] {
+		d.Patch = append(d.Patch, p...)
+	}
+	return d, nil
+}
+
+// Compact drops the history before the given revision, clients older than it
+// have to fetch the head document again.
+func (s *SyncServer) Compact(rev uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	head := s.base + uint64(len(s.history))
+	if rev > head {
+		rev = head
+	}
+	if rev > s.base {
+		s.history = append([]Patch(nil), s.history[rev-s.base:]...)
+		s.base = rev
+	}
+}
+
+// SyncClient holds a replica of a document synchronized from a SyncServer.
+// It is safe for concurrent use.
+type SyncClient struct {
+	mu      sync.RWMutex
+	rev     uint64
+	doc     *Node
+	options *Options
+}
+
+// NewSyncClient creates a SyncClient with the document at the given revision.
+func NewSyncClient(rev uint64, doc []byte, options *Options) *SyncClient {
+	if options == nil {
+		options = NewOptions()
+	}
+	return &SyncClient{rev: rev, doc: NewNode(doc), options: options}
+}
+
+// Revision returns the revision of the replica, it should be sent to the server to request a delta.
+func (c *SyncClient) Revision() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.rev
+}
+
+// Document returns the document of the replica.
+func (c *SyncClient) Document() ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.doc.MarshalJSON()
+}
+
+// Apply applies a delta from the server to the replica, and verifies the checksum of the result.
+// The replica is left untouched if the delta does not apply or the checksum mismatches.
+func (c *SyncClient) Apply(d *Delta) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if d.From != c.rev {
+		return fmt.Errorf("unable to apply delta from revision %d to revision %d, %w",
+			d.From, c.rev, ErrUnknownRevision)
+	}
+
+	doc := c.doc.Clone()
+	if err := doc.Patch(d.Patch, c.options); err != nil {
+		return err
+	}
+	sum, err := doc.checksum()
+	if err != nil {
+		return err
+	}
+	if sum != d.Checksum {
+		return fmt.Errorf("unable to apply delta to revision %d, %w", d.To, ErrChecksumMismatch)
+	}
+	c.rev, c.doc = d.To, doc
+	return nil
+}
+
+// Checksum returns the hex encoded SHA-256 digest of a JSON document.
+// The digest is computed over the canonical JSON (see Node.MarshalCanonical), so it does not
+// depend on the order of object keys, insignificant whitespace or the notation of numbers and strings.
+func Checksum(doc []byte) (string, error) {
+	return NewNode(doc).checksum()
+}
+
+func (n *Node) checksum() (string, error) {
+	data, err := n.MarshalCanonical()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,113 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksum(t *testing.T) {
+	assert := assert.New(t)
+
+	a, err := Checksum([]byte(`{"a": 1, "b": [true, null]}`))
+	assert.Nil(err)
+	b, err := Checksum([]byte(`{"b":[true,null],"a":1}`))
+	assert.Nil(err)
+	assert.Equal(a, b)
+
+	b, err = Checksum([]byte(`{"b":[true,null],"a":2}`))
+	assert.Nil(err)
+	assert.NotEqual(a, b)
+
+	_, err = Checksum([]byte(`{"a":`))
+	assert.NotNil(err)
+}
+
+type testClock struct {
+	t time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.t = c.t.Add(time.Second)
+	return c.t
+}
+
+func TestSync(t *testing.T) {
+	assert := assert.New(t)
+
+	clock := &testClock{t: time.Unix(1000, 0)}
+	options := NewOptions()
+	options.Clock = clock
+	s, err := NewSyncServer([]byte(`{"name":"John","tags":[]}`), options)
+	assert.Nil(err)
+	assert.Equal(uint64(0), s.Revision())
+
+	c0 := NewSyncClient(0, []byte(`{"name":"John","tags":[]}`), nil)
+
+	rev, err := s.Commit(Patch{{Op: "replace", Path: "/name", Value: []byte(`"Jane"`)}})
+	assert.Nil(err)
+	assert.Equal(uint64(1), rev)
+
+	c1 := NewSyncClient(1, []byte(`{"name":"Jane","tags":[]}`), nil)
+
+	rev, err = s.Commit(Patch{{Op: "add", Path: "/tags/-", Value: []byte(`"a"`)}})
+	assert.Nil(err)
+	assert.Equal(uint64(2), rev)
+
+	_, err = s.Commit(Patch{{Op: "remove", Path: "/age"}})
+	assert.NotNil(err)
+	rev, doc, err := s.Head()
+	assert.Nil(err)
+	assert.Equal(uint64(2), rev)
+	assert.Equal(`{"name":"Jane","tags":["a"]}`, string(doc))
+
+	d, err := s.Delta(0)
+	assert.Nil(err)
+	assert.Equal(uint64(0), d.From)
+	assert.Equal(uint64(2), d.To)
+	assert.Equal(2, len(d.Patch))
+	assert.Equal(time.Unix(1003, 0), d.Time)
+
+	assert.Nil(c0.Apply(d))
+	assert.Equal(uint64(2), c0.Revision())
+	doc, err = c0.Document()
+	assert.Nil(err)
+	assert.Equal(`{"name":"Jane","tags":["a"]}`, string(doc))
+
+	// delta does not start at the client revision
+	err = c1.Apply(d)
+	assert.True(errors.Is(err, ErrUnknownRevision))
+
+	d, err = s.Delta(1)
+	assert.Nil(err)
+	assert.Equal(1, len(d.Patch))
+
+	d.Checksum = "bad"
+	err = c1.Apply(d)
+	assert.True(errors.Is(err, ErrChecksumMismatch))
+	assert.Equal(uint64(1), c1.Revision())
+	doc, err = c1.Document()
+	assert.Nil(err)
+	assert.Equal(`{"name":"Jane","tags":[]}`, string(doc))
+
+	d, err = s.Delta(2)
+	assert.Nil(err)
+	assert.Equal(0, len(d.Patch))
+
+	_, err = s.Delta(3)
+	assert.True(errors.Is(err, ErrUnknownRevision))
+
+	s.Compact(1)
+	_, err = s.Delta(0)
+	assert.True(errors.Is(err, ErrUnknownRevision))
+	d, err = s.Delta(1)
+	assert.Nil(err)
+	assert.Equal(1, len(d.Patch))
+	assert.Nil(c1.Apply(d))
+	assert.Equal(uint64(2), c1.Revision())
+}
@@ -0,0 +1,119 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// templateVar is the member name of the placeholders of the patch templates.
+const templateVar = "$var"
+
+// Bind returns a copy of the patch template with the placeholders in the values of the operations,
+// and in the values of the "splice" operations, replaced by the variables. A placeholder is an object with a single "$var" member, whose value is
+// the name of a variable, such as {"op": "replace", "path": "/owner", "value": {"$var": "user"}},
+// it can be the value itself or nested in the value. A nil variable is bound as null.
+// It returns an error wrapping ErrMissing if a variable is not defined.
+func (p Patch) Bind(vars map[string]json.RawMessage) (Patch, error) {
+	res := make(Patch, len(p))
+	for i, op := range p {
+		value, err := bindValue(op.Value, vars)
+		if err != nil {
+			return nil, fmt.Errorf("unable to bind operation %d %q for path %q, %w", i, op.Op, op.Path, err)
+		}
+		if op.Apply != nil {
+			if op.Apply, err = op.Apply.Bind(vars); err != nil {
+				return nil, fmt.Errorf("unable to bind operation %d %q for path %q, %w", i, op.Op, op.Path, err)
+			}
+		}
+		if op.Values != nil {
+			values := make([]json.RawMessage, len(op.Values))
+			for j, v := range op.Values {
+				if values[j], err = bindValue(v, vars); err != nil {
+					return nil, fmt.Errorf("unable to bind operation %d %q for path %q, %w", i, op.Op, op.Path, err)
+				}
+			}
+			op.Values = values
+		}
+		op.Value, op.compiled = value, nil
+		res[i] = op
+	}
+	return res, nil
+}
+
+// ApplyTemplate binds the patch template with the variables, and applies it to the JSON document
+// with the passed in Options, see Patch.Bind. It returns the new document.
+func (p Patch) ApplyTemplate(doc []byte, vars map[string]json.RawMessage, options *Options) ([]byte, error) {
+	b, err := p.Bind(vars)
+	if err != nil {
+		return nil, err
+	}
+	return b.ApplyWithOptions(doc, options)
+}
+
+// bindValue returns the value with the placeholders replaced, the values without placeholders are returned as is.
+func bindValue(value json.RawMessage, vars map[string]json.RawMessage) (json.RawMessage, error) {
+	if !bytes.Contains(value, []byte(`"`+templateVar+`"`)) {
+		return value, nil
+	}
+
+	n, err := NewNode(value).bind(vars)
+	if err != nil {
+		return nil, err
+	}
+	return n.MarshalJSON()
+}
+
+// bind replaces the placeholders in the node, it returns the node of the variable if the node is a placeholder.
+func (n *Node) bind(vars map[string]json.RawMessage) (*Node, error) {
+	pd, err := n.intoContainer()
+	if pd == nil {
+		if n != nil && n.raw != nil && checkWhich(*n.raw) != eOther {
+			// the invalid objects and arrays.
+			return nil, err
+		}
+		return n, nil
+	}
+
+	switch n.which {
+	case eDoc:
+		if len(n.doc.keys) == 1 && n.doc.keys[0] == templateVar {
+			return bindVar(n.doc.obj[templateVar], vars)
+		}
+		for _, k := range n.doc.keys {
+			if n.doc.obj[k], err = n.doc.obj[k].bind(vars); err != nil {
+				return nil, err
+			}
+		}
+	case eAry:
+		for i, v := range n.ary {
+			if n.ary[i], err = v.bind(vars); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// bindVar returns the node of the variable named by the "$var" member of a placeholder.
+func bindVar(name *Node, vars map[string]json.RawMessage) (*Node, error) {
+	var key string
+	if name == nil || name.raw == nil || json.Unmarshal(*name.raw, &key) != nil {
+		return nil, fmt.Errorf("unable to bind placeholder without variable name, %w", ErrInvalid)
+	}
+
+	v, ok := vars[key]
+	if !ok {
+		return nil, fmt.Errorf("unable to bind variable %q, %w", key, ErrMissing)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	if err := checkValidJSON(v); err != nil {
+		return nil, fmt.Errorf("unable to bind variable %q, %w", key, ErrInvalid)
+	}
+	return NewNode(v), nil
+}
@@ -0,0 +1,100 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatchBind(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := NewPatch([]byte(`[
+		{"op": "replace", "path": "/owner", "value": {"$var": "user"}},
+		{"op": "add", "path": "/tags/-", "value": {"name": {"$var": "tag"}, "at": [1, {"$var": "n"}]}},
+		{"op": "add", "path": "/note", "value": {"$var": "none"}},
+		{"op": "test", "path": "/id", "value": 1}
+	]`))
+	assert.Nil(err)
+
+	vars := map[string]json.RawMessage{
+		"user": json.RawMessage(`{"id":"u1","name":"Alice"}`),
+		"tag":  json.RawMessage(`"x"`),
+		"n":    json.RawMessage(`2`),
+		"none": nil,
+	}
+	b, err := p.Bind(vars)
+	assert.Nil(err)
+	assert.Equal(`{"id":"u1","name":"Alice"}`, string(b[0].Value))
+	assert.Equal(`{"name":"x","at":[1,2]}`, string(b[1].Value))
+	assert.Equal(`null`, string(b[2].Value))
+	assert.Equal(`1`, string(b[3].Value))
+	// the template is not modified.
+	assert.Equal(`{"$var": "user"}`, string(p[0].Value))
+
+	doc := []byte(`{"id":1,"owner":null,"tags":[]}`)
+	res, err := p.ApplyTemplate(doc, vars, nil)
+	assert.Nil(err)
+	assert.Equal(`{"id":1,"owner":{"id":"u1","name":"Alice"},"tags":[{"name":"x","at":[1,2]}],"note":null}`, string(res))
+
+	vars["user"] = json.RawMessage(`"u2"`)
+	res, err = p.ApplyTemplate(doc, vars, nil)
+	assert.Nil(err)
+	assert.Equal(`{"id":1,"owner":"u2","tags":[{"name":"x","at":[1,2]}],"note":null}`, string(res))
+
+	delete(vars, "tag")
+	_, err = p.ApplyTemplate(doc, vars, nil)
+	assert.ErrorIs(err, ErrMissing)
+
+	for i, c := range []struct {
+		value string
+		vars  map[string]json.RawMessage
+	}{
+		{`{"$var": 1}`, nil},
+		{`{"$var": null}`, nil},
+		{`{"$var": "a"}`, map[string]json.RawMessage{"a": json.RawMessage(`{`)}},
+	} {
+		_, err := Patch{{Op: "add", Path: "/a", Value: json.RawMessage(c.value)}}.Bind(c.vars)
+		assert.ErrorIs(err, ErrInvalid, "case %d", i)
+	}
+
+	_, err = Patch{{Op: "add", Path: "/a", Value: json.RawMessage(`[{"$var": "a"}`)}}.Bind(nil)
+	assert.NotNil(err)
+
+	// a "$var" member along with other members is not a placeholder.
+	b, err = Patch{{Op: "add", Path: "/a", Value: json.RawMessage(`{"$var": "a", "b": 1}`)}}.Bind(nil)
+	assert.Nil(err)
+	assert.Equal(`{"$var":"a","b":1}`, string(b[0].Value))
+
+	options := NewOptions()
+	options.EnablePredicates = true
+	p, err = NewPatch([]byte(`[{"op": "not", "path": "", "apply": [{"op": "contains", "path": "/owner", "value": {"$var": "user"}}]}]`))
+	assert.Nil(err)
+	res, err = p.ApplyTemplate([]byte(`{"owner":"bob"}`), map[string]json.RawMessage{"user": json.RawMessage(`"al"`)}, options)
+	assert.Nil(err)
+	assert.Equal(`{"owner":"bob"}`, string(res))
+	_, err = p.ApplyTemplate([]byte(`{"owner":"alice"}`), map[string]json.RawMessage{"user": json.RawMessage(`"al"`)}, options)
+	assert.ErrorIs(err, ErrTestFailed)
+
+	// the values of the "splice" operations are bound too.
+	options = NewOptions()
+	options.EnableSplice = true
+	p, err = NewPatch([]byte(`[{"op": "splice", "path": "/l/1", "remove": 1, "values": [{"$var": "a"}, {"b": {"$var": "b"}}, 3]}]`))
+	assert.Nil(err)
+	vars = map[string]json.RawMessage{"a": json.RawMessage(`"x"`), "b": nil}
+	b, err = p.Bind(vars)
+	assert.Nil(err)
+	assert.Equal(`"x"`, string(b[0].Values[0]))
+	assert.Equal(`{"b":null}`, string(b[0].Values[1]))
+	assert.Equal(`3`, string(b[0].Values[2]))
+	assert.Equal(`{"$var": "a"}`, string(p[0].Values[0]))
+	res, err = p.ApplyTemplate([]byte(`{"l":[1,2]}`), vars, options)
+	assert.Nil(err)
+	assert.Equal(`{"l":[1,"x",{"b":null},3]}`, string(res))
+	_, err = p.Bind(map[string]json.RawMessage{"a": json.RawMessage(`1`)})
+	assert.ErrorIs(err, ErrMissing)
+}
@@ -0,0 +1,235 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WithGuards returns a copy of the patch with a "test" operation inserted before every "replace"
+// and "remove" operation, which asserts the value in the document that the patch is built for.
+// Applying the guarded patch fails if any of the replaced or removed values has been changed since.
+func WithGuards(p Patch, doc []byte) (Patch, error) {
+	guarded := make(Patch, 0, len(p))
+	err := NewNode(doc).patch(p, nil, func(c Change) {
+		op := p[c.Index]
+		if (op.Op == "replace" || op.Op == "remove") && c.Old != nil {
+			guarded = append(guarded, NewTestOperation(op.Path, c.Old))
+		}
+		guarded = append(guarded, op)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return guarded, nil
+}
+
+// Rebase returns a copy of the patch with the prefix JSON Pointer prepended to the paths
+// and the from paths of the operations, so that a patch for a subtree applies to the whole document.
+func (p Patch) Rebase(prefix string) Patch {
+	rebased := make(Patch, len(p))
+	for i, op := range p {
+		op.Path = prefix + op.Path
+		if op.From != "" || op.Op == "move" || op.Op == "copy" {
+			op.From = prefix + op.From
+		}
+		rebased[i] = op
+	}
+	return rebased
+}
+
+// FilterByPrefix returns the operations of the patch which path or from path is in the subtree
+// at the prefix JSON Pointer, or is an ancestor of the prefix, such as a "replace" operation of
+// the whole document or a "move" operation of a parent, which change the subtree as a whole.
+func (p Patch) FilterByPrefix(prefix string) Patch {
+	filtered := make(Patch, 0)
+	for _, op := range p {
+		if pathsOverlap(op.Path, prefix) ||
+			(op.Op == "move" || op.Op == "copy") && pathsOverlap(op.From, prefix) {
+			filtered = append(filtered, op)
+		}
+	}
+	return filtered
+}
+
+// hasPathPrefix reports whether the path is in the subtree at prefix.
+func hasPathPrefix(path, prefix string) bool {
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// ConcatPatches returns a patch of the operations of the patches in order.
+func ConcatPatches(ps ...Patch) Patch {
+	n := 0
+	for _, p := range ps {
+		n += len(p)
+	}
+	res := make(Patch, 0, n)
+	for _, p := range ps {
+		res = append(res, p...)
+	}
+	return res
+}
+
+// Squash returns a minimal patch that is equivalent to the patch for the document,
+// the redundant and overwritten operations are eliminated.
+// The squashed patch is computed by diffing the document with the patch applied.
+func (p Patch) Squash(doc []byte) (Patch, error) {
+	res, err := p.Apply(doc)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(doc, res, nil)
+}
+
+// Prune returns a copy of the patch without the operations that don't change the document:
+// the "add", "replace" and "copy" operations that write a value equal to the value already at their path,
+// and the "move" operations to their from path. The other operations are kept, the "test" operations too.
+// The operations are checked against the document with the previous operations applied.
+func (p Patch) Prune(doc []byte) (Patch, error) {
+	pruned := make(Patch, 0, len(p))
+	err := NewNode(doc).patch(p, nil, func(c Change) {
+		op := p[c.Index]
+		switch op.Op {
+		case "add", "replace", "copy":
+			if c.Old != nil && NewNode(c.Old).Equal(NewNode(c.New)) {
+				return
+			}
+		case "move":
+			if c.From == c.Path {
+				return
+			}
+		}
+		pruned = append(pruned, op)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pruned, nil
+}
+
+// ErrTransformConflict is returned by Transform when an operation can not be transformed
+// against a concurrent operation, such as an operation in a subtree that has been removed.
+var ErrTransformConflict = errors.New("conflicting operations")
+
+// Transform rewrites the patch a, which is produced for the same document as the patch b,
+// so that it applies after the patch b has been applied. The array indices in the paths of a are
+// adjusted for the elements inserted and removed by b, and the paths in the subtrees moved by b follow them.
+// The operations of a that remove the values already removed by b are dropped, and the elements inserted
+// at the same index by both patches are ordered after the ones of b. Numeric reference tokens are
+// treated as array indices. It returns an error wrapping ErrTransformConflict if an operation of a
+// reads or writes a value that has been removed or replaced by b.
+func Transform(a, b Patch) (Patch, error) {
+	res := make(Patch, 0, len(a))
+	bs := make(Patch, len(b))
+	copy(bs, b)
+
+	for i, x := range a {
+		keep := true
+		ys := bs[:0]
+		for j, y := range bs {
+			// x and y apply to the same document here, x is transformed to apply after y,
+			// and y is transformed to apply after x for the next operations of a.
+			if keep {
+				x2, ok := transformOp(x, y, true)
+				switch {
+				case !ok && x.Op == "remove" && y.Op == "remove" && x.Path == y.Path:
+					keep = false
+				case !ok:
+					return nil, fmt.Errorf("unable to transform operation %d %q for path %q against operation %d %q, %w",
+						i, x.Op, x.Path, j, y.Op, ErrTransformConflict)
+				}
+
+				if y2, ok := transformOp(y, x, false); ok && keep {
+					ys = append(ys, y2)
+				}
+				x = x2
+			} else {
+				ys = append(ys, y)
+			}
+		}
+
+		bs = ys
+		if keep {
+			res = append(res, x)
+		}
+	}
+	return res, nil
+}
+
+// transformOp transforms the operation x to apply after the concurrent operation y,
+// yFirst indicates that y is ordered before x for the elements inserted at the same index.
+// It returns false if x reads or writes a value that has been removed or replaced by y.
+func transformOp(x, y Operation, yFirst bool) (Operation, bool) {
+	insert := x.Op == "add" || x.Op == "move" || x.Op == "copy"
+	path, ok := transformPath(x.Path, insert, y, yFirst)
+	if !ok {
+		return x, false
+	}
+	if x.Op == "move" || x.Op == "copy" {
+		if x.From, ok = transformPath(x.From, false, y, yFirst); !ok {
+			return x, false
+		}
+	}
+	x.Path = path
+	return x, true
+}
+
+// transformPath transforms the path to apply after the operation y. insert indicates that
+// the path is the target of an "add", "move" or "copy" operation, which doesn't need an existing value.
+func transformPath(path string, insert bool, y Operation, yFirst bool) (string, bool) {
+	switch y.Op {
+	case "add", "copy":
+		return insertPath(path, insert, y.Path, yFirst), true
+	case "remove":
+		if hasPathPrefix(path, y.Path) && !(insert && path == y.Path) {
+			return path, false
+		}
+		return shiftPath(path, y.Path, -1), true
+	case "move":
+		if hasPathPrefix(path, y.From) && !(insert && path == y.From) {
+			return y.Path + path[len(y.From):], true
+		}
+		return insertPath(shiftPath(path, y.From, -1), insert, y.Path, yFirst), true
+	case "replace":
+		return path, path == y.Path || !hasPathPrefix(path, y.Path)
+	}
+	return path, true
+}
+
+// insertPath shifts the path for the element inserted at target, the paths inserting at the same index
+// are shifted only if the target is inserted first.
+func insertPath(path string, insert bool, target string, targetFirst bool) string {
+	if insert && path == target && !targetFirst {
+		return path
+	}
+	return shiftPath(path, target, 1)
+}
+
+// shiftPath shifts the array index in the path at the same level of the index of the array element
+// that target inserts (delta 1) or removes (delta -1).
+func shiftPath(path, target string, delta int) string {
+	i := strings.LastIndexByte(target, '/')
+	if i < 0 {
+		return path
+	}
+	parent, key := target[:i+1], target[i+1:]
+	idx, err := strconv.Atoi(key)
+	if err != nil || idx < 0 || !strings.HasPrefix(path, parent) {
+		return path
+	}
+
+	rest := path[len(parent):]
+	token, tail := rest, ""
+	if k := strings.IndexByte(rest, '/'); k >= 0 {
+		token, tail = rest[:k], rest[k:]
+	}
+	n, err := strconv.Atoi(token)
+	if err != nil || n < idx || n == idx && delta < 0 {
+		return path
+	}
+	return parent + strconv.Itoa(n+delta) + tail
+}
@@ -0,0 +1,223 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithGuards(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"name":"John","tags":["a","b"],"meta":{"v":1}}`)
+	p := Patch{
+		NewReplaceOperation("/name", []byte(`"Jane"`)),
+		NewAddOperation("/tags/0", []byte(`"z"`)),
+		NewRemoveOperation("/tags/1"),
+		NewReplaceOperation("/name", []byte(`"Joe"`)),
+		NewReplaceOperation("", []byte(`{"meta":{}}`)),
+	}
+
+	guarded, err := WithGuards(p, doc)
+	assert.Nil(err)
+	assert.Equal(`[{"op":"test","path":"/name","value":"John"},`+
+		`{"op":"replace","path":"/name","value":"Jane"},`+
+		`{"op":"add","path":"/tags/0","value":"z"},`+
+		`{"op":"test","path":"/tags/1","value":"a"},`+
+		`{"op":"remove","path":"/tags/1"},`+
+		`{"op":"test","path":"/name","value":"Jane"},`+
+		`{"op":"replace","path":"/name","value":"Joe"},`+
+		`{"op":"test","path":"","value":{"name":"Joe","tags":["z","b"],"meta":{"v":1}}},`+
+		`{"op":"replace","path":"","value":{"meta":{}}}]`, mustJSONString(guarded))
+
+	res, err := guarded.Apply(doc)
+	assert.Nil(err)
+	assert.Equal(`{"meta":{}}`, string(res))
+
+	_, err = guarded.Apply([]byte(`{"name":"Jim","tags":["a","b"],"meta":{"v":1}}`))
+	assert.True(errors.Is(err, ErrTestFailed))
+
+	_, err = WithGuards(Patch{NewRemoveOperation("/age")}, doc)
+	assert.True(errors.Is(err, ErrMissing))
+}
+
+func TestRebaseAndFilterByPrefix(t *testing.T) {
+	assert := assert.New(t)
+
+	p := Patch{
+		NewReplaceOperation("/name", []byte(`"Jane"`)),
+		NewMoveOperation("", "/spec"),
+		NewCopyOperation("/tags/0", "/first"),
+	}
+	r := p.Rebase("/items/0")
+	assert.Equal(Patch{
+		NewReplaceOperation("/items/0/name", []byte(`"Jane"`)),
+		NewMoveOperation("/items/0", "/items/0/spec"),
+		NewCopyOperation("/items/0/tags/0", "/items/0/first"),
+	}, r)
+	assert.Equal("/name", p[0].Path)
+	assert.Equal(p, p.Rebase(""))
+
+	p = Patch{
+		NewReplaceOperation("/spec/name", []byte(`"Jane"`)),
+		NewAddOperation("/specs", []byte(`{}`)),
+		NewRemoveOperation("/spec"),
+		NewMoveOperation("/spec/tags", "/status/tags"),
+		NewCopyOperation("/status/tags", "/status/labels"),
+		NewTestOperation("/status", []byte(`{}`)),
+	}
+	assert.Equal(Patch{p[0], p[2], p[3]}, p.FilterByPrefix("/spec"))
+	assert.Equal(Patch{p[3], p[4], p[5]}, p.FilterByPrefix("/status"))
+	assert.Equal(Patch{}, p.FilterByPrefix("/meta"))
+	assert.Equal(p, p.FilterByPrefix(""))
+
+	p = Patch{
+		NewReplaceOperation("", []byte(`{}`)),
+		NewRemoveOperation("/a"),
+		NewTestOperation("/a/b", []byte(`{}`)),
+		NewMoveOperation("/a", "/x"),
+		NewCopyOperation("", "/y"),
+		NewAddOperation("/a/bc", []byte(`1`)),
+		NewRemoveOperation("/a/c/b"),
+		NewMoveOperation("/z", "/w"),
+	}
+	assert.Equal(Patch{p[0], p[1], p[2], p[3], p[4]}, p.FilterByPrefix("/a/b"))
+	assert.Equal(Patch{p[0], p[3], p[4]}, p.FilterByPrefix("/x/b"))
+
+	res, err := Patch{NewAddOperation("/tags/-", []byte(`"b"`))}.Rebase("/spec").
+		Apply([]byte(`{"spec":{"tags":["a"]}}`))
+	assert.Nil(err)
+	assert.Equal(`{"spec":{"tags":["a","b"]}}`, string(res))
+}
+
+func TestConcatAndSquash(t *testing.T) {
+	assert := assert.New(t)
+
+	a := Patch{
+		NewReplaceOperation("/name", []byte(`"Jane"`)),
+		NewAddOperation("/tags/-", []byte(`"b"`)),
+	}
+	b := Patch{
+		NewRemoveOperation("/name"),
+		NewReplaceOperation("/tags/1", []byte(`"c"`)),
+		NewAddOperation("/age", []byte(`1`)),
+		NewReplaceOperation("/age", []byte(`2`)),
+	}
+	p := ConcatPatches(a, nil, b)
+	assert.Equal(6, len(p))
+	assert.Equal(Patch{}, ConcatPatches())
+
+	doc := []byte(`{"name":"John","tags":["a"]}`)
+	s, err := p.Squash(doc)
+	assert.Nil(err)
+	assert.True(len(s) < len(p))
+
+	res, err := p.Apply(doc)
+	assert.Nil(err)
+	sres, err := s.Apply(doc)
+	assert.Nil(err)
+	assert.True(Equal(res, sres))
+
+	s, err = Patch{
+		NewReplaceOperation("/name", []byte(`"Jane"`)),
+		NewReplaceOperation("/name", []byte(`"John"`)),
+	}.Squash(doc)
+	assert.Nil(err)
+	assert.Equal(0, len(s))
+
+	_, err = b.Squash(doc)
+	assert.NotNil(err)
+}
+
+func TestPrune(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"name":"John","tags":["a","b"],"meta":{"v":1,"w":[1, 2]}}`)
+	p := Patch{
+		NewReplaceOperation("/name", []byte(`"John"`)),
+		NewReplaceOperation("/name", []byte(`"Jane"`)),
+		NewAddOperation("/meta/v", []byte(`1`)),
+		NewAddOperation("/meta/w", []byte(`[1,2]`)),
+		NewAddOperation("/tags/1", []byte(`"b"`)),
+		NewTestOperation("/name", []byte(`"Jane"`)),
+		NewMoveOperation("/meta", "/meta"),
+		NewCopyOperation("/tags/1", "/tags/2"),
+		NewCopyOperation("/meta/v", "/meta/x"),
+		NewCopyOperation("/meta/x", "/meta/v"),
+		NewReplaceOperation("", []byte(`{"name":"Jane","tags":["a","b","b","b"],"meta":{"v":1,"w":[1,2],"x":1}}`)),
+		NewRemoveOperation("/meta/x"),
+	}
+	pruned, err := p.Prune(doc)
+	assert.Nil(err)
+	assert.Equal(Patch{p[1], p[4], p[5], p[7], p[8], p[11]}, pruned)
+
+	res, err := p.Apply(doc)
+	assert.Nil(err)
+	pres, err := pruned.Apply(doc)
+	assert.Nil(err)
+	assert.True(Equal(res, pres))
+
+	pruned, err = Patch{NewAddOperation("/name", []byte(`"John"`))}.Prune(doc)
+	assert.Nil(err)
+	assert.Equal(Patch{}, pruned)
+
+	_, err = Patch{NewReplaceOperation("/age", []byte(`1`))}.Prune(doc)
+	assert.True(errors.Is(err, ErrMissing))
+}
+
+func TestTransform(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := []byte(`{"list":["a","b","c","d"],"meta":{"x":{"y":1}},"name":"n"}`)
+	a := Patch{
+		NewReplaceOperation("/list/2", []byte(`"C"`)),
+		NewRemoveOperation("/list/0"),
+		NewAddOperation("/meta/x/z", []byte(`2`)),
+		NewTestOperation("/list/2", []byte(`"d"`)),
+		NewAddOperation("/list/0", []byte(`"y"`)),
+	}
+	b := Patch{
+		NewAddOperation("/list/0", []byte(`"z"`)),
+		NewRemoveOperation("/list/2"),
+		NewMoveOperation("/meta/x", "/moved"),
+		NewReplaceOperation("/name", []byte(`"m"`)),
+	}
+
+	a2, err := Transform(a, b)
+	assert.Nil(err)
+	assert.Equal(Patch{
+		NewReplaceOperation("/list/2", []byte(`"C"`)),
+		NewRemoveOperation("/list/1"),
+		NewAddOperation("/moved/z", []byte(`2`)),
+		NewTestOperation("/list/2", []byte(`"d"`)),
+		NewAddOperation("/list/1", []byte(`"y"`)),
+	}, a2)
+
+	res, err := ConcatPatches(b, a2).Apply(doc)
+	assert.Nil(err)
+	assert.Equal(`{"list":["z","y","C","d"],"meta":{},"name":"m","moved":{"y":1,"z":2}}`, string(res))
+
+	a2, err = Transform(Patch{NewMoveOperation("/list/3", "/list/0")}, Patch{NewRemoveOperation("/list/0")})
+	assert.Nil(err)
+	assert.Equal(Patch{NewMoveOperation("/list/2", "/list/0")}, a2)
+
+	a2, err = Transform(Patch{NewRemoveOperation("/list/1"), NewRemoveOperation("/list/1")},
+		Patch{NewRemoveOperation("/list/2")})
+	assert.Nil(err)
+	assert.Equal(Patch{NewRemoveOperation("/list/1")}, a2)
+
+	_, err = Transform(Patch{NewReplaceOperation("/list/1", []byte(`1`))}, Patch{NewRemoveOperation("/list/1")})
+	assert.True(errors.Is(err, ErrTransformConflict))
+	_, err = Transform(Patch{NewAddOperation("/meta/x/y", []byte(`1`))}, Patch{NewReplaceOperation("/meta", []byte(`1`))})
+	assert.True(errors.Is(err, ErrTransformConflict))
+	_, err = Transform(Patch{NewCopyOperation("/meta/x", "/c")}, Patch{NewRemoveOperation("/meta")})
+	assert.True(errors.Is(err, ErrTransformConflict))
+
+	a2, err = Transform(Patch{NewReplaceOperation("/name", []byte(`"a"`))}, Patch{NewReplaceOperation("/name", []byte(`"b"`))})
+	assert.Nil(err)
+	assert.Equal(1, len(a2))
+}
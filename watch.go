@@ -0,0 +1,86 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+)
+
+// watchList is the watchers of the changes of a node.
+type watchList struct {
+	watchers []*watcher
+}
+
+type watcher struct {
+	prefix string
+	fn     func(path string, old, new json.RawMessage)
+}
+
+// OnChange registers fn to be called with the path, the old and the new values of every change of the node
+// that overlaps the prefix: a change at the prefix, under it, or of an ancestor of it, so that the cached
+// values of the prefix can be invalidated. The empty prefix watches all the changes.
+// The changes are made by the patches of the node, including PatchAt, PatchAtomic, Remove,
+// RemoveChildren, ReplaceChildren, Redact and RedactChildren, fn is called after each operation
+// with the resolved paths as Change, a "move" operation is a removal at its from path and an addition at
+// its path, and the "test" operations are not changes. For PatchAtomic, fn is called after all operations
+// succeed. The old or the new value is nil if there is no value. It returns a function that cancels
+// the registration.
+func (n *Node) OnChange(prefix string, fn func(path string, old, new json.RawMessage)) (cancel func()) {
+	if n.watch == nil {
+		n.watch = &watchList{}
+	}
+	w := &watcher{prefix: prefix, fn: fn}
+	n.watch.watchers = append(n.watch.watchers, w)
+	list := n.watch
+	return func() {
+		for i, v := range list.watchers {
+			if v == w {
+				list.watchers = append(list.watchers[:i:i], list.watchers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// watching returns the function that notifies the watchers of the node, with the paths of the changes
+// prefixed by basePath, or nil if the node has no watchers.
+func (n *Node) watching(basePath string) func(Change) {
+	if n == nil || n.watch == nil || len(n.watch.watchers) == 0 {
+		return nil
+	}
+	list := n.watch
+	return func(c Change) {
+		switch c.Op {
+		case "test":
+		case "move":
+			list.notify(basePath+c.From, c.Old, nil)
+			list.notify(basePath+c.Path, nil, c.New)
+		default:
+			list.notify(basePath+c.Path, c.Old, c.New)
+		}
+	}
+}
+
+func (l *watchList) notify(path string, old, new json.RawMessage) {
+	// the watchers may be canceled by the callbacks.
+	for _, w := range append([]*watcher(nil), l.watchers...) {
+		if pathsOverlap(path, w.prefix) {
+			w.fn(path, old, new)
+		}
+	}
+}
+
+// chainObserve returns the function that calls a and b, either of them may be nil.
+func chainObserve(a, b func(Change)) func(Change) {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	return func(c Change) {
+		a(c)
+		b(c)
+	}
+}
@@ -0,0 +1,90 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type watchEvent struct {
+	path, old, new string
+}
+
+func TestNodeOnChange(t *testing.T) {
+	assert := assert.New(t)
+
+	node := NewNode([]byte(`{"user":{"name":"a","tags":["x"]},"count":1}`))
+	var user, all []watchEvent
+	record := func(events *[]watchEvent) func(path string, old, new json.RawMessage) {
+		return func(path string, old, new json.RawMessage) {
+			*events = append(*events, watchEvent{path, string(old), string(new)})
+		}
+	}
+	cancelUser := node.OnChange("/user/tags", record(&user))
+	cancelAll := node.OnChange("", record(&all))
+
+	assert.NoError(node.Patch(Patch{
+		{Op: "replace", Path: "/count", Value: []byte(`2`)},
+		{Op: "add", Path: "/user/tags/-", Value: []byte(`"y"`)},
+		{Op: "test", Path: "/count", Value: []byte(`2`)},
+		{Op: "move", From: "/user/name", Path: "/name"},
+	}, nil))
+	assert.Equal([]watchEvent{{"/user/tags/1", "", `"y"`}}, user)
+	assert.Equal([]watchEvent{
+		{"/count", "1", "2"},
+		{"/user/tags/1", "", `"y"`},
+		{"/user/name", `"a"`, ""},
+		{"/name", "", `"a"`},
+	}, all)
+
+	// the changes of the ancestors overlap the prefix.
+	user, all = nil, nil
+	assert.NoError(node.PatchAt("/user", Patch{{Op: "replace", Path: "", Value: []byte(`{"tags":[]}`)}}, nil))
+	assert.NoError(node.PatchAt("/user", Patch{{Op: "add", Path: "/tags/0", Value: []byte(`"z"`)}}, nil))
+	_, err := node.Remove("/count", nil)
+	assert.NoError(err)
+	assert.Equal([]watchEvent{
+		{"/user", `{"tags":["x","y"]}`, `{"tags":[]}`},
+		{"/user/tags/0", "", `"z"`},
+	}, user)
+	assert.Len(all, 3)
+
+	// the atomic patches notify after all operations succeed.
+	user, all = nil, nil
+	err = node.PatchAtomic(Patch{
+		{Op: "remove", Path: "/user/tags/0"},
+		{Op: "test", Path: "/name", Value: []byte(`"b"`)},
+	}, nil)
+	assert.ErrorIs(err, ErrTestFailed)
+	assert.Nil(user)
+	assert.NoError(node.PatchAtomic(Patch{{Op: "remove", Path: "/user/tags/0"}}, nil))
+	assert.Equal([]watchEvent{{"/user/tags/0", `"z"`, ""}}, user)
+	assert.NoError(node.PatchAtomic(Patch{{Op: "add", Path: "/user/tags/-", Value: []byte(`1`)}}, nil))
+	assert.Len(user, 2)
+
+	// the redactions are changes.
+	user, all = nil, nil
+	count, err := node.Redact([]string{"/user/tags/*", "/name"}, []byte(`"***"`), nil)
+	assert.NoError(err)
+	assert.Equal(2, count)
+	assert.Equal([]watchEvent{{"/user/tags/0", `1`, `"***"`}}, user)
+	assert.Len(all, 2)
+	count, err = node.RedactChildren([]*PV{{Path: "/tags/0", Value: []byte(`"***"`)}}, []string{"/tags/0"}, nil, nil)
+	assert.NoError(err)
+	assert.Equal(1, count)
+	assert.Equal(watchEvent{"/user/tags/0", `"***"`, ""}, user[1])
+
+	cancelUser()
+	cancelUser()
+	user, all = nil, nil
+	assert.NoError(node.Patch(Patch{{Op: "add", Path: "/user/tags/-", Value: []byte(`2`)}}, nil))
+	assert.Nil(user)
+	assert.Len(all, 1)
+	cancelAll()
+	assert.NoError(node.Patch(Patch{{Op: "add", Path: "/user/tags/-", Value: []byte(`3`)}}, nil))
+	assert.Len(all, 1)
+}
@@ -0,0 +1,536 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package yamlpatch applies JSON patches (RFC 6902) and JSON merge patches (RFC 7386) to YAML documents,
+// such as Kubernetes manifests and CI configurations.
+//
+// The YAML document is converted into JSON and patched by the jsonpatch package, then the result is merged
+// back into the original YAML tree: the unchanged values keep their styles, comments, anchors and tags,
+// the changed values keep their comments, and the indentation of the document is detected and kept.
+// Aliases are expanded where the aliased values are changed. Only single document streams are supported.
+package yamlpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	jsonpatch "github.com/ldclabs/json-patch"
+	"gopkg.in/yaml.v3"
+)
+
+// maxDepth limits the nesting depth of the YAML documents, including the expansion of aliases.
+const maxDepth = 1000
+
+var decimalInt = regexp.MustCompile(`^-?(0|[1-9][0-9]*)$`)
+
+// ApplyToYAML applies the JSON patch to the YAML document, and returns the new YAML document.
+func ApplyToYAML(doc []byte, patch jsonpatch.Patch) ([]byte, error) {
+	return ApplyToYAMLWithOptions(doc, patch, jsonpatch.NewOptions())
+}
+
+// ApplyToYAMLWithOptions applies the JSON patch to the YAML document with the passed in Options,
+// and returns the new YAML document.
+func ApplyToYAMLWithOptions(doc []byte, patch jsonpatch.Patch, options *jsonpatch.Options) ([]byte, error) {
+	return transform(doc, func(data []byte) ([]byte, error) {
+		return patch.ApplyWithOptions(data, options)
+	})
+}
+
+// MergePatchYAML applies the JSON merge patch to the YAML document, and returns the new YAML document.
+// The merge patch can be written in YAML or JSON.
+func MergePatchYAML(doc, patch []byte) ([]byte, error) {
+	mp, err := ToJSON(patch)
+	if err != nil {
+		return nil, err
+	}
+	return transform(doc, func(data []byte) ([]byte, error) {
+		return jsonpatch.MergePatch(data, mp)
+	})
+}
+
+// ToJSON converts the YAML document into JSON, the order of mapping keys is kept.
+func ToJSON(doc []byte) ([]byte, error) {
+	root, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	return toJSON(root, 0)
+}
+
+func transform(doc []byte, fn func([]byte) ([]byte, error)) ([]byte, error) {
+	root, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	data, err := toJSON(root, 0)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = fn(data); err != nil {
+		return nil, err
+	}
+
+	content := root.Content[0]
+	if root.Content[0], err = merge(content, data, 0); err != nil {
+		return nil, err
+	}
+	root = expandAliases(root, make(map[*yaml.Node]bool), 0)
+
+	var buf bytes.Buffer
+	en := yaml.NewEncoder(&buf)
+	en.SetIndent(detectIndent(doc))
+	if err = en.Encode(root); err != nil {
+		return nil, err
+	}
+	if err = en.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decode decodes the YAML document into a document node with a content.
+func decode(doc []byte) (*yaml.Node, error) {
+	root := &yaml.Node{}
+	de := yaml.NewDecoder(bytes.NewReader(doc))
+	err := de.Decode(root)
+	switch {
+	case err == io.EOF:
+		root.Kind = yaml.DocumentNode
+	case err != nil:
+		return nil, err
+	default:
+		var extra yaml.Node
+		if err = de.Decode(&extra); err != io.EOF {
+			if err == nil {
+				err = errors.New("multiple YAML documents are not supported")
+			}
+			return nil, err
+		}
+	}
+
+	if len(root.Content) == 0 {
+		root.Content = []*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}}
+	}
+	return root, nil
+}
+
+// toJSON converts the YAML node into JSON.
+func toJSON(n *yaml.Node, depth int) ([]byte, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("YAML nesting depth exceeds %d", maxDepth)
+	}
+
+	var buf bytes.Buffer
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return []byte("null"), nil
+		}
+		return toJSON(n.Content[0], depth+1)
+
+	case yaml.AliasNode:
+		return toJSON(n.Alias, depth+1)
+
+	case yaml.ScalarNode:
+		return scalarJSON(n)
+
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, v := range n.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			data, err := toJSON(v, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(data)
+		}
+		buf.WriteByte(']')
+		return buf.Bytes(), nil
+
+	case yaml.MappingNode:
+		obj := &object{values: make(map[string]json.RawMessage)}
+		if err := obj.addMapping(n, depth); err != nil {
+			return nil, err
+		}
+		return obj.marshal()
+	}
+	return nil, fmt.Errorf("unexpected YAML node at line %d", n.Line)
+}
+
+// object is a JSON object with the order of keys.
+type object struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+func (o *object) set(key string, value json.RawMessage, override bool) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	} else if !override {
+		return
+	}
+	o.values[key] = value
+}
+
+// addMapping adds the pairs of the mapping node, the explicit keys override the merged keys ("<<").
+func (o *object) addMapping(n *yaml.Node, depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("YAML nesting depth exceeds %d", maxDepth)
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := resolveAlias(n.Content[i]), n.Content[i+1]
+		if k.Kind != yaml.ScalarNode {
+			return fmt.Errorf("unsupported YAML mapping key at line %d", k.Line)
+		}
+
+		if k.ShortTag() == "!!merge" {
+			v = resolveAlias(v)
+			merges := []*yaml.Node{v}
+			if v.Kind == yaml.SequenceNode {
+				merges = v.Content
+			}
+			for _, m := range merges {
+				m = resolveAlias(m)
+				if m.Kind != yaml.MappingNode {
+					return fmt.Errorf("invalid YAML merge at line %d", m.Line)
+				}
+				merged := &object{values: make(map[string]json.RawMessage)}
+				if err := merged.addMapping(m, depth+1); err != nil {
+					return err
+				}
+				for _, mk := range merged.keys {
+					o.set(mk, merged.values[mk], false)
+				}
+			}
+			continue
+		}
+
+		data, err := toJSON(v, depth+1)
+		if err != nil {
+			return err
+		}
+		o.set(k.Value, data, true)
+	}
+	return nil
+}
+
+func (o *object) marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(o.values[k])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for i := 0; n.Kind == yaml.AliasNode && n.Alias != nil && i < maxDepth; i++ {
+		n = n.Alias
+	}
+	return n
+}
+
+func scalarJSON(n *yaml.Node) ([]byte, error) {
+	switch n.ShortTag() {
+	case "!!null":
+		return []byte("null"), nil
+
+	case "!!bool":
+		var b bool
+		if err := n.Decode(&b); err != nil {
+			return nil, err
+		}
+		return json.Marshal(b)
+
+	case "!!int":
+		if decimalInt.MatchString(n.Value) {
+			return []byte(n.Value), nil
+		}
+		var i big.Int
+		s := strings.ReplaceAll(n.Value, "_", "")
+		if _, ok := i.SetString(strings.TrimPrefix(s, "+"), 0); !ok {
+			return nil, fmt.Errorf("invalid YAML integer %q at line %d", n.Value, n.Line)
+		}
+		return []byte(i.String()), nil
+
+	case "!!float":
+		if json.Valid([]byte(n.Value)) {
+			return []byte(n.Value), nil
+		}
+		var f float64
+		if err := n.Decode(&f); err != nil {
+			return nil, err
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("unsupported YAML float %q at line %d", n.Value, n.Line)
+		}
+		return []byte(strconv.FormatFloat(f, 'g', -1, 64)), nil
+
+	case "!!binary":
+		return json.Marshal(strings.Join(strings.Fields(n.Value), ""))
+	}
+	// strings, timestamps and the custom tags.
+	return json.Marshal(n.Value)
+}
+
+// merge merges the patched JSON value into the original YAML node, and returns the node of the value.
+func merge(orig *yaml.Node, data json.RawMessage, depth int) (*yaml.Node, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("YAML nesting depth exceeds %d", maxDepth)
+	}
+	if cur, err := toJSON(orig, depth); err == nil && jsonpatch.Equal(cur, data) {
+		return orig, nil
+	}
+
+	data = bytes.TrimSpace(data)
+	switch {
+	case orig.Kind == yaml.MappingNode && data[0] == '{' && !hasMergeKey(orig):
+		obj, err := decodeObject(data)
+		if err != nil {
+			return nil, err
+		}
+
+		n := *orig
+		n.Content = make([]*yaml.Node, 0, 2*len(obj.keys))
+		seen := make(map[string]bool, len(obj.keys))
+		for i := 0; i+1 < len(orig.Content); i += 2 {
+			k := orig.Content[i]
+			v, ok := obj.values[k.Value]
+			if !ok {
+				continue
+			}
+			nv, err := merge(orig.Content[i+1], v, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, k, nv)
+			seen[k.Value] = true
+		}
+		for _, k := range obj.keys {
+			if seen[k] {
+				continue
+			}
+			nv, err := fromJSON(obj.values[k], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, stringNode(k), nv)
+		}
+		return &n, nil
+
+	case orig.Kind == yaml.SequenceNode && data[0] == '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+
+		n := *orig
+		n.Content = make([]*yaml.Node, len(items))
+		for i, v := range items {
+			var err error
+			if i < len(orig.Content) {
+				n.Content[i], err = merge(orig.Content[i], v, depth+1)
+			} else {
+				n.Content[i], err = fromJSON(v, depth+1)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &n, nil
+	}
+
+	n, err := fromJSON(data, depth)
+	if err != nil {
+		return nil, err
+	}
+	n.HeadComment, n.LineComment, n.FootComment = orig.HeadComment, orig.LineComment, orig.FootComment
+	return n, nil
+}
+
+func hasMergeKey(n *yaml.Node) bool {
+	for i := 0; i < len(n.Content); i += 2 {
+		if n.Content[i].ShortTag() == "!!merge" {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeObject(data []byte) (*object, error) {
+	de := json.NewDecoder(bytes.NewReader(data))
+	if _, err := de.Token(); err != nil {
+		return nil, err
+	}
+
+	obj := &object{values: make(map[string]json.RawMessage)}
+	for de.More() {
+		t, err := de.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := t.(string)
+		var v json.RawMessage
+		if err = de.Decode(&v); err != nil {
+			return nil, err
+		}
+		obj.set(key, v, true)
+	}
+	return obj, nil
+}
+
+// fromJSON converts the JSON value into a new YAML node.
+func fromJSON(data json.RawMessage, depth int) (*yaml.Node, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("JSON nesting depth exceeds %d", maxDepth)
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("unexpected empty JSON value")
+	}
+
+	switch data[0] {
+	case '{':
+		obj, err := decodeObject(data)
+		if err != nil {
+			return nil, err
+		}
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, k := range obj.keys {
+			v, err := fromJSON(obj.values[k], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, stringNode(k), v)
+		}
+		return n, nil
+
+	case '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+		n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range items {
+			v, err := fromJSON(item, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, v)
+		}
+		return n, nil
+
+	case '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+		return stringNode(s), nil
+
+	case 't', 'f':
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: string(data)}, nil
+
+	case 'n':
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("invalid JSON value %q", data)
+	}
+	tag := "!!int"
+	if bytes.ContainsAny(data, ".eE") {
+		tag = "!!float"
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: string(data)}, nil
+}
+
+func stringNode(s string) *yaml.Node {
+	n := &yaml.Node{}
+	n.SetString(s)
+	// YAML 1.1 parsers, such as of Kubernetes, resolve these strings as booleans.
+	if yaml11Bools[s] && n.Style == 0 {
+		n.Style = yaml.DoubleQuotedStyle
+	}
+	return n
+}
+
+var yaml11Bools = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"n": true, "N": true, "no": true, "No": true, "NO": true,
+	"on": true, "On": true, "ON": true, "off": true, "Off": true, "OFF": true,
+}
+
+// expandAliases replaces the aliases of the anchors that are no longer before them in the document,
+// because the anchored values have been removed or changed, with the copies of the aliased values.
+func expandAliases(n *yaml.Node, anchors map[*yaml.Node]bool, depth int) *yaml.Node {
+	if depth > maxDepth {
+		return n
+	}
+	if n.Kind == yaml.AliasNode && n.Alias != nil {
+		if anchors[n.Alias] {
+			return n
+		}
+		c := copyNode(n.Alias, 0)
+		c.Anchor = ""
+		c.HeadComment, c.LineComment, c.FootComment = n.HeadComment, n.LineComment, n.FootComment
+		return expandAliases(c, anchors, depth+1)
+	}
+
+	if n.Anchor != "" {
+		anchors[n] = true
+	}
+	for i, c := range n.Content {
+		n.Content[i] = expandAliases(c, anchors, depth+1)
+	}
+	return n
+}
+
+func copyNode(n *yaml.Node, depth int) *yaml.Node {
+	c := *n
+	if len(n.Content) > 0 && depth < maxDepth {
+		c.Content = make([]*yaml.Node, len(n.Content))
+		for i, v := range n.Content {
+			c.Content[i] = copyNode(v, depth+1)
+		}
+	}
+	return &c
+}
+
+// detectIndent returns the indentation of the first indented line of the YAML document, or 2.
+func detectIndent(doc []byte) int {
+	for _, line := range strings.Split(string(doc), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		if indent := len(line) - len(trimmed); indent > 0 {
+			if indent > 8 {
+				break
+			}
+			return indent
+		}
+	}
+	return 2
+}
@@ -0,0 +1,151 @@
+// (c) 2022-2022, LDC Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package yamlpatch
+
+import (
+	"testing"
+
+	jsonpatch "github.com/ldclabs/json-patch"
+	"github.com/stretchr/testify/assert"
+)
+
+const deployment = `# the web deployment
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web # the name
+  labels: &labels
+    app: web
+spec:
+  replicas: 2
+  selector:
+    matchLabels: *labels
+  template:
+    spec:
+      containers:
+        - name: web
+          image: "nginx:1.23"
+          ports:
+            - containerPort: 80
+`
+
+func TestApplyToYAML(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := jsonpatch.NewPatch([]byte(`[
+		{"op": "test", "path": "/spec/selector/matchLabels/app", "value": "web"},
+		{"op": "replace", "path": "/spec/replicas", "value": 3},
+		{"op": "replace", "path": "/metadata/name", "value": "web-v2"},
+		{"op": "add", "path": "/spec/template/spec/containers/0/env", "value": [{"name": "MODE", "value": "on"}]},
+		{"op": "remove", "path": "/spec/template/spec/containers/0/ports"}
+	]`))
+	assert.Nil(err)
+
+	res, err := ApplyToYAML([]byte(deployment), p)
+	assert.Nil(err)
+	assert.Equal(`# the web deployment
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web-v2 # the name
+  labels: &labels
+    app: web
+spec:
+  replicas: 3
+  selector:
+    matchLabels: *labels
+  template:
+    spec:
+      containers:
+        - name: web
+          image: "nginx:1.23"
+          env:
+            - name: MODE
+              value: "on"
+`, string(res))
+
+	res, err = ApplyToYAML([]byte(deployment), jsonpatch.Patch{
+		jsonpatch.NewReplaceOperation("/spec/selector/matchLabels/app", []byte(`"api"`)),
+	})
+	assert.Nil(err)
+	assert.Contains(string(res), "  labels: &labels\n    app: web\n")
+	assert.Contains(string(res), "  selector:\n    matchLabels:\n      app: api\n")
+
+	_, err = ApplyToYAML([]byte(deployment), jsonpatch.Patch{jsonpatch.NewRemoveOperation("/status")})
+	assert.ErrorIs(err, jsonpatch.ErrMissing)
+	_, err = ApplyToYAML([]byte("a: 1\n---\nb: 2\n"), nil)
+	assert.NotNil(err)
+	_, err = ApplyToYAML([]byte("a: [1"), nil)
+	assert.NotNil(err)
+}
+
+func TestMergePatchYAML(t *testing.T) {
+	assert := assert.New(t)
+
+	res, err := MergePatchYAML([]byte(deployment), []byte(`
+metadata:
+  labels: null
+  annotations:
+    team: infra
+spec:
+  replicas: 1
+`))
+	assert.Nil(err)
+	assert.Equal(`# the web deployment
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web # the name
+  annotations:
+    team: infra
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: web
+  template:
+    spec:
+      containers:
+        - name: web
+          image: "nginx:1.23"
+          ports:
+            - containerPort: 80
+`, string(res))
+
+	res, err = MergePatchYAML([]byte("a:\n    b: 1\n"), []byte(`{"a": {"c": [true, "yes", 1.50]}}`))
+	assert.Nil(err)
+	assert.Equal("a:\n    b: 1\n    c:\n        - true\n        - \"yes\"\n        - 1.50\n", string(res))
+}
+
+func TestToJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	for i, c := range []struct {
+		yaml, json string
+	}{
+		{``, `null`},
+		{`b: 1
+a: [0x1F, 0o17, 1_000, +5, 12345678901234567890123, 1.50, .5, 1e3, -.inf]`, ``},
+		{`b: 1
+a: [0x1F, 0o17, 1_000, +5, 12345678901234567890123, 1.50, .5, 1e3]`,
+			`{"b":1,"a":[31,15,1000,5,12345678901234567890123,1.50,0.5,1e3]}`},
+		{`base: &base {x: 1, y: 2}
+point:
+  <<: *base
+  y: 3
+  z: ~
+date: 2022-10-01
+"on": yes
+bin: !!binary aGVsbG8=`,
+			`{"base":{"x":1,"y":2},"point":{"x":1,"y":3,"z":null},"date":"2022-10-01","on":"yes","bin":"aGVsbG8="}`},
+	} {
+		res, err := ToJSON([]byte(c.yaml))
+		if c.json == "" {
+			assert.NotNil(err, "case %d", i)
+			continue
+		}
+		assert.Nil(err, "case %d", i)
+		assert.Equal(c.json, string(res), "case %d", i)
+	}
+}